@@ -2,17 +2,27 @@ package database
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"log"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// MaxOpenConns คือจำนวน connection สูงสุดที่เปิดไปยัง SQLite
+// มากกว่าหนึ่งเพื่อให้ request ที่อ่านอย่างเดียวยังทำงานพร้อมกันได้ใน WAL mode
+const MaxOpenConns = 8
+
 type DB struct {
 	*sql.DB
 }
 
 func NewConnection(dataSourceName string) (*DB, error) {
-	db, err := sql.Open("sqlite3", dataSourceName)
+	db, err := sql.Open("sqlite3", connectionDSN(dataSourceName))
 	if err != nil {
 		return nil, err
 	}
@@ -20,11 +30,42 @@ func NewConnection(dataSourceName string) (*DB, error) {
 	if err := db.Ping(); err != nil {
 		return nil, err
 	}
+	var foreignKeys bool
+	if err := db.QueryRow("PRAGMA foreign_keys").Scan(&foreignKeys); err != nil {
+		return nil, err
+	}
+	if !foreignKeys {
+		return nil, errors.New("foreign key enforcement could not be enabled")
+	}
+
+	// SQLite เขียนได้ทีละ transaction ถ้าเปิด connection ไม่จำกัด request ที่เขียนพร้อมกันจำนวนมาก
+	// จะไปแย่ง lock กันใน busy handler (ไม่เรียงคิว) จนบางรายการรอเกิน busy_timeout
+	// จำกัด connection ไว้ให้ request ที่เหลือต่อคิวใน pool ของ database/sql แทน
+	db.SetMaxOpenConns(MaxOpenConns)
+	db.SetMaxIdleConns(MaxOpenConns)
 
 	return &DB{db}, nil
 }
 
-func (db *DB) Migrate() error {
+// connectionDSN เติม option ที่ระบบต้องพึ่งลงใน DSN ไม่ว่าผู้เรียกจะส่งอะไรมา
+//   - _foreign_keys=on: PRAGMA foreign_keys มีผลเฉพาะ connection ที่รัน จึงเปิดผ่าน DSN ให้ทุก connection ที่ pool เปิด
+//   - _txlock=immediate: hash chain ของ point_ledger อ่าน hash ล่าสุดแล้ว INSERT ใน transaction เดียวกัน
+//     ถ้าเป็น deferred สอง transaction จะอ่าน hash เดียวกันแล้วต่อ chain ซ้อนกันได้ จึงแทนค่า _txlock ที่ส่งมาเสมอ
+func connectionDSN(dataSourceName string) string {
+	path, query, _ := strings.Cut(dataSourceName, "?")
+	params := []string{}
+	for _, param := range strings.Split(query, "&") {
+		if param == "" || strings.HasPrefix(param, "_txlock=") {
+			continue
+		}
+		params = append(params, param)
+	}
+	params = append(params, "_foreign_keys=on", "_txlock=immediate")
+	return path + "?" + strings.Join(params, "&")
+}
+
+func (db *DB) Migrate() (err error) {
+	// column ที่เป็นจำนวนแต้ม (points, amount, fee, change, ...) เก็บเป็น INTEGER หน่วย 1/100 แต้ม (models.Points)
 	createUsersTable := `
 	CREATE TABLE IF NOT EXISTS users (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
@@ -45,7 +86,7 @@ func (db *DB) Migrate() error {
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		from_user_id INTEGER NOT NULL,
 		to_user_id INTEGER NOT NULL,
-		amount REAL NOT NULL CHECK (amount > 0 AND amount <= 2.0 AND ROUND(amount, 2) = amount),
+		amount INTEGER NOT NULL CHECK (amount > 0),
 		status TEXT NOT NULL CHECK (status IN ('pending','processing','completed','failed','cancelled','reversed')),
 		note TEXT,
 		idempotency_key TEXT NOT NULL UNIQUE,
@@ -53,6 +94,17 @@ func (db *DB) Migrate() error {
 		updated_at DATETIME NOT NULL,
 		completed_at DATETIME,
 		fail_reason TEXT,
+		request_hash TEXT,
+		reversed_at DATETIME,
+		reversal_reason TEXT,
+		expires_at DATETIME,
+		scheduled_at DATETIME,
+		mandate_id INTEGER REFERENCES transfer_mandates(id),
+		batch_id TEXT,
+		fail_code TEXT,
+		fail_rule TEXT,
+		fee INTEGER NOT NULL DEFAULT 0,
+		client_key TEXT,
 		FOREIGN KEY (from_user_id) REFERENCES users(id),
 		FOREIGN KEY (to_user_id) REFERENCES users(id)
 	);`
@@ -61,29 +113,303 @@ func (db *DB) Migrate() error {
 	CREATE TABLE IF NOT EXISTS point_ledger (
 		id INTEGER PRIMARY KEY AUTOINCREMENT,
 		user_id INTEGER NOT NULL,
-		change REAL NOT NULL,
-		balance_after REAL NOT NULL,
+		change INTEGER NOT NULL,
+		balance_after INTEGER NOT NULL,
 		event_type TEXT NOT NULL CHECK (event_type IN ('transfer_out','transfer_in','adjust','earn','redeem')),
 		transfer_id INTEGER,
 		reference TEXT,
 		metadata TEXT,
 		created_at DATETIME NOT NULL,
+		prev_hash TEXT NOT NULL DEFAULT '',
+		hash TEXT NOT NULL DEFAULT '',
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (transfer_id) REFERENCES transfers(id)
+	);`
+
+	createPointHoldsTable := `
+	CREATE TABLE IF NOT EXISTS point_holds (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		status TEXT NOT NULL CHECK (status IN ('active','captured','released','expired')),
+		transfer_id INTEGER,
+		reference TEXT,
+		expires_at DATETIME NOT NULL,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		merchant_id TEXT,
+		captured_amount INTEGER CHECK (captured_amount IS NULL OR (captured_amount > 0 AND captured_amount <= amount)),
 		FOREIGN KEY (user_id) REFERENCES users(id),
 		FOREIGN KEY (transfer_id) REFERENCES transfers(id)
 	);`
 
+	createTransferMandatesTable := `
+	CREATE TABLE IF NOT EXISTS transfer_mandates (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		from_user_id INTEGER NOT NULL,
+		to_user_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		note TEXT,
+		cron_expr TEXT,
+		interval_seconds INTEGER CHECK (interval_seconds IS NULL OR interval_seconds > 0),
+		start_at DATETIME NOT NULL,
+		end_at DATETIME,
+		max_occurrences INTEGER,
+		occurrences INTEGER NOT NULL DEFAULT 0,
+		consecutive_failures INTEGER NOT NULL DEFAULT 0,
+		status TEXT NOT NULL CHECK (status IN ('active','paused','cancelled','completed')),
+		next_run_at DATETIME,
+		last_run_at DATETIME,
+		last_error TEXT,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		CHECK ((cron_expr IS NULL) != (interval_seconds IS NULL)),
+		FOREIGN KEY (from_user_id) REFERENCES users(id),
+		FOREIGN KEY (to_user_id) REFERENCES users(id)
+	);`
+
+	createPointRequestsTable := `
+	CREATE TABLE IF NOT EXISTS point_requests (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		requester_id INTEGER NOT NULL,
+		payer_id INTEGER NOT NULL,
+		amount INTEGER NOT NULL CHECK (amount > 0),
+		note TEXT,
+		status TEXT NOT NULL CHECK (status IN ('pending','approved','declined','expired')),
+		transfer_id INTEGER,
+		decline_reason TEXT,
+		expires_at DATETIME NOT NULL,
+		responded_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY (requester_id) REFERENCES users(id),
+		FOREIGN KEY (payer_id) REFERENCES users(id),
+		FOREIGN KEY (transfer_id) REFERENCES transfers(id)
+	);`
+
+	// เพดานการโอนต่อ membership level (แก้ได้ผ่าน admin API)
+	createTransferLimitsTable := `
+	CREATE TABLE IF NOT EXISTS transfer_limits (
+		membership_level TEXT PRIMARY KEY CHECK (membership_level IN ('Gold', 'Silver', 'Bronze')),
+		per_transaction INTEGER NOT NULL CHECK (per_transaction > 0),
+		daily_amount INTEGER NOT NULL CHECK (daily_amount > 0),
+		daily_count INTEGER NOT NULL CHECK (daily_count > 0),
+		monthly_amount INTEGER NOT NULL CHECK (monthly_amount > 0),
+		monthly_count INTEGER NOT NULL CHECK (monthly_count > 0),
+		updated_at DATETIME NOT NULL
+	);`
+
+	// ค่าธรรมเนียมการโอนต่อ membership level (แก้ได้ผ่าน admin API)
+	createTransferFeesTable := `
+	CREATE TABLE IF NOT EXISTS transfer_fees (
+		membership_level TEXT PRIMARY KEY CHECK (membership_level IN ('Gold', 'Silver', 'Bronze')),
+		flat_fee INTEGER NOT NULL CHECK (flat_fee >= 0),
+		percent_fee REAL NOT NULL CHECK (percent_fee >= 0 AND percent_fee <= 100),
+		updated_at DATETIME NOT NULL
+	);`
+
+	// กฎการโอนที่ตั้งค่าได้ ประเมินตาม position (ดู services.RuleService)
+	createTransferRulesTable := `
+	CREATE TABLE IF NOT EXISTS transfer_rules (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		name TEXT NOT NULL UNIQUE,
+		position INTEGER NOT NULL,
+		description TEXT,
+		expression TEXT NOT NULL,
+		error_code TEXT NOT NULL,
+		message TEXT NOT NULL,
+		enabled INTEGER NOT NULL DEFAULT 1,
+		mode TEXT NOT NULL CHECK (mode IN ('enforce','shadow')),
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+	// ปลายทาง webhook และ log การส่ง event (ดู services.WebhookService)
+	createWebhookSubscriptionsTable := `
+	CREATE TABLE IF NOT EXISTS webhook_subscriptions (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		event_types TEXT NOT NULL,
+		description TEXT,
+		is_active INTEGER NOT NULL DEFAULT 1,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL
+	);`
+
+	createWebhookDeliveriesTable := `
+	CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		subscription_id INTEGER NOT NULL,
+		event_id TEXT NOT NULL,
+		event_type TEXT NOT NULL,
+		payload TEXT NOT NULL,
+		status TEXT NOT NULL CHECK (status IN ('pending','succeeded','failed')),
+		attempts INTEGER NOT NULL DEFAULT 0,
+		next_attempt_at DATETIME,
+		last_status_code INTEGER,
+		last_error TEXT,
+		redelivery_of INTEGER REFERENCES webhook_deliveries(id),
+		delivered_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY (subscription_id) REFERENCES webhook_subscriptions(id)
+	);`
+
+	// เกณฑ์การตรวจ fraud (แถวเดียว) และคิวของ transfer ที่ถูกพักไว้รอตรวจ (ดู services.FraudService)
+	createFraudSettingsTable := `
+	CREATE TABLE IF NOT EXISTS fraud_settings (
+		id INTEGER PRIMARY KEY CHECK (id = 1),
+		enabled INTEGER NOT NULL DEFAULT 1,
+		window_seconds INTEGER NOT NULL CHECK (window_seconds > 0),
+		sender_velocity INTEGER NOT NULL CHECK (sender_velocity > 0),
+		receiver_velocity INTEGER NOT NULL CHECK (receiver_velocity > 0),
+		funnel_senders INTEGER NOT NULL CHECK (funnel_senders > 1),
+		ring_max_hops INTEGER NOT NULL CHECK (ring_max_hops >= 3),
+		new_account_days INTEGER NOT NULL CHECK (new_account_days >= 0),
+		new_account_transfers INTEGER NOT NULL CHECK (new_account_transfers > 0),
+		review_score INTEGER NOT NULL CHECK (review_score > 0),
+		block_score INTEGER NOT NULL CHECK (block_score >= review_score),
+		updated_at DATETIME NOT NULL
+	);`
+
+	createFraudReviewsTable := `
+	CREATE TABLE IF NOT EXISTS fraud_reviews (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		transfer_id INTEGER NOT NULL UNIQUE,
+		score INTEGER NOT NULL,
+		signals TEXT NOT NULL,
+		status TEXT NOT NULL CHECK (status IN ('pending','approved','rejected','closed')),
+		decision_note TEXT,
+		decided_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY (transfer_id) REFERENCES transfers(id)
+	);`
+
+	// dispute ของ transfer ที่สำเร็จแล้ว พร้อม audit trail ของทุก action (เพิ่มอย่างเดียว)
+	createTransferDisputesTable := `
+	CREATE TABLE IF NOT EXISTS transfer_disputes (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		transfer_id INTEGER NOT NULL,
+		opened_by INTEGER NOT NULL,
+		counterparty_id INTEGER NOT NULL,
+		reason TEXT NOT NULL,
+		status TEXT NOT NULL CHECK (status IN ('open','contested','accepted','withdrawn','resolved')),
+		resolution TEXT CHECK (resolution IN ('reversed','rejected')),
+		response_note TEXT,
+		resolution_note TEXT,
+		resolved_at DATETIME,
+		created_at DATETIME NOT NULL,
+		updated_at DATETIME NOT NULL,
+		FOREIGN KEY (transfer_id) REFERENCES transfers(id),
+		FOREIGN KEY (opened_by) REFERENCES users(id),
+		FOREIGN KEY (counterparty_id) REFERENCES users(id)
+	);`
+
+	createTransferDisputeEventsTable := `
+	CREATE TABLE IF NOT EXISTS transfer_dispute_events (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		dispute_id INTEGER NOT NULL,
+		action TEXT NOT NULL,
+		actor TEXT NOT NULL CHECK (actor IN ('opener','counterparty','admin','support')),
+		actor_user_id INTEGER,
+		from_status TEXT,
+		to_status TEXT NOT NULL,
+		note TEXT,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY (dispute_id) REFERENCES transfer_disputes(id)
+	);`
+
+	// ผลของการตรวจ users.points เทียบกับ point_ledger และ transfers (ดู services.ReconciliationService)
+	// discrepancies เก็บเป็น JSON array ของ models.ReconciliationDiscrepancy
+	createReconciliationReportsTable := `
+	CREATE TABLE IF NOT EXISTS reconciliation_reports (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		triggered_by TEXT NOT NULL CHECK (triggered_by IN ('scheduled','manual','cli')),
+		repair INTEGER NOT NULL DEFAULT 0,
+		users_checked INTEGER NOT NULL,
+		entries_checked INTEGER NOT NULL,
+		transfers_checked INTEGER NOT NULL,
+		discrepancy_count INTEGER NOT NULL,
+		repaired_count INTEGER NOT NULL,
+		discrepancies TEXT NOT NULL,
+		started_at DATETIME NOT NULL,
+		finished_at DATETIME NOT NULL
+	);`
+
+	// hash ของ entry ล่าสุดที่ถูก sign ด้วย Ed25519 (ดู services.LedgerAuditService)
+	createLedgerCheckpointsTable := `
+	CREATE TABLE IF NOT EXISTS ledger_checkpoints (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		ledger_entry_id INTEGER NOT NULL,
+		entry_count INTEGER NOT NULL,
+		hash TEXT NOT NULL,
+		key_id TEXT NOT NULL,
+		public_key TEXT NOT NULL,
+		signature TEXT NOT NULL,
+		created_at DATETIME NOT NULL
+	);`
+
+	// ยอดปิดรายวันของ user ที่มี entry ในวันนั้น (ดู services.BalanceHistoryService)
+	// เป็นสำเนาของ balance_after ของ entry สุดท้ายของวัน ใช้เป็นจุดเริ่มแทนการไล่ ledger ทั้งหมด
+	createBalanceSnapshotsTable := `
+	CREATE TABLE IF NOT EXISTS balance_snapshots (
+		id INTEGER PRIMARY KEY AUTOINCREMENT,
+		user_id INTEGER NOT NULL,
+		day TEXT NOT NULL,
+		ledger_entry_id INTEGER NOT NULL,
+		balance INTEGER NOT NULL,
+		created_at DATETIME NOT NULL,
+		FOREIGN KEY (user_id) REFERENCES users(id),
+		FOREIGN KEY (ledger_entry_id) REFERENCES point_ledger(id)
+	);`
+
 	// Create indexes
 	createIndexes := []string{
 		"CREATE INDEX IF NOT EXISTS idx_transfers_from ON transfers(from_user_id);",
 		"CREATE INDEX IF NOT EXISTS idx_transfers_to ON transfers(to_user_id);",
 		"CREATE INDEX IF NOT EXISTS idx_transfers_created ON transfers(created_at);",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_from_created ON transfers(from_user_id, created_at, id);",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_to_created ON transfers(to_user_id, created_at, id);",
 		"CREATE INDEX IF NOT EXISTS idx_ledger_user ON point_ledger(user_id);",
 		"CREATE INDEX IF NOT EXISTS idx_ledger_transfer ON point_ledger(transfer_id);",
 		"CREATE INDEX IF NOT EXISTS idx_ledger_created ON point_ledger(created_at);",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_status ON transfers(status, expires_at);",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_scheduled ON transfers(status, scheduled_at);",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_mandate ON transfers(mandate_id);",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_batch ON transfers(batch_id);",
+		"CREATE INDEX IF NOT EXISTS idx_transfers_fail_code ON transfers(fail_code);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_transfers_client_key ON transfers(from_user_id, client_key) WHERE client_key IS NOT NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_mandates_from ON transfer_mandates(from_user_id);",
+		"CREATE INDEX IF NOT EXISTS idx_mandates_due ON transfer_mandates(status, next_run_at);",
+		"CREATE INDEX IF NOT EXISTS idx_point_requests_payer ON point_requests(payer_id, status);",
+		"CREATE INDEX IF NOT EXISTS idx_point_requests_requester ON point_requests(requester_id, status);",
+		"CREATE INDEX IF NOT EXISTS idx_point_requests_expiry ON point_requests(status, expires_at);",
+		"CREATE INDEX IF NOT EXISTS idx_holds_user_status ON point_holds(user_id, status);",
+		"CREATE INDEX IF NOT EXISTS idx_holds_transfer ON point_holds(transfer_id);",
+		"CREATE INDEX IF NOT EXISTS idx_holds_merchant_expiry ON point_holds(status, expires_at) WHERE transfer_id IS NULL;",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);",
+		"CREATE INDEX IF NOT EXISTS idx_webhook_deliveries_subscription ON webhook_deliveries(subscription_id, id);",
+		"CREATE INDEX IF NOT EXISTS idx_fraud_reviews_status ON fraud_reviews(status, id);",
+		// transfer หนึ่งมี dispute ที่ยังเปิดอยู่ได้ทีละหนึ่ง
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_disputes_active_transfer ON transfer_disputes(transfer_id) WHERE status IN ('open','contested');",
+		"CREATE INDEX IF NOT EXISTS idx_disputes_transfer ON transfer_disputes(transfer_id, id);",
+		"CREATE INDEX IF NOT EXISTS idx_disputes_opened_by ON transfer_disputes(opened_by, id);",
+		"CREATE INDEX IF NOT EXISTS idx_disputes_counterparty ON transfer_disputes(counterparty_id, id);",
+		"CREATE INDEX IF NOT EXISTS idx_disputes_status ON transfer_disputes(status, id);",
+		"CREATE INDEX IF NOT EXISTS idx_dispute_events_dispute ON transfer_dispute_events(dispute_id, id);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_ledger_checkpoints_entry ON ledger_checkpoints(ledger_entry_id);",
+		"CREATE UNIQUE INDEX IF NOT EXISTS idx_balance_snapshots_user_day ON balance_snapshots(user_id, day);",
+		"CREATE INDEX IF NOT EXISTS idx_balance_snapshots_day ON balance_snapshots(day);",
 	}
 
 	// Execute migrations
-	tables := []string{createUsersTable, createTransfersTable, createPointLedgerTable}
+	tables := []string{createUsersTable, createTransfersTable, createPointLedgerTable, createPointHoldsTable,
+		createTransferMandatesTable, createPointRequestsTable, createTransferLimitsTable,
+		createTransferRulesTable, createTransferFeesTable, createWebhookSubscriptionsTable, createWebhookDeliveriesTable,
+		createFraudSettingsTable, createFraudReviewsTable, createTransferDisputesTable, createTransferDisputeEventsTable,
+		createReconciliationReportsTable, createLedgerCheckpointsTable, createBalanceSnapshotsTable}
 	for _, table := range tables {
 		if _, err := db.Exec(table); err != nil {
 			log.Printf("Error creating table: %v", err)
@@ -91,6 +417,115 @@ func (db *DB) Migrate() error {
 		}
 	}
 
+	// migration ด้านล่างอาจต้องแก้ข้อมูลเดิม จึงปลด trigger ที่กันการแก้ไขก่อนแล้วติดตั้งกลับตอนจบ
+	// ติดตั้งกลับใน defer เพื่อให้ขั้นที่ล้มเหลวกลางทางไม่ทิ้ง database ไว้โดยไม่มี trigger
+	if err := db.dropIntegrityTriggers(); err != nil {
+		log.Printf("Error dropping integrity triggers: %v", err)
+		return err
+	}
+	defer func() {
+		if createErr := db.createIntegrityTriggers(); createErr != nil {
+			log.Printf("Error creating integrity triggers: %v", createErr)
+			if err == nil {
+				err = createErr
+			}
+		}
+	}()
+
+	// database ที่สร้างก่อนมี client_key เก็บ Idempotency-Key ของ client ไว้ใน idempotency_key
+	hadClientKey, err := db.hasColumn("transfers", "client_key")
+	if err != nil {
+		return err
+	}
+
+	// เพิ่ม column ใหม่ให้ database เดิมที่สร้างไว้ก่อนหน้า
+	columns := []struct{ table, column, definition string }{
+		{"transfers", "request_hash", "TEXT"},
+		{"transfers", "reversed_at", "DATETIME"},
+		{"transfers", "reversal_reason", "TEXT"},
+		{"transfers", "expires_at", "DATETIME"},
+		{"transfers", "scheduled_at", "DATETIME"},
+		{"transfers", "mandate_id", "INTEGER REFERENCES transfer_mandates(id)"},
+		{"transfers", "batch_id", "TEXT"},
+		{"transfers", "fail_code", "TEXT"},
+		{"transfers", "fail_rule", "TEXT"},
+		{"transfers", "fee", "INTEGER NOT NULL DEFAULT 0"},
+		{"transfers", "client_key", "TEXT"},
+		{"point_ledger", "prev_hash", "TEXT NOT NULL DEFAULT ''"},
+		{"point_ledger", "hash", "TEXT NOT NULL DEFAULT ''"},
+		{"point_holds", "merchant_id", "TEXT"},
+		{"point_holds", "captured_amount", "INTEGER CHECK (captured_amount IS NULL OR (captured_amount > 0 AND captured_amount <= amount))"},
+	}
+	for _, col := range columns {
+		if err := db.addColumnIfMissing(col.table, col.column, col.definition); err != nil {
+			log.Printf("Error adding column %s.%s: %v", col.table, col.column, err)
+			return err
+		}
+	}
+	if !hadClientKey {
+		if err := db.backfillTransferClientKeys(); err != nil {
+			log.Printf("Error backfilling transfer client keys: %v", err)
+			return err
+		}
+	}
+
+	// แปลงก่อน rebuild อื่น เพราะตรวจ database เดิมจากชนิดของ column transfers.amount
+	pointTables := []pointTable{
+		{"users", createUsersTable, []string{"points"}},
+		{"transfers", createTransfersTable, []string{"amount", "fee"}},
+		{"point_ledger", createPointLedgerTable, []string{"change", "balance_after"}},
+		{"point_holds", createPointHoldsTable, []string{"amount", "captured_amount"}},
+		{"transfer_mandates", createTransferMandatesTable, []string{"amount"}},
+		{"point_requests", createPointRequestsTable, []string{"amount"}},
+		{"transfer_limits", createTransferLimitsTable, []string{"per_transaction", "daily_amount", "monthly_amount"}},
+		{"transfer_fees", createTransferFeesTable, []string{"flat_fee"}},
+	}
+	if err := db.convertPointsToFixed(pointTables); err != nil {
+		log.Printf("Error converting point columns: %v", err)
+		return err
+	}
+
+	if err := db.rebuildTransfersWithoutAmountCap(createTransfersTable); err != nil {
+		log.Printf("Error rebuilding transfers table: %v", err)
+		return err
+	}
+
+	// ต้องทำหลังแปลงแต้มเป็นหน่วย 1/100 เพราะ change และ balance_after เป็นส่วนหนึ่งของ hash
+	if err := db.backfillLedgerHashes(); err != nil {
+		log.Printf("Error backfilling ledger hashes: %v", err)
+		return err
+	}
+
+	if err := db.normalizeHoldExpiry(); err != nil {
+		log.Printf("Error normalizing hold expiry: %v", err)
+		return err
+	}
+
+	if err := db.normalizeTransferExpiry(); err != nil {
+		log.Printf("Error normalizing transfer expiry: %v", err)
+		return err
+	}
+
+	if err := db.seedTransferLimits(); err != nil {
+		log.Printf("Error seeding transfer limits: %v", err)
+		return err
+	}
+
+	if err := db.seedTransferRules(); err != nil {
+		log.Printf("Error seeding transfer rules: %v", err)
+		return err
+	}
+
+	if err := db.seedTransferFees(); err != nil {
+		log.Printf("Error seeding transfer fees: %v", err)
+		return err
+	}
+
+	if err := db.seedFraudSettings(); err != nil {
+		log.Printf("Error seeding fraud settings: %v", err)
+		return err
+	}
+
 	// Create indexes
 	for _, index := range createIndexes {
 		if _, err := db.Exec(index); err != nil {
@@ -99,6 +534,469 @@ func (db *DB) Migrate() error {
 		}
 	}
 
+	if err := db.logForeignKeyViolations(); err != nil {
+		log.Printf("Error checking foreign keys: %v", err)
+		return err
+	}
+
+	if err := db.EnsureOpeningBalances(); err != nil {
+		log.Printf("Error writing opening balances: %v", err)
+		return err
+	}
+
 	log.Println("Database migration completed successfully")
 	return nil
 }
+
+// addColumnIfMissing เพิ่ม column ให้ table ถ้ายังไม่มี (SQLite ไม่รองรับ ADD COLUMN IF NOT EXISTS)
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	exists, err := db.hasColumn(table, column)
+	if err != nil || exists {
+		return err
+	}
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// hasColumn บอกว่า table มี column นี้แล้วหรือยัง
+func (db *DB) hasColumn(table, column string) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM pragma_table_info(?) WHERE name = ?", table, column).Scan(&count)
+	return count > 0, err
+}
+
+// backfillTransferClientKeys ย้าย Idempotency-Key ของ client (transfer ที่มี request_hash) ไปไว้ใน client_key
+// ทำครั้งเดียวตอนเพิ่ม column เพราะ key ที่ถูกปล่อยแล้วมี client_key เป็น NULL และต้องไม่ถูกเติมกลับ
+func (db *DB) backfillTransferClientKeys() error {
+	result, err := db.Exec("UPDATE transfers SET client_key = idempotency_key WHERE request_hash IS NOT NULL")
+	if err != nil {
+		return err
+	}
+	if count, err := result.RowsAffected(); err == nil && count > 0 {
+		log.Printf("Moved %d client idempotency key(s) to transfers.client_key", count)
+	}
+	return nil
+}
+
+// normalizeHoldExpiry แปลง expires_at ของ hold ที่ยัง active (ทั้งของร้านค้าและของ transfer) เป็น UTC
+// database เดิมเก็บเป็นเวลาท้องถิ่น ซึ่งเทียบแบบ string กับเวลา UTC ที่ sweeper และ capture ใช้ไม่ได้
+func (db *DB) normalizeHoldExpiry() error {
+	n, err := db.normalizeToUTC("point_holds", "status = ?", models.HoldStatusActive)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Converted expiry of %d active hold(s) to UTC", n)
+	}
+	return nil
+}
+
+// normalizeTransferExpiry แปลง expires_at ของ transfer ที่ยัง pending เป็น UTC ด้วยเหตุผลเดียวกับ hold
+func (db *DB) normalizeTransferExpiry() error {
+	n, err := db.normalizeToUTC("transfers", "status = ? AND expires_at IS NOT NULL", models.TransferStatusPending)
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		log.Printf("Converted expiry of %d pending transfer(s) to UTC", n)
+	}
+	return nil
+}
+
+// normalizeToUTC เขียน expires_at ของแถวใน table ที่ตรง condition และยังไม่เป็น UTC ใหม่เป็น UTC คืนจำนวนแถวที่แปลง
+func (db *DB) normalizeToUTC(table, condition string, args ...interface{}) (int, error) {
+	rows, err := db.Query(`SELECT id, expires_at FROM `+table+` WHERE `+condition+` AND expires_at NOT LIKE '%+00:00'`, args...)
+	if err != nil {
+		return 0, err
+	}
+	expiry := make(map[int]time.Time)
+	for rows.Next() {
+		var id int
+		var expiresAt time.Time
+		if err := rows.Scan(&id, &expiresAt); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		expiry[id] = expiresAt
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	for id, expiresAt := range expiry {
+		if _, err := db.Exec(`UPDATE `+table+` SET expires_at = ? WHERE id = ?`, expiresAt.UTC(), id); err != nil {
+			return 0, err
+		}
+	}
+	return len(expiry), nil
+}
+
+// rebuildTransfersWithoutAmountCap ลบ CHECK amount <= 2.0 ออกจาก transfers ที่สร้างก่อนมี limit ต่อ tier
+// SQLite แก้ CHECK ด้วย ALTER TABLE ไม่ได้ จึงต้องสร้าง table ใหม่แล้วย้ายข้อมูล
+func (db *DB) rebuildTransfersWithoutAmountCap(createTransfersTable string) error {
+	var ddl string
+	if err := db.QueryRow("SELECT sql FROM sqlite_master WHERE type = 'table' AND name = 'transfers'").Scan(&ddl); err != nil {
+		return err
+	}
+	if !strings.Contains(ddl, "amount <= 2.0") {
+		return nil
+	}
+
+	err := db.withoutForeignKeys(func(tx *sql.Tx) error {
+		return rebuildTable(tx, "transfers", createTransfersTable, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Println("Rebuilt transfers table without the fixed 2.00 amount cap")
+	return nil
+}
+
+// pointTable คือ table ที่มี column จำนวนแต้ม พร้อม DDL ปัจจุบันของมัน
+type pointTable struct {
+	name    string
+	ddl     string
+	columns []string
+}
+
+// convertPointsToFixed แปลง database ที่สร้างก่อนมี models.Points (column แต้มเป็น REAL หน่วยแต้ม)
+// ให้เก็บเป็น INTEGER หน่วย 1/100 แต้ม โดยสร้าง table ใหม่ด้วย DDL ปัจจุบันและคูณค่าเดิมด้วย 100
+// ทุก table แปลงใน transaction เดียว ถ้ามีค่าที่ละเอียดเกิน 2 ตำแหน่ง (แปลงแล้วเสียค่า) จะไม่แปลงเลย
+func (db *DB) convertPointsToFixed(tables []pointTable) error {
+	var amountType string
+	err := db.QueryRow("SELECT type FROM pragma_table_info('transfers') WHERE name = 'amount'").Scan(&amountType)
+	if err != nil {
+		return err
+	}
+	if amountType != "REAL" {
+		return nil
+	}
+
+	err = db.withoutForeignKeys(func(tx *sql.Tx) error {
+		for _, table := range tables {
+			exprs := make(map[string]string, len(table.columns))
+			for _, column := range table.columns {
+				// float ที่สะสมจากการบวกลบ (เช่น 0.30000000000000004) ยังถือว่าเป็นค่า 2 ตำแหน่ง
+				var inexact int
+				err := tx.QueryRow(fmt.Sprintf("SELECT COUNT(*) FROM %s WHERE ABS(%s * 100 - ROUND(%s * 100)) > 1e-6",
+					table.name, column, column)).Scan(&inexact)
+				if err != nil {
+					return err
+				}
+				if inexact > 0 {
+					return fmt.Errorf("%s.%s has %d value(s) with more than 2 decimal places", table.name, column, inexact)
+				}
+				exprs[column] = fmt.Sprintf("CAST(ROUND(%s * 100) AS INTEGER)", column)
+			}
+			if err := rebuildTable(tx, table.name, table.ddl, exprs); err != nil {
+				return fmt.Errorf("rebuild %s: %w", table.name, err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Println("Converted point columns to fixed-point hundredths")
+	return nil
+}
+
+// rebuildTable สร้าง table ใหม่จาก DDL (แบบ CREATE TABLE IF NOT EXISTS <table>) แล้วย้ายข้อมูลเดิมมา
+// exprs ใช้แปลงค่าของ column ระหว่างย้าย (column ที่ไม่อยู่ใน exprs ย้ายตามเดิม)
+// index ของ table เดิมหายไปด้วย Migrate จะสร้างใหม่ตอนท้าย
+func rebuildTable(tx *sql.Tx, table, ddl string, exprs map[string]string) error {
+	rows, err := tx.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	var columns, values []string
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			colType    string
+			notNull    int
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultVal, &pk); err != nil {
+			rows.Close()
+			return err
+		}
+		columns = append(columns, name)
+		if expr, ok := exprs[name]; ok {
+			values = append(values, expr)
+		} else {
+			values = append(values, name)
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	statements := []string{
+		strings.Replace(ddl, "IF NOT EXISTS "+table, table+"_rebuild", 1),
+		fmt.Sprintf("INSERT INTO %s_rebuild (%s) SELECT %s FROM %s",
+			table, strings.Join(columns, ", "), strings.Join(values, ", "), table),
+		"DROP TABLE " + table,
+		fmt.Sprintf("ALTER TABLE %s_rebuild RENAME TO %s", table, table),
+	}
+	for _, stmt := range statements {
+		if _, err := tx.Exec(stmt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedTransferLimits ใส่ค่าเริ่มต้นของ limit แต่ละ tier ถ้ายังไม่มี (ไม่ทับค่าที่ admin แก้ไว้)
+func (db *DB) seedTransferLimits() error {
+	now := time.Now()
+	defaults := []struct {
+		level          string
+		perTransaction models.Points
+		dailyAmount    models.Points
+		dailyCount     int
+		monthlyAmount  models.Points
+		monthlyCount   int
+	}{
+		{"Gold", 2 * models.PointsScale, 20 * models.PointsScale, 20, 300 * models.PointsScale, 300},
+		{"Silver", 2 * models.PointsScale, 10 * models.PointsScale, 10, 150 * models.PointsScale, 150},
+		{"Bronze", 2 * models.PointsScale, 5 * models.PointsScale, 5, 75 * models.PointsScale, 75},
+	}
+	for _, d := range defaults {
+		_, err := db.Exec(`
+			INSERT OR IGNORE INTO transfer_limits (membership_level, per_transaction, daily_amount, daily_count,
+			                                       monthly_amount, monthly_count, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			d.level, d.perTransaction, d.dailyAmount, d.dailyCount, d.monthlyAmount, d.monthlyCount, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedTransferRules ใส่กฎการโอนเริ่มต้น (เดิมเขียนไว้ใน TransferService) เฉพาะตอนที่ยังไม่มี rule เลย
+// เพื่อไม่ให้ rule ที่ admin ลบไปแล้วกลับมาเองตอน restart
+func (db *DB) seedTransferRules() error {
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transfer_rules").Scan(&count); err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	now := time.Now()
+	defaults := []struct {
+		name, description, expression, errorCode, message string
+		enabled                                           bool
+	}{
+		{"amount_positive", "Amount must be greater than 0",
+			`amount <= 0`, "VALIDATION_ERROR", "amount must be greater than 0", true},
+		{"amount_precision", "Amount can have at most 2 decimal places",
+			`round(amount * 100) != amount * 100`, "VALIDATION_ERROR", "amount can have at most 2 decimal places", true},
+		{"self_transfer", "Cannot transfer to yourself",
+			`from == to`, "INVALID_TRANSFER", "cannot transfer to yourself", true},
+		{"repeat_recipient", "Cannot transfer to the same user as the last completed transfer (mandates and batches are exempt)",
+			`source == "transfer" && to == last_to`, "DUPLICATE_TRANSFER",
+			"cannot transfer to user {to} again - last transfer was also to this user", true},
+		{"cooldown", "Minimum time between two transfers from the same user",
+			`source == "transfer" && seconds_since_last < 10`, "TRANSFER_COOLDOWN",
+			"please wait before sending another transfer", false},
+		{"blocked_pairs", "Sender:receiver pairs that may not transfer to each other",
+			`pair in []`, "BLOCKED_TRANSFER", "transfers between these users are not allowed", false},
+	}
+	for i, d := range defaults {
+		_, err := db.Exec(`
+			INSERT INTO transfer_rules (name, position, description, expression, error_code, message,
+			                            enabled, mode, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, 'enforce', ?, ?)`,
+			d.name, (i+1)*10, d.description, d.expression, d.errorCode, d.message, d.enabled, now, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedTransferFees ใส่ค่าธรรมเนียมเริ่มต้นของแต่ละ tier ถ้ายังไม่มี (Gold ไม่เสียค่าธรรมเนียม)
+func (db *DB) seedTransferFees() error {
+	now := time.Now()
+	defaults := []struct {
+		level      string
+		flatFee    models.Points
+		percentFee float64
+	}{
+		{"Gold", 0, 0},
+		{"Silver", 0, 0.5},
+		{"Bronze", 1, 1}, // flat 0.01 แต้ม
+	}
+	for _, d := range defaults {
+		_, err := db.Exec(`
+			INSERT OR IGNORE INTO transfer_fees (membership_level, flat_fee, percent_fee, updated_at)
+			VALUES (?, ?, ?, ?)`,
+			d.level, d.flatFee, d.percentFee, now)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// seedFraudSettings ใส่เกณฑ์การตรวจ fraud เริ่มต้นถ้ายังไม่มี
+// วง 3 คนอย่างเดียว (60) ถูกพักรอตรวจ ส่วนวงที่มาพร้อมการโอนถี่ (60 + 30) ถูกปฏิเสธทันที
+func (db *DB) seedFraudSettings() error {
+	_, err := db.Exec(`
+		INSERT OR IGNORE INTO fraud_settings (id, enabled, window_seconds, sender_velocity, receiver_velocity,
+		                                      funnel_senders, ring_max_hops, new_account_days, new_account_transfers,
+		                                      review_score, block_score, updated_at)
+		VALUES (1, 1, 3600, 10, 20, 5, 4, 7, 5, 50, 80, ?)`,
+		time.Now())
+	return err
+}
+
+// backfillLedgerHashes สร้าง hash chain ให้ database เดิมที่เขียน ledger ก่อนมี hash ตามลำดับ id
+// ทำเฉพาะเมื่อยังไม่มี entry ไหนมี hash ถ้า chain เริ่มแล้ว entry ที่ไม่มี hash ถูกเขียนนอกระบบ
+// จึงไม่ต่อ chain ให้ (ไม่อย่างนั้นจะกลบการแก้ไข) และปล่อยให้การ verify รายงาน
+func (db *DB) backfillLedgerHashes() error {
+	var missing int
+	if err := db.QueryRow("SELECT COUNT(*) FROM point_ledger WHERE hash = ''").Scan(&missing); err != nil {
+		return err
+	}
+	if missing == 0 {
+		return nil
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var hashed int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM point_ledger WHERE hash <> ''").Scan(&hashed); err != nil {
+		return err
+	}
+	if hashed > 0 {
+		log.Printf("Warning: %d ledger entries have no hash but the chain has already started; leaving them for verification", missing)
+		return nil
+	}
+
+	rows, err := tx.Query(`
+		SELECT id, user_id, change, balance_after, event_type, transfer_id, reference, metadata, created_at
+		FROM point_ledger
+		ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	var entries []models.PointLedger
+	for rows.Next() {
+		var e models.PointLedger
+		if err := rows.Scan(&e.ID, &e.UserID, &e.Change, &e.BalanceAfter, &e.EventType,
+			&e.TransferID, &e.Reference, &e.Metadata, &e.CreatedAt); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	prevHash := models.LedgerGenesisHash
+	for i := range entries {
+		e := &entries[i]
+		e.PrevHash = prevHash
+		e.Hash = e.ComputeHash()
+		if _, err := tx.Exec("UPDATE point_ledger SET prev_hash = ?, hash = ? WHERE id = ?", e.PrevHash, e.Hash, e.ID); err != nil {
+			return err
+		}
+		prevHash = e.Hash
+	}
+
+	log.Printf("Backfilled hash chain for %d ledger entries", len(entries))
+	return tx.Commit()
+}
+
+// EnsureOpeningBalances เขียน adjust entry (reference opening_balance) ให้ user ที่มีแต้มแต่ยังไม่มี ledger entry
+// เช่น user ที่ seed ไว้หรือมีแต้มก่อนเริ่มเขียน ledger เพื่อให้ reconciliation ไม่รายงานว่ายอดไม่มีที่มา
+// entry ต่อ hash chain ตามปกติ และลงเวลาที่เขียนจริง (ไม่ย้อนเวลา)
+func (db *DB) EnsureOpeningBalances() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.Query(`
+		SELECT id, points FROM users u
+		WHERE points != 0 AND NOT EXISTS (SELECT 1 FROM point_ledger WHERE user_id = u.id)
+		ORDER BY id`)
+	if err != nil {
+		return err
+	}
+	var entries []models.PointLedger
+	for rows.Next() {
+		var e models.PointLedger
+		if err := rows.Scan(&e.UserID, &e.Change); err != nil {
+			rows.Close()
+			return err
+		}
+		entries = append(entries, e)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		return nil
+	}
+
+	prevHash := models.LedgerGenesisHash
+	err = tx.QueryRow("SELECT hash FROM point_ledger ORDER BY id DESC LIMIT 1").Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return err
+	}
+
+	now := time.Now()
+	reference := models.OpeningBalanceReference
+	for i := range entries {
+		e := &entries[i]
+		e.BalanceAfter = e.Change
+		e.EventType = models.EventTypeAdjust
+		e.Reference = &reference
+		e.CreatedAt = now
+		e.PrevHash = prevHash
+		e.Hash = e.ComputeHash()
+		if _, err := tx.Exec(`
+			INSERT INTO point_ledger (user_id, change, balance_after, event_type, reference, created_at, prev_hash, hash)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			e.UserID, e.Change, e.BalanceAfter, e.EventType, e.Reference, e.CreatedAt, e.PrevHash, e.Hash); err != nil {
+			return err
+		}
+		prevHash = e.Hash
+	}
+
+	log.Printf("Wrote opening balance ledger entries for %d user(s)", len(entries))
+	return tx.Commit()
+}
+
+// EnsureHouseAccount สร้างบัญชีระบบที่รับค่าธรรมเนียมการโอน (member_id = models.HouseMemberID) ถ้ายังไม่มี
+// เรียกหลัง seed ข้อมูลตัวอย่าง เพื่อไม่ให้บัญชีระบบได้ id แรกไป
+func (db *DB) EnsureHouseAccount() error {
+	now := time.Now()
+	_, err := db.Exec(`
+		INSERT OR IGNORE INTO users (member_id, first_name, last_name, phone, email,
+		                             membership_date, membership_level, points, created_at, updated_at)
+		VALUES (?, 'House', 'Account', '-', 'house@system.local', ?, 'Gold', 0, ?, ?)`,
+		models.HouseMemberID, now, now, now)
+	return err
+}
@@ -0,0 +1,183 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+// newTestDB เปิดฐานข้อมูลชั่วคราวที่ migrate แล้ว ด้วย DSN แบบเดียวกับ main
+func newTestDB(t *testing.T) *DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL&_synchronous=NORMAL"
+	db, err := NewConnection(dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	return db
+}
+
+// insertTestUser เพิ่ม user ที่มีแต้ม points โดยตรง (เหมือนข้อมูลที่ seed ไว้ก่อนมี ledger) คืน id
+func insertTestUser(t *testing.T, db *DB, memberID string, points models.Points) int {
+	t.Helper()
+	now := time.Now()
+	result, err := db.Exec(`
+		INSERT INTO users (member_id, first_name, last_name, phone, email,
+		                   membership_date, membership_level, points, created_at, updated_at)
+		VALUES (?, 'Tst', 'Usr', ?, ?, ?, 'Gold', ?, ?, ?)`,
+		memberID, memberID, memberID+"@example.com", now, points, now, now)
+	if err != nil {
+		t.Fatalf("insert user: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+func TestMigrateConvertsActiveHoldExpiryToUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("ICT", 7*60*60)
+	defer func() { time.Local = local }()
+
+	db := newTestDB(t)
+	userID := insertTestUser(t, db, "LBK000001", 10*models.PointsScale)
+
+	// hold ที่ database เดิมเขียนไว้เป็นเวลาท้องถิ่น
+	expiresAt := time.Now().Add(time.Hour)
+	now := time.Now()
+	for _, status := range []models.HoldStatus{models.HoldStatusActive, models.HoldStatusReleased} {
+		if _, err := db.Exec(`
+			INSERT INTO point_holds (user_id, amount, status, merchant_id, expires_at, created_at, updated_at)
+			VALUES (?, 100, ?, 'shop-1', ?, ?, ?)`, userID, status, expiresAt, now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate again: %v", err)
+	}
+
+	rows, err := db.Query("SELECT status, CAST(expires_at AS TEXT), expires_at FROM point_holds ORDER BY id")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var status, raw string
+		var stored time.Time
+		if err := rows.Scan(&status, &raw, &stored); err != nil {
+			t.Fatal(err)
+		}
+		if !stored.Equal(expiresAt) {
+			t.Fatalf("%s hold expires at %v, want %v", status, stored, expiresAt)
+		}
+		utc := strings.HasSuffix(raw, "+00:00")
+		if status == string(models.HoldStatusActive) && !utc {
+			t.Fatalf("active hold expiry %q was not converted to UTC", raw)
+		}
+		if status != string(models.HoldStatusActive) && utc {
+			t.Fatalf("closed hold expiry %q was rewritten", raw)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMigrateConvertsPendingTransferExpiryToUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("ICT", 7*60*60)
+	defer func() { time.Local = local }()
+
+	db := newTestDB(t)
+	from := insertTestUser(t, db, "LBK000001", 10*models.PointsScale)
+	to := insertTestUser(t, db, "LBK000002", 0)
+
+	// transfer two-phase และ hold ที่ database เดิมเขียนไว้เป็นเวลาท้องถิ่น
+	expiresAt := time.Now().Add(15 * time.Minute)
+	now := time.Now()
+	for i, status := range []models.TransferStatus{models.TransferStatusPending, models.TransferStatusCancelled} {
+		result, err := db.Exec(`
+			INSERT INTO transfers (from_user_id, to_user_id, amount, status, idempotency_key, created_at, updated_at, expires_at)
+			VALUES (?, ?, 100, ?, ?, ?, ?, ?)`, from, to, status, fmt.Sprintf("idem-%d", i), now, now, expiresAt)
+		if err != nil {
+			t.Fatal(err)
+		}
+		transferID, _ := result.LastInsertId()
+		holdStatus := models.HoldStatusActive
+		if status != models.TransferStatusPending {
+			holdStatus = models.HoldStatusReleased
+		}
+		if _, err := db.Exec(`
+			INSERT INTO point_holds (user_id, amount, status, transfer_id, expires_at, created_at, updated_at)
+			VALUES (?, 100, ?, ?, ?, ?, ?)`, from, holdStatus, transferID, expiresAt, now, now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate again: %v", err)
+	}
+
+	for _, table := range []string{"transfers", "point_holds"} {
+		rows, err := db.Query("SELECT status, CAST(expires_at AS TEXT), expires_at FROM " + table + " ORDER BY id")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for rows.Next() {
+			var status, raw string
+			var stored time.Time
+			if err := rows.Scan(&status, &raw, &stored); err != nil {
+				t.Fatal(err)
+			}
+			if !stored.Equal(expiresAt) {
+				t.Fatalf("%s %s row expires at %v, want %v", table, status, stored, expiresAt)
+			}
+			open := status == string(models.TransferStatusPending) || status == string(models.HoldStatusActive)
+			if utc := strings.HasSuffix(raw, "+00:00"); utc != open {
+				t.Fatalf("%s %s row expiry is %q, want UTC only for open rows", table, status, raw)
+			}
+		}
+		rows.Close()
+	}
+}
+
+// TestNewConnectionForcesImmediateTransactions ตรวจว่า transaction ทุกอันจอง write lock ตั้งแต่ BEGIN
+// แม้ DSN จะไม่ระบุหรือระบุ _txlock เป็นค่าอื่น (hash chain ของ ledger พึ่งข้อนี้)
+func TestNewConnectionForcesImmediateTransactions(t *testing.T) {
+	for _, query := range []string{"?_busy_timeout=100", "?_busy_timeout=100&_txlock=deferred", "?_txlock=exclusive&_busy_timeout=100"} {
+		t.Run(query, func(t *testing.T) {
+			db, err := NewConnection(filepath.Join(t.TempDir(), "test.db") + query)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			first, err := db.Begin()
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer first.Rollback()
+
+			second, err := db.Begin()
+			if err == nil {
+				second.Rollback()
+				t.Fatal("a second transaction began while the first holds the write lock")
+			}
+			if !strings.Contains(err.Error(), "database is locked") {
+				t.Fatalf("second BEGIN failed with %q, want database is locked", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,140 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log"
+)
+
+// integrityTriggers กันการแก้ข้อมูลที่ต้องไม่เปลี่ยนหลังเขียนแล้ว แม้จะแก้ตรงที่ database ไม่ผ่าน API
+//   - point_ledger เป็น append-only: UPDATE และ DELETE ถูก abort ทุกกรณี
+//   - transfers ลบไม่ได้ และ column ที่กำหนดตัวการโอน (คู่โอน, จำนวน, ค่าธรรมเนียม, key) แก้ไม่ได้
+//   - completed_at, reversed_at และ reversal_reason เขียนได้ครั้งเดียว
+//   - client_key เปลี่ยนได้อย่างเดียวคือปล่อยเป็น NULL เมื่อพ้นช่วง replay
+//   - status เปลี่ยนได้ตาม state machine ใน services/transfer_state.go เท่านั้น (ต้องแก้ให้ตรงกันทั้งสองที่)
+var integrityTriggers = []struct{ name, body string }{
+	{"point_ledger_no_update", `
+	BEFORE UPDATE ON point_ledger
+	BEGIN
+		SELECT RAISE(ABORT, 'point_ledger is append-only: entries cannot be updated');
+	END`},
+	{"point_ledger_no_delete", `
+	BEFORE DELETE ON point_ledger
+	BEGIN
+		SELECT RAISE(ABORT, 'point_ledger is append-only: entries cannot be deleted');
+	END`},
+	{"transfers_no_delete", `
+	BEFORE DELETE ON transfers
+	BEGIN
+		SELECT RAISE(ABORT, 'transfers cannot be deleted');
+	END`},
+	{"transfers_immutable_columns", `
+	BEFORE UPDATE ON transfers
+	WHEN NEW.id IS NOT OLD.id
+		OR NEW.idempotency_key IS NOT OLD.idempotency_key
+		OR NEW.request_hash IS NOT OLD.request_hash
+		OR NEW.from_user_id IS NOT OLD.from_user_id
+		OR NEW.to_user_id IS NOT OLD.to_user_id
+		OR NEW.amount IS NOT OLD.amount
+		OR NEW.fee IS NOT OLD.fee
+		OR NEW.created_at IS NOT OLD.created_at
+		OR NEW.scheduled_at IS NOT OLD.scheduled_at
+		OR NEW.mandate_id IS NOT OLD.mandate_id
+		OR NEW.batch_id IS NOT OLD.batch_id
+		OR (OLD.completed_at IS NOT NULL AND NEW.completed_at IS NOT OLD.completed_at)
+		OR (OLD.reversed_at IS NOT NULL AND NEW.reversed_at IS NOT OLD.reversed_at)
+		OR (OLD.reversal_reason IS NOT NULL AND NEW.reversal_reason IS NOT OLD.reversal_reason)
+		OR (NEW.client_key IS NOT OLD.client_key AND NEW.client_key IS NOT NULL)
+	BEGIN
+		SELECT RAISE(ABORT, 'transfers: immutable column cannot be changed');
+	END`},
+	{"transfers_status_transition", `
+	BEFORE UPDATE OF status ON transfers
+	WHEN NEW.status IS NOT OLD.status
+		AND NOT ((OLD.status = 'pending' AND NEW.status IN ('processing', 'cancelled'))
+			OR (OLD.status = 'processing' AND NEW.status IN ('completed', 'failed'))
+			OR (OLD.status = 'completed' AND NEW.status = 'reversed'))
+	BEGIN
+		SELECT RAISE(ABORT, 'transfers: illegal status transition');
+	END`},
+}
+
+// dropIntegrityTriggers ลบ trigger ก่อน migration อื่นที่ต้องแก้ข้อมูลเดิม (เช่น backfill hash ของ ledger)
+// createIntegrityTriggers ติดตั้งกลับตอนจบ Migrate ทั้งกรณีสำเร็จและล้มเหลว
+func (db *DB) dropIntegrityTriggers() error {
+	for _, trigger := range integrityTriggers {
+		if _, err := db.Exec("DROP TRIGGER IF EXISTS " + trigger.name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// createIntegrityTriggers สร้าง trigger ใหม่ทุกครั้งเพื่อให้ database เดิมได้เงื่อนไขล่าสุด
+func (db *DB) createIntegrityTriggers() error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, trigger := range integrityTriggers {
+		if _, err := tx.Exec("DROP TRIGGER IF EXISTS " + trigger.name); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("CREATE TRIGGER " + trigger.name + trigger.body); err != nil {
+			return fmt.Errorf("create trigger %s: %w", trigger.name, err)
+		}
+	}
+	return tx.Commit()
+}
+
+// withoutForeignKeys รัน fn ใน transaction บน connection ที่ปิด foreign key ไว้
+// ใช้กับการ rebuild table (DROP table เดิมที่มี table อื่นอ้างถึงไม่ได้ถ้าเปิด foreign key)
+// PRAGMA foreign_keys เปลี่ยนระหว่าง transaction ไม่ได้ และมีผลเฉพาะ connection จึงต้องจอง connection เอง
+func (db *DB) withoutForeignKeys(fn func(tx *sql.Tx) error) error {
+	ctx := context.Background()
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA foreign_keys = OFF"); err != nil {
+		return err
+	}
+	// เปิดกลับก่อนคืน connection ให้ pool
+	defer conn.ExecContext(ctx, "PRAGMA foreign_keys = ON")
+
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// logForeignKeyViolations รายงานแถวเดิมที่อ้างถึงแถวที่ไม่มีอยู่ (เขียนก่อนเปิด foreign key)
+// ไม่แก้ข้อมูลให้ เพราะต้องให้คนตรวจว่าจะเก็บหรือลบ
+func (db *DB) logForeignKeyViolations() error {
+	rows, err := db.Query("SELECT \"table\", COUNT(*) FROM pragma_foreign_key_check GROUP BY \"table\"")
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var table string
+		var count int
+		if err := rows.Scan(&table, &count); err != nil {
+			return err
+		}
+		log.Printf("Warning: %d row(s) in %s reference missing rows (written before foreign keys were enforced)", count, table)
+	}
+	return rows.Err()
+}
@@ -0,0 +1,218 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+// insertTestTransfer เพิ่ม transfer ตรงที่ database ด้วยสถานะ status คืน id
+func insertTestTransfer(t *testing.T, db *DB, fromUserID, toUserID int, status models.TransferStatus) int {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transfers").Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	result, err := db.Exec(`
+		INSERT INTO transfers (from_user_id, to_user_id, amount, status, idempotency_key, created_at, updated_at, client_key)
+		VALUES (?, ?, 100, ?, ?, ?, ?, ?)`,
+		fromUserID, toUserID, status, fmt.Sprintf("idem-%d", n), now, now, fmt.Sprintf("client-%d", n))
+	if err != nil {
+		t.Fatalf("insert transfer: %v", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return int(id)
+}
+
+// expectAbort ตรวจว่า statement ถูก database ปฏิเสธด้วย error ที่มีข้อความ want
+func expectAbort(t *testing.T, db *DB, want, query string, args ...any) {
+	t.Helper()
+	_, err := db.Exec(query, args...)
+	if err == nil {
+		t.Fatalf("%s succeeded, want an error containing %q", strings.TrimSpace(query), want)
+	}
+	if !strings.Contains(err.Error(), want) {
+		t.Fatalf("%s failed with %q, want an error containing %q", strings.TrimSpace(query), err, want)
+	}
+}
+
+func TestPointLedgerIsAppendOnly(t *testing.T) {
+	db := newTestDB(t)
+	userID := insertTestUser(t, db, "LBK000001", 10*models.PointsScale)
+	if err := db.EnsureOpeningBalances(); err != nil {
+		t.Fatal(err)
+	}
+	var entryID int
+	if err := db.QueryRow("SELECT id FROM point_ledger WHERE user_id = ?", userID).Scan(&entryID); err != nil {
+		t.Fatal(err)
+	}
+
+	expectAbort(t, db, "entries cannot be updated", "UPDATE point_ledger SET change = 0 WHERE id = ?", entryID)
+	expectAbort(t, db, "entries cannot be updated", "UPDATE point_ledger SET metadata = '{}' WHERE id = ?", entryID)
+	expectAbort(t, db, "entries cannot be deleted", "DELETE FROM point_ledger WHERE id = ?", entryID)
+
+	var change models.Points
+	if err := db.QueryRow("SELECT change FROM point_ledger WHERE id = ?", entryID).Scan(&change); err != nil {
+		t.Fatalf("entry is gone after a rejected delete: %v", err)
+	}
+	if change != 10*models.PointsScale {
+		t.Fatalf("entry change is %s after a rejected update, want 10.00", change)
+	}
+}
+
+func TestTransferImmutableColumns(t *testing.T) {
+	db := newTestDB(t)
+	from := insertTestUser(t, db, "LBK000001", 0)
+	to := insertTestUser(t, db, "LBK000002", 0)
+	other := insertTestUser(t, db, "LBK000003", 0)
+
+	const immutable = "immutable column cannot be changed"
+	tests := []struct {
+		name, set string
+		args      []any
+	}{
+		{"amount", "amount = 200", nil},
+		{"fee", "fee = 1", nil},
+		{"sender", "from_user_id = ?", []any{other}},
+		{"recipient", "to_user_id = ?", []any{other}},
+		{"idempotency key", "idempotency_key = 'changed'", nil},
+		{"request hash", "request_hash = 'changed'", nil},
+		{"created at", "created_at = ?", []any{time.Now().Add(-time.Hour)}},
+		{"client key", "client_key = 'changed'", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id := insertTestTransfer(t, db, from, to, models.TransferStatusPending)
+			expectAbort(t, db, immutable, "UPDATE transfers SET "+tt.set+" WHERE id = ?", append(tt.args, id)...)
+		})
+	}
+
+	t.Run("write-once columns", func(t *testing.T) {
+		id := insertTestTransfer(t, db, from, to, models.TransferStatusPending)
+		if _, err := db.Exec("UPDATE transfers SET completed_at = ?, reversed_at = ?, reversal_reason = 'r' WHERE id = ?",
+			time.Now(), time.Now(), id); err != nil {
+			t.Fatalf("first write of write-once columns: %v", err)
+		}
+		expectAbort(t, db, immutable, "UPDATE transfers SET completed_at = ? WHERE id = ?", time.Now().Add(time.Hour), id)
+		expectAbort(t, db, immutable, "UPDATE transfers SET reversed_at = NULL WHERE id = ?", id)
+		expectAbort(t, db, immutable, "UPDATE transfers SET reversal_reason = 'other' WHERE id = ?", id)
+	})
+
+	t.Run("mutable columns", func(t *testing.T) {
+		id := insertTestTransfer(t, db, from, to, models.TransferStatusPending)
+		if _, err := db.Exec("UPDATE transfers SET note = 'n', updated_at = ?, client_key = NULL WHERE id = ?", time.Now(), id); err != nil {
+			t.Fatalf("update of mutable columns and client key release: %v", err)
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		id := insertTestTransfer(t, db, from, to, models.TransferStatusPending)
+		expectAbort(t, db, "transfers cannot be deleted", "DELETE FROM transfers WHERE id = ?", id)
+	})
+}
+
+func TestTransferStatusTransitions(t *testing.T) {
+	db := newTestDB(t)
+	from := insertTestUser(t, db, "LBK000001", 0)
+	to := insertTestUser(t, db, "LBK000002", 0)
+
+	tests := []struct {
+		from, to models.TransferStatus
+		legal    bool
+	}{
+		{models.TransferStatusPending, models.TransferStatusProcessing, true},
+		{models.TransferStatusPending, models.TransferStatusCancelled, true},
+		{models.TransferStatusProcessing, models.TransferStatusCompleted, true},
+		{models.TransferStatusProcessing, models.TransferStatusFailed, true},
+		{models.TransferStatusCompleted, models.TransferStatusReversed, true},
+		{models.TransferStatusPending, models.TransferStatusCompleted, false},
+		{models.TransferStatusPending, models.TransferStatusReversed, false},
+		{models.TransferStatusProcessing, models.TransferStatusPending, false},
+		{models.TransferStatusCompleted, models.TransferStatusFailed, false},
+		{models.TransferStatusFailed, models.TransferStatusProcessing, false},
+		{models.TransferStatusCancelled, models.TransferStatusPending, false},
+		{models.TransferStatusReversed, models.TransferStatusCompleted, false},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s to %s", tt.from, tt.to), func(t *testing.T) {
+			id := insertTestTransfer(t, db, from, to, tt.from)
+			if tt.legal {
+				if _, err := db.Exec("UPDATE transfers SET status = ? WHERE id = ?", tt.to, id); err != nil {
+					t.Fatalf("legal transition rejected: %v", err)
+				}
+				return
+			}
+			expectAbort(t, db, "illegal status transition", "UPDATE transfers SET status = ? WHERE id = ?", tt.to, id)
+
+			var status models.TransferStatus
+			if err := db.QueryRow("SELECT status FROM transfers WHERE id = ?", id).Scan(&status); err != nil {
+				t.Fatal(err)
+			}
+			if status != tt.from {
+				t.Fatalf("status is %s after a rejected transition, want %s", status, tt.from)
+			}
+		})
+	}
+}
+
+func TestForeignKeysRejectOrphans(t *testing.T) {
+	db := newTestDB(t)
+	userID := insertTestUser(t, db, "LBK000001", 0)
+	other := insertTestUser(t, db, "LBK000002", 0)
+	const missing = 999999
+	const fk = "FOREIGN KEY constraint failed"
+	now := time.Now()
+
+	expectAbort(t, db, fk, `
+		INSERT INTO transfers (from_user_id, to_user_id, amount, status, idempotency_key, created_at, updated_at)
+		VALUES (?, ?, 100, 'pending', 'orphan-transfer', ?, ?)`, userID, missing, now, now)
+	expectAbort(t, db, fk, `
+		INSERT INTO point_ledger (user_id, change, balance_after, event_type, created_at)
+		VALUES (?, 100, 100, 'adjust', ?)`, missing, now)
+	expectAbort(t, db, fk, `
+		INSERT INTO point_ledger (user_id, change, balance_after, event_type, transfer_id, created_at)
+		VALUES (?, 100, 100, 'adjust', ?, ?)`, userID, missing, now)
+	expectAbort(t, db, fk, `
+		INSERT INTO point_holds (user_id, amount, status, expires_at, created_at, updated_at)
+		VALUES (?, 100, 'active', ?, ?, ?)`, missing, now, now, now)
+
+	// user ที่ยังถูกอ้างถึงลบไม่ได้
+	insertTestTransfer(t, db, userID, other, models.TransferStatusPending)
+	expectAbort(t, db, fk, "DELETE FROM users WHERE id = ?", other)
+}
+
+// TestFailedMigrationKeepsIntegrityTriggers ทำให้ Migrate ล้มเหลวหลังปลด trigger แล้ว (ชื่อ index ชนกับ table)
+// trigger ต้องถูกติดตั้งกลับและยังกันการแก้ ledger ได้
+func TestFailedMigrationKeepsIntegrityTriggers(t *testing.T) {
+	db := newTestDB(t)
+	userID := insertTestUser(t, db, "LBK000001", 10*models.PointsScale)
+	if err := db.EnsureOpeningBalances(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("DROP INDEX idx_transfers_from"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec("CREATE TABLE idx_transfers_from (id INTEGER)"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := db.Migrate(); err == nil {
+		t.Fatal("migrate succeeded, want it to fail on the conflicting index name")
+	}
+
+	var triggers int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'trigger'").Scan(&triggers); err != nil {
+		t.Fatal(err)
+	}
+	if triggers != len(integrityTriggers) {
+		t.Fatalf("%d triggers after a failed migration, want %d", triggers, len(integrityTriggers))
+	}
+	expectAbort(t, db, "entries cannot be updated", "UPDATE point_ledger SET change = 0 WHERE user_id = ?", userID)
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"crypto/subtle"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// AdminAuth กัน endpoint ของ admin ด้วย header X-Admin-Key
+// ถ้าไม่ได้ตั้ง key ทุก request ถูกปฏิเสธ (503) ยกเว้นเปิด allowWithoutKey ไว้ชัดเจนสำหรับตอน develop
+func AdminAuth(apiKey string, allowWithoutKey bool) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if apiKey == "" {
+			if allowWithoutKey {
+				return c.Next()
+			}
+			return c.Status(fiber.StatusServiceUnavailable).JSON(fiber.Map{
+				"error":   "ADMIN_DISABLED",
+				"message": "Admin endpoints are disabled because ADMIN_API_KEY is not set",
+			})
+		}
+		if subtle.ConstantTimeCompare([]byte(c.Get("X-Admin-Key")), []byte(apiKey)) != 1 {
+			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+				"error":   "UNAUTHORIZED",
+				"message": "A valid X-Admin-Key header is required",
+			})
+		}
+		return c.Next()
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestAdminAuth(t *testing.T) {
+	tests := []struct {
+		name            string
+		apiKey          string
+		allowWithoutKey bool
+		header          string
+		want            int
+	}{
+		{"no key configured fails closed", "", false, "", fiber.StatusServiceUnavailable},
+		{"no key configured ignores any header", "", false, "anything", fiber.StatusServiceUnavailable},
+		{"explicit dev bypass", "", true, "", fiber.StatusOK},
+		{"missing header", "secret", false, "", fiber.StatusUnauthorized},
+		{"wrong header", "secret", false, "wrong", fiber.StatusUnauthorized},
+		{"dev bypass does not skip a configured key", "secret", true, "", fiber.StatusUnauthorized},
+		{"valid header", "secret", false, "secret", fiber.StatusOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			app := fiber.New()
+			app.Get("/admin", AdminAuth(tt.apiKey, tt.allowWithoutKey), func(c *fiber.Ctx) error {
+				return c.SendStatus(fiber.StatusOK)
+			})
+
+			req := httptest.NewRequest("GET", "/admin", nil)
+			if tt.header != "" {
+				req.Header.Set("X-Admin-Key", tt.header)
+			}
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if resp.StatusCode != tt.want {
+				t.Fatalf("got status %d, want %d", resp.StatusCode, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type BalanceHistoryHandler struct {
+	service *services.BalanceHistoryService
+}
+
+func NewBalanceHistoryHandler(service *services.BalanceHistoryService) *BalanceHistoryHandler {
+	return &BalanceHistoryHandler{service: service}
+}
+
+// GET /users/:id/balance?at= - แต้มของ user ณ เวลา at คำนวณจาก ledger
+// ถ้าไม่ส่ง at ส่งต่อให้ handler ถัดไป (UserHandler.GetBalance) ซึ่งคืนแต้มปัจจุบันพร้อม hold
+func (h *BalanceHistoryHandler) GetBalanceAt(c *fiber.Ctx) error {
+	v := c.Query("at")
+	if v == "" {
+		return c.Next()
+	}
+
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+	at, err := time.Parse(time.RFC3339, v)
+	if err != nil {
+		return queryParamError(c, "at must be an RFC3339 timestamp")
+	}
+
+	balance, err := h.service.GetBalanceAt(userID, at)
+	if err != nil {
+		return balanceHistoryError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   balance,
+	})
+}
+
+// GET /users/:id/balance/history?interval=day&from=&to= - ยอดปิดรายวัน (from/to เป็น YYYY-MM-DD)
+func (h *BalanceHistoryHandler) GetHistory(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	response, err := h.service.GetHistory(models.BalanceHistoryQuery{
+		UserID:   userID,
+		Interval: models.BalanceInterval(c.Query("interval")),
+		From:     c.Query("from"),
+		To:       c.Query("to"),
+	})
+	if err != nil {
+		return balanceHistoryError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+func balanceHistoryError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrInvalidBalanceQuery):
+		return queryParamError(c, err.Error())
+	case err.Error() == "user not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "User not found",
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "INTERNAL_ERROR",
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type DisputeHandler struct {
+	service *services.DisputeService
+}
+
+func NewDisputeHandler(service *services.DisputeService) *DisputeHandler {
+	return &DisputeHandler{service: service}
+}
+
+// POST /transfers/:id/disputes - ผู้โอนเปิด dispute ว่าโอนผิด
+func (h *DisputeHandler) OpenDispute(c *fiber.Ctx) error {
+	var req models.DisputeCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	dispute, err := h.service.OpenDispute(c.Params("id"), req)
+	if err != nil {
+		return disputeError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.DisputeResponse{
+		Dispute: *dispute,
+	})
+}
+
+// GET /users/:id/disputes?role=opened|received|all&status= - dispute ที่ user เป็นผู้เปิดหรือคู่กรณี
+func (h *DisputeHandler) GetUserDisputes(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	response, err := h.service.GetUserDisputes(userID, c.Query("role"), models.DisputeStatus(c.Query("status")))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GET /disputes/:disputeId - ดู dispute พร้อม audit trail
+func (h *DisputeHandler) GetDispute(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("disputeId"))
+	if err != nil {
+		return disputeError(c, services.ErrDisputeNotFound)
+	}
+
+	response, err := h.service.GetDispute(id)
+	if err != nil {
+		return disputeError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// POST /disputes/:disputeId/accept - คู่กรณียอมคืนแต้ม transfer ถูก reverse
+func (h *DisputeHandler) AcceptDispute(c *fiber.Ctx) error {
+	return h.respond(c, h.service.AcceptDispute)
+}
+
+// POST /disputes/:disputeId/contest - คู่กรณีโต้แย้ง รอ admin ตัดสิน
+func (h *DisputeHandler) ContestDispute(c *fiber.Ctx) error {
+	return h.respond(c, h.service.ContestDispute)
+}
+
+// POST /disputes/:disputeId/withdraw - ผู้เปิดถอน dispute
+func (h *DisputeHandler) WithdrawDispute(c *fiber.Ctx) error {
+	return h.respond(c, h.service.WithdrawDispute)
+}
+
+func (h *DisputeHandler) respond(c *fiber.Ctx, respond func(int, models.DisputeRespondRequest) (*models.DisputeResponse, error)) error {
+	id, err := strconv.Atoi(c.Params("disputeId"))
+	if err != nil {
+		return disputeError(c, services.ErrDisputeNotFound)
+	}
+
+	var req models.DisputeRespondRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	response, err := respond(id, req)
+	if err != nil {
+		return disputeError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// GET /admin/disputes?status= - dispute ทั้งหมด เก่าสุดก่อน
+func (h *DisputeHandler) ListDisputes(c *fiber.Ctx) error {
+	response, err := h.service.GetDisputes(models.DisputeStatus(c.Query("status")), c.QueryInt("limit", 50))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// POST /admin/disputes/:disputeId/resolve - admin ตัดสินให้ reverse หรือ reject
+func (h *DisputeHandler) ResolveDispute(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("disputeId"))
+	if err != nil {
+		return disputeError(c, services.ErrDisputeNotFound)
+	}
+
+	var req models.DisputeResolveRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	response, err := h.service.ResolveDispute(id, req)
+	if err != nil {
+		return disputeError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+func disputeError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrDisputeNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Dispute not found",
+		})
+	case err.Error() == "transfer not found", errors.Is(err, repositories.ErrTransferNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Transfer not found",
+		})
+	case errors.Is(err, repositories.ErrDisputeActive):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "DISPUTE_ALREADY_OPEN",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrDisputeClosed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "INVALID_DISPUTE_STATUS",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrTransferNotDisputable), errors.Is(err, repositories.ErrTransferNotReversible):
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "INVALID_TRANSFER_STATUS",
+			"message": err.Error(),
+		})
+	case errors.Is(err, repositories.ErrInsufficientPointsForReversal):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "INSUFFICIENT_POINTS",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": err.Error(),
+	})
+}
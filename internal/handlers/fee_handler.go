@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type FeeHandler struct {
+	service *services.FeeService
+}
+
+func NewFeeHandler(service *services.FeeService) *FeeHandler {
+	return &FeeHandler{service: service}
+}
+
+// GET /admin/fees - ค่าธรรมเนียมการโอนของทุก membership level
+func (h *FeeHandler) GetFees(c *fiber.Ctx) error {
+	response, err := h.service.GetFees()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// PUT /admin/fees/:level - แก้ค่าธรรมเนียมการโอนของ membership level
+func (h *FeeHandler) UpdateFee(c *fiber.Ctx) error {
+	var req models.TransferFeeUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	fee, err := h.service.UpdateFee(c.Params("level"), req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+	if fee == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Membership level must be Gold, Silver or Bronze",
+		})
+	}
+
+	return c.JSON(models.TransferFeeResponse{
+		Fee: *fee,
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type FraudHandler struct {
+	fraud     *services.FraudService
+	transfers *services.TransferService
+}
+
+func NewFraudHandler(fraud *services.FraudService, transfers *services.TransferService) *FraudHandler {
+	return &FraudHandler{fraud: fraud, transfers: transfers}
+}
+
+// GET /admin/fraud/settings - เกณฑ์การตรวจ fraud
+func (h *FraudHandler) GetSettings(c *fiber.Ctx) error {
+	settings, err := h.fraud.GetSettings()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(models.FraudSettingsResponse{
+		Settings: *settings,
+	})
+}
+
+// PUT /admin/fraud/settings - แก้เกณฑ์การตรวจ fraud หรือเปิด/ปิดการตรวจ
+func (h *FraudHandler) UpdateSettings(c *fiber.Ctx) error {
+	var req models.FraudSettingsUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	settings, err := h.fraud.UpdateSettings(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(models.FraudSettingsResponse{
+		Settings: *settings,
+	})
+}
+
+// GET /admin/fraud/reviews - คิว transfer ที่รอตรวจ (กรองด้วย ?status= ได้ ค่าเริ่มต้น pending)
+func (h *FraudHandler) GetReviews(c *fiber.Ctx) error {
+	response, err := h.fraud.GetReviews(models.FraudReviewStatus(c.Query("status")), c.QueryInt("limit", 50))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GET /admin/fraud/reviews/:reviewId - ดู review พร้อม transfer ที่ถูกพักไว้
+func (h *FraudHandler) GetReview(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return fraudError(c, services.ErrFraudReviewNotFound)
+	}
+
+	review, err := h.fraud.GetReview(id)
+	if err != nil {
+		return fraudError(c, err)
+	}
+	transfer, err := h.transfers.GetTransferByIdemKey(review.IdemKey)
+	if err != nil {
+		return fraudError(c, err)
+	}
+
+	return c.JSON(models.FraudReviewResponse{
+		Review:   *review,
+		Transfer: transfer,
+	})
+}
+
+// POST /admin/fraud/reviews/:reviewId/approve - อนุมัติและโอน transfer ที่ถูกพักไว้
+func (h *FraudHandler) ApproveReview(c *fiber.Ctx) error {
+	return h.decide(c, h.transfers.ApproveFraudReview)
+}
+
+// POST /admin/fraud/reviews/:reviewId/reject - ปฏิเสธ transfer ที่ถูกพักไว้และคืน hold ให้ผู้โอน
+func (h *FraudHandler) RejectReview(c *fiber.Ctx) error {
+	return h.decide(c, h.transfers.RejectFraudReview)
+}
+
+func (h *FraudHandler) decide(c *fiber.Ctx, decide func(int, models.FraudReviewDecisionRequest) (*models.FraudReviewResponse, error)) error {
+	id, err := strconv.Atoi(c.Params("reviewId"))
+	if err != nil {
+		return fraudError(c, services.ErrFraudReviewNotFound)
+	}
+
+	// body ไม่บังคับ ส่งมาเฉพาะเมื่อต้องการบันทึก note
+	var req models.FraudReviewDecisionRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "VALIDATION_ERROR",
+				"message": "Invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	response, err := decide(id, req)
+	if err != nil {
+		return fraudError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+func fraudError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrFraudReviewNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Fraud review not found",
+		})
+	case errors.Is(err, services.ErrFraudReviewClosed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "FRAUD_REVIEW_CLOSED",
+			"message": err.Error(),
+		})
+	case errors.Is(err, repositories.ErrTransferNotFound):
+		return transferLifecycleError(c, errors.New("transfer not found"))
+	}
+	if err.Error() == "note cannot exceed 512 characters" {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+	return transferLifecycleError(c, err)
+}
+
+// fraudBlocked ตอบ 422 พร้อมคะแนนและสัญญาณที่ทำให้การโอนถูกปฏิเสธ
+func fraudBlocked(c *fiber.Ctx, fraudErr *services.FraudBlockedError) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":   fraudErr.Code(),
+		"message": fraudErr.Error(),
+		"details": fraudErr.Assessment,
+	})
+}
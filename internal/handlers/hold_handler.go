@@ -0,0 +1,169 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type HoldHandler struct {
+	service *services.HoldService
+}
+
+func NewHoldHandler(service *services.HoldService) *HoldHandler {
+	return &HoldHandler{service: service}
+}
+
+// POST /users/:id/holds - ร้านค้ากันแต้มของสมาชิกตอน checkout
+func (h *HoldHandler) CreateHold(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	var req models.HoldCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	hold, err := h.service.CreateHold(userID, req)
+	if err != nil {
+		return holdError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.HoldResponse{
+		Hold: *hold,
+	})
+}
+
+// GET /users/:id/holds?status= - hold ของร้านค้าของสมาชิก
+func (h *HoldHandler) GetHolds(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	response, err := h.service.GetHolds(userID, models.HoldStatus(c.Query("status")))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GET /users/:id/holds/:holdId - ดู hold
+func (h *HoldHandler) GetHold(c *fiber.Ctx) error {
+	userID, holdID, ok := holdParams(c)
+	if !ok {
+		return holdError(c, repositories.ErrHoldNotFound)
+	}
+
+	hold, err := h.service.GetHold(userID, holdID)
+	if err != nil {
+		return holdError(c, err)
+	}
+
+	return c.JSON(models.HoldResponse{
+		Hold: *hold,
+	})
+}
+
+// POST /users/:id/holds/:holdId/capture - ตัดแต้มจาก hold ทั้งหมดหรือบางส่วน
+func (h *HoldHandler) CaptureHold(c *fiber.Ctx) error {
+	userID, holdID, ok := holdParams(c)
+	if !ok {
+		return holdError(c, repositories.ErrHoldNotFound)
+	}
+
+	// body ไม่บังคับ ส่งมาเฉพาะเมื่อ capture บางส่วน
+	var req models.HoldCaptureRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "VALIDATION_ERROR",
+				"message": "Invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	response, err := h.service.CaptureHold(userID, holdID, req)
+	if err != nil {
+		return holdError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// POST /users/:id/holds/:holdId/void - ปล่อยแต้มที่ hold ไว้คืน
+func (h *HoldHandler) VoidHold(c *fiber.Ctx) error {
+	userID, holdID, ok := holdParams(c)
+	if !ok {
+		return holdError(c, repositories.ErrHoldNotFound)
+	}
+
+	hold, err := h.service.VoidHold(userID, holdID)
+	if err != nil {
+		return holdError(c, err)
+	}
+
+	return c.JSON(models.HoldResponse{
+		Hold: *hold,
+	})
+}
+
+func holdParams(c *fiber.Ctx) (int, int, bool) {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return 0, 0, false
+	}
+	holdID, err := strconv.Atoi(c.Params("holdId"))
+	if err != nil {
+		return 0, 0, false
+	}
+	return userID, holdID, true
+}
+
+func holdError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, repositories.ErrHoldNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Hold not found",
+		})
+	case err.Error() == "user not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "User not found",
+		})
+	case errors.Is(err, repositories.ErrHoldNotActive):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "INVALID_HOLD_STATUS",
+			"message": err.Error(),
+		})
+	case errors.Is(err, repositories.ErrHoldExpired):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error":   "HOLD_EXPIRED",
+			"message": err.Error(),
+		})
+	case errors.Is(err, repositories.ErrInsufficientPoints):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "INSUFFICIENT_POINTS",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": err.Error(),
+	})
+}
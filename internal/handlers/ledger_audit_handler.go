@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"errors"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LedgerAuditHandler struct {
+	service *services.LedgerAuditService
+}
+
+func NewLedgerAuditHandler(service *services.LedgerAuditService) *LedgerAuditHandler {
+	return &LedgerAuditHandler{service: service}
+}
+
+// GET /admin/ledger/verify - ไล่ตรวจ hash chain ของ ledger และรายงานจุดแรกที่ขาด
+func (h *LedgerAuditHandler) Verify(c *fiber.Ctx) error {
+	verification, err := h.service.Verify()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(models.LedgerVerificationResponse{
+		Verification: *verification,
+	})
+}
+
+// POST /admin/ledger/checkpoints - sign hash ของ entry ล่าสุดทันที
+// คืน 200 พร้อม checkpoint เดิมถ้าไม่มี entry ใหม่ตั้งแต่ checkpoint ล่าสุด
+func (h *LedgerAuditHandler) CreateCheckpoint(c *fiber.Ctx) error {
+	checkpoint, created, err := h.service.CreateCheckpoint()
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrLedgerSigningKeyMissing):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "SIGNING_KEY_NOT_CONFIGURED",
+				"message": "Set LEDGER_SIGNING_KEY to create ledger checkpoints",
+			})
+		case errors.Is(err, services.ErrLedgerChainInvalid):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "LEDGER_CHAIN_BROKEN",
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+	if checkpoint == nil {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "LEDGER_EMPTY",
+			"message": "The ledger has no entries to checkpoint",
+		})
+	}
+
+	status := fiber.StatusOK
+	if created {
+		status = fiber.StatusCreated
+	}
+	return c.Status(status).JSON(models.LedgerCheckpointResponse{
+		Checkpoint: *checkpoint,
+	})
+}
+
+// GET /admin/ledger/checkpoints?limit= - checkpoint ล่าสุดก่อน
+func (h *LedgerAuditHandler) ListCheckpoints(c *fiber.Ctx) error {
+	response, err := h.service.GetCheckpoints(c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
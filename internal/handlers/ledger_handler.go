@@ -0,0 +1,75 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LedgerHandler struct {
+	service *services.LedgerService
+}
+
+func NewLedgerHandler(service *services.LedgerService) *LedgerHandler {
+	return &LedgerHandler{service: service}
+}
+
+// GET /users/:id/ledger?eventType=&createdFrom=&createdTo=&sort=&pageSize=&cursor= - ประวัติการเปลี่ยนแต้มพร้อมยอดต้นงวดและปลายงวด
+func (h *LedgerHandler) GetLedger(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	q := models.LedgerQuery{
+		UserID:   userID,
+		Sort:     models.LedgerSort(c.Query("sort")),
+		PageSize: c.QueryInt("pageSize", 50),
+		Cursor:   c.Query("cursor"),
+	}
+
+	// eventType รับหลายค่าคั่นด้วย comma เช่น transfer_out,transfer_in
+	if v := c.Query("eventType"); v != "" {
+		for _, eventType := range strings.Split(v, ",") {
+			q.EventTypes = append(q.EventTypes, models.EventType(strings.TrimSpace(eventType)))
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"createdFrom", &q.CreatedFrom}, {"createdTo", &q.CreatedTo}} {
+		name, dst := p.name, p.dst
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return queryParamError(c, name+" must be an RFC3339 timestamp")
+			}
+			*dst = &t
+		}
+	}
+
+	response, err := h.service.GetLedger(q)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrInvalidLedgerQuery):
+			return queryParamError(c, err.Error())
+		case err.Error() == "user not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   "NOT_FOUND",
+				"message": "User not found",
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"strconv"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type LimitHandler struct {
+	service *services.LimitService
+}
+
+func NewLimitHandler(service *services.LimitService) *LimitHandler {
+	return &LimitHandler{service: service}
+}
+
+// GET /admin/limits - limit การโอนของทุก membership level
+func (h *LimitHandler) GetLimits(c *fiber.Ctx) error {
+	response, err := h.service.GetLimits()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// PUT /admin/limits/:level - แก้ limit การโอนของ membership level
+func (h *LimitHandler) UpdateLimit(c *fiber.Ctx) error {
+	var req models.TransferLimitUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	limit, err := h.service.UpdateLimit(c.Params("level"), req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+	if limit == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Membership level must be Gold, Silver or Bronze",
+		})
+	}
+
+	return c.JSON(models.TransferLimitResponse{
+		Limit: *limit,
+	})
+}
+
+// GET /users/:id/limits - limit ของ user และยอดที่ใช้ไปในวันนี้และเดือนนี้
+func (h *LimitHandler) GetUserLimits(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	response, err := h.service.GetUserLimits(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+	if response == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "User not found",
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// limitExceeded ตอบ 422 พร้อมบอกว่าชน limit ไหนและยังเหลือโอนได้เท่าไร
+func limitExceeded(c *fiber.Ctx, limitErr *services.LimitExceededError) error {
+	return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+		"error":   "LIMIT_EXCEEDED",
+		"message": limitErr.Error(),
+		"details": limitErr,
+	})
+}
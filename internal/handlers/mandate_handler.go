@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type MandateHandler struct {
+	service *services.MandateService
+}
+
+func NewMandateHandler(service *services.MandateService) *MandateHandler {
+	return &MandateHandler{service: service}
+}
+
+// GET /users/:id/mandates - รายการคำสั่งโอนประจำของ user
+func (h *MandateHandler) GetMandates(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	response, err := h.service.GetMandates(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// POST /users/:id/mandates - สร้างคำสั่งโอนประจำ
+func (h *MandateHandler) CreateMandate(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	var req models.MandateCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	mandate, err := h.service.CreateMandate(userID, req)
+	if err != nil {
+		var limitErr *services.LimitExceededError
+		if errors.As(err, &limitErr) {
+			return limitExceeded(c, limitErr)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.MandateResponse{
+		Mandate: *mandate,
+	})
+}
+
+// GET /users/:id/mandates/:mandateId - ดูคำสั่งโอนประจำ
+func (h *MandateHandler) GetMandate(c *fiber.Ctx) error {
+	userID, mandateID, ok := mandateParams(c)
+	if !ok {
+		return invalidUserID(c)
+	}
+
+	mandate, err := h.service.GetMandate(userID, mandateID)
+	if err != nil {
+		return mandateError(c, err)
+	}
+
+	return c.JSON(models.MandateResponse{
+		Mandate: *mandate,
+	})
+}
+
+// PUT /users/:id/mandates/:mandateId - แก้ไข หยุดชั่วคราว หรือเปิดใช้คำสั่งโอนประจำอีกครั้ง
+func (h *MandateHandler) UpdateMandate(c *fiber.Ctx) error {
+	userID, mandateID, ok := mandateParams(c)
+	if !ok {
+		return invalidUserID(c)
+	}
+
+	var req models.MandateUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	mandate, err := h.service.UpdateMandate(userID, mandateID, req)
+	if err != nil {
+		return mandateError(c, err)
+	}
+
+	return c.JSON(models.MandateResponse{
+		Mandate: *mandate,
+	})
+}
+
+// DELETE /users/:id/mandates/:mandateId - ยกเลิกคำสั่งโอนประจำ
+func (h *MandateHandler) CancelMandate(c *fiber.Ctx) error {
+	userID, mandateID, ok := mandateParams(c)
+	if !ok {
+		return invalidUserID(c)
+	}
+
+	mandate, err := h.service.CancelMandate(userID, mandateID)
+	if err != nil {
+		return mandateError(c, err)
+	}
+
+	return c.JSON(models.MandateResponse{
+		Mandate: *mandate,
+	})
+}
+
+func mandateParams(c *fiber.Ctx) (int, int, bool) {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return 0, 0, false
+	}
+	mandateID, err := strconv.Atoi(c.Params("mandateId"))
+	if err != nil || mandateID < 1 {
+		return 0, 0, false
+	}
+	return userID, mandateID, true
+}
+
+func mandateError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrMandateNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Mandate not found",
+		})
+	}
+	var limitErr *services.LimitExceededError
+	if errors.As(err, &limitErr) {
+		return limitExceeded(c, limitErr)
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,162 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type PointRequestHandler struct {
+	service *services.PointRequestService
+}
+
+func NewPointRequestHandler(service *services.PointRequestService) *PointRequestHandler {
+	return &PointRequestHandler{service: service}
+}
+
+// POST /point-requests - ขอแต้มจาก user อื่น
+func (h *PointRequestHandler) CreateRequest(c *fiber.Ctx) error {
+	var req models.PointRequestCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	pr, err := h.service.CreateRequest(req)
+	if err != nil {
+		var limitErr *services.LimitExceededError
+		if errors.As(err, &limitErr) {
+			return limitExceeded(c, limitErr)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.PointRequestResponse{
+		PointRequest: *pr,
+	})
+}
+
+// GET /users/:id/point-requests?role=incoming|outgoing|all&status= - คำขอแต้มของ user
+func (h *PointRequestHandler) GetRequests(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	response, err := h.service.GetRequests(userID, c.Query("role"), c.Query("status"))
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// POST /point-requests/:requestId/approve - payer อนุมัติและโอนแต้ม
+func (h *PointRequestHandler) ApproveRequest(c *fiber.Ctx) error {
+	requestID, req, err := parseRespond(c)
+	if err != nil {
+		return err
+	}
+
+	pr, transfer, err := h.service.ApproveRequest(requestID, req)
+	if err != nil {
+		return pointRequestError(c, err)
+	}
+
+	return c.JSON(models.PointRequestResponse{
+		PointRequest: *pr,
+		Transfer:     transfer,
+	})
+}
+
+// POST /point-requests/:requestId/decline - payer ปฏิเสธคำขอ
+func (h *PointRequestHandler) DeclineRequest(c *fiber.Ctx) error {
+	requestID, req, err := parseRespond(c)
+	if err != nil {
+		return err
+	}
+
+	pr, err := h.service.DeclineRequest(requestID, req)
+	if err != nil {
+		return pointRequestError(c, err)
+	}
+
+	return c.JSON(models.PointRequestResponse{
+		PointRequest: *pr,
+	})
+}
+
+// parseRespond อ่าน requestId และ body ถ้าไม่ผ่านจะตอบ 400 ไปแล้วและคืน error ที่ fiber ส่งกลับ
+func parseRespond(c *fiber.Ctx) (int, models.PointRequestRespondRequest, error) {
+	var req models.PointRequestRespondRequest
+
+	requestID, err := strconv.Atoi(c.Params("requestId"))
+	if err != nil || requestID < 1 {
+		return 0, req, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Request ID must be a positive integer",
+		})
+	}
+
+	if err := c.BodyParser(&req); err != nil {
+		return 0, req, c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+	return requestID, req, nil
+}
+
+func pointRequestError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrPointRequestNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Point request not found",
+		})
+	case errors.Is(err, services.ErrPointRequestExpired):
+		return c.Status(fiber.StatusGone).JSON(fiber.Map{
+			"error":   "REQUEST_EXPIRED",
+			"message": err.Error(),
+		})
+	case errors.Is(err, services.ErrPointRequestClosed):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "INVALID_REQUEST_STATUS",
+			"message": err.Error(),
+		})
+	}
+
+	// error จากการโอน ใช้ code เดียวกับ POST /transfers
+	var limitErr *services.LimitExceededError
+	if errors.As(err, &limitErr) {
+		return limitExceeded(c, limitErr)
+	}
+	var ruleErr *services.RuleViolationError
+	if errors.As(err, &ruleErr) {
+		return ruleViolation(c, ruleErr)
+	}
+	code := services.TransferErrorCode(err)
+	status := fiber.StatusBadRequest
+	switch code {
+	case "INSUFFICIENT_POINTS":
+		status = fiber.StatusConflict
+	case "INVALID_TRANSFER", "DUPLICATE_TRANSFER":
+		status = fiber.StatusUnprocessableEntity
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   code,
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type ReconciliationHandler struct {
+	service *services.ReconciliationService
+}
+
+func NewReconciliationHandler(service *services.ReconciliationService) *ReconciliationHandler {
+	return &ReconciliationHandler{service: service}
+}
+
+// POST /admin/reconciliation/runs - ตรวจยอดเทียบกับ ledger ทันที ส่ง {"repair": true} เพื่อเขียน adjust entry
+func (h *ReconciliationHandler) Run(c *fiber.Ctx) error {
+	// body ไม่บังคับ ไม่ส่งมาคือตรวจอย่างเดียว
+	var req models.ReconciliationRunRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+				"error":   "VALIDATION_ERROR",
+				"message": "Invalid request body: " + err.Error(),
+			})
+		}
+	}
+
+	report, err := h.service.Run(models.ReconciliationTriggerManual, req.Repair)
+	if err != nil {
+		if errors.Is(err, services.ErrReconciliationRunning) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "RECONCILIATION_RUNNING",
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.ReconciliationReportResponse{
+		Report: *report,
+	})
+}
+
+// GET /admin/reconciliation/runs?limit= - ผลการรันล่าสุดก่อน
+func (h *ReconciliationHandler) ListReports(c *fiber.Ctx) error {
+	response, err := h.service.GetReports(c.QueryInt("limit", 20))
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// GET /admin/reconciliation/runs/:runId - ผลการรันหนึ่งครั้งพร้อมรายการที่ไม่ตรงกัน
+func (h *ReconciliationHandler) GetReport(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("runId"))
+	if err != nil {
+		return reconciliationReportNotFound(c)
+	}
+
+	report, err := h.service.GetReport(id)
+	if err != nil {
+		if err.Error() == "reconciliation report not found" {
+			return reconciliationReportNotFound(c)
+		}
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(models.ReconciliationReportResponse{
+		Report: *report,
+	})
+}
+
+func reconciliationReportNotFound(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error":   "NOT_FOUND",
+		"message": "Reconciliation report not found",
+	})
+}
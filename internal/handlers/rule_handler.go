@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"errors"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type RuleHandler struct {
+	service *services.RuleService
+}
+
+func NewRuleHandler(service *services.RuleService) *RuleHandler {
+	return &RuleHandler{service: service}
+}
+
+// GET /admin/rules - transfer rule ทั้งหมดตามลำดับที่ประเมิน พร้อมตัวแปรที่ใช้ใน expression ได้
+func (h *RuleHandler) GetRules(c *fiber.Ctx) error {
+	response, err := h.service.GetRules()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// POST /admin/rules - เพิ่ม transfer rule (มีผลทันที)
+func (h *RuleHandler) CreateRule(c *fiber.Ctx) error {
+	var req models.TransferRuleCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	rule, err := h.service.CreateRule(req)
+	if err != nil {
+		return ruleError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.TransferRuleResponse{
+		Rule: *rule,
+	})
+}
+
+// PUT /admin/rules/:name - แก้ transfer rule (มีผลทันที)
+func (h *RuleHandler) UpdateRule(c *fiber.Ctx) error {
+	var req models.TransferRuleUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	rule, err := h.service.UpdateRule(c.Params("name"), req)
+	if err != nil {
+		return ruleError(c, err)
+	}
+
+	return c.JSON(models.TransferRuleResponse{
+		Rule: *rule,
+	})
+}
+
+// DELETE /admin/rules/:name - ลบ transfer rule
+func (h *RuleHandler) DeleteRule(c *fiber.Ctx) error {
+	if err := h.service.DeleteRule(c.Params("name")); err != nil {
+		return ruleError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Rule deleted successfully",
+	})
+}
+
+// POST /admin/rules/reload - โหลด rule จาก database ใหม่ (เช่น หลังแก้ table ตรงๆ)
+func (h *RuleHandler) ReloadRules(c *fiber.Ctx) error {
+	if err := h.service.Reload(); err != nil {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":   "INVALID_RULE",
+			"message": err.Error(),
+		})
+	}
+
+	return h.GetRules(c)
+}
+
+func ruleError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrRuleNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Rule not found",
+		})
+	case errors.Is(err, repositories.ErrDuplicateRuleName):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "DUPLICATE_RULE",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": err.Error(),
+	})
+}
+
+// ruleViolation ตอบ error code ของ rule ที่ปฏิเสธการโอน
+// VALIDATION_ERROR เป็น 400 ส่วน code อื่นถือว่า request ถูกต้องแต่ขัดกับ policy จึงเป็น 422
+func ruleViolation(c *fiber.Ctx, ruleErr *services.RuleViolationError) error {
+	status := fiber.StatusUnprocessableEntity
+	if ruleErr.Code == "VALIDATION_ERROR" {
+		status = fiber.StatusBadRequest
+	}
+	return c.Status(status).JSON(fiber.Map{
+		"error":   ruleErr.Code,
+		"message": ruleErr.Message,
+		"details": fiber.Map{"rule": ruleErr.Rule},
+	})
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"testing"
+
+	"kbtg-backend/internal/database"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/services"
+	"kbtg-backend/internal/testutil"
+)
+
+// newTestTransferService ต่อ TransferService แบบเดียวกับ main (ไม่มี fraud และ webhook)
+// rule ใน disabledRules ถูกปิดก่อนโหลด
+func newTestTransferService(t *testing.T, db *database.DB, disabledRules ...string) *services.TransferService {
+	t.Helper()
+	testutil.DisableRules(t, db, disabledRules...)
+
+	transferRepo := repositories.NewTransferRepository(db.DB)
+	rules := services.NewRuleService(repositories.NewRuleRepository(db.DB), transferRepo)
+	if err := rules.Reload(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+	return services.NewTransferService(
+		transferRepo,
+		services.NewUserService(repositories.NewUserRepository(db.DB)),
+		services.NewLimitService(repositories.NewLimitRepository(db.DB)),
+		rules,
+		services.NewFeeService(repositories.NewFeeRepository(db.DB)),
+	)
+}
@@ -1,9 +1,13 @@
 package handlers
 
 import (
+	"errors"
 	"strconv"
+	"strings"
+	"time"
 
 	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
 	"kbtg-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -27,11 +31,40 @@ func (h *TransferHandler) CreateTransfer(c *fiber.Ctx) error {
 		})
 	}
 
+	// client ส่ง Idempotency-Key มาเพื่อให้ retry ได้โดยไม่โอนซ้ำ
+	req.IdemKey = c.Get("Idempotency-Key")
+
 	transfer, err := h.service.CreateTransfer(req)
 	if err != nil {
 		statusCode := fiber.StatusBadRequest
 		errorCode := "VALIDATION_ERROR"
 
+		switch {
+		case errors.Is(err, services.ErrIdempotencyKeyMismatch):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":   "IDEMPOTENCY_KEY_REUSED",
+				"message": err.Error(),
+			})
+		case errors.Is(err, services.ErrRecipientNotFound), errors.Is(err, services.ErrRecipientAmbiguous):
+			return recipientError(c, err)
+		}
+
+		var limitErr *services.LimitExceededError
+		if errors.As(err, &limitErr) {
+			return limitExceeded(c, limitErr)
+		}
+
+		// ถูกปฏิเสธโดย transfer rule ใช้ error code ของ rule
+		var ruleErr *services.RuleViolationError
+		if errors.As(err, &ruleErr) {
+			return ruleViolation(c, ruleErr)
+		}
+
+		var fraudErr *services.FraudBlockedError
+		if errors.As(err, &fraudErr) {
+			return fraudBlocked(c, fraudErr)
+		}
+
 		// ถ้าเป็น insufficient points ให้ return 409 Conflict
 		if err.Error() == "insufficient points" ||
 			len(err.Error()) > 20 && err.Error()[:20] == "insufficient points:" {
@@ -39,32 +72,219 @@ func (h *TransferHandler) CreateTransfer(c *fiber.Ctx) error {
 			errorCode = "INSUFFICIENT_POINTS"
 		}
 
-		// ถ้าเป็นโอนให้ตัวเอง ให้ return 422
-		if err.Error() == "cannot transfer to yourself" {
-			statusCode = fiber.StatusUnprocessableEntity
-			errorCode = "INVALID_TRANSFER"
-		}
-
-		// ถ้าเป็นโอนซ้ำกับ user เดิม ให้ return 422
-		if len(err.Error()) > 14 && err.Error()[:14] == "cannot transfer" {
-			statusCode = fiber.StatusUnprocessableEntity
-			errorCode = "DUPLICATE_TRANSFER"
-		}
-
 		return c.Status(statusCode).JSON(fiber.Map{
 			"error":   errorCode,
 			"message": err.Error(),
 		})
 	}
 
-	// Set Idempotency-Key header
+	// Idempotency-Key ของ response คือ idemKey ที่ใช้เรียก transfer นี้ (ไม่ใช่ key ที่ client ส่งมา)
 	c.Set("Idempotency-Key", transfer.IdemKey)
 
+	// ถูกพักไว้รอตรวจ fraud: แต้มถูก hold แล้วแต่ยังไม่โอนจนกว่า admin จะอนุมัติ
+	if transfer.UnderReview {
+		return c.Status(fiber.StatusAccepted).JSON(models.TransferCreateResponse{
+			Transfer: *transfer,
+		})
+	}
+
 	return c.Status(fiber.StatusCreated).JSON(models.TransferCreateResponse{
 		Transfer: *transfer,
 	})
 }
 
+// POST /transfers/quote - จำลองการโอนโดยไม่บันทึก คืนค่าธรรมเนียม แต้มหลังโอน และทุกเหตุผลที่จะถูกปฏิเสธ
+// ตอบ 200 เสมอเมื่อ quote ได้ ดูผลจาก allowed และ violations
+func (h *TransferHandler) QuoteTransfer(c *fiber.Ctx) error {
+	var req models.TransferCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	quote, err := h.service.QuoteTransfer(req)
+	if err != nil {
+		switch {
+		case errors.Is(err, services.ErrRecipientNotFound), errors.Is(err, services.ErrRecipientAmbiguous):
+			return recipientError(c, err)
+		case err.Error() == "from user not found":
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   "USER_NOT_FOUND",
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(models.TransferQuoteResponse{
+		Quote: *quote,
+	})
+}
+
+// POST /transfers/batch - โอนจากผู้โอนคนเดียวไปหลายผู้รับใน transaction เดียว
+func (h *TransferHandler) BatchTransfer(c *fiber.Ctx) error {
+	var req models.BatchTransferRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	response, err := h.service.BatchTransfer(req)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	// ไม่มี leg ไหนสำเร็จเลย ตอบ 422 พร้อมผลของแต่ละ leg
+	if response.Succeeded == 0 {
+		return c.Status(fiber.StatusUnprocessableEntity).JSON(response)
+	}
+	return c.Status(fiber.StatusCreated).JSON(response)
+}
+
+// POST /transfers/:id/confirm - ยืนยันคำสั่งโอนแบบ two-phase ที่ยัง pending
+func (h *TransferHandler) ConfirmTransfer(c *fiber.Ctx) error {
+	transfer, err := h.service.ConfirmTransfer(c.Params("id"))
+	if err != nil {
+		return transferLifecycleError(c, err)
+	}
+
+	return c.JSON(models.TransferGetResponse{
+		Transfer: *transfer,
+	})
+}
+
+// POST /transfers/:id/cancel - ยกเลิกคำสั่งโอนแบบ two-phase ที่ยัง pending
+func (h *TransferHandler) CancelTransfer(c *fiber.Ctx) error {
+	transfer, err := h.service.CancelTransfer(c.Params("id"))
+	if err != nil {
+		return transferLifecycleError(c, err)
+	}
+
+	return c.JSON(models.TransferGetResponse{
+		Transfer: *transfer,
+	})
+}
+
+// GET /users/:id/scheduled-transfers - รายการโอนล่วงหน้าที่ยังไม่ถึงเวลา
+func (h *TransferHandler) GetScheduledTransfers(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	response, err := h.service.GetScheduledTransfers(userID)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// DELETE /users/:id/scheduled-transfers/:transferId - ยกเลิกโอนล่วงหน้า
+func (h *TransferHandler) CancelScheduledTransfer(c *fiber.Ctx) error {
+	userID, err := strconv.Atoi(c.Params("id"))
+	if err != nil || userID < 1 {
+		return invalidUserID(c)
+	}
+
+	transfer, err := h.service.CancelScheduledTransfer(userID, c.Params("transferId"))
+	if err != nil {
+		return transferLifecycleError(c, err)
+	}
+
+	return c.JSON(models.TransferGetResponse{
+		Transfer: *transfer,
+	})
+}
+
+// invalidUserID ตอบ 400 เมื่อ :id ใน path ไม่ใช่ตัวเลขบวก
+func invalidUserID(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": "User ID must be a positive integer",
+	})
+}
+
+// transferLifecycleError แปลง error จากการเปลี่ยนสถานะ transfer เป็น HTTP response
+func transferLifecycleError(c *fiber.Ctx, err error) error {
+	switch {
+	case err.Error() == "transfer not found":
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Transfer not found",
+		})
+	case errors.Is(err, services.ErrIllegalTransition), errors.Is(err, repositories.ErrStatusConflict):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "INVALID_TRANSFER_STATUS",
+			"message": err.Error(),
+		})
+	case strings.HasPrefix(err.Error(), "insufficient points"):
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "INSUFFICIENT_POINTS",
+			"message": err.Error(),
+		})
+	}
+	return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "INTERNAL_ERROR",
+		"message": err.Error(),
+	})
+}
+
+// POST /admin/transfers/:id/reverse - ยกเลิกคำสั่งโอนที่สำเร็จแล้ว (สำหรับทีม support)
+func (h *TransferHandler) ReverseTransfer(c *fiber.Ctx) error {
+	idemKey := c.Params("id")
+
+	var req models.TransferReverseRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	transfer, err := h.service.ReverseTransfer(idemKey, req)
+	if err != nil {
+		switch {
+		case errors.Is(err, repositories.ErrTransferNotFound):
+			return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+				"error":   "NOT_FOUND",
+				"message": "Transfer not found",
+			})
+		case errors.Is(err, repositories.ErrInsufficientPointsForReversal):
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "INSUFFICIENT_POINTS",
+				"message": err.Error(),
+			})
+		case errors.Is(err, repositories.ErrTransferNotReversible):
+			return c.Status(fiber.StatusUnprocessableEntity).JSON(fiber.Map{
+				"error":   "INVALID_TRANSFER_STATUS",
+				"message": err.Error(),
+			})
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(models.TransferReverseResponse{
+		Transfer: *transfer,
+	})
+}
+
 // GET /transfers/:id - ดูสถานะคำสั่งโอน
 func (h *TransferHandler) GetTransfer(c *fiber.Ctx) error {
 	idemKey := c.Params("id")
@@ -94,7 +314,7 @@ func (h *TransferHandler) GetTransfer(c *fiber.Ctx) error {
 	})
 }
 
-// GET /transfers?userId=X&page=1&pageSize=20 - ค้นประวัติการโอน
+// GET /transfers?userId=X&status=failed&page=1&pageSize=20 - ค้นประวัติการโอน
 func (h *TransferHandler) GetTransfers(c *fiber.Ctx) error {
 	userIDStr := c.Query("userId")
 	if userIDStr == "" {
@@ -112,11 +332,60 @@ func (h *TransferHandler) GetTransfers(c *fiber.Ctx) error {
 		})
 	}
 
-	page := c.QueryInt("page", 1)
-	pageSize := c.QueryInt("pageSize", 20)
+	q := models.TransferQuery{
+		UserID:    userID,
+		Direction: models.TransferDirection(c.Query("direction")),
+		Status:    models.TransferStatus(c.Query("status")),
+		Sort:      models.TransferSort(c.Query("sort")),
+		Page:      c.QueryInt("page", 1),
+		PageSize:  c.QueryInt("pageSize", 20),
+		Cursor:    c.Query("cursor"),
+	}
+
+	if v := c.Query("counterpartyId"); v != "" {
+		if q.CounterpartyID, err = strconv.Atoi(v); err != nil || q.CounterpartyID < 1 {
+			return queryParamError(c, "counterpartyId must be a positive integer")
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **time.Time
+	}{{"createdFrom", &q.CreatedFrom}, {"createdTo", &q.CreatedTo}} {
+		name, dst := p.name, p.dst
+		if v := c.Query(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return queryParamError(c, name+" must be an RFC3339 timestamp")
+			}
+			*dst = &t
+		}
+	}
+	for _, p := range []struct {
+		name string
+		dst  **models.Points
+	}{{"minAmount", &q.MinAmount}, {"maxAmount", &q.MaxAmount}} {
+		name, dst := p.name, p.dst
+		if v := c.Query(name); v != "" {
+			amount, err := models.ParsePoints(v)
+			if err != nil || amount < 0 {
+				return queryParamError(c, name+" must be a non-negative number with at most 2 decimal places")
+			}
+			*dst = &amount
+		}
+	}
+	if v := c.Query("includeTotal"); v != "" {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return queryParamError(c, "includeTotal must be true or false")
+		}
+		q.IncludeTotal = &b
+	}
 
-	response, err := h.service.GetTransfersByUserID(userID, page, pageSize)
+	response, err := h.service.GetTransfers(q)
 	if err != nil {
+		if errors.Is(err, services.ErrInvalidTransferQuery) {
+			return queryParamError(c, err.Error())
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "INTERNAL_ERROR",
 			"message": err.Error(),
@@ -125,3 +394,10 @@ func (h *TransferHandler) GetTransfers(c *fiber.Ctx) error {
 
 	return c.JSON(response)
 }
+
+func queryParamError(c *fiber.Ctx, message string) error {
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": message,
+	})
+}
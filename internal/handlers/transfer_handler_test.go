@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/testutil"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// TestCreateTransferConcurrentStress ยิง POST /transfers พร้อมกันหลายร้อย request ระหว่าง user กลุ่มเล็ก
+// (ทุกระดับ จึงมีค่าธรรมเนียมเข้าบัญชีระบบด้วย) แล้วตรวจว่าแต้มรวมไม่เปลี่ยน ไม่มียอดติดลบ และ ledger ตรงกับ users.points
+func TestCreateTransferConcurrentStress(t *testing.T) {
+	const (
+		userCount = 20
+		requests  = 500
+	)
+
+	db := testutil.NewDB(t)
+	// เปิด limit รายวัน/รายเดือนให้กว้าง เหลือแค่แต้มไม่พอที่ทำให้ request ถูกปฏิเสธ
+	if _, err := db.Exec(`
+		UPDATE transfer_limits
+		SET daily_amount = 1000000, daily_count = 100000, monthly_amount = 10000000, monthly_count = 1000000`); err != nil {
+		t.Fatal(err)
+	}
+	service := newTestTransferService(t, db, "repeat_recipient")
+
+	app := fiber.New()
+	app.Post("/api/v1/transfers", NewTransferHandler(service).CreateTransfer)
+
+	levels := []string{"Gold", "Silver", "Bronze"}
+	users := make([]*models.User, userCount)
+	for i := range users {
+		// บางคนมีแต้มน้อยเพื่อให้แข่งกันจนแต้มไม่พอ
+		points := models.Points(50 * models.PointsScale)
+		if i%2 == 0 {
+			points = 3 * models.PointsScale
+		}
+		users[i] = testutil.NewUserAtLevel(t, db, levels[i%len(levels)], points)
+	}
+	initial := testutil.TotalPoints(t, db)
+
+	rng := rand.New(rand.NewSource(1))
+	bodies := make([][]byte, requests)
+	for i := range bodies {
+		from := rng.Intn(userCount)
+		to := (from + 1 + rng.Intn(userCount-1)) % userCount
+		amount := models.Points(1 + rng.Intn(int(2*models.PointsScale)))
+		bodies[i], _ = json.Marshal(map[string]interface{}{
+			"fromUserId": users[from].ID,
+			"toUserId":   users[to].ID,
+			"amount":     amount,
+		})
+	}
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		statuses = make(map[int]int)
+	)
+	start := make(chan struct{})
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(body []byte) {
+			defer wg.Done()
+			<-start
+			req := httptest.NewRequest("POST", "/api/v1/transfers", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req, -1)
+			if err != nil {
+				t.Errorf("request failed: %v", err)
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != fiber.StatusCreated {
+				var errBody struct {
+					Error string `json:"error"`
+				}
+				data, _ := io.ReadAll(resp.Body)
+				json.Unmarshal(data, &errBody)
+				if resp.StatusCode != fiber.StatusConflict || errBody.Error != "INSUFFICIENT_POINTS" {
+					t.Errorf("unexpected response %d: %s", resp.StatusCode, data)
+				}
+			}
+			mu.Lock()
+			statuses[resp.StatusCode]++
+			mu.Unlock()
+		}(bodies[i])
+	}
+	close(start)
+	wg.Wait()
+
+	if statuses[fiber.StatusCreated] == 0 {
+		t.Fatalf("no transfer succeeded: %v", statuses)
+	}
+	t.Logf("responses: %v", statuses)
+
+	if got := testutil.TotalPoints(t, db); got != initial {
+		t.Fatalf("total points changed from %s to %s", initial, got)
+	}
+
+	var negative int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users WHERE points < 0").Scan(&negative); err != nil {
+		t.Fatal(err)
+	}
+	if negative != 0 {
+		t.Fatalf("%d users have a negative balance", negative)
+	}
+
+	var completed int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transfers WHERE status = 'completed'").Scan(&completed); err != nil {
+		t.Fatal(err)
+	}
+	if completed != statuses[fiber.StatusCreated] {
+		t.Fatalf("%d completed transfers, %d responses were 201", completed, statuses[fiber.StatusCreated])
+	}
+
+	// ledger ของแต่ละ user (รวม entry แต้มตั้งต้น) ต้องรวมได้เท่ากับ users.points
+	for _, user := range users {
+		var points, ledger models.Points
+		if err := db.QueryRow(`
+			SELECT u.points, COALESCE((SELECT SUM(change) FROM point_ledger WHERE user_id = u.id), 0)
+			FROM users u WHERE u.id = ?`, user.ID).Scan(&points, &ledger); err != nil {
+			t.Fatal(err)
+		}
+		if ledger != points {
+			t.Errorf("user %d: ledger sums to %s but has %s", user.ID, ledger, points)
+		}
+	}
+}
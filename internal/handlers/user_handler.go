@@ -2,9 +2,11 @@ package handlers
 
 import (
 	"database/sql"
+	"errors"
 	"strconv"
 
 	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
 	"kbtg-backend/internal/services"
 
 	"github.com/gofiber/fiber/v2"
@@ -66,6 +68,38 @@ func (h *UserHandler) GetUser(c *fiber.Ctx) error {
 	})
 }
 
+// GET /users/:id/balance - Get points, held points and available points
+func (h *UserHandler) GetBalance(c *fiber.Ctx) error {
+	idParam := c.Params("id")
+	id, err := strconv.Atoi(idParam)
+	if err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "Invalid user ID",
+			"message": "User ID must be a number",
+		})
+	}
+
+	balance, err := h.service.GetBalance(id)
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to get balance",
+			"message": err.Error(),
+		})
+	}
+
+	if balance == nil {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "User not found",
+			"message": "User with the specified ID does not exist",
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   balance,
+	})
+}
+
 // POST /users - Create new user
 func (h *UserHandler) CreateUser(c *fiber.Ctx) error {
 	var req models.CreateUserRequest
@@ -112,6 +146,9 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 
 	user, err := h.service.UpdateUser(id, req)
 	if err != nil {
+		if errors.Is(err, repositories.ErrHouseAccount) {
+			return houseAccountError(c, err)
+		}
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error":   "Failed to update user",
 			"message": err.Error(),
@@ -132,6 +169,14 @@ func (h *UserHandler) UpdateUser(c *fiber.Ctx) error {
 	})
 }
 
+// houseAccountError ตอบ 403 เมื่อพยายามแก้หรือลบบัญชีระบบที่รับค่าธรรมเนียม
+func houseAccountError(c *fiber.Ctx, err error) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error":   "HOUSE_ACCOUNT",
+		"message": err.Error(),
+	})
+}
+
 // DELETE /users/:id - Delete user
 func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 	idParam := c.Params("id")
@@ -151,6 +196,15 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 				"message": "User with the specified ID does not exist",
 			})
 		}
+		if errors.Is(err, repositories.ErrHouseAccount) {
+			return houseAccountError(c, err)
+		}
+		if errors.Is(err, repositories.ErrUserHasHistory) {
+			return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+				"error":   "USER_HAS_HISTORY",
+				"message": err.Error(),
+			})
+		}
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error":   "Failed to delete user",
 			"message": err.Error(),
@@ -162,3 +216,44 @@ func (h *UserHandler) DeleteUser(c *fiber.Ctx) error {
 		"message": "User deleted successfully",
 	})
 }
+
+// GET /users/resolve?memberId=|phone=|email= - หาผู้รับและคืนชื่อที่ปิดบางส่วนไว้ให้ยืนยันก่อนโอน
+func (h *UserHandler) ResolveRecipient(c *fiber.Ctx) error {
+	var lookup models.RecipientLookup
+	if err := c.QueryParser(&lookup); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid query: " + err.Error(),
+		})
+	}
+
+	recipient, err := h.service.ResolveDisplay(lookup)
+	if err != nil {
+		if errors.Is(err, services.ErrRecipientNotFound) || errors.Is(err, services.ErrRecipientAmbiguous) {
+			return recipientError(c, err)
+		}
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(fiber.Map{
+		"status": "success",
+		"data":   recipient,
+	})
+}
+
+// recipientError ตอบ 404 เมื่อไม่พบผู้รับ และ 409 เมื่อข้อมูลตรงกับสมาชิกหลายคน
+func recipientError(c *fiber.Ctx, err error) error {
+	if errors.Is(err, services.ErrRecipientAmbiguous) {
+		return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+			"error":   "RECIPIENT_AMBIGUOUS",
+			"message": "The identifier matches more than one member; use the member ID instead",
+		})
+	}
+	return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+		"error":   "RECIPIENT_NOT_FOUND",
+		"message": "No member matches the given identifier",
+	})
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+
+	"kbtg-backend/internal/database"
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/services"
+	"kbtg-backend/internal/testutil"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func newTestUserApp(db *database.DB) *fiber.App {
+	handler := NewUserHandler(services.NewUserService(repositories.NewUserRepository(db.DB)))
+	app := fiber.New()
+	app.Put("/users/:id", handler.UpdateUser)
+	app.Delete("/users/:id", handler.DeleteUser)
+	return app
+}
+
+func houseUserID(t *testing.T, db *database.DB) int {
+	t.Helper()
+	id, err := repositories.NewFeeRepository(db.DB).GetHouseUserID()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func TestUpdateHouseAccountIsForbidden(t *testing.T) {
+	db := testutil.NewDB(t)
+	app := newTestUserApp(db)
+	houseID := houseUserID(t, db)
+	before := testutil.UserPoints(t, db, houseID)
+
+	req := httptest.NewRequest("PUT", fmt.Sprintf("/users/%d", houseID), bytes.NewReader([]byte(`{"points": 1000000}`)))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Fatalf("got status %d, want 403", resp.StatusCode)
+	}
+	if got := testutil.UserPoints(t, db, houseID); got != before {
+		t.Fatalf("house account points changed from %s to %s", before, got)
+	}
+
+	// user ทั่วไปยังแก้ได้ตามปกติ
+	user := testutil.NewUser(t, db, 0)
+	req = httptest.NewRequest("PUT", fmt.Sprintf("/users/%d", user.ID), bytes.NewReader([]byte(`{"points": 5}`)))
+	req.Header.Set("Content-Type", "application/json")
+	if resp, err = app.Test(req); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("updating a member got status %d, want 200", resp.StatusCode)
+	}
+	if got := testutil.UserPoints(t, db, user.ID); got != 5*models.PointsScale {
+		t.Fatalf("member has %s points, want 5.00", got)
+	}
+}
+
+func TestDeleteHouseAccountIsForbidden(t *testing.T) {
+	db := testutil.NewDB(t)
+	app := newTestUserApp(db)
+	houseID := houseUserID(t, db)
+
+	// บัญชี house ที่ยังไม่มี ledger ไม่มี foreign key กันการลบ
+	resp, err := app.Test(httptest.NewRequest("DELETE", fmt.Sprintf("/users/%d", houseID), nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Fatalf("got status %d, want 403", resp.StatusCode)
+	}
+	if id := houseUserID(t, db); id != houseID {
+		t.Fatalf("house account id changed from %d to %d", houseID, id)
+	}
+
+	user := testutil.NewUser(t, db, 0)
+	if resp, err = app.Test(httptest.NewRequest("DELETE", fmt.Sprintf("/users/%d", user.ID), nil)); err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("deleting a member got status %d, want 200", resp.StatusCode)
+	}
+}
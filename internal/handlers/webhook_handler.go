@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/services"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+type WebhookHandler struct {
+	service *services.WebhookService
+}
+
+func NewWebhookHandler(service *services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{service: service}
+}
+
+// GET /webhooks - subscription ทั้งหมด พร้อม event type ที่ subscribe ได้
+func (h *WebhookHandler) GetSubscriptions(c *fiber.Ctx) error {
+	response, err := h.service.GetSubscriptions()
+	if err != nil {
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "INTERNAL_ERROR",
+			"message": err.Error(),
+		})
+	}
+
+	return c.JSON(response)
+}
+
+// POST /webhooks - สร้าง subscription (secret แสดงเฉพาะใน response นี้)
+func (h *WebhookHandler) CreateSubscription(c *fiber.Ctx) error {
+	var req models.WebhookSubscriptionCreateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	sub, err := h.service.CreateSubscription(req)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.Status(fiber.StatusCreated).JSON(models.WebhookSubscriptionResponse{
+		Subscription: *sub,
+	})
+}
+
+// GET /webhooks/:id - ดู subscription
+func (h *WebhookHandler) GetSubscription(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return webhookError(c, services.ErrWebhookSubscriptionNotFound)
+	}
+
+	sub, err := h.service.GetSubscription(id)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.JSON(models.WebhookSubscriptionResponse{
+		Subscription: *sub,
+	})
+}
+
+// PUT /webhooks/:id - แก้ url, event type, คำอธิบาย หรือเปิด/ปิด subscription
+func (h *WebhookHandler) UpdateSubscription(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return webhookError(c, services.ErrWebhookSubscriptionNotFound)
+	}
+
+	var req models.WebhookSubscriptionUpdateRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+			"error":   "VALIDATION_ERROR",
+			"message": "Invalid request body: " + err.Error(),
+		})
+	}
+
+	sub, err := h.service.UpdateSubscription(id, req)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.JSON(models.WebhookSubscriptionResponse{
+		Subscription: *sub,
+	})
+}
+
+// DELETE /webhooks/:id - ลบ subscription พร้อม log การส่ง
+func (h *WebhookHandler) DeleteSubscription(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return webhookError(c, services.ErrWebhookSubscriptionNotFound)
+	}
+
+	if err := h.service.DeleteSubscription(id); err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"message": "Webhook subscription deleted successfully",
+	})
+}
+
+// POST /webhooks/:id/ping - ส่ง event webhook.ping เพื่อทดสอบ receiver
+func (h *WebhookHandler) Ping(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return webhookError(c, services.ErrWebhookSubscriptionNotFound)
+	}
+
+	if err := h.service.Ping(id); err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+		"message": "Ping event queued for delivery",
+	})
+}
+
+// GET /webhooks/:id/deliveries - log การส่งของ subscription ล่าสุดก่อน
+func (h *WebhookHandler) GetDeliveries(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return webhookError(c, services.ErrWebhookSubscriptionNotFound)
+	}
+
+	status := models.WebhookDeliveryStatus(c.Query("status"))
+	response, err := h.service.GetDeliveries(id, status, c.QueryInt("limit", 50))
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.JSON(response)
+}
+
+// POST /webhooks/:id/deliveries/:deliveryId/redeliver - ส่ง event ของ delivery เดิมอีกครั้ง
+func (h *WebhookHandler) Redeliver(c *fiber.Ctx) error {
+	id, err := strconv.Atoi(c.Params("id"))
+	if err != nil {
+		return webhookError(c, services.ErrWebhookSubscriptionNotFound)
+	}
+	deliveryID, err := strconv.Atoi(c.Params("deliveryId"))
+	if err != nil {
+		return webhookError(c, services.ErrWebhookDeliveryNotFound)
+	}
+
+	delivery, err := h.service.Redeliver(id, deliveryID)
+	if err != nil {
+		return webhookError(c, err)
+	}
+
+	return c.Status(fiber.StatusAccepted).JSON(models.WebhookDeliveryResponse{
+		Delivery: *delivery,
+	})
+}
+
+func webhookError(c *fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, services.ErrWebhookSubscriptionNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Webhook subscription not found",
+		})
+	case errors.Is(err, services.ErrWebhookDeliveryNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
+			"error":   "NOT_FOUND",
+			"message": "Webhook delivery not found",
+		})
+	}
+	return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
+		"error":   "VALIDATION_ERROR",
+		"message": err.Error(),
+	})
+}
@@ -0,0 +1,46 @@
+package models
+
+import "time"
+
+// BalanceDayLayout คือรูปแบบวันที่ของ balance history และ balance_snapshots (วันตามเวลาของ server)
+const BalanceDayLayout = "2006-01-02"
+
+// BalanceInterval คือช่วงของแต่ละจุดใน balance history (ตอนนี้มีแค่รายวัน)
+type BalanceInterval string
+
+const BalanceIntervalDay BalanceInterval = "day"
+
+// PointInTimeBalance คือแต้มของ user ณ เวลา At คำนวณจาก point_ledger
+// ไม่มี held/available เพราะ hold ไม่ได้อยู่ใน ledger
+type PointInTimeBalance struct {
+	UserID int       `json:"userId"`
+	At     time.Time `json:"at"`
+	Points Points    `json:"points"`
+}
+
+// BalanceHistoryQuery คือช่วงวัน [From, To] (รวมทั้งสองวัน) ในรูปแบบ BalanceDayLayout
+type BalanceHistoryQuery struct {
+	UserID   int
+	Interval BalanceInterval
+	From     string
+	To       string
+}
+
+// BalancePoint คือยอดปิดของหนึ่งวัน Change คือส่วนต่างจากยอดปิดของวันก่อนหน้า
+type BalancePoint struct {
+	Date           string `json:"date"`
+	ClosingBalance Points `json:"closingBalance"`
+	Change         Points `json:"change"`
+	// Partial = true สำหรับวันนี้ซึ่งยังไม่จบวัน ClosingBalance คือแต้ม ณ ตอนที่ขอ
+	Partial bool `json:"partial,omitempty"`
+}
+
+type BalanceHistoryResponse struct {
+	UserID   int             `json:"userId"`
+	Interval BalanceInterval `json:"interval"`
+	From     string          `json:"from"`
+	To       string          `json:"to"`
+	// OpeningBalance คือแต้มก่อนเริ่มวัน From
+	OpeningBalance Points         `json:"openingBalance"`
+	Data           []BalancePoint `json:"data"`
+}
@@ -0,0 +1,53 @@
+package models
+
+type BatchMode string
+
+const (
+	// BatchModeAllOrNothing ถ้า leg ใด leg หนึ่งไม่ผ่าน จะไม่มีการโอนเลย
+	BatchModeAllOrNothing BatchMode = "all_or_nothing"
+	// BatchModeBestEffort โอนเฉพาะ leg ที่ผ่าน ข้าม leg ที่ไม่ผ่าน
+	BatchModeBestEffort BatchMode = "best_effort"
+)
+
+type BatchLegStatus string
+
+const (
+	BatchLegStatusCompleted BatchLegStatus = "completed"
+	BatchLegStatusFailed    BatchLegStatus = "failed"
+	// BatchLegStatusSkipped ใช้ในโหมด all_or_nothing กับ leg ที่ผ่านแต่ไม่ถูกโอนเพราะ leg อื่นไม่ผ่าน
+	BatchLegStatusSkipped BatchLegStatus = "skipped"
+)
+
+type BatchTransferLeg struct {
+	ToUserID int     `json:"toUserId" validate:"required,min=1"`
+	Amount   Points  `json:"amount" validate:"required,min=0.01,max=2"`
+	Note     *string `json:"note,omitempty" validate:"omitempty,max=512"`
+}
+
+type BatchTransferRequest struct {
+	FromUserID int                `json:"fromUserId" validate:"required,min=1"`
+	Mode       BatchMode          `json:"mode" validate:"omitempty,oneof=all_or_nothing best_effort"`
+	Legs       []BatchTransferLeg `json:"legs" validate:"required,min=1,max=100"`
+}
+
+type BatchLegResult struct {
+	Index     int            `json:"index"`
+	ToUserID  int            `json:"toUserId"`
+	Amount    Points         `json:"amount"`
+	Status    BatchLegStatus `json:"status"`
+	Transfer  *Transfer      `json:"transfer,omitempty"`
+	ErrorCode string         `json:"error,omitempty"`
+	Message   string         `json:"message,omitempty"`
+}
+
+type BatchTransferResponse struct {
+	BatchID        string           `json:"batchId"`
+	Mode           BatchMode        `json:"mode"`
+	Status         string           `json:"status"`
+	Succeeded      int              `json:"succeeded"`
+	Failed         int              `json:"failed"`
+	TotalRequested Points           `json:"totalRequested"`
+	TotalFees      Points           `json:"totalFees"`
+	TotalDebited   Points           `json:"totalDebited"`
+	Legs           []BatchLegResult `json:"legs"`
+}
@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+type DisputeStatus string
+
+const (
+	// DisputeStatusOpen รอผู้รับตอบ
+	DisputeStatusOpen DisputeStatus = "open"
+	// DisputeStatusContested ผู้รับไม่ยอมคืน รอ admin ตัดสิน
+	DisputeStatusContested DisputeStatus = "contested"
+	// DisputeStatusAccepted ผู้รับยอมคืน transfer ถูก reverse แล้ว
+	DisputeStatusAccepted DisputeStatus = "accepted"
+	// DisputeStatusWithdrawn ผู้เปิดถอน dispute เอง
+	DisputeStatusWithdrawn DisputeStatus = "withdrawn"
+	// DisputeStatusResolved admin (หรือการ reverse โดยทีม support) ปิด dispute ดู Resolution
+	DisputeStatusResolved DisputeStatus = "resolved"
+)
+
+// DisputeResolution คือผลของ dispute ที่ปิดแล้ว
+type DisputeResolution string
+
+const (
+	DisputeResolutionReversed DisputeResolution = "reversed"
+	DisputeResolutionRejected DisputeResolution = "rejected"
+)
+
+// ผู้ที่ทำ action กับ dispute (บันทึกใน audit trail)
+const (
+	DisputeActorOpener       = "opener"
+	DisputeActorCounterparty = "counterparty"
+	DisputeActorAdmin        = "admin"
+	// DisputeActorSupport คือการ reverse transfer โดยตรงผ่าน POST /admin/transfers/:id/reverse
+	DisputeActorSupport = "support"
+)
+
+// action ใน audit trail ของ dispute
+const (
+	DisputeActionOpened    = "opened"
+	DisputeActionContested = "contested"
+	DisputeActionAccepted  = "accepted"
+	DisputeActionWithdrawn = "withdrawn"
+	DisputeActionResolved  = "resolved"
+)
+
+// TransferDispute คือคำร้องของผู้โอนว่า transfer ที่สำเร็จแล้วโอนผิด ขอแต้มคืนจากผู้รับ (counterparty)
+type TransferDispute struct {
+	ID             int                `json:"disputeId" db:"id"`
+	TransferID     int                `json:"transferId" db:"transfer_id"`
+	IdemKey        string             `json:"idemKey" db:"-"`
+	OpenedBy       int                `json:"openedBy" db:"opened_by"`
+	CounterpartyID int                `json:"counterpartyId" db:"counterparty_id"`
+	Amount         Points             `json:"amount" db:"-"`
+	Reason         string             `json:"reason" db:"reason"`
+	Status         DisputeStatus      `json:"status" db:"status"`
+	Resolution     *DisputeResolution `json:"resolution,omitempty" db:"resolution"`
+	ResponseNote   *string            `json:"responseNote,omitempty" db:"response_note"`
+	ResolutionNote *string            `json:"resolutionNote,omitempty" db:"resolution_note"`
+	ResolvedAt     *time.Time         `json:"resolvedAt,omitempty" db:"resolved_at"`
+	CreatedAt      time.Time          `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time          `json:"updatedAt" db:"updated_at"`
+}
+
+// TransferDisputeEvent คือหนึ่งรายการใน audit trail ของ dispute (เพิ่มอย่างเดียว ไม่แก้ไข)
+type TransferDisputeEvent struct {
+	ID          int            `json:"id" db:"id"`
+	DisputeID   int            `json:"disputeId" db:"dispute_id"`
+	Action      string         `json:"action" db:"action"`
+	Actor       string         `json:"actor" db:"actor"`
+	ActorUserID *int           `json:"actorUserId,omitempty" db:"actor_user_id"`
+	FromStatus  *DisputeStatus `json:"fromStatus,omitempty" db:"from_status"`
+	ToStatus    DisputeStatus  `json:"toStatus" db:"to_status"`
+	Note        *string        `json:"note,omitempty" db:"note"`
+	CreatedAt   time.Time      `json:"createdAt" db:"created_at"`
+}
+
+type DisputeCreateRequest struct {
+	UserID int    `json:"userId" validate:"required,min=1"`
+	Reason string `json:"reason" validate:"required,max=512"`
+}
+
+// DisputeRespondRequest ใช้ตอน accept, contest หรือ withdraw โดย userId ต้องเป็นคู่กรณีที่มีสิทธิ์ทำ action นั้น
+type DisputeRespondRequest struct {
+	UserID int     `json:"userId" validate:"required,min=1"`
+	Note   *string `json:"note,omitempty" validate:"omitempty,max=512"`
+}
+
+// DisputeResolveRequest ใช้ตอน admin ตัดสิน: outcome เป็น "reverse" (คืนแต้มให้ผู้โอน) หรือ "reject"
+type DisputeResolveRequest struct {
+	Outcome string  `json:"outcome" validate:"required,oneof=reverse reject"`
+	Note    *string `json:"note,omitempty" validate:"omitempty,max=512"`
+}
+
+type DisputeResponse struct {
+	Dispute  TransferDispute        `json:"dispute"`
+	Events   []TransferDisputeEvent `json:"events,omitempty"`
+	Transfer *Transfer              `json:"transfer,omitempty"`
+}
+
+type DisputeListResponse struct {
+	Data []TransferDispute `json:"data"`
+}
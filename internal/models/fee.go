@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+// HouseMemberID คือ member_id ของบัญชีระบบที่รับค่าธรรมเนียมการโอน
+const HouseMemberID = "HOUSE"
+
+// TransferFee คือค่าธรรมเนียมการโอนของ membership level หนึ่ง (ผู้โอนเป็นผู้จ่าย)
+// ค่าธรรมเนียม = flatFee + amount * percentFee / 100 ปัดเป็นทศนิยม 2 ตำแหน่ง
+type TransferFee struct {
+	MembershipLevel string    `json:"membershipLevel" db:"membership_level"`
+	FlatFee         Points    `json:"flatFee" db:"flat_fee"`
+	PercentFee      float64   `json:"percentFee" db:"percent_fee"`
+	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// TransferFeeUpdateRequest แก้เฉพาะ field ที่ส่งมา
+type TransferFeeUpdateRequest struct {
+	FlatFee    *Points  `json:"flatFee,omitempty" validate:"omitempty,gte=0"`
+	PercentFee *float64 `json:"percentFee,omitempty" validate:"omitempty,gte=0,lte=100"`
+}
+
+type TransferFeeListResponse struct {
+	Data []TransferFee `json:"data"`
+	// HouseUserID คือ user id ของบัญชีที่รับค่าธรรมเนียม ใช้ดูยอดผ่าน /users/:id/balance
+	HouseUserID int `json:"houseUserId"`
+}
+
+type TransferFeeResponse struct {
+	Fee TransferFee `json:"fee"`
+}
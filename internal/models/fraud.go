@@ -0,0 +1,112 @@
+package models
+
+import "time"
+
+// สัญญาณที่ FraudService ใช้ให้คะแนนการโอน
+const (
+	FraudSignalSenderVelocity   = "sender_velocity"
+	FraudSignalReceiverVelocity = "receiver_velocity"
+	FraudSignalFunnel           = "funnel"
+	FraudSignalRing             = "ring"
+	FraudSignalNewAccount       = "new_account"
+)
+
+// FraudSignal คือสัญญาณหนึ่งที่พบในการโอน พร้อมคะแนนที่ได้และรายละเอียดที่อ่านได้
+type FraudSignal struct {
+	Name   string `json:"name"`
+	Score  int    `json:"score"`
+	Detail string `json:"detail"`
+}
+
+type FraudDecision string
+
+const (
+	FraudDecisionAllow  FraudDecision = "allow"
+	FraudDecisionReview FraudDecision = "review"
+	FraudDecisionBlock  FraudDecision = "block"
+)
+
+// FraudAssessment คือผลการให้คะแนนการโอนหนึ่งรายการก่อน commit
+type FraudAssessment struct {
+	Score    int           `json:"score"`
+	Decision FraudDecision `json:"decision"`
+	Signals  []FraudSignal `json:"signals"`
+}
+
+// FraudSettings คือเกณฑ์ของการตรวจ fraud (มีแถวเดียว แก้ได้ผ่าน admin API)
+// ทุกสัญญาณนับจาก transfer ที่สร้างภายใน WindowSeconds ที่ผ่านมา
+type FraudSettings struct {
+	Enabled       bool `json:"enabled" db:"enabled"`
+	WindowSeconds int  `json:"windowSeconds" db:"window_seconds"`
+	// SenderVelocity และ ReceiverVelocity คือจำนวนการโอนออก/เข้าสูงสุดใน window ก่อนถูกนับเป็นสัญญาณ
+	SenderVelocity   int `json:"senderVelocity" db:"sender_velocity"`
+	ReceiverVelocity int `json:"receiverVelocity" db:"receiver_velocity"`
+	// FunnelSenders คือจำนวนผู้โอนที่ต่างกันเข้าบัญชีเดียว (รวมรายการนี้) ที่ถือว่าเป็นการรวมแต้ม
+	FunnelSenders int `json:"funnelSenders" db:"funnel_senders"`
+	// RingMaxHops คือความยาวสูงสุดของวง A→B→...→A ที่ตรวจ (วงต้องมีอย่างน้อย 3 คน)
+	RingMaxHops int `json:"ringMaxHops" db:"ring_max_hops"`
+	// บัญชีที่อายุไม่ถึง NewAccountDays และโอนออกถึง NewAccountTransfers ครั้งใน window (รวมรายการนี้)
+	NewAccountDays      int       `json:"newAccountDays" db:"new_account_days"`
+	NewAccountTransfers int       `json:"newAccountTransfers" db:"new_account_transfers"`
+	ReviewScore         int       `json:"reviewScore" db:"review_score"`
+	BlockScore          int       `json:"blockScore" db:"block_score"`
+	UpdatedAt           time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// FraudSettingsUpdateRequest แก้เฉพาะ field ที่ส่งมา
+type FraudSettingsUpdateRequest struct {
+	Enabled             *bool `json:"enabled,omitempty"`
+	WindowSeconds       *int  `json:"windowSeconds,omitempty" validate:"omitempty,min=60"`
+	SenderVelocity      *int  `json:"senderVelocity,omitempty" validate:"omitempty,min=1"`
+	ReceiverVelocity    *int  `json:"receiverVelocity,omitempty" validate:"omitempty,min=1"`
+	FunnelSenders       *int  `json:"funnelSenders,omitempty" validate:"omitempty,min=2"`
+	RingMaxHops         *int  `json:"ringMaxHops,omitempty" validate:"omitempty,min=3,max=6"`
+	NewAccountDays      *int  `json:"newAccountDays,omitempty" validate:"omitempty,min=0"`
+	NewAccountTransfers *int  `json:"newAccountTransfers,omitempty" validate:"omitempty,min=1"`
+	ReviewScore         *int  `json:"reviewScore,omitempty" validate:"omitempty,min=1,max=100"`
+	BlockScore          *int  `json:"blockScore,omitempty" validate:"omitempty,min=1,max=100"`
+}
+
+type FraudSettingsResponse struct {
+	Settings FraudSettings `json:"settings"`
+}
+
+type FraudReviewStatus string
+
+const (
+	FraudReviewPending  FraudReviewStatus = "pending"
+	FraudReviewApproved FraudReviewStatus = "approved"
+	FraudReviewRejected FraudReviewStatus = "rejected"
+	// FraudReviewClosed คือรายการที่ transfer ถูกยกเลิกหรือหมดอายุก่อน admin ตัดสิน
+	FraudReviewClosed FraudReviewStatus = "closed"
+)
+
+// FraudReview คือ transfer ที่ถูกพักไว้ (pending พร้อม hold แต้ม) รอ admin อนุมัติหรือปฏิเสธ
+type FraudReview struct {
+	ID           int               `json:"reviewId" db:"id"`
+	TransferID   int               `json:"transferId" db:"transfer_id"`
+	IdemKey      string            `json:"idemKey" db:"-"`
+	FromUserID   int               `json:"fromUserId" db:"-"`
+	ToUserID     int               `json:"toUserId" db:"-"`
+	Amount       Points            `json:"amount" db:"-"`
+	Score        int               `json:"score" db:"score"`
+	Signals      []FraudSignal     `json:"signals" db:"signals"`
+	Status       FraudReviewStatus `json:"status" db:"status"`
+	DecisionNote *string           `json:"decisionNote,omitempty" db:"decision_note"`
+	DecidedAt    *time.Time        `json:"decidedAt,omitempty" db:"decided_at"`
+	CreatedAt    time.Time         `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time         `json:"updatedAt" db:"updated_at"`
+}
+
+type FraudReviewDecisionRequest struct {
+	Note *string `json:"note,omitempty" validate:"omitempty,max=512"`
+}
+
+type FraudReviewResponse struct {
+	Review   FraudReview `json:"review"`
+	Transfer *Transfer   `json:"transfer,omitempty"`
+}
+
+type FraudReviewListResponse struct {
+	Data []FraudReview `json:"data"`
+}
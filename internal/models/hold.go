@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+type HoldStatus string
+
+const (
+	HoldStatusActive   HoldStatus = "active"
+	HoldStatusCaptured HoldStatus = "captured"
+	HoldStatusReleased HoldStatus = "released"
+	HoldStatusExpired  HoldStatus = "expired"
+)
+
+// PointHold คือแต้มที่ถูกกันไว้ (ยังไม่ถูกหักจริง) เช่น ระหว่างรอยืนยัน transfer แบบ two-phase
+// หรือ hold ของร้านค้าพันธมิตรตอน checkout (TransferID เป็น nil และมี MerchantID)
+type PointHold struct {
+	ID             int        `json:"holdId" db:"id"`
+	UserID         int        `json:"userId" db:"user_id"`
+	Amount         Points     `json:"amount" db:"amount"`
+	Status         HoldStatus `json:"status" db:"status"`
+	TransferID     *int       `json:"transferId,omitempty" db:"transfer_id"`
+	MerchantID     *string    `json:"merchantId,omitempty" db:"merchant_id"`
+	Reference      *string    `json:"reference,omitempty" db:"reference"`
+	CapturedAmount *Points    `json:"capturedAmount,omitempty" db:"captured_amount"`
+	ExpiresAt      time.Time  `json:"expiresAt" db:"expires_at"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// HoldCreateRequest ใช้ตอนร้านค้ากันแต้มของสมาชิกไว้ตอน checkout
+type HoldCreateRequest struct {
+	Amount           Points `json:"amount" validate:"required,min=0.01"`
+	MerchantID       string `json:"merchantId" validate:"required,max=64"`
+	Reference        string `json:"reference,omitempty" validate:"omitempty,max=128"`
+	ExpiresInMinutes *int   `json:"expiresInMinutes,omitempty" validate:"omitempty,min=1,max=10080"`
+}
+
+// HoldCaptureRequest ตัดแต้มจาก hold ถ้าไม่ระบุ amount จะตัดทั้งหมด ส่วนที่เหลือถูกปล่อยคืน
+type HoldCaptureRequest struct {
+	Amount *Points `json:"amount,omitempty" validate:"omitempty,min=0.01"`
+}
+
+type HoldResponse struct {
+	Hold        PointHold    `json:"hold"`
+	LedgerEntry *PointLedger `json:"ledgerEntry,omitempty"`
+}
+
+type HoldListResponse struct {
+	Data []PointHold `json:"data"`
+}
+
+// UserBalance แยกแต้มทั้งหมด แต้มที่ถูก hold และแต้มที่ใช้ได้จริง
+type UserBalance struct {
+	UserID    int    `json:"userId"`
+	Points    Points `json:"points"`
+	Held      Points `json:"held"`
+	Available Points `json:"available"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// LedgerSort คือลำดับของ ledger entry เรียงตาม id ซึ่งเป็นลำดับที่เขียนจริง
+type LedgerSort string
+
+const (
+	LedgerSortNewest LedgerSort = "created_desc"
+	LedgerSortOldest LedgerSort = "created_asc"
+)
+
+// LedgerQuery คือเงื่อนไขค้น ledger ของ user ช่วงเวลาเป็น [CreatedFrom, CreatedTo)
+type LedgerQuery struct {
+	UserID      int
+	EventTypes  []EventType
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	Sort        LedgerSort
+	PageSize    int
+	Cursor      string
+}
+
+// LedgerCursor คือ entry ตัวสุดท้ายในหน้า ใช้หาหน้าถัดไปแบบ keyset
+type LedgerCursor struct {
+	Sort LedgerSort `json:"s"`
+	ID   int        `json:"i"`
+}
+
+// LedgerSummary คือยอดของ user ในช่วงที่เลือก (ทุก event type ไม่ขึ้นกับ filter eventType)
+// OpeningBalance คือแต้มก่อนเริ่มช่วง ClosingBalance คือแต้มตอนจบช่วง (หรือปัจจุบันถ้าไม่ระบุ createdTo)
+type LedgerSummary struct {
+	From           *time.Time `json:"from,omitempty"`
+	To             *time.Time `json:"to,omitempty"`
+	OpeningBalance Points     `json:"openingBalance"`
+	ClosingBalance Points     `json:"closingBalance"`
+	Credits        Points     `json:"credits"`
+	Debits         Points     `json:"debits"`
+	EntryCount     int        `json:"entryCount"`
+}
+
+type LedgerListResponse struct {
+	Data     []PointLedger `json:"data"`
+	Summary  LedgerSummary `json:"summary"`
+	PageSize int           `json:"pageSize"`
+	// NextCursor ใช้ขอหน้าถัดไป ไม่มีถ้าเป็นหน้าสุดท้าย
+	NextCursor string `json:"nextCursor,omitempty"`
+}
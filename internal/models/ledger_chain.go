@@ -0,0 +1,109 @@
+package models
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// LedgerGenesisHash คือ PrevHash ของ entry แรกใน chain
+var LedgerGenesisHash = strings.Repeat("0", 64)
+
+// ComputeHash คืน SHA-256 (hex) ของ JSON array แบบไม่มีช่องว่าง
+// [prevHash, userId, change, balanceAfter, eventType, transferId, reference, metadata, createdAt]
+// โดย change/balanceAfter เป็นหน่วย 1/100 แต้ม และ createdAt เป็น RFC3339 (nanosecond) ใน UTC
+// ไม่รวม id เพราะลำดับของ entry ถูกผูกไว้ด้วย PrevHash อยู่แล้ว
+func (e *PointLedger) ComputeHash() string {
+	// ไม่ escape <, > และ & เพื่อให้ auditor คำนวณซ้ำด้วย JSON encoder ทั่วไปได้
+	var content bytes.Buffer
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	_ = encoder.Encode([]any{
+		e.PrevHash,
+		e.UserID,
+		int64(e.Change),
+		int64(e.BalanceAfter),
+		e.EventType,
+		e.TransferID,
+		e.Reference,
+		e.Metadata,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+	})
+	sum := sha256.Sum256(bytes.TrimSuffix(content.Bytes(), []byte("\n")))
+	return hex.EncodeToString(sum[:])
+}
+
+// LedgerCheckpoint คือ hash ของ entry ล่าสุดในเวลาหนึ่งที่ถูก sign ด้วย Ed25519
+// auditor เก็บ checkpoint ไว้นอก database เพื่อตรวจภายหลังว่า entry จนถึง LedgerEntryID ไม่ถูกแก้
+type LedgerCheckpoint struct {
+	ID            int       `json:"id"`
+	LedgerEntryID int       `json:"ledgerEntryId"`
+	EntryCount    int       `json:"entryCount"`
+	Hash          string    `json:"hash"`
+	KeyID         string    `json:"keyId"`
+	PublicKey     string    `json:"publicKey"`
+	Signature     string    `json:"signature"`
+	CreatedAt     time.Time `json:"createdAt"`
+}
+
+// SigningPayload คือข้อความที่ถูก sign แต่ละบรรทัดคั่นด้วย \n:
+// kbtg-ledger-checkpoint/v1, ledgerEntryId, entryCount, hash, createdAt (RFC3339 nanosecond, UTC)
+func (c *LedgerCheckpoint) SigningPayload() []byte {
+	return []byte(fmt.Sprintf("kbtg-ledger-checkpoint/v1\n%d\n%d\n%s\n%s",
+		c.LedgerEntryID, c.EntryCount, c.Hash, c.CreatedAt.UTC().Format(time.RFC3339Nano)))
+}
+
+// LedgerBreakReason คือสาเหตุที่ chain ขาด
+type LedgerBreakReason string
+
+const (
+	// LedgerBreakPrevHash: PrevHash ไม่ใช่ Hash ของ entry ก่อนหน้า (มี entry ถูกลบหรือแทรก)
+	LedgerBreakPrevHash LedgerBreakReason = "prev_hash_mismatch"
+	// LedgerBreakHash: Hash ไม่ตรงกับเนื้อหาของ entry (entry ถูกแก้)
+	LedgerBreakHash LedgerBreakReason = "hash_mismatch"
+	// LedgerBreakCheckpoint: entry ไม่ตรงกับ hash หรือจำนวน entry ใน checkpoint (chain ถูกคำนวณใหม่ทั้งเส้น)
+	LedgerBreakCheckpoint LedgerBreakReason = "checkpoint_mismatch"
+	// LedgerBreakCheckpointMissing: ไม่มี entry ที่ checkpoint อ้างถึง (entry ท้าย chain ถูกลบ)
+	LedgerBreakCheckpointMissing LedgerBreakReason = "checkpoint_entry_missing"
+	// LedgerBreakSignature: signature ของ checkpoint ไม่ถูกต้องสำหรับ key ที่ตั้งไว้
+	LedgerBreakSignature LedgerBreakReason = "checkpoint_signature_invalid"
+)
+
+// LedgerBrokenLink คือจุดแรกที่ chain ขาด Expected คือค่าที่คำนวณได้ Actual คือค่าที่เก็บไว้
+type LedgerBrokenLink struct {
+	LedgerEntryID int               `json:"ledgerEntryId"`
+	CheckpointID  *int              `json:"checkpointId,omitempty"`
+	Reason        LedgerBreakReason `json:"reason"`
+	Expected      string            `json:"expected,omitempty"`
+	Actual        string            `json:"actual,omitempty"`
+	Message       string            `json:"message"`
+}
+
+// LedgerVerification คือผลการไล่ตรวจ hash chain ตั้งแต่ entry แรก
+type LedgerVerification struct {
+	Valid              bool   `json:"valid"`
+	EntriesChecked     int    `json:"entriesChecked"`
+	LastEntryID        int    `json:"lastEntryId"`
+	LastHash           string `json:"lastHash"`
+	CheckpointsChecked int    `json:"checkpointsChecked"`
+	// SignaturesVerified = false เมื่อไม่ได้ตั้ง signing key จึงตรวจเฉพาะ hash ของ checkpoint
+	SignaturesVerified bool              `json:"signaturesVerified"`
+	FirstBrokenLink    *LedgerBrokenLink `json:"firstBrokenLink,omitempty"`
+	VerifiedAt         time.Time         `json:"verifiedAt"`
+}
+
+type LedgerVerificationResponse struct {
+	Verification LedgerVerification `json:"verification"`
+}
+
+type LedgerCheckpointResponse struct {
+	Checkpoint LedgerCheckpoint `json:"checkpoint"`
+}
+
+type LedgerCheckpointListResponse struct {
+	Data []LedgerCheckpoint `json:"data"`
+}
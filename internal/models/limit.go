@@ -0,0 +1,48 @@
+package models
+
+import "time"
+
+// TransferLimit คือเพดานการโอนของ membership level หนึ่ง (นับเฉพาะฝั่งผู้โอน)
+type TransferLimit struct {
+	MembershipLevel string    `json:"membershipLevel" db:"membership_level"`
+	PerTransaction  Points    `json:"perTransaction" db:"per_transaction"`
+	DailyAmount     Points    `json:"dailyAmount" db:"daily_amount"`
+	DailyCount      int       `json:"dailyCount" db:"daily_count"`
+	MonthlyAmount   Points    `json:"monthlyAmount" db:"monthly_amount"`
+	MonthlyCount    int       `json:"monthlyCount" db:"monthly_count"`
+	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// TransferLimitUpdateRequest แก้เฉพาะ field ที่ส่งมา
+type TransferLimitUpdateRequest struct {
+	PerTransaction *Points `json:"perTransaction,omitempty" validate:"omitempty,gt=0"`
+	DailyAmount    *Points `json:"dailyAmount,omitempty" validate:"omitempty,gt=0"`
+	DailyCount     *int    `json:"dailyCount,omitempty" validate:"omitempty,min=1"`
+	MonthlyAmount  *Points `json:"monthlyAmount,omitempty" validate:"omitempty,gt=0"`
+	MonthlyCount   *int    `json:"monthlyCount,omitempty" validate:"omitempty,min=1"`
+}
+
+// LimitUsage คือยอดที่ user โอนออกไปแล้วในช่วงเวลาหนึ่ง และส่วนที่ยังโอนได้
+type LimitUsage struct {
+	Since           time.Time `json:"since"`
+	UsedAmount      Points    `json:"usedAmount"`
+	UsedCount       int       `json:"usedCount"`
+	RemainingAmount Points    `json:"remainingAmount"`
+	RemainingCount  int       `json:"remainingCount"`
+}
+
+type UserLimitsResponse struct {
+	UserID          int           `json:"userId"`
+	MembershipLevel string        `json:"membershipLevel"`
+	Limits          TransferLimit `json:"limits"`
+	Daily           LimitUsage    `json:"daily"`
+	Monthly         LimitUsage    `json:"monthly"`
+}
+
+type TransferLimitListResponse struct {
+	Data []TransferLimit `json:"data"`
+}
+
+type TransferLimitResponse struct {
+	Limit TransferLimit `json:"limit"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+type MandateStatus string
+
+const (
+	MandateStatusActive    MandateStatus = "active"
+	MandateStatusPaused    MandateStatus = "paused"
+	MandateStatusCancelled MandateStatus = "cancelled"
+	MandateStatusCompleted MandateStatus = "completed"
+)
+
+// Mandate คือคำสั่งโอนประจำ (standing order) ที่สร้าง transfer จริงตามตารางเวลา
+type Mandate struct {
+	ID                  int           `json:"mandateId" db:"id"`
+	FromUserID          int           `json:"fromUserId" db:"from_user_id"`
+	ToUserID            int           `json:"toUserId" db:"to_user_id"`
+	Amount              Points        `json:"amount" db:"amount"`
+	Note                *string       `json:"note,omitempty" db:"note"`
+	Cron                *string       `json:"cron,omitempty" db:"cron_expr"`
+	IntervalSeconds     *int          `json:"intervalSeconds,omitempty" db:"interval_seconds"`
+	StartAt             time.Time     `json:"startAt" db:"start_at"`
+	EndAt               *time.Time    `json:"endAt,omitempty" db:"end_at"`
+	MaxOccurrences      *int          `json:"maxOccurrences,omitempty" db:"max_occurrences"`
+	Occurrences         int           `json:"occurrences" db:"occurrences"`
+	ConsecutiveFailures int           `json:"consecutiveFailures" db:"consecutive_failures"`
+	Status              MandateStatus `json:"status" db:"status"`
+	NextRunAt           *time.Time    `json:"nextRunAt,omitempty" db:"next_run_at"`
+	LastRunAt           *time.Time    `json:"lastRunAt,omitempty" db:"last_run_at"`
+	LastError           *string       `json:"lastError,omitempty" db:"last_error"`
+	CreatedAt           time.Time     `json:"createdAt" db:"created_at"`
+	UpdatedAt           time.Time     `json:"updatedAt" db:"updated_at"`
+}
+
+// MandateCreateRequest ต้องระบุ cron หรือ intervalSeconds อย่างใดอย่างหนึ่ง
+type MandateCreateRequest struct {
+	ToUserID        int        `json:"toUserId" validate:"required,min=1"`
+	Amount          Points     `json:"amount" validate:"required,min=0.01,max=2"`
+	Note            *string    `json:"note,omitempty" validate:"omitempty,max=512"`
+	Cron            *string    `json:"cron,omitempty"`
+	IntervalSeconds *int       `json:"intervalSeconds,omitempty" validate:"omitempty,min=3600"`
+	StartAt         *time.Time `json:"startAt,omitempty"`
+	EndAt           *time.Time `json:"endAt,omitempty"`
+	MaxOccurrences  *int       `json:"maxOccurrences,omitempty" validate:"omitempty,min=1"`
+}
+
+type MandateUpdateRequest struct {
+	Amount         *Points        `json:"amount,omitempty" validate:"omitempty,min=0.01,max=2"`
+	Note           *string        `json:"note,omitempty" validate:"omitempty,max=512"`
+	EndAt          *time.Time     `json:"endAt,omitempty"`
+	MaxOccurrences *int           `json:"maxOccurrences,omitempty" validate:"omitempty,min=1"`
+	Status         *MandateStatus `json:"status,omitempty" validate:"omitempty,oneof=active paused"`
+}
+
+type MandateResponse struct {
+	Mandate Mandate `json:"mandate"`
+}
+
+type MandateListResponse struct {
+	Data []Mandate `json:"data"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+type PointRequestStatus string
+
+const (
+	PointRequestStatusPending  PointRequestStatus = "pending"
+	PointRequestStatusApproved PointRequestStatus = "approved"
+	PointRequestStatusDeclined PointRequestStatus = "declined"
+	PointRequestStatusExpired  PointRequestStatus = "expired"
+)
+
+// PointRequest คือคำขอแต้ม (pull payment): requester ขอแต้มจาก payer และ payer เป็นคนอนุมัติ
+type PointRequest struct {
+	ID            int                `json:"requestId" db:"id"`
+	RequesterID   int                `json:"requesterId" db:"requester_id"`
+	PayerID       int                `json:"payerId" db:"payer_id"`
+	Amount        Points             `json:"amount" db:"amount"`
+	Note          *string            `json:"note,omitempty" db:"note"`
+	Status        PointRequestStatus `json:"status" db:"status"`
+	TransferID    *int               `json:"transferId,omitempty" db:"transfer_id"`
+	DeclineReason *string            `json:"declineReason,omitempty" db:"decline_reason"`
+	ExpiresAt     time.Time          `json:"expiresAt" db:"expires_at"`
+	RespondedAt   *time.Time         `json:"respondedAt,omitempty" db:"responded_at"`
+	CreatedAt     time.Time          `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time          `json:"updatedAt" db:"updated_at"`
+}
+
+type PointRequestCreateRequest struct {
+	RequesterID    int     `json:"requesterId" validate:"required,min=1"`
+	PayerID        int     `json:"payerId" validate:"required,min=1"`
+	Amount         Points  `json:"amount" validate:"required,min=0.01,max=2"`
+	Note           *string `json:"note,omitempty" validate:"omitempty,max=512"`
+	ExpiresInHours *int    `json:"expiresInHours,omitempty" validate:"omitempty,min=1,max=720"`
+}
+
+// PointRequestRespondRequest ใช้ตอน approve หรือ decline โดย payerId ต้องตรงกับผู้ถูกขอ
+type PointRequestRespondRequest struct {
+	PayerID int     `json:"payerId" validate:"required,min=1"`
+	Reason  *string `json:"reason,omitempty" validate:"omitempty,max=512"`
+}
+
+type PointRequestResponse struct {
+	PointRequest PointRequest `json:"pointRequest"`
+	Transfer     *Transfer    `json:"transfer,omitempty"`
+}
+
+type PointRequestListResponse struct {
+	Data []PointRequest `json:"data"`
+}
@@ -0,0 +1,114 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Points คือจำนวนแต้มแบบ fixed-point เก็บเป็นจำนวนเต็มในหน่วย 1/100 แต้ม (12.50 แต้ม = 1250)
+// ใช้ทั้งใน model, database (column INTEGER) และ JSON แทน float64 เพื่อให้บวกลบได้ตรงโดยไม่ต้องปัดเศษ
+// ใน JSON ยังเป็นตัวเลขทศนิยมเหมือนเดิม (เช่น 12.5) และรับทศนิยมได้ไม่เกิน 2 ตำแหน่ง
+type Points int64
+
+// PointsScale คือจำนวนหน่วยย่อยใน 1 แต้ม
+const PointsScale Points = 100
+
+var (
+	ErrPointsPrecision = errors.New("points can have at most 2 decimal places")
+	ErrPointsRange     = errors.New("points value is out of range")
+)
+
+// ParsePoints แปลงตัวเลขทศนิยม (เช่น "12.5", "1e2") เป็น Points แบบไม่ปัดเศษ
+// ตัวเลขที่มีทศนิยมเกิน 2 ตำแหน่งคืน ErrPointsPrecision
+func ParsePoints(s string) (Points, error) {
+	r, ok := new(big.Rat).SetString(strings.TrimSpace(s))
+	if !ok {
+		return 0, fmt.Errorf("invalid points value %q", s)
+	}
+	r.Mul(r, big.NewRat(int64(PointsScale), 1))
+	if !r.IsInt() {
+		return 0, ErrPointsPrecision
+	}
+	if !r.Num().IsInt64() {
+		return 0, ErrPointsRange
+	}
+	return Points(r.Num().Int64()), nil
+}
+
+// Float64 คืนค่าเป็นหน่วยแต้ม ใช้เฉพาะที่ต้องการตัวเลขทั่วไป เช่น expression ของ transfer rule
+func (p Points) Float64() float64 {
+	return float64(p) / float64(PointsScale)
+}
+
+// String คืนค่าแบบทศนิยม 2 ตำแหน่งเสมอ (เช่น "12.50") ใช้ในข้อความ error
+func (p Points) String() string {
+	sign := ""
+	u := uint64(p)
+	if p < 0 {
+		sign, u = "-", uint64(-p)
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, u/uint64(PointsScale), u%uint64(PointsScale))
+}
+
+// MarshalJSON เขียนเป็นตัวเลขที่ตัดศูนย์ท้ายออก (12.50 -> 12.5, 12.00 -> 12) ให้หน้าตาเหมือน float64 เดิม
+func (p Points) MarshalJSON() ([]byte, error) {
+	s := p.String()
+	s = strings.TrimRight(s, "0")
+	s = strings.TrimSuffix(s, ".")
+	return []byte(s), nil
+}
+
+func (p *Points) UnmarshalJSON(data []byte) error {
+	s := string(data)
+	if s == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(s); err == nil {
+		s = unquoted
+	}
+	v, err := ParsePoints(s)
+	if err != nil {
+		return err
+	}
+	*p = v
+	return nil
+}
+
+// Scan อ่านค่าจาก column INTEGER (หน่วย 1/100 แต้ม)
+func (p *Points) Scan(src interface{}) error {
+	switch v := src.(type) {
+	case nil:
+		*p = 0
+	case int64:
+		*p = Points(v)
+	case float64:
+		// SQLite อาจคืนจำนวนเต็มเป็น REAL ได้ (เช่นผลของ expression) แต่ค่าที่มีเศษแปลว่าข้อมูลยังไม่ถูก migrate
+		if v != float64(int64(v)) {
+			return fmt.Errorf("points column holds fractional value %v", v)
+		}
+		*p = Points(v)
+	case []byte:
+		n, err := strconv.ParseInt(string(v), 10, 64)
+		if err != nil {
+			return err
+		}
+		*p = Points(n)
+	case string:
+		n, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			return err
+		}
+		*p = Points(n)
+	default:
+		return fmt.Errorf("cannot scan %T into Points", src)
+	}
+	return nil
+}
+
+func (p Points) Value() (driver.Value, error) {
+	return int64(p), nil
+}
@@ -0,0 +1,15 @@
+package models
+
+// RecipientLookup ระบุผู้รับด้วยข้อมูลที่สมาชิกรู้ แทน user id ภายใน (ใช้ได้อย่างใดอย่างหนึ่ง)
+type RecipientLookup struct {
+	MemberID string `json:"memberId,omitempty" query:"memberId"`
+	Phone    string `json:"phone,omitempty" query:"phone"`
+	Email    string `json:"email,omitempty" query:"email"`
+}
+
+// ResolvedRecipient คือผู้รับที่หาเจอ พร้อมชื่อที่ปิดบางส่วนไว้ให้ผู้โอนยืนยันก่อนส่ง
+type ResolvedRecipient struct {
+	MemberID    string `json:"memberId"`
+	DisplayName string `json:"displayName"`
+	MatchedBy   string `json:"matchedBy"`
+}
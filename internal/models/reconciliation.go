@@ -0,0 +1,69 @@
+package models
+
+import "time"
+
+// ReconciliationTrigger คือที่มาของการรัน reconciliation
+type ReconciliationTrigger string
+
+const (
+	ReconciliationTriggerScheduled ReconciliationTrigger = "scheduled"
+	ReconciliationTriggerManual    ReconciliationTrigger = "manual"
+	ReconciliationTriggerCLI       ReconciliationTrigger = "cli"
+)
+
+// DiscrepancyKind คือประเภทของความไม่ตรงกันระหว่าง users.points, point_ledger และ transfers
+type DiscrepancyKind string
+
+const (
+	// DiscrepancyBalanceMismatch: users.points ไม่เท่ากับยอดตาม ledger (ยอดยกมา + ผลรวม change)
+	DiscrepancyBalanceMismatch DiscrepancyKind = "balance_mismatch"
+	// DiscrepancyUnledgeredBalance: user มีแต้มแต่ไม่มี ledger entry เลย
+	DiscrepancyUnledgeredBalance DiscrepancyKind = "unledgered_balance"
+	// DiscrepancyChainBreak: balance_after ของ entry ไม่เท่ากับ balance_after ของ entry ก่อนหน้า + change
+	DiscrepancyChainBreak DiscrepancyKind = "chain_break"
+	// DiscrepancyTransferPair: transfer ไม่มีคู่ transfer_out/transfer_in ที่ตรงกับสถานะพอดีหนึ่งคู่
+	DiscrepancyTransferPair DiscrepancyKind = "transfer_pair"
+)
+
+// ReconciliationDiscrepancy คือความไม่ตรงกันหนึ่งรายการ Expected คือค่าตาม ledger หรือ transfer ส่วน Actual คือค่าที่พบ
+type ReconciliationDiscrepancy struct {
+	Kind          DiscrepancyKind `json:"kind"`
+	UserID        *int            `json:"userId,omitempty"`
+	LedgerEntryID *int            `json:"ledgerEntryId,omitempty"`
+	TransferID    *int            `json:"transferId,omitempty"`
+	Expected      *Points         `json:"expected,omitempty"`
+	Actual        *Points         `json:"actual,omitempty"`
+	Message       string          `json:"message"`
+	// Repaired = true เมื่อ repair mode เขียน adjust entry ให้ ledger ตรงกับ users.points แล้ว
+	Repaired          bool   `json:"repaired,omitempty"`
+	AdjustmentEntryID *int   `json:"adjustmentEntryId,omitempty"`
+	RepairError       string `json:"repairError,omitempty"`
+}
+
+// ReconciliationReport คือผลของการรัน reconciliation หนึ่งครั้ง
+type ReconciliationReport struct {
+	ID               int                         `json:"id"`
+	Trigger          ReconciliationTrigger       `json:"trigger"`
+	Repair           bool                        `json:"repair"`
+	UsersChecked     int                         `json:"usersChecked"`
+	EntriesChecked   int                         `json:"entriesChecked"`
+	TransfersChecked int                         `json:"transfersChecked"`
+	DiscrepancyCount int                         `json:"discrepancyCount"`
+	RepairedCount    int                         `json:"repairedCount"`
+	Discrepancies    []ReconciliationDiscrepancy `json:"discrepancies"`
+	StartedAt        time.Time                   `json:"startedAt"`
+	FinishedAt       time.Time                   `json:"finishedAt"`
+}
+
+type ReconciliationRunRequest struct {
+	// Repair = true เขียน adjust entry ให้ user ที่ยอดไม่ตรง โดยยึด users.points เป็นยอดจริง
+	Repair bool `json:"repair"`
+}
+
+type ReconciliationReportResponse struct {
+	Report ReconciliationReport `json:"report"`
+}
+
+type ReconciliationReportListResponse struct {
+	Data []ReconciliationReport `json:"data"`
+}
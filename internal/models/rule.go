@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+type RuleMode string
+
+const (
+	// RuleModeEnforce ปฏิเสธการโอนเมื่อ rule ตรง
+	RuleModeEnforce RuleMode = "enforce"
+	// RuleModeShadow แค่ log ว่า rule จะปฏิเสธ ใช้ลอง rule ใหม่ก่อนเปิดใช้จริง
+	RuleModeShadow RuleMode = "shadow"
+)
+
+// TransferRule คือกฎการโอนที่ตั้งค่าได้ ถ้า expression เป็นจริง การโอนจะถูกปฏิเสธด้วย errorCode และ message
+type TransferRule struct {
+	Name        string    `json:"name" db:"name"`
+	Position    int       `json:"position" db:"position"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	Expression  string    `json:"expression" db:"expression"`
+	ErrorCode   string    `json:"errorCode" db:"error_code"`
+	Message     string    `json:"message" db:"message"`
+	Enabled     bool      `json:"enabled" db:"enabled"`
+	Mode        RuleMode  `json:"mode" db:"mode"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type TransferRuleCreateRequest struct {
+	Name        string   `json:"name" validate:"required"`
+	Position    int      `json:"position"`
+	Description *string  `json:"description,omitempty"`
+	Expression  string   `json:"expression" validate:"required"`
+	ErrorCode   string   `json:"errorCode" validate:"required"`
+	Message     string   `json:"message" validate:"required"`
+	Enabled     *bool    `json:"enabled,omitempty"`
+	Mode        RuleMode `json:"mode,omitempty" validate:"omitempty,oneof=enforce shadow"`
+}
+
+// TransferRuleUpdateRequest แก้เฉพาะ field ที่ส่งมา
+type TransferRuleUpdateRequest struct {
+	Position    *int      `json:"position,omitempty"`
+	Description *string   `json:"description,omitempty"`
+	Expression  *string   `json:"expression,omitempty"`
+	ErrorCode   *string   `json:"errorCode,omitempty"`
+	Message     *string   `json:"message,omitempty"`
+	Enabled     *bool     `json:"enabled,omitempty"`
+	Mode        *RuleMode `json:"mode,omitempty" validate:"omitempty,oneof=enforce shadow"`
+}
+
+type TransferRuleResponse struct {
+	Rule TransferRule `json:"rule"`
+}
+
+type TransferRuleListResponse struct {
+	Data      []TransferRule    `json:"data"`
+	Variables map[string]string `json:"variables"`
+}
@@ -14,24 +14,76 @@ const (
 )
 
 type Transfer struct {
-	ID          int            `json:"transferId,omitempty" db:"id"`
-	IdemKey     string         `json:"idemKey" db:"idempotency_key"`
-	FromUserID  int            `json:"fromUserId" db:"from_user_id"`
-	ToUserID    int            `json:"toUserId" db:"to_user_id"`
-	Amount      float64        `json:"amount" db:"amount"`
-	Status      TransferStatus `json:"status" db:"status"`
-	Note        *string        `json:"note,omitempty" db:"note"`
-	CreatedAt   time.Time      `json:"createdAt" db:"created_at"`
-	UpdatedAt   time.Time      `json:"updated_at" db:"updated_at"`
-	CompletedAt *time.Time     `json:"completedAt,omitempty" db:"completed_at"`
-	FailReason  *string        `json:"failReason,omitempty" db:"fail_reason"`
+	ID             int            `json:"transferId,omitempty" db:"id"`
+	IdemKey        string         `json:"idemKey" db:"idempotency_key"`
+	FromUserID     int            `json:"fromUserId" db:"from_user_id"`
+	ToUserID       int            `json:"toUserId" db:"to_user_id"`
+	Amount         Points         `json:"amount" db:"amount"`
+	Fee            Points         `json:"fee" db:"fee"`
+	TotalDebited   Points         `json:"totalDebited" db:"-"`
+	Status         TransferStatus `json:"status" db:"status"`
+	Note           *string        `json:"note,omitempty" db:"note"`
+	CreatedAt      time.Time      `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at" db:"updated_at"`
+	CompletedAt    *time.Time     `json:"completedAt,omitempty" db:"completed_at"`
+	FailReason     *string        `json:"failReason,omitempty" db:"fail_reason"`
+	FailCode       *string        `json:"failCode,omitempty" db:"fail_code"`
+	FailRule       *string        `json:"failRule,omitempty" db:"fail_rule"`
+	RequestHash    *string        `json:"-" db:"request_hash"`
+	ReversedAt     *time.Time     `json:"reversedAt,omitempty" db:"reversed_at"`
+	ReversalReason *string        `json:"reversalReason,omitempty" db:"reversal_reason"`
+	ExpiresAt      *time.Time     `json:"expiresAt,omitempty" db:"expires_at"`
+	ScheduledAt    *time.Time     `json:"scheduledAt,omitempty" db:"scheduled_at"`
+	MandateID      *int           `json:"mandateId,omitempty" db:"mandate_id"`
+	BatchID        *string        `json:"batchId,omitempty" db:"batch_id"`
+	// ClientKey คือ Idempotency-Key ที่ผู้โอนส่งมา (unique ต่อผู้โอน) เป็น nil เมื่อพ้นช่วง replay แล้ว
+	ClientKey *string `json:"clientKey,omitempty" db:"client_key"`
+	// UnderReview = true เมื่อ transfer ถูกพักไว้รอตรวจ fraud (สถานะ pending จนกว่า admin จะตัดสิน)
+	UnderReview bool `json:"underReview,omitempty" db:"-"`
+	// DisputeID และ DisputeStatus คือ dispute ล่าสุดของ transfer (ถ้ามี)
+	DisputeID     *int           `json:"disputeId,omitempty" db:"-"`
+	DisputeStatus *DisputeStatus `json:"disputeStatus,omitempty" db:"-"`
 }
 
 type TransferCreateRequest struct {
 	FromUserID int     `json:"fromUserId" validate:"required,min=1"`
-	ToUserID   int     `json:"toUserId" validate:"required,min=1"`
-	Amount     float64 `json:"amount" validate:"required,min=0.01,max=2"`
+	ToUserID   int     `json:"toUserId" validate:"required_without_all=ToMemberID ToPhone ToEmail,omitempty,min=1"`
+	Amount     Points  `json:"amount" validate:"required,min=0.01,max=2"`
 	Note       *string `json:"note,omitempty" validate:"omitempty,max=512"`
+	// ToMemberID, ToPhone หรือ ToEmail ใช้ระบุผู้รับแทน toUserId ได้ (อย่างใดอย่างหนึ่ง)
+	ToMemberID string `json:"toMemberId,omitempty"`
+	ToPhone    string `json:"toPhone,omitempty"`
+	ToEmail    string `json:"toEmail,omitempty"`
+	// TwoPhase = true สร้าง transfer เป็น pending และ hold แต้มไว้จนกว่าจะ confirm หรือ cancel
+	TwoPhase bool `json:"twoPhase,omitempty"`
+	// ScheduledAt ถ้าระบุ transfer จะถูกเก็บเป็น pending และโอนจริงเมื่อถึงเวลา
+	ScheduledAt *time.Time `json:"scheduledAt,omitempty"`
+
+	// IdemKey (Idempotency-Key header ของผู้โอน เก็บใน client_key) และ RequestHash ไม่ได้อยู่ใน body
+	IdemKey     string `json:"-"`
+	RequestHash string `json:"-"`
+
+	// MandateID ถูกตั้งเมื่อ transfer ถูกสร้างจากคำสั่งโอนประจำ
+	MandateID *int `json:"-"`
+
+	// Fee คือค่าธรรมเนียมที่ TransferService คำนวณจาก tier ของผู้โอน หักเพิ่มจาก amount
+	Fee Points `json:"-"`
+}
+
+// TransferFailure คือเหตุผลที่ transfer ไม่สำเร็จ
+// Code เป็น error code เดียวกับที่ API ตอบ ส่วน Rule คือ rule หรือ limit ที่ปฏิเสธ (ถ้ามี)
+type TransferFailure struct {
+	Code   string
+	Rule   *string
+	Reason string
+}
+
+type TransferReverseRequest struct {
+	Reason string `json:"reason" validate:"required,max=512"`
+}
+
+type TransferReverseResponse struct {
+	Transfer Transfer `json:"transfer"`
 }
 
 type TransferCreateResponse struct {
@@ -42,11 +94,18 @@ type TransferGetResponse struct {
 	Transfer Transfer `json:"transfer"`
 }
 
+type ScheduledTransferListResponse struct {
+	Data []Transfer `json:"data"`
+}
+
 type TransferListResponse struct {
 	Data     []Transfer `json:"data"`
-	Page     int        `json:"page"`
+	Page     int        `json:"page,omitempty"`
 	PageSize int        `json:"pageSize"`
-	Total    int        `json:"total"`
+	// Total มีเฉพาะเมื่อขอให้นับ (ค่าเริ่มต้นของแบบ offset)
+	Total *int `json:"total,omitempty"`
+	// NextCursor ใช้ขอหน้าถัดไป ไม่มีถ้าเป็นหน้าสุดท้าย
+	NextCursor string `json:"nextCursor,omitempty"`
 }
 
 type EventType string
@@ -59,14 +118,21 @@ const (
 	EventTypeRedeem      EventType = "redeem"
 )
 
+// OpeningBalanceReference คือ reference ของ adjust entry ที่บันทึกแต้มตั้งต้นของ user
+// (แต้มที่ให้ตอนสร้าง user หรือแต้มที่มีอยู่ก่อนเริ่มเขียน ledger)
+const OpeningBalanceReference = "opening_balance"
+
 type PointLedger struct {
 	ID           int       `json:"id" db:"id"`
 	UserID       int       `json:"userId" db:"user_id"`
-	Change       float64   `json:"change" db:"change"`
-	BalanceAfter float64   `json:"balanceAfter" db:"balance_after"`
+	Change       Points    `json:"change" db:"change"`
+	BalanceAfter Points    `json:"balanceAfter" db:"balance_after"`
 	EventType    EventType `json:"eventType" db:"event_type"`
 	TransferID   *int      `json:"transferId,omitempty" db:"transfer_id"`
 	Reference    *string   `json:"reference,omitempty" db:"reference"`
 	Metadata     *string   `json:"metadata,omitempty" db:"metadata"`
 	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+	// PrevHash คือ Hash ของ entry ก่อนหน้าใน chain ของทั้งระบบ ส่วน Hash คำนวณจากเนื้อหาของ entry รวมกับ PrevHash
+	PrevHash string `json:"prevHash" db:"prev_hash"`
+	Hash     string `json:"hash" db:"hash"`
 }
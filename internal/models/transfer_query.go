@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// TransferDirection กรองประวัติการโอนตามฝั่งของ user
+type TransferDirection string
+
+const (
+	TransferDirectionAll      TransferDirection = ""
+	TransferDirectionSent     TransferDirection = "sent"
+	TransferDirectionReceived TransferDirection = "received"
+)
+
+// TransferSort คือลำดับของประวัติการโอน ทุกแบบใช้ id เป็นตัวตัดสินสุดท้ายเพื่อให้ลำดับคงที่
+type TransferSort string
+
+const (
+	TransferSortNewest     TransferSort = "created_desc"
+	TransferSortOldest     TransferSort = "created_asc"
+	TransferSortAmountDesc TransferSort = "amount_desc"
+	TransferSortAmountAsc  TransferSort = "amount_asc"
+)
+
+// TransferQuery คือเงื่อนไขค้นประวัติการโอนของ user
+// ถ้ามี Cursor จะใช้ keyset pagination และไม่สน Page
+type TransferQuery struct {
+	UserID         int
+	Direction      TransferDirection
+	Status         TransferStatus
+	CounterpartyID int
+	CreatedFrom    *time.Time
+	CreatedTo      *time.Time
+	MinAmount      *Points
+	MaxAmount      *Points
+	Sort           TransferSort
+	Page           int
+	PageSize       int
+	Cursor         string
+	// IncludeTotal nil = นับ total เฉพาะแบบ offset (เหมือนเดิม)
+	IncludeTotal *bool
+}
+
+// TransferCursor คือตำแหน่งของ transfer ตัวสุดท้ายในหน้า ใช้หาหน้าถัดไปแบบ keyset
+type TransferCursor struct {
+	Sort      TransferSort `json:"s"`
+	CreatedAt time.Time    `json:"c"`
+	Amount    Points       `json:"a"`
+	ID        int          `json:"i"`
+}
@@ -0,0 +1,32 @@
+package models
+
+// TransferQuoteViolation คือเหตุผลหนึ่งข้อที่การโอนจะถูกปฏิเสธ
+// Code เป็น error code เดียวกับที่ POST /transfers ตอบ
+type TransferQuoteViolation struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	// Rule คือชื่อ rule, limit หรือสัญญาณ fraud ที่ปฏิเสธ (ถ้ามี)
+	Rule    string      `json:"rule,omitempty"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// TransferQuote คือผลของการจำลองการโอนโดยไม่บันทึกอะไรลงระบบ
+// BalanceBefore และ BalanceAfter เป็นแต้มของผู้โอนเท่านั้น
+type TransferQuote struct {
+	FromUserID   int    `json:"fromUserId"`
+	ToUserID     int    `json:"toUserId"`
+	Amount       Points `json:"amount"`
+	Fee          Points `json:"fee"`
+	TotalDebited Points `json:"totalDebited"`
+	// Allowed = true เมื่อไม่มี violation เลย (การโอนจริงอาจยังถูกปฏิเสธได้ถ้ายอดเปลี่ยนระหว่างนั้น)
+	Allowed bool `json:"allowed"`
+	// UnderReview = true เมื่อการโอนจริงจะถูกพักไว้รอตรวจ fraud
+	UnderReview   bool                     `json:"underReview,omitempty"`
+	BalanceBefore UserBalance              `json:"balanceBefore"`
+	BalanceAfter  UserBalance              `json:"balanceAfter"`
+	Violations    []TransferQuoteViolation `json:"violations"`
+}
+
+type TransferQuoteResponse struct {
+	Quote TransferQuote `json:"quote"`
+}
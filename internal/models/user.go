@@ -11,7 +11,7 @@ type User struct {
 	Email           string    `json:"email" db:"email"`
 	MembershipDate  time.Time `json:"membership_date" db:"membership_date"`
 	MembershipLevel string    `json:"membership_level" db:"membership_level"`
-	Points          int       `json:"points" db:"points"`
+	Points          Points    `json:"points" db:"points"`
 	CreatedAt       time.Time `json:"created_at" db:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at" db:"updated_at"`
 }
@@ -22,7 +22,7 @@ type CreateUserRequest struct {
 	Phone           string `json:"phone" validate:"required"`
 	Email           string `json:"email" validate:"required,email"`
 	MembershipLevel string `json:"membership_level" validate:"required,oneof=Gold Silver Bronze"`
-	Points          int    `json:"points" validate:"min=0"`
+	Points          Points `json:"points" validate:"min=0"`
 }
 
 type UpdateUserRequest struct {
@@ -31,5 +31,5 @@ type UpdateUserRequest struct {
 	Phone           *string `json:"phone,omitempty"`
 	Email           *string `json:"email,omitempty" validate:"omitempty,email"`
 	MembershipLevel *string `json:"membership_level,omitempty" validate:"omitempty,oneof=Gold Silver Bronze"`
-	Points          *int    `json:"points,omitempty" validate:"omitempty,min=0"`
+	Points          *Points `json:"points,omitempty" validate:"omitempty,min=0"`
 }
@@ -0,0 +1,117 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// ประเภท event ที่ส่งไปยัง webhook
+const (
+	WebhookEventTransferCompleted  = "transfer.completed"
+	WebhookEventTransferFailed     = "transfer.failed"
+	WebhookEventTransferReversed   = "transfer.reversed"
+	WebhookEventLedgerEntryCreated = "ledger.entry_created"
+	WebhookEventUserUpdated        = "user.updated"
+	// WebhookEventDisputeOpened แจ้งคู่กรณีเมื่อมี dispute ใหม่ ส่วน dispute.updated ส่งทุกครั้งที่สถานะเปลี่ยน
+	WebhookEventDisputeOpened  = "dispute.opened"
+	WebhookEventDisputeUpdated = "dispute.updated"
+	// WebhookEventLedgerCheckpoint ส่ง checkpoint ที่ sign แล้วให้ auditor เก็บไว้นอก database
+	WebhookEventLedgerCheckpoint = "ledger.checkpoint_created"
+	// WebhookEventPing ส่งเฉพาะเมื่อเรียก ping subscription เพื่อทดสอบ receiver
+	WebhookEventPing = "webhook.ping"
+)
+
+// WebhookEventAll ใน eventTypes หมายถึงรับทุก event
+const WebhookEventAll = "*"
+
+// WebhookEventTypes คือ event ที่ subscribe ได้
+var WebhookEventTypes = []string{
+	WebhookEventTransferCompleted,
+	WebhookEventTransferFailed,
+	WebhookEventTransferReversed,
+	WebhookEventLedgerEntryCreated,
+	WebhookEventUserUpdated,
+	WebhookEventDisputeOpened,
+	WebhookEventDisputeUpdated,
+	WebhookEventLedgerCheckpoint,
+}
+
+// WebhookSubscription คือปลายทางที่รับ event แบบ HTTP POST
+// Secret ใช้เซ็น payload ด้วย HMAC-SHA256 และแสดงเฉพาะตอนสร้าง
+type WebhookSubscription struct {
+	ID          int       `json:"subscriptionId" db:"id"`
+	URL         string    `json:"url" db:"url"`
+	EventTypes  []string  `json:"eventTypes" db:"event_types"`
+	Description *string   `json:"description,omitempty" db:"description"`
+	IsActive    bool      `json:"isActive" db:"is_active"`
+	Secret      string    `json:"secret,omitempty" db:"secret"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type WebhookSubscriptionCreateRequest struct {
+	URL         string   `json:"url" validate:"required,url"`
+	EventTypes  []string `json:"eventTypes" validate:"required,min=1"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=256"`
+	// Secret ถ้าไม่ระบุระบบจะสุ่มให้
+	Secret *string `json:"secret,omitempty" validate:"omitempty,min=16,max=128"`
+}
+
+type WebhookSubscriptionUpdateRequest struct {
+	URL         *string  `json:"url,omitempty" validate:"omitempty,url"`
+	EventTypes  []string `json:"eventTypes,omitempty" validate:"omitempty,min=1"`
+	Description *string  `json:"description,omitempty" validate:"omitempty,max=256"`
+	IsActive    *bool    `json:"isActive,omitempty"`
+}
+
+type WebhookSubscriptionResponse struct {
+	Subscription WebhookSubscription `json:"subscription"`
+}
+
+type WebhookSubscriptionListResponse struct {
+	Data       []WebhookSubscription `json:"data"`
+	EventTypes []string              `json:"eventTypes"`
+}
+
+// WebhookEvent คือ body ที่ POST ไปยัง subscription
+type WebhookEvent struct {
+	ID        string      `json:"id"`
+	Type      string      `json:"type"`
+	CreatedAt time.Time   `json:"createdAt"`
+	Data      interface{} `json:"data"`
+}
+
+type WebhookDeliveryStatus string
+
+const (
+	WebhookDeliveryPending   WebhookDeliveryStatus = "pending"
+	WebhookDeliverySucceeded WebhookDeliveryStatus = "succeeded"
+	WebhookDeliveryFailed    WebhookDeliveryStatus = "failed"
+)
+
+// WebhookDelivery คือการส่ง event หนึ่งครั้งไปยัง subscription หนึ่ง รวมผลของความพยายามล่าสุด
+// การส่งซ้ำด้วยมือสร้าง delivery ใหม่ที่มี event id เดิมและ redeliveryOf ชี้ไปที่ delivery เดิม
+type WebhookDelivery struct {
+	ID             int                   `json:"deliveryId" db:"id"`
+	SubscriptionID int                   `json:"subscriptionId" db:"subscription_id"`
+	EventID        string                `json:"eventId" db:"event_id"`
+	EventType      string                `json:"eventType" db:"event_type"`
+	Payload        json.RawMessage       `json:"payload" db:"payload"`
+	Status         WebhookDeliveryStatus `json:"status" db:"status"`
+	Attempts       int                   `json:"attempts" db:"attempts"`
+	NextAttemptAt  *time.Time            `json:"nextAttemptAt,omitempty" db:"next_attempt_at"`
+	LastStatusCode *int                  `json:"lastStatusCode,omitempty" db:"last_status_code"`
+	LastError      *string               `json:"lastError,omitempty" db:"last_error"`
+	RedeliveryOf   *int                  `json:"redeliveryOf,omitempty" db:"redelivery_of"`
+	DeliveredAt    *time.Time            `json:"deliveredAt,omitempty" db:"delivered_at"`
+	CreatedAt      time.Time             `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time             `json:"updatedAt" db:"updated_at"`
+}
+
+type WebhookDeliveryResponse struct {
+	Delivery WebhookDelivery `json:"delivery"`
+}
+
+type WebhookDeliveryListResponse struct {
+	Data []WebhookDelivery `json:"data"`
+}
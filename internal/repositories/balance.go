@@ -0,0 +1,78 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+// ErrInsufficientPoints ถูกคืนเมื่อแต้มที่ใช้ได้ (points - hold) ไม่พอสำหรับการหักหรือ hold
+var ErrInsufficientPoints = errors.New("insufficient points")
+
+// availableGuard เป็นเงื่อนไข SQL ว่าแต้มของ users หลังเปลี่ยน (points + ?) ยังไม่ต่ำกว่าแต้มที่ถูก hold อยู่
+// (active และ expires_at > ? ซึ่งต้องเป็นเวลา UTC แบบเดียวกับที่ hold เก็บไว้)
+// ใช้ใน WHERE ของ statement ที่เปลี่ยนแต้ม เพื่อให้การตรวจและการเขียนเป็น statement เดียวกัน
+const availableGuard = `points + ? >= (
+		SELECT COALESCE(SUM(amount), 0) FROM point_holds
+		WHERE user_id = users.id AND status = 'active' AND expires_at > ?)`
+
+// applyPointChange เปลี่ยนแต้มของ user ด้วย UPDATE เดียวและคืนยอดหลังเปลี่ยน
+// การหัก (change < 0) สำเร็จเฉพาะเมื่อแต้มที่ใช้ได้พอ เว้นแต่ allowNegative
+// การตรวจอยู่ใน WHERE จึงไม่มีช่วงที่ request อื่นแทรกระหว่างอ่านกับเขียนได้ แม้ transaction จะไม่ได้ lock ก่อน
+func applyPointChange(tx *sql.Tx, userID int, change models.Points, allowNegative bool, now time.Time) (models.Points, error) {
+	guard := change >= 0 || allowNegative
+
+	var balance models.Points
+	err := tx.QueryRow(`
+		UPDATE users
+		SET points = points + ?, updated_at = ?
+		WHERE id = ? AND (? OR `+availableGuard+`)
+		RETURNING points`,
+		change, now, userID, guard, change, now.UTC()).Scan(&balance)
+	if err == sql.ErrNoRows {
+		if guard {
+			return 0, errors.New("user not found")
+		}
+		return 0, pointChangeRejected(tx, userID, -change, now)
+	}
+	return balance, err
+}
+
+// reserveHold กันแต้มของ user ไว้ผูกกับ transfer ถ้าแต้มที่ใช้ได้พอ (ตรวจและ insert ใน statement เดียว)
+func reserveHold(tx *sql.Tx, userID int, amount models.Points, transferID *int, reference *string, expiresAt, now time.Time) (int64, error) {
+	result, err := tx.Exec(`
+		INSERT INTO point_holds (user_id, amount, status, transfer_id, reference, expires_at, created_at, updated_at)
+		SELECT id, ?, ?, ?, ?, ?, ?, ?
+		FROM users
+		WHERE id = ? AND `+availableGuard,
+		amount, models.HoldStatusActive, transferID, reference, expiresAt, now, now, userID, -amount, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return 0, err
+	} else if n == 0 {
+		return 0, pointChangeRejected(tx, userID, amount, now)
+	}
+	return result.LastInsertId()
+}
+
+// pointChangeRejected อธิบายว่าทำไมการหักหรือ hold ถูกปฏิเสธ: ไม่มี user หรือแต้มไม่พอ
+func pointChangeRejected(tx *sql.Tx, userID int, need models.Points, now time.Time) error {
+	var points models.Points
+	err := tx.QueryRow("SELECT points FROM users WHERE id = ?", userID).Scan(&points)
+	if err == sql.ErrNoRows {
+		return errors.New("from user not found")
+	}
+	if err != nil {
+		return err
+	}
+	held, err := heldPoints(tx, userID, now)
+	if err != nil {
+		return err
+	}
+	return fmt.Errorf("%w: have %s, need %s", ErrInsufficientPoints, points-held, need)
+}
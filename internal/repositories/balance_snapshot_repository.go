@@ -0,0 +1,176 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+// BalanceSnapshotRepository เก็บยอดปิดรายวันของ user และคำนวณแต้มย้อนหลังจาก snapshot ต่อด้วย point_ledger
+// snapshot เป็นแค่ตัวช่วยให้ไม่ต้องไล่ ledger ทั้งหมด ผลลัพธ์ต้องเท่ากับการคำนวณจาก ledger อย่างเดียวเสมอ
+type BalanceSnapshotRepository struct {
+	db *sql.DB
+}
+
+func NewBalanceSnapshotRepository(db *sql.DB) *BalanceSnapshotRepository {
+	return &BalanceSnapshotRepository{db: db}
+}
+
+// LatestDay คือวันล่าสุดที่มี snapshot ("" ถ้ายังไม่เคยทำ)
+func (r *BalanceSnapshotRepository) LatestDay() (string, error) {
+	var day string
+	err := r.db.QueryRow("SELECT COALESCE(MAX(day), '') FROM balance_snapshots").Scan(&day)
+	return day, err
+}
+
+// NextActivity คือเวลาของ ledger entry แรกที่ created_at >= from (from = nil คือ entry แรกสุด)
+// คืน nil ถ้าไม่มี entry หลังจากนั้น
+func (r *BalanceSnapshotRepository) NextActivity(from *time.Time) (*time.Time, error) {
+	var createdAt time.Time
+	var err error
+	if from == nil {
+		err = r.db.QueryRow("SELECT created_at FROM point_ledger ORDER BY created_at LIMIT 1").Scan(&createdAt)
+	} else {
+		err = r.db.QueryRow(`
+			SELECT created_at FROM point_ledger
+			WHERE created_at >= ?
+			ORDER BY created_at LIMIT 1`, from.Local()).Scan(&createdAt)
+	}
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &createdAt, nil
+}
+
+// CreateDay บันทึกยอดปิดของวัน day (ช่วง [start, end)) ให้ทุก user ที่มี entry ในวันนั้น คืนจำนวน snapshot ที่เขียน
+// snapshot ที่มีอยู่แล้วจะไม่ถูกเขียนทับ
+func (r *BalanceSnapshotRepository) CreateDay(day string, start, end, now time.Time) (int, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO balance_snapshots (user_id, day, ledger_entry_id, balance, created_at)
+		SELECT user_id, ?, id, balance_after, ?
+		FROM point_ledger
+		WHERE id IN (
+			SELECT MAX(id) FROM point_ledger
+			WHERE created_at >= ? AND created_at < ?
+			GROUP BY user_id)
+		ON CONFLICT (user_id, day) DO NOTHING`,
+		day, now, start.Local(), end.Local())
+	if err != nil {
+		return 0, err
+	}
+	count, err := result.RowsAffected()
+	return int(count), err
+}
+
+// BalanceAt คือแต้มของ user ณ เวลา at (ผลของทุก entry ที่ created_at < at)
+func (r *BalanceSnapshotRepository) BalanceAt(userID int, at time.Time) (models.Points, error) {
+	return snapshotBalanceAt(r.db, userID, at)
+}
+
+// DailyClosings คืนแต้มก่อน from และยอดปิดของแต่ละวันที่ user มี entry ในช่วง [from, to)
+// key ของ map เป็นวันในรูปแบบ models.BalanceDayLayout วันที่ไม่มี entry ไม่อยู่ใน map
+func (r *BalanceSnapshotRepository) DailyClosings(userID int, from, to time.Time) (models.Points, map[string]models.Points, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, nil, err
+	}
+	defer tx.Rollback()
+
+	opening, err := snapshotBalanceAt(tx, userID, from)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	closings := make(map[string]models.Points)
+	rows, err := tx.Query(`
+		SELECT day, balance FROM balance_snapshots
+		WHERE user_id = ? AND day >= ? AND day < ?`,
+		userID, from.Format(models.BalanceDayLayout), to.Format(models.BalanceDayLayout))
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var day string
+		var balance models.Points
+		if err := rows.Scan(&day, &balance); err != nil {
+			return 0, nil, err
+		}
+		closings[day] = balance
+	}
+	if err := rows.Err(); err != nil {
+		return 0, nil, err
+	}
+
+	// วันหลัง snapshot ล่าสุด (รวมวันนี้) ยังไม่มี snapshot จึงอ่านจาก ledger
+	ledgerFrom := from
+	var latestDay string
+	if err := tx.QueryRow("SELECT COALESCE(MAX(day), '') FROM balance_snapshots").Scan(&latestDay); err != nil {
+		return 0, nil, err
+	}
+	if latestDay != "" {
+		latest, err := time.ParseInLocation(models.BalanceDayLayout, latestDay, time.Local)
+		if err != nil {
+			return 0, nil, fmt.Errorf("invalid balance snapshot day %q: %w", latestDay, err)
+		}
+		if next := latest.AddDate(0, 0, 1); next.After(ledgerFrom) {
+			ledgerFrom = next
+		}
+	}
+	if !ledgerFrom.Before(to) {
+		return opening, closings, nil
+	}
+
+	entries, err := tx.Query(`
+		SELECT balance_after, created_at FROM point_ledger
+		WHERE user_id = ? AND created_at >= ? AND created_at < ?
+		ORDER BY id`, userID, ledgerFrom.Local(), to.Local())
+	if err != nil {
+		return 0, nil, err
+	}
+	defer entries.Close()
+	for entries.Next() {
+		var balance models.Points
+		var createdAt time.Time
+		if err := entries.Scan(&balance, &createdAt); err != nil {
+			return 0, nil, err
+		}
+		closings[createdAt.Local().Format(models.BalanceDayLayout)] = balance
+	}
+	if err := entries.Err(); err != nil {
+		return 0, nil, err
+	}
+	return opening, closings, nil
+}
+
+// snapshotBalanceAt เริ่มจาก snapshot ล่าสุดของวันที่จบก่อน at แล้วใช้ entry หลัง snapshot ที่ created_at < at
+// ถ้า user ยังไม่มี snapshot ก่อนหน้านั้นใช้ ledgerBalanceAt ตามเดิม
+func snapshotBalanceAt(q queryer, userID int, at time.Time) (models.Points, error) {
+	var snapshotEntryID int
+	var balance models.Points
+	err := q.QueryRow(`
+		SELECT ledger_entry_id, balance FROM balance_snapshots
+		WHERE user_id = ? AND day < ?
+		ORDER BY day DESC LIMIT 1`, userID, at.Local().Format(models.BalanceDayLayout)).Scan(&snapshotEntryID, &balance)
+	if err == sql.ErrNoRows {
+		return ledgerBalanceAt(q, userID, &at, false)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// id > snapshotEntryID ใช้ index (user_id, id) จึงไล่เฉพาะ entry หลัง snapshot
+	err = q.QueryRow(`
+		SELECT balance_after FROM point_ledger
+		WHERE user_id = ? AND id > ? AND created_at < ?
+		ORDER BY id DESC LIMIT 1`, userID, snapshotEntryID, at.Local()).Scan(&balance)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	return balance, nil
+}
@@ -0,0 +1,300 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+var (
+	// ErrDisputeActive ถูกคืนเมื่อ transfer มี dispute ที่ยังเปิดอยู่แล้ว
+	ErrDisputeActive = errors.New("transfer already has an open dispute")
+	// ErrDisputeConflict ถูกคืนเมื่อสถานะ dispute เปลี่ยนไปก่อนที่จะ update ได้
+	ErrDisputeConflict = errors.New("dispute status changed")
+)
+
+// activeDisputeStatuses คือสถานะที่ dispute ยังไม่ปิด
+var activeDisputeStatuses = []models.DisputeStatus{models.DisputeStatusOpen, models.DisputeStatusContested}
+
+// DisputeTransition คือการเปลี่ยนสถานะ dispute หนึ่งครั้ง พร้อมข้อมูลที่บันทึกลง audit trail
+type DisputeTransition struct {
+	DisputeID   int
+	From        []models.DisputeStatus
+	To          models.DisputeStatus
+	Resolution  *models.DisputeResolution
+	Action      string
+	Actor       string
+	ActorUserID *int
+	Note        *string
+}
+
+// dispute พร้อมข้อมูลหลักของ transfer
+const disputeColumns = `d.id, d.transfer_id, t.idempotency_key, d.opened_by, d.counterparty_id, t.amount, d.reason,
+	d.status, d.resolution, d.response_note, d.resolution_note, d.resolved_at, d.created_at, d.updated_at`
+
+const disputeFrom = ` FROM transfer_disputes d JOIN transfers t ON t.id = d.transfer_id`
+
+func scanDispute(row rowScanner) (*models.TransferDispute, error) {
+	var d models.TransferDispute
+	err := row.Scan(
+		&d.ID, &d.TransferID, &d.IdemKey, &d.OpenedBy, &d.CounterpartyID, &d.Amount, &d.Reason,
+		&d.Status, &d.Resolution, &d.ResponseNote, &d.ResolutionNote, &d.ResolvedAt, &d.CreatedAt, &d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+type DisputeRepository struct {
+	db *sql.DB
+}
+
+func NewDisputeRepository(db *sql.DB) *DisputeRepository {
+	return &DisputeRepository{db: db}
+}
+
+// Create เปิด dispute ของ transfer พร้อมบันทึก action "opened" ใน transaction เดียว
+func (r *DisputeRepository) Create(transfer models.Transfer, openedBy int, reason string) (*models.TransferDispute, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	counterpartyID := transfer.ToUserID
+	if openedBy == transfer.ToUserID {
+		counterpartyID = transfer.FromUserID
+	}
+
+	now := time.Now()
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO transfer_disputes (transfer_id, opened_by, counterparty_id, reason, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		transfer.ID, openedBy, counterpartyID, reason, models.DisputeStatusOpen, now, now).Scan(&id)
+	if err != nil {
+		if isUniqueViolation(err, "transfer_disputes.transfer_id") {
+			return nil, ErrDisputeActive
+		}
+		return nil, err
+	}
+
+	if err := insertDisputeEvent(tx, id, models.DisputeActionOpened, models.DisputeActorOpener, &openedBy,
+		nil, models.DisputeStatusOpen, &reason, now); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// GetByID ดึง dispute ตาม id (nil ถ้าไม่มี)
+func (r *DisputeRepository) GetByID(id int) (*models.TransferDispute, error) {
+	d, err := scanDispute(r.db.QueryRow(`SELECT `+disputeColumns+disputeFrom+` WHERE d.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// GetByUserID ดึง dispute ของ user ตามบทบาท: "opened" (user เป็นผู้เปิด), "received" (user เป็นคู่กรณี) หรือ "all"
+func (r *DisputeRepository) GetByUserID(userID int, role string, status models.DisputeStatus) ([]models.TransferDispute, error) {
+	query := `SELECT ` + disputeColumns + disputeFrom + ` WHERE `
+	var args []interface{}
+	switch role {
+	case "opened":
+		query += `d.opened_by = ?`
+		args = append(args, userID)
+	case "received":
+		query += `d.counterparty_id = ?`
+		args = append(args, userID)
+	default:
+		query += `(d.opened_by = ? OR d.counterparty_id = ?)`
+		args = append(args, userID, userID)
+	}
+	if status != "" {
+		query += ` AND d.status = ?`
+		args = append(args, status)
+	}
+	return r.queryDisputes(query+` ORDER BY d.id DESC`, args...)
+}
+
+// List ดึง dispute ทั้งหมด เก่าสุดก่อน (คิวของ admin) กรองตาม status ถ้าระบุ
+func (r *DisputeRepository) List(status models.DisputeStatus, limit int) ([]models.TransferDispute, error) {
+	query := `SELECT ` + disputeColumns + disputeFrom
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE d.status = ?`
+		args = append(args, status)
+	}
+	return r.queryDisputes(query+` ORDER BY d.id LIMIT ?`, append(args, limit)...)
+}
+
+func (r *DisputeRepository) queryDisputes(query string, args ...interface{}) ([]models.TransferDispute, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var disputes []models.TransferDispute
+	for rows.Next() {
+		d, err := scanDispute(rows)
+		if err != nil {
+			return nil, err
+		}
+		disputes = append(disputes, *d)
+	}
+	return disputes, rows.Err()
+}
+
+// LatestByTransfers คืน dispute ล่าสุดของแต่ละ transfer ใน transferIDs (key คือ transfer id)
+func (r *DisputeRepository) LatestByTransfers(transferIDs []int) (map[int]models.TransferDispute, error) {
+	latest := make(map[int]models.TransferDispute)
+	if len(transferIDs) == 0 {
+		return latest, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(transferIDs)), ",")
+	args := make([]interface{}, len(transferIDs))
+	for i, id := range transferIDs {
+		args[i] = id
+	}
+	disputes, err := r.queryDisputes(`SELECT `+disputeColumns+disputeFrom+`
+		WHERE d.id IN (SELECT MAX(id) FROM transfer_disputes WHERE transfer_id IN (`+placeholders+`) GROUP BY transfer_id)`,
+		args...)
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range disputes {
+		latest[d.TransferID] = d
+	}
+	return latest, nil
+}
+
+// GetEvents ดึง audit trail ของ dispute ตามลำดับเวลา
+func (r *DisputeRepository) GetEvents(disputeID int) ([]models.TransferDisputeEvent, error) {
+	rows, err := r.db.Query(`
+		SELECT id, dispute_id, action, actor, actor_user_id, from_status, to_status, note, created_at
+		FROM transfer_dispute_events
+		WHERE dispute_id = ?
+		ORDER BY id`, disputeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []models.TransferDisputeEvent
+	for rows.Next() {
+		var e models.TransferDisputeEvent
+		if err := rows.Scan(&e.ID, &e.DisputeID, &e.Action, &e.Actor, &e.ActorUserID,
+			&e.FromStatus, &e.ToStatus, &e.Note, &e.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Transition เปลี่ยนสถานะ dispute ที่ไม่ต้อง reverse transfer (contest, withdraw, admin reject)
+func (r *DisputeRepository) Transition(t DisputeTransition) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := transitionDispute(tx, t, time.Now()); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// transitionDispute เปลี่ยนสถานะ dispute แบบมีเงื่อนไขและบันทึก audit trail
+// คืน ErrDisputeConflict ถ้าสถานะปัจจุบันไม่อยู่ใน t.From แล้ว
+func transitionDispute(tx *sql.Tx, t DisputeTransition, now time.Time) error {
+	var from models.DisputeStatus
+	err := tx.QueryRow(`SELECT status FROM transfer_disputes WHERE id = ?`, t.DisputeID).Scan(&from)
+	if err != nil {
+		return err
+	}
+
+	allowed := false
+	for _, status := range t.From {
+		allowed = allowed || status == from
+	}
+	if !allowed {
+		return fmt.Errorf("%w: dispute %d is %s", ErrDisputeConflict, t.DisputeID, from)
+	}
+
+	// note ของคู่กรณีเก็บเป็น response_note ส่วนของ admin หรือ support เก็บเป็น resolution_note
+	var responseNote, resolutionNote *string
+	switch t.Actor {
+	case models.DisputeActorCounterparty:
+		responseNote = t.Note
+	case models.DisputeActorAdmin, models.DisputeActorSupport:
+		resolutionNote = t.Note
+	}
+	var resolvedAt *time.Time
+	if t.To != models.DisputeStatusOpen && t.To != models.DisputeStatusContested {
+		resolvedAt = &now
+	}
+
+	result, err := tx.Exec(`
+		UPDATE transfer_disputes
+		SET status = ?, resolution = COALESCE(?, resolution), response_note = COALESCE(?, response_note),
+		    resolution_note = COALESCE(?, resolution_note), resolved_at = COALESCE(?, resolved_at), updated_at = ?
+		WHERE id = ? AND status = ?`,
+		t.To, t.Resolution, responseNote, resolutionNote, resolvedAt, now, t.DisputeID, from)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return fmt.Errorf("%w: dispute %d changed concurrently", ErrDisputeConflict, t.DisputeID)
+	}
+
+	return insertDisputeEvent(tx, t.DisputeID, t.Action, t.Actor, t.ActorUserID, &from, t.To, t.Note, now)
+}
+
+// resolveActiveDisputes ปิด dispute ที่ยังเปิดอยู่ของ transfer ที่ถูก reverse โดยทีม support โดยตรง
+func resolveActiveDisputes(tx *sql.Tx, transferID int, reason string, now time.Time) error {
+	var disputeID int
+	err := tx.QueryRow(`SELECT id FROM transfer_disputes WHERE transfer_id = ? AND status IN (?, ?)`,
+		transferID, activeDisputeStatuses[0], activeDisputeStatuses[1]).Scan(&disputeID)
+	if err == sql.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	resolution := models.DisputeResolutionReversed
+	return transitionDispute(tx, DisputeTransition{
+		DisputeID:  disputeID,
+		From:       activeDisputeStatuses,
+		To:         models.DisputeStatusResolved,
+		Resolution: &resolution,
+		Action:     models.DisputeActionResolved,
+		Actor:      models.DisputeActorSupport,
+		Note:       &reason,
+	}, now)
+}
+
+func insertDisputeEvent(tx *sql.Tx, disputeID int, action, actor string, actorUserID *int,
+	from *models.DisputeStatus, to models.DisputeStatus, note *string, now time.Time) error {
+	_, err := tx.Exec(`
+		INSERT INTO transfer_dispute_events (dispute_id, action, actor, actor_user_id, from_status, to_status, note, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		disputeID, action, actor, actorUserID, from, to, note, now)
+	return err
+}
@@ -0,0 +1,90 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+type FeeRepository struct {
+	db *sql.DB
+}
+
+func NewFeeRepository(db *sql.DB) *FeeRepository {
+	return &FeeRepository{db: db}
+}
+
+const feeColumns = `membership_level, flat_fee, percent_fee, updated_at`
+
+func scanFee(row rowScanner) (*models.TransferFee, error) {
+	var fee models.TransferFee
+	err := row.Scan(
+		&fee.MembershipLevel,
+		&fee.FlatFee,
+		&fee.PercentFee,
+		&fee.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &fee, nil
+}
+
+func (r *FeeRepository) GetAll() ([]models.TransferFee, error) {
+	rows, err := r.db.Query(`SELECT ` + feeColumns + ` FROM transfer_fees
+		ORDER BY CASE membership_level WHEN 'Gold' THEN 1 WHEN 'Silver' THEN 2 ELSE 3 END`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var fees []models.TransferFee
+	for rows.Next() {
+		fee, err := scanFee(rows)
+		if err != nil {
+			return nil, err
+		}
+		fees = append(fees, *fee)
+	}
+	return fees, rows.Err()
+}
+
+// GetByLevel ดึงค่าธรรมเนียมของ membership level (nil ถ้าไม่มี)
+func (r *FeeRepository) GetByLevel(level string) (*models.TransferFee, error) {
+	fee, err := scanFee(r.db.QueryRow(`SELECT `+feeColumns+` FROM transfer_fees WHERE membership_level = ?`, level))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return fee, err
+}
+
+// GetForUser ดึงค่าธรรมเนียมตาม membership level ปัจจุบันของ user (nil ถ้าไม่พบ user)
+func (r *FeeRepository) GetForUser(userID int) (*models.TransferFee, error) {
+	fee, err := scanFee(r.db.QueryRow(`
+		SELECT f.membership_level, f.flat_fee, f.percent_fee, f.updated_at
+		FROM users u
+		JOIN transfer_fees f ON f.membership_level = u.membership_level
+		WHERE u.id = ?`, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return fee, err
+}
+
+func (r *FeeRepository) Update(fee *models.TransferFee) error {
+	fee.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+		UPDATE transfer_fees
+		SET flat_fee = ?, percent_fee = ?, updated_at = ?
+		WHERE membership_level = ?`,
+		fee.FlatFee, fee.PercentFee, fee.UpdatedAt, fee.MembershipLevel)
+	return err
+}
+
+// GetHouseUserID ดึง user id ของบัญชีที่รับค่าธรรมเนียม
+func (r *FeeRepository) GetHouseUserID() (int, error) {
+	var id int
+	err := r.db.QueryRow("SELECT id FROM users WHERE member_id = ?", models.HouseMemberID).Scan(&id)
+	return id, err
+}
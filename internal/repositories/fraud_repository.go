@@ -0,0 +1,226 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+type FraudRepository struct {
+	db *sql.DB
+}
+
+func NewFraudRepository(db *sql.DB) *FraudRepository {
+	return &FraudRepository{db: db}
+}
+
+// activeTransferCondition คือ transfer ที่นับเป็นประวัติของการตรวจ fraud:
+// สำเร็จแล้ว หรือกำลังรอ (two-phase หรือรอตรวจ) แต่ไม่นับ transfer ล่วงหน้าที่ยังไม่ถึงเวลา
+const activeTransferCondition = `(status = 'completed' OR (status IN ('pending', 'processing') AND scheduled_at IS NULL))`
+
+const fraudSettingsColumns = `enabled, window_seconds, sender_velocity, receiver_velocity, funnel_senders,
+	ring_max_hops, new_account_days, new_account_transfers, review_score, block_score, updated_at`
+
+func (r *FraudRepository) GetSettings() (*models.FraudSettings, error) {
+	var s models.FraudSettings
+	err := r.db.QueryRow(`SELECT `+fraudSettingsColumns+` FROM fraud_settings WHERE id = 1`).Scan(
+		&s.Enabled,
+		&s.WindowSeconds,
+		&s.SenderVelocity,
+		&s.ReceiverVelocity,
+		&s.FunnelSenders,
+		&s.RingMaxHops,
+		&s.NewAccountDays,
+		&s.NewAccountTransfers,
+		&s.ReviewScore,
+		&s.BlockScore,
+		&s.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *FraudRepository) UpdateSettings(s *models.FraudSettings) error {
+	s.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+		UPDATE fraud_settings
+		SET enabled = ?, window_seconds = ?, sender_velocity = ?, receiver_velocity = ?, funnel_senders = ?,
+		    ring_max_hops = ?, new_account_days = ?, new_account_transfers = ?, review_score = ?, block_score = ?,
+		    updated_at = ?
+		WHERE id = 1`,
+		s.Enabled, s.WindowSeconds, s.SenderVelocity, s.ReceiverVelocity, s.FunnelSenders,
+		s.RingMaxHops, s.NewAccountDays, s.NewAccountTransfers, s.ReviewScore, s.BlockScore, s.UpdatedAt)
+	return err
+}
+
+// SentCount นับ transfer ที่ user โอนออกตั้งแต่ since
+func (r *FraudRepository) SentCount(userID int, since time.Time) (int, error) {
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COUNT(*) FROM transfers
+		WHERE from_user_id = ? AND created_at >= ? AND `+activeTransferCondition,
+		userID, since).Scan(&count)
+	return count, err
+}
+
+// ReceivedStats นับ transfer ที่ user ได้รับตั้งแต่ since และจำนวนผู้โอนที่ไม่ซ้ำกัน (ไม่นับ excludeSender)
+func (r *FraudRepository) ReceivedStats(userID, excludeSender int, since time.Time) (count, otherSenders int, err error) {
+	err = r.db.QueryRow(`
+		SELECT COUNT(*), COUNT(DISTINCT CASE WHEN from_user_id != ? THEN from_user_id END)
+		FROM transfers
+		WHERE to_user_id = ? AND created_at >= ? AND `+activeTransferCondition,
+		excludeSender, userID, since).Scan(&count, &otherSenders)
+	return count, otherSenders, err
+}
+
+// RingLength หาวงที่สั้นที่สุดที่การโอน from → to จะปิด (เส้นทาง to → ... → from จาก transfer ตั้งแต่ since)
+// คืนจำนวนคนในวง หรือ 0 ถ้าไม่มีวงที่มีอย่างน้อย 3 คนและยาวไม่เกิน maxHops
+func (r *FraudRepository) RingLength(fromUserID, toUserID int, since time.Time, maxHops int) (int, error) {
+	var depth sql.NullInt64
+	err := r.db.QueryRow(`
+		WITH RECURSIVE path(user_id, depth) AS (
+			SELECT ?, 0
+			UNION
+			SELECT t.to_user_id, p.depth + 1
+			FROM path p
+			JOIN transfers t ON t.from_user_id = p.user_id
+			WHERE p.depth < ? AND t.created_at >= ?
+			  AND (t.status = 'completed' OR (t.status IN ('pending', 'processing') AND t.scheduled_at IS NULL))
+		)
+		SELECT MIN(depth) FROM path WHERE user_id = ? AND depth >= 2`,
+		toUserID, maxHops-1, since, fromUserID).Scan(&depth)
+	if err != nil || !depth.Valid {
+		return 0, err
+	}
+	return int(depth.Int64) + 1, nil
+}
+
+// AccountCreatedAt คืนเวลาที่สร้าง user (nil ถ้าไม่มี)
+func (r *FraudRepository) AccountCreatedAt(userID int) (*time.Time, error) {
+	var createdAt time.Time
+	err := r.db.QueryRow(`SELECT created_at FROM users WHERE id = ?`, userID).Scan(&createdAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &createdAt, nil
+}
+
+// review พร้อมข้อมูลหลักของ transfer ที่ถูกพักไว้
+const fraudReviewColumns = `r.id, r.transfer_id, t.idempotency_key, t.from_user_id, t.to_user_id, t.amount,
+	r.score, r.signals, r.status, r.decision_note, r.decided_at, r.created_at, r.updated_at`
+
+const fraudReviewFrom = ` FROM fraud_reviews r JOIN transfers t ON t.id = r.transfer_id`
+
+func scanFraudReview(row rowScanner) (*models.FraudReview, error) {
+	var review models.FraudReview
+	var signals string
+	err := row.Scan(
+		&review.ID,
+		&review.TransferID,
+		&review.IdemKey,
+		&review.FromUserID,
+		&review.ToUserID,
+		&review.Amount,
+		&review.Score,
+		&signals,
+		&review.Status,
+		&review.DecisionNote,
+		&review.DecidedAt,
+		&review.CreatedAt,
+		&review.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(signals), &review.Signals); err != nil {
+		return nil, err
+	}
+	return &review, nil
+}
+
+// GetReview ดึง review ตาม id (nil ถ้าไม่มี)
+func (r *FraudRepository) GetReview(id int) (*models.FraudReview, error) {
+	review, err := scanFraudReview(r.db.QueryRow(`SELECT `+fraudReviewColumns+fraudReviewFrom+` WHERE r.id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return review, err
+}
+
+// IsUnderReview บอกว่า transfer มี review ที่ยังรอตัดสินอยู่หรือไม่
+func (r *FraudRepository) IsUnderReview(transferID int) (bool, error) {
+	var exists bool
+	err := r.db.QueryRow(`SELECT EXISTS (SELECT 1 FROM fraud_reviews WHERE transfer_id = ? AND status = ?)`,
+		transferID, models.FraudReviewPending).Scan(&exists)
+	return exists, err
+}
+
+// ListReviews ดึง review เก่าสุดก่อน (คิวตามลำดับที่เข้า) กรองตาม status ถ้าระบุ
+func (r *FraudRepository) ListReviews(status models.FraudReviewStatus, limit int) ([]models.FraudReview, error) {
+	query := `SELECT ` + fraudReviewColumns + fraudReviewFrom
+	var args []interface{}
+	if status != "" {
+		query += ` WHERE r.status = ?`
+		args = append(args, status)
+	}
+	rows, err := r.db.Query(query+` ORDER BY r.id LIMIT ?`, append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reviews []models.FraudReview
+	for rows.Next() {
+		review, err := scanFraudReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		reviews = append(reviews, *review)
+	}
+	return reviews, rows.Err()
+}
+
+// DecideReview ตัดสิน review ที่ยัง pending คืน false ถ้าถูกตัดสินหรือปิดไปแล้ว
+func (r *FraudRepository) DecideReview(id int, status models.FraudReviewStatus, note *string) (bool, error) {
+	now := time.Now()
+	result, err := r.db.Exec(`
+		UPDATE fraud_reviews
+		SET status = ?, decision_note = ?, decided_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		status, note, now, now, id, models.FraudReviewPending)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n == 1, err
+}
+
+// insertFraudReview เพิ่ม transfer เข้าคิวรอตรวจ (ใช้ใน transaction เดียวกับที่สร้าง transfer)
+func insertFraudReview(tx *sql.Tx, transferID int, assessment models.FraudAssessment, now time.Time) error {
+	signals, err := json.Marshal(assessment.Signals)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO fraud_reviews (transfer_id, score, signals, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?)`,
+		transferID, assessment.Score, string(signals), models.FraudReviewPending, now, now)
+	return err
+}
+
+// closeFraudReview ปิด review ที่ยังรออยู่ของ transfer ที่ถูกยกเลิกหรือหมดอายุก่อน admin ตัดสิน
+func closeFraudReview(q queryer, transferID int, now time.Time) error {
+	_, err := q.Exec(`
+		UPDATE fraud_reviews
+		SET status = ?, updated_at = ?
+		WHERE transfer_id = ? AND status = ?`,
+		models.FraudReviewClosed, now, transferID, models.FraudReviewPending)
+	return err
+}
@@ -0,0 +1,232 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+// queryer ใช้ได้ทั้ง *sql.DB และ *sql.Tx
+type queryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// heldPoints รวมแต้มที่ถูก hold อยู่ (active และยังไม่หมดอายุ ณ now) ของ user
+// hold ที่เลย expires_at แล้วไม่นับแม้ sweeper ยังไม่ได้เปลี่ยนสถานะ เพราะ capture หรือ confirm ไม่ได้แล้ว
+func heldPoints(q queryer, userID int, now time.Time) (models.Points, error) {
+	var held models.Points
+	err := q.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0)
+		FROM point_holds
+		WHERE user_id = ? AND status = ? AND expires_at > ?`,
+		userID, models.HoldStatusActive, now.UTC()).Scan(&held)
+	return held, err
+}
+
+// closeTransferHold ปิด hold ที่ยัง active ของ transfer ด้วยสถานะที่กำหนด
+func closeTransferHold(q queryer, transferID int, status models.HoldStatus, now time.Time) error {
+	_, err := q.Exec(`
+		UPDATE point_holds
+		SET status = ?, updated_at = ?
+		WHERE transfer_id = ? AND status = ?`,
+		status, now, transferID, models.HoldStatusActive)
+	return err
+}
+
+var (
+	ErrHoldNotFound = errors.New("hold not found")
+	// ErrHoldNotActive ถูกคืนเมื่อ hold ถูก capture, void หรือหมดอายุไปแล้ว
+	ErrHoldNotActive = errors.New("hold is no longer active")
+	// ErrHoldExpired ถูกคืนเมื่อ capture หลังเลย expires_at แต่ sweeper ยังไม่ได้เปลี่ยนสถานะ
+	ErrHoldExpired = errors.New("hold has expired")
+)
+
+// HoldCaptureReference ใช้เป็น reference ของ ledger entry redeem ที่เกิดจากการ capture hold ของร้านค้า
+const HoldCaptureReference = "hold_capture"
+
+const holdColumns = `id, user_id, amount, status, transfer_id, merchant_id, reference, captured_amount,
+	expires_at, created_at, updated_at`
+
+func scanHold(row rowScanner) (*models.PointHold, error) {
+	var h models.PointHold
+	err := row.Scan(
+		&h.ID, &h.UserID, &h.Amount, &h.Status, &h.TransferID, &h.MerchantID, &h.Reference, &h.CapturedAmount,
+		&h.ExpiresAt, &h.CreatedAt, &h.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &h, nil
+}
+
+// HoldRepository ดูแล hold ของร้านค้า (transfer_id เป็น NULL) hold ของ transfer แบบ two-phase
+// ถูกเปิดและปิดโดย TransferRepository
+type HoldRepository struct {
+	db *sql.DB
+}
+
+func NewHoldRepository(db *sql.DB) *HoldRepository {
+	return &HoldRepository{db: db}
+}
+
+// Create กันแต้มของ user ให้ร้านค้า ถ้าแต้มที่ใช้ได้ (หัก hold อื่นที่ active) พอ
+// reference คือเลขอ้างอิงของร้านค้า เช่น order id (ว่างได้)
+// expires_at เก็บเป็น UTC เพราะ ExpireDue และ closeMerchantHold เทียบกับ now.UTC() แบบ string
+// now คือเวลาของ service ใช้ทั้ง created_at และตัดสินว่า hold อื่นหมดอายุแล้วหรือยัง
+func (r *HoldRepository) Create(userID int, amount models.Points, merchantID, reference string, expiresAt, now time.Time) (*models.PointHold, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	id, err := reserveHold(tx, userID, amount, nil, nullableString(reference), expiresAt.UTC(), now)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := tx.Exec(`UPDATE point_holds SET merchant_id = ? WHERE id = ?`, merchantID, id); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return r.GetByID(int(id))
+}
+
+// GetByID ดึง hold ของร้านค้าตาม id (nil ถ้าไม่มี หรือเป็น hold ของ transfer)
+func (r *HoldRepository) GetByID(id int) (*models.PointHold, error) {
+	h, err := scanHold(r.db.QueryRow(`
+		SELECT `+holdColumns+`
+		FROM point_holds
+		WHERE id = ? AND transfer_id IS NULL`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return h, err
+}
+
+// GetByUserID ดึง hold ของร้านค้าของ user ใหม่สุดก่อน กรองตาม status ถ้าระบุ
+func (r *HoldRepository) GetByUserID(userID int, status models.HoldStatus) ([]models.PointHold, error) {
+	query := `SELECT ` + holdColumns + ` FROM point_holds WHERE user_id = ? AND transfer_id IS NULL`
+	args := []interface{}{userID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+
+	rows, err := r.db.Query(query+` ORDER BY id DESC`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var holds []models.PointHold
+	for rows.Next() {
+		h, err := scanHold(rows)
+		if err != nil {
+			return nil, err
+		}
+		holds = append(holds, *h)
+	}
+	return holds, rows.Err()
+}
+
+// Capture ตัดแต้ม amount จาก hold เป็น ledger entry redeem ส่วนที่เหลือของ hold ถูกปล่อยคืนในคราวเดียว
+// hold ถูกปิดก่อนหักแต้ม เพื่อให้ guard ไม่นับแต้มที่กำลังจะหักซ้ำ
+func (r *HoldRepository) Capture(id int, amount models.Points, now time.Time) (*models.PointHold, *models.PointLedger, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	if err := closeMerchantHold(tx, id, models.HoldStatusCaptured, &amount, now); err != nil {
+		return nil, nil, err
+	}
+	hold, err := scanHold(tx.QueryRow(`SELECT `+holdColumns+` FROM point_holds WHERE id = ?`, id))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	balance, err := applyPointChange(tx, hold.UserID, -amount, false, now)
+	if err != nil {
+		return nil, nil, err
+	}
+	reference := HoldCaptureReference
+	inserted := &models.PointLedger{
+		UserID: hold.UserID, Change: -amount, BalanceAfter: balance, EventType: models.EventTypeRedeem,
+		Reference: &reference, CreatedAt: now,
+		Metadata: ledgerMetadata(map[string]any{
+			"holdId": hold.ID, "merchantId": hold.MerchantID, "merchantReference": hold.Reference,
+		}),
+	}
+	if err := insertLedgerEntry(tx, inserted); err != nil {
+		return nil, nil, err
+	}
+
+	entry, err := scanLedgerEntry(tx.QueryRow(`SELECT `+ledgerColumns+` FROM point_ledger WHERE id = ?`, inserted.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+	return hold, entry, nil
+}
+
+// Void ปล่อย hold ที่ยัง active คืนทั้งหมด
+func (r *HoldRepository) Void(id int, now time.Time) (*models.PointHold, error) {
+	if err := closeMerchantHold(r.db, id, models.HoldStatusReleased, nil, now); err != nil {
+		return nil, err
+	}
+	return r.GetByID(id)
+}
+
+// ExpireDue เปลี่ยน hold ของร้านค้าที่เลย expires_at แล้วเป็น expired คืนจำนวนที่เปลี่ยน
+// hold ของ transfer ถูกปล่อยพร้อมกับการยกเลิก transfer ที่หมดเวลา จึงไม่ยุ่งที่นี่
+func (r *HoldRepository) ExpireDue(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE point_holds
+		SET status = ?, updated_at = ?
+		WHERE transfer_id IS NULL AND status = ? AND expires_at <= ?`,
+		models.HoldStatusExpired, now, models.HoldStatusActive, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// closeMerchantHold ปิด hold ของร้านค้าที่ยัง active และยังไม่หมดอายุ ด้วย UPDATE แบบมีเงื่อนไข
+// ถ้าไม่มีแถวถูกเปลี่ยน จะอ่านสถานะเพื่อบอกว่าไม่มี hold, ปิดไปแล้ว หรือหมดอายุ
+func closeMerchantHold(q queryer, id int, status models.HoldStatus, capturedAmount *models.Points, now time.Time) error {
+	result, err := q.Exec(`
+		UPDATE point_holds
+		SET status = ?, captured_amount = ?, updated_at = ?
+		WHERE id = ? AND transfer_id IS NULL AND status = ? AND expires_at > ?`,
+		status, capturedAmount, now, id, models.HoldStatusActive, now.UTC())
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n > 0 {
+		return nil
+	}
+
+	var current models.HoldStatus
+	err = q.QueryRow(`SELECT status FROM point_holds WHERE id = ? AND transfer_id IS NULL`, id).Scan(&current)
+	switch {
+	case err == sql.ErrNoRows:
+		return ErrHoldNotFound
+	case err != nil:
+		return err
+	case current == models.HoldStatusActive:
+		return ErrHoldExpired
+	}
+	return fmt.Errorf("%w: hold %d is %s", ErrHoldNotActive, id, current)
+}
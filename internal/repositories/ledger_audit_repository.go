@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"database/sql"
+
+	"kbtg-backend/internal/models"
+)
+
+const ledgerCheckpointColumns = `id, ledger_entry_id, entry_count, hash, key_id, public_key, signature, created_at`
+
+func scanLedgerCheckpoint(row rowScanner) (*models.LedgerCheckpoint, error) {
+	var c models.LedgerCheckpoint
+	err := row.Scan(&c.ID, &c.LedgerEntryID, &c.EntryCount, &c.Hash, &c.KeyID, &c.PublicKey, &c.Signature, &c.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// LedgerAuditRepository อ่าน hash chain ของ point_ledger และเก็บ checkpoint ที่ sign แล้ว
+type LedgerAuditRepository struct {
+	db *sql.DB
+}
+
+func NewLedgerAuditRepository(db *sql.DB) *LedgerAuditRepository {
+	return &LedgerAuditRepository{db: db}
+}
+
+// ChainEntries ดึง entry ถัดจาก afterID ตามลำดับ id ทีละ limit รายการ
+func (r *LedgerAuditRepository) ChainEntries(afterID, limit int) ([]models.PointLedger, error) {
+	rows, err := r.db.Query(`
+		SELECT `+ledgerColumns+`
+		FROM point_ledger
+		WHERE id > ?
+		ORDER BY id
+		LIMIT ?`, afterID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.PointLedger
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// Checkpoints ดึง checkpoint ทั้งหมดเรียงตาม entry ที่ครอบคลุม
+func (r *LedgerAuditRepository) Checkpoints() ([]models.LedgerCheckpoint, error) {
+	return r.queryCheckpoints(`SELECT ` + ledgerCheckpointColumns + ` FROM ledger_checkpoints ORDER BY ledger_entry_id`)
+}
+
+// ListCheckpoints ดึง checkpoint ล่าสุดก่อน
+func (r *LedgerAuditRepository) ListCheckpoints(limit int) ([]models.LedgerCheckpoint, error) {
+	return r.queryCheckpoints(`SELECT `+ledgerCheckpointColumns+` FROM ledger_checkpoints ORDER BY id DESC LIMIT ?`, limit)
+}
+
+func (r *LedgerAuditRepository) queryCheckpoints(query string, args ...any) ([]models.LedgerCheckpoint, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var checkpoints []models.LedgerCheckpoint
+	for rows.Next() {
+		c, err := scanLedgerCheckpoint(rows)
+		if err != nil {
+			return nil, err
+		}
+		checkpoints = append(checkpoints, *c)
+	}
+	return checkpoints, rows.Err()
+}
+
+// GetCheckpointByEntry ดึง checkpoint ของ entry (nil ถ้าไม่มี)
+func (r *LedgerAuditRepository) GetCheckpointByEntry(ledgerEntryID int) (*models.LedgerCheckpoint, error) {
+	c, err := scanLedgerCheckpoint(r.db.QueryRow(`
+		SELECT `+ledgerCheckpointColumns+`
+		FROM ledger_checkpoints
+		WHERE ledger_entry_id = ?`, ledgerEntryID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return c, err
+}
+
+// CreateCheckpoint บันทึก checkpoint และเติม ID คืน false ถ้ามี checkpoint ของ entry นี้อยู่แล้ว
+func (r *LedgerAuditRepository) CreateCheckpoint(c *models.LedgerCheckpoint) (bool, error) {
+	err := r.db.QueryRow(`
+		INSERT INTO ledger_checkpoints (ledger_entry_id, entry_count, hash, key_id, public_key, signature, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (ledger_entry_id) DO NOTHING
+		RETURNING id`,
+		c.LedgerEntryID, c.EntryCount, c.Hash, c.KeyID, c.PublicKey, c.Signature, c.CreatedAt).Scan(&c.ID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
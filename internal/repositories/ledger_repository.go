@@ -0,0 +1,193 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+const ledgerColumns = `id, user_id, change, balance_after, event_type, transfer_id, reference, metadata, created_at,
+	prev_hash, hash`
+
+func scanLedgerEntry(row rowScanner) (*models.PointLedger, error) {
+	var entry models.PointLedger
+	err := row.Scan(&entry.ID, &entry.UserID, &entry.Change, &entry.BalanceAfter, &entry.EventType,
+		&entry.TransferID, &entry.Reference, &entry.Metadata, &entry.CreatedAt, &entry.PrevHash, &entry.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// insertLedgerEntry เขียน entry ต่อท้าย hash chain ของทั้งระบบ แล้วเติม ID, PrevHash และ Hash ให้ entry
+// ทุกที่ที่เขียน point_ledger ต้องผ่านฟังก์ชันนี้ ใน transaction เดียวกับการเปลี่ยน users.points
+// transaction เป็นแบบ immediate (database.NewConnection บังคับ _txlock=immediate) จึงไม่มีใครเขียน entry แทรกระหว่างอ่าน hash ล่าสุดกับ INSERT
+func insertLedgerEntry(tx *sql.Tx, entry *models.PointLedger) error {
+	err := tx.QueryRow("SELECT hash FROM point_ledger ORDER BY id DESC LIMIT 1").Scan(&entry.PrevHash)
+	if err == sql.ErrNoRows {
+		entry.PrevHash = models.LedgerGenesisHash
+	} else if err != nil {
+		return err
+	}
+	entry.Hash = entry.ComputeHash()
+
+	return tx.QueryRow(`
+		INSERT INTO point_ledger (user_id, change, balance_after, event_type, transfer_id, reference, metadata,
+			created_at, prev_hash, hash)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		entry.UserID, entry.Change, entry.BalanceAfter, entry.EventType, entry.TransferID, entry.Reference,
+		entry.Metadata, entry.CreatedAt, entry.PrevHash, entry.Hash).Scan(&entry.ID)
+}
+
+// ledgerMetadata แปลง key/value เป็น JSON สำหรับ column metadata (key เรียงตามตัวอักษร ไม่ escape HTML
+// เหมือน json_object ของ SQLite ที่ใช้ก่อนหน้า) ต้องสร้างก่อน INSERT เพราะ metadata เป็นส่วนหนึ่งของ hash
+func ledgerMetadata(fields map[string]any) *string {
+	var content strings.Builder
+	encoder := json.NewEncoder(&content)
+	encoder.SetEscapeHTML(false)
+	// ค่าที่ใส่มามีแต่ string และตัวเลข Encode จึงไม่ error
+	_ = encoder.Encode(fields)
+	metadata := strings.TrimSuffix(content.String(), "\n")
+	return &metadata
+}
+
+// LedgerRepository อ่าน point_ledger (เขียนผ่าน repository ของ transfer, hold ฯลฯ เท่านั้น)
+type LedgerRepository struct {
+	db *sql.DB
+}
+
+func NewLedgerRepository(db *sql.DB) *LedgerRepository {
+	return &LedgerRepository{db: db}
+}
+
+// Search ดึง ledger entry ของ user ตามเงื่อนไขใน q เรียงตาม id
+// afterID > 0 ดึงต่อจาก entry นั้นตามทิศทางของ q.Sort
+func (r *LedgerRepository) Search(q models.LedgerQuery, afterID, limit int) ([]models.PointLedger, error) {
+	where, args := ledgerQueryWhere(q.UserID, q.CreatedFrom, q.CreatedTo)
+	if len(q.EventTypes) > 0 {
+		where += " AND event_type IN (" + strings.TrimSuffix(strings.Repeat("?,", len(q.EventTypes)), ",") + ")"
+		for _, eventType := range q.EventTypes {
+			args = append(args, eventType)
+		}
+	}
+
+	orderBy, after := "id DESC", "<"
+	if q.Sort == models.LedgerSortOldest {
+		orderBy, after = "id ASC", ">"
+	}
+	if afterID > 0 {
+		where += " AND id " + after + " ?"
+		args = append(args, afterID)
+	}
+
+	rows, err := r.db.Query(`
+		SELECT `+ledgerColumns+`
+		FROM point_ledger
+		WHERE `+where+`
+		ORDER BY `+orderBy+`
+		LIMIT ?`,
+		append(args, limit)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.PointLedger
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// Summary สรุปยอดของ user ในช่วง [from, to) จาก snapshot เดียวกัน
+func (r *LedgerRepository) Summary(userID int, from, to *time.Time) (*models.LedgerSummary, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	summary := models.LedgerSummary{From: from, To: to}
+	if summary.OpeningBalance, err = ledgerBalanceAt(tx, userID, from, true); err != nil {
+		return nil, err
+	}
+	if summary.ClosingBalance, err = ledgerBalanceAt(tx, userID, to, false); err != nil {
+		return nil, err
+	}
+
+	where, args := ledgerQueryWhere(userID, from, to)
+	err = tx.QueryRow(`
+		SELECT COALESCE(SUM(CASE WHEN change > 0 THEN change END), 0),
+		       COALESCE(SUM(CASE WHEN change < 0 THEN -change END), 0),
+		       COUNT(*)
+		FROM point_ledger
+		WHERE `+where, args...).Scan(&summary.Credits, &summary.Debits, &summary.EntryCount)
+	if err != nil {
+		return nil, err
+	}
+	return &summary, nil
+}
+
+// ledgerBalanceAt คือแต้มของ user ก่อน entry แรกที่ created_at >= at
+// at = nil หมายถึงก่อน entry แรกทั้งหมดถ้า start = true หรือหลัง entry ล่าสุดถ้า start = false
+// ใช้ balance_after ของ entry ก่อนหน้าถ้ามี ไม่อย่างนั้นย้อนจาก entry ถัดไป (balance_after - change)
+// ถ้าไม่มี entry หลังจากนั้นเลย แต้มไม่ได้เปลี่ยนผ่าน ledger จึงใช้ users.points ปัจจุบัน
+func ledgerBalanceAt(q queryer, userID int, at *time.Time, start bool) (models.Points, error) {
+	var balance models.Points
+	var err error
+	switch {
+	case at != nil:
+		// เวลาใน database ถูกเขียนด้วยเวลา local จึงต้องเทียบในรูปแบบเดียวกัน
+		err = q.QueryRow(`
+			SELECT balance_after FROM point_ledger
+			WHERE user_id = ? AND created_at < ?
+			ORDER BY id DESC LIMIT 1`, userID, at.Local()).Scan(&balance)
+		if err == sql.ErrNoRows {
+			err = q.QueryRow(`
+				SELECT balance_after - change FROM point_ledger
+				WHERE user_id = ? AND created_at >= ?
+				ORDER BY id LIMIT 1`, userID, at.Local()).Scan(&balance)
+		}
+	case start:
+		err = q.QueryRow(`
+			SELECT balance_after - change FROM point_ledger
+			WHERE user_id = ?
+			ORDER BY id LIMIT 1`, userID).Scan(&balance)
+	default:
+		err = q.QueryRow(`
+			SELECT balance_after FROM point_ledger
+			WHERE user_id = ?
+			ORDER BY id DESC LIMIT 1`, userID).Scan(&balance)
+	}
+	if err == sql.ErrNoRows {
+		err = q.QueryRow(`SELECT points FROM users WHERE id = ?`, userID).Scan(&balance)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to compute ledger balance: %w", err)
+	}
+	return balance, nil
+}
+
+// ledgerQueryWhere สร้างเงื่อนไข WHERE ของ user และช่วงเวลา [from, to)
+func ledgerQueryWhere(userID int, from, to *time.Time) (string, []interface{}) {
+	conditions := []string{"user_id = ?"}
+	args := []interface{}{userID}
+	if from != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, from.Local())
+	}
+	if to != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, to.Local())
+	}
+	return strings.Join(conditions, " AND "), args
+}
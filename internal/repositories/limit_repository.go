@@ -0,0 +1,106 @@
+package repositories
+
+import (
+	"database/sql"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+type LimitRepository struct {
+	db *sql.DB
+}
+
+func NewLimitRepository(db *sql.DB) *LimitRepository {
+	return &LimitRepository{db: db}
+}
+
+const limitColumns = `membership_level, per_transaction, daily_amount, daily_count,
+	monthly_amount, monthly_count, updated_at`
+
+func scanLimit(row rowScanner) (*models.TransferLimit, error) {
+	var limit models.TransferLimit
+	err := row.Scan(
+		&limit.MembershipLevel,
+		&limit.PerTransaction,
+		&limit.DailyAmount,
+		&limit.DailyCount,
+		&limit.MonthlyAmount,
+		&limit.MonthlyCount,
+		&limit.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &limit, nil
+}
+
+func (r *LimitRepository) GetAll() ([]models.TransferLimit, error) {
+	rows, err := r.db.Query(`SELECT ` + limitColumns + ` FROM transfer_limits
+		ORDER BY CASE membership_level WHEN 'Gold' THEN 1 WHEN 'Silver' THEN 2 ELSE 3 END`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var limits []models.TransferLimit
+	for rows.Next() {
+		limit, err := scanLimit(rows)
+		if err != nil {
+			return nil, err
+		}
+		limits = append(limits, *limit)
+	}
+	return limits, rows.Err()
+}
+
+// GetByLevel ดึง limit ของ membership level (nil ถ้าไม่มี)
+func (r *LimitRepository) GetByLevel(level string) (*models.TransferLimit, error) {
+	limit, err := scanLimit(r.db.QueryRow(`SELECT `+limitColumns+` FROM transfer_limits WHERE membership_level = ?`, level))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return limit, err
+}
+
+// GetForUser ดึง limit ตาม membership level ปัจจุบันของ user (nil ถ้าไม่พบ user)
+func (r *LimitRepository) GetForUser(userID int) (*models.TransferLimit, error) {
+	limit, err := scanLimit(r.db.QueryRow(`
+		SELECT l.membership_level, l.per_transaction, l.daily_amount, l.daily_count,
+		       l.monthly_amount, l.monthly_count, l.updated_at
+		FROM users u
+		JOIN transfer_limits l ON l.membership_level = u.membership_level
+		WHERE u.id = ?`, userID))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return limit, err
+}
+
+func (r *LimitRepository) Update(limit *models.TransferLimit) error {
+	limit.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+		UPDATE transfer_limits
+		SET per_transaction = ?, daily_amount = ?, daily_count = ?,
+		    monthly_amount = ?, monthly_count = ?, updated_at = ?
+		WHERE membership_level = ?`,
+		limit.PerTransaction, limit.DailyAmount, limit.DailyCount,
+		limit.MonthlyAmount, limit.MonthlyCount, limit.UpdatedAt, limit.MembershipLevel)
+	return err
+}
+
+// SentSince รวมยอดและจำนวน transfer ที่ user โอนออกตั้งแต่ since
+// นับ transfer ที่สำเร็จแล้วตามเวลาที่สำเร็จ และ transfer ที่ยังค้าง (two-phase) ตามเวลาที่สร้าง
+// transfer ล่วงหน้าที่ยังไม่ถึงเวลาจะยังไม่ถูกนับ ส่วน transfer ที่ถูก reverse หรือไม่สำเร็จไม่นับ
+func (r *LimitRepository) SentSince(userID int, since time.Time) (models.Points, int, error) {
+	var amount models.Points
+	var count int
+	err := r.db.QueryRow(`
+		SELECT COALESCE(SUM(amount), 0), COUNT(*)
+		FROM transfers
+		WHERE from_user_id = ?
+		  AND ((status = 'completed' AND completed_at >= ?)
+		    OR (status IN ('pending', 'processing') AND scheduled_at IS NULL AND created_at >= ?))`,
+		userID, since, since).Scan(&amount, &count)
+	return amount, count, err
+}
@@ -0,0 +1,158 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+const mandateColumns = `id, from_user_id, to_user_id, amount, note, cron_expr, interval_seconds,
+		       start_at, end_at, max_occurrences, occurrences, consecutive_failures, status,
+		       next_run_at, last_run_at, last_error, created_at, updated_at`
+
+func scanMandate(row rowScanner) (*models.Mandate, error) {
+	var m models.Mandate
+	err := row.Scan(
+		&m.ID, &m.FromUserID, &m.ToUserID, &m.Amount, &m.Note, &m.Cron, &m.IntervalSeconds,
+		&m.StartAt, &m.EndAt, &m.MaxOccurrences, &m.Occurrences, &m.ConsecutiveFailures, &m.Status,
+		&m.NextRunAt, &m.LastRunAt, &m.LastError, &m.CreatedAt, &m.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+type MandateRepository struct {
+	db *sql.DB
+}
+
+func NewMandateRepository(db *sql.DB) *MandateRepository {
+	return &MandateRepository{db: db}
+}
+
+// Create บันทึกคำสั่งโอนประจำใหม่ (next_run_at คำนวณมาจาก service)
+func (r *MandateRepository) Create(m models.Mandate) (*models.Mandate, error) {
+	now := time.Now()
+
+	var toUserID int
+	err := r.db.QueryRow("SELECT id FROM users WHERE id = ?", m.ToUserID).Scan(&toUserID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, fmt.Errorf("to user not found")
+		}
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO transfer_mandates (from_user_id, to_user_id, amount, note, cron_expr, interval_seconds,
+		                               start_at, end_at, max_occurrences, status, next_run_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ` + mandateColumns
+
+	return scanMandate(r.db.QueryRow(query,
+		m.FromUserID, m.ToUserID, m.Amount, m.Note, m.Cron, m.IntervalSeconds,
+		m.StartAt.UTC(), utcPtr(m.EndAt), m.MaxOccurrences, m.Status, utcPtr(m.NextRunAt), now, now,
+	))
+}
+
+func (r *MandateRepository) GetByID(id int) (*models.Mandate, error) {
+	m, err := scanMandate(r.db.QueryRow("SELECT "+mandateColumns+" FROM transfer_mandates WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return m, nil
+}
+
+// GetByUserID ดึงคำสั่งโอนประจำทั้งหมดที่ user เป็นผู้โอน
+func (r *MandateRepository) GetByUserID(userID int) ([]models.Mandate, error) {
+	return r.query(`
+		SELECT `+mandateColumns+`
+		FROM transfer_mandates
+		WHERE from_user_id = ?
+		ORDER BY created_at DESC`, userID)
+}
+
+// ListDue ดึงคำสั่งโอนประจำที่ active และถึงเวลาโอนแล้ว
+func (r *MandateRepository) ListDue(now time.Time, limit int) ([]models.Mandate, error) {
+	return r.query(`
+		SELECT `+mandateColumns+`
+		FROM transfer_mandates
+		WHERE status = ? AND next_run_at IS NOT NULL AND next_run_at <= ?
+		ORDER BY next_run_at, id
+		LIMIT ?`, models.MandateStatusActive, now.UTC(), limit)
+}
+
+// Update บันทึก field ที่แก้ได้ของ mandate (amount, note, end_at, max_occurrences, status, next_run_at)
+func (r *MandateRepository) Update(m models.Mandate) (*models.Mandate, error) {
+	query := `
+		UPDATE transfer_mandates
+		SET amount = ?, note = ?, end_at = ?, max_occurrences = ?, status = ?, next_run_at = ?,
+		    consecutive_failures = ?, updated_at = ?
+		WHERE id = ?
+		RETURNING ` + mandateColumns
+
+	updated, err := scanMandate(r.db.QueryRow(query,
+		m.Amount, m.Note, utcPtr(m.EndAt), m.MaxOccurrences, m.Status, utcPtr(m.NextRunAt),
+		m.ConsecutiveFailures, time.Now(), m.ID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return updated, err
+}
+
+// RecordRun บันทึกผลการโอนหนึ่งรอบ: จำนวนครั้ง, ความล้มเหลวติดกัน, สถานะ และรอบถัดไป
+// เงื่อนไข next_run_at = expectedRun กันไม่ให้ runner สองตัวบันทึกรอบเดียวกันซ้ำ
+func (r *MandateRepository) RecordRun(m models.Mandate, expectedRun time.Time, runAt time.Time) (bool, error) {
+	result, err := r.db.Exec(`
+		UPDATE transfer_mandates
+		SET occurrences = ?, consecutive_failures = ?, status = ?, next_run_at = ?,
+		    last_run_at = ?, last_error = ?, updated_at = ?
+		WHERE id = ? AND next_run_at = ?`,
+		m.Occurrences, m.ConsecutiveFailures, m.Status, utcPtr(m.NextRunAt),
+		runAt.UTC(), m.LastError, time.Now(), m.ID, expectedRun.UTC())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+func (r *MandateRepository) query(query string, args ...interface{}) ([]models.Mandate, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var mandates []models.Mandate
+	for rows.Next() {
+		m, err := scanMandate(rows)
+		if err != nil {
+			return nil, err
+		}
+		mandates = append(mandates, *m)
+	}
+	return mandates, rows.Err()
+}
+
+// utcPtr แปลงเวลาเป็น UTC ก่อนเก็บ เพื่อให้เทียบเวลาแบบ string ใน SQLite ได้ถูกต้อง
+func utcPtr(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	u := t.UTC()
+	return &u
+}
+
+// MandateIdemKey คือ idempotency key ของ transfer ที่ mandate สร้างในแต่ละรอบ
+// ถ้า runner ล่มหลังโอนแล้วแต่ก่อนบันทึกรอบ การรันซ้ำจะไม่โอนซ้ำ
+func MandateIdemKey(mandateID int, runAt time.Time) string {
+	return fmt.Sprintf("mandate-%d-%s", mandateID, runAt.UTC().Format("20060102T150405Z"))
+}
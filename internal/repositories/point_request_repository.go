@@ -0,0 +1,141 @@
+package repositories
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+const pointRequestColumns = `id, requester_id, payer_id, amount, note, status, transfer_id, decline_reason,
+		       expires_at, responded_at, created_at, updated_at`
+
+func scanPointRequest(row rowScanner) (*models.PointRequest, error) {
+	var pr models.PointRequest
+	err := row.Scan(
+		&pr.ID, &pr.RequesterID, &pr.PayerID, &pr.Amount, &pr.Note, &pr.Status, &pr.TransferID,
+		&pr.DeclineReason, &pr.ExpiresAt, &pr.RespondedAt, &pr.CreatedAt, &pr.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &pr, nil
+}
+
+type PointRequestRepository struct {
+	db *sql.DB
+}
+
+func NewPointRequestRepository(db *sql.DB) *PointRequestRepository {
+	return &PointRequestRepository{db: db}
+}
+
+func (r *PointRequestRepository) Create(req models.PointRequestCreateRequest, expiresAt, now time.Time) (*models.PointRequest, error) {
+	for _, userID := range []int{req.RequesterID, req.PayerID} {
+		var id int
+		if err := r.db.QueryRow("SELECT id FROM users WHERE id = ?", userID).Scan(&id); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, fmt.Errorf("user %d not found", userID)
+			}
+			return nil, err
+		}
+	}
+
+	now = now.UTC()
+	query := `
+		INSERT INTO point_requests (requester_id, payer_id, amount, note, status, expires_at, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING ` + pointRequestColumns
+
+	return scanPointRequest(r.db.QueryRow(query,
+		req.RequesterID, req.PayerID, req.Amount, req.Note, models.PointRequestStatusPending,
+		expiresAt.UTC(), now, now,
+	))
+}
+
+func (r *PointRequestRepository) GetByID(id int) (*models.PointRequest, error) {
+	pr, err := scanPointRequest(r.db.QueryRow("SELECT "+pointRequestColumns+" FROM point_requests WHERE id = ?", id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return pr, nil
+}
+
+// GetByUserID ดึงคำขอแต้มของ user ตามบทบาท: "incoming" (user เป็น payer), "outgoing" (user เป็น requester) หรือ "all"
+func (r *PointRequestRepository) GetByUserID(userID int, role string, status string) ([]models.PointRequest, error) {
+	query := "SELECT " + pointRequestColumns + " FROM point_requests WHERE "
+	args := []interface{}{}
+
+	switch role {
+	case "outgoing":
+		query += "requester_id = ?"
+		args = append(args, userID)
+	case "all":
+		query += "(requester_id = ? OR payer_id = ?)"
+		args = append(args, userID, userID)
+	default:
+		query += "payer_id = ?"
+		args = append(args, userID)
+	}
+
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at DESC, id DESC"
+
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var requests []models.PointRequest
+	for rows.Next() {
+		pr, err := scanPointRequest(rows)
+		if err != nil {
+			return nil, err
+		}
+		requests = append(requests, *pr)
+	}
+	return requests, rows.Err()
+}
+
+// TransitionStatus เปลี่ยนสถานะคำขอแบบมีเงื่อนไข คืน false ถ้าสถานะไม่ใช่ from แล้ว
+func (r *PointRequestRepository) TransitionStatus(id int, from, to models.PointRequestStatus, declineReason *string, now time.Time) (bool, error) {
+	now = now.UTC()
+	result, err := r.db.Exec(`
+		UPDATE point_requests
+		SET status = ?, decline_reason = COALESCE(?, decline_reason), responded_at = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		to, declineReason, now, now, id, from)
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	return affected == 1, err
+}
+
+// SetTransfer ผูก transfer ที่เกิดจากการ approve กับคำขอ
+func (r *PointRequestRepository) SetTransfer(id, transferID int, now time.Time) error {
+	_, err := r.db.Exec("UPDATE point_requests SET transfer_id = ?, updated_at = ? WHERE id = ?",
+		transferID, now.UTC(), id)
+	return err
+}
+
+// ExpirePending เปลี่ยนคำขอที่ยัง pending และเลยเวลาแล้วเป็น expired คืนจำนวนที่เปลี่ยน
+func (r *PointRequestRepository) ExpirePending(now time.Time) (int64, error) {
+	result, err := r.db.Exec(`
+		UPDATE point_requests
+		SET status = ?, updated_at = ?
+		WHERE status = ? AND expires_at <= ?`,
+		models.PointRequestStatusExpired, now.UTC(), models.PointRequestStatusPending, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
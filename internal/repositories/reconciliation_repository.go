@@ -0,0 +1,330 @@
+package repositories
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+// ReconciliationReference ใช้เป็น reference ของ adjust entry ที่ repair mode เขียน
+const ReconciliationReference = "reconciliation"
+
+// ReconciliationRepository ตรวจ users.points เทียบกับ point_ledger และ transfers
+// แต่ละการตรวจเป็น SELECT เดียว จึงเห็น snapshot เดียวกันโดยไม่ต้อง lock database ระหว่างตรวจ
+type ReconciliationRepository struct {
+	db *sql.DB
+}
+
+func NewReconciliationRepository(db *sql.DB) *ReconciliationRepository {
+	return &ReconciliationRepository{db: db}
+}
+
+// ledgerBalanceColumns คือยอดตาม ledger ของ user u: ยอดยกมาก่อน entry แรก (NULL ถ้าไม่มี entry)
+// บวกผลรวม change ทั้งหมด
+const ledgerBalanceColumns = `
+	(SELECT COUNT(*) FROM point_ledger WHERE user_id = u.id),
+	(SELECT balance_after - change FROM point_ledger WHERE user_id = u.id ORDER BY id LIMIT 1),
+	(SELECT COALESCE(SUM(change), 0) FROM point_ledger WHERE user_id = u.id)`
+
+type ledgerBalance struct {
+	userID  int
+	points  models.Points
+	entries int
+	opening *models.Points
+	sum     models.Points
+}
+
+func scanLedgerBalance(row rowScanner) (*ledgerBalance, error) {
+	var b ledgerBalance
+	if err := row.Scan(&b.userID, &b.points, &b.entries, &b.opening, &b.sum); err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+// expected คือยอดตาม ledger
+func (b *ledgerBalance) expected() models.Points {
+	if b.opening == nil {
+		return b.sum
+	}
+	return *b.opening + b.sum
+}
+
+// BalanceDiscrepancies คืนจำนวน user ที่ตรวจ และ user ที่ users.points ไม่ตรงกับยอดตาม ledger
+func (r *ReconciliationRepository) BalanceDiscrepancies() (int, []models.ReconciliationDiscrepancy, error) {
+	rows, err := r.db.Query(`SELECT u.id, u.points,` + ledgerBalanceColumns + ` FROM users u ORDER BY u.id`)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	checked := 0
+	var discrepancies []models.ReconciliationDiscrepancy
+	for rows.Next() {
+		b, err := scanLedgerBalance(rows)
+		if err != nil {
+			return 0, nil, err
+		}
+		checked++
+
+		expected := b.expected()
+		if b.points == expected {
+			continue
+		}
+		userID, actual := b.userID, b.points
+		d := models.ReconciliationDiscrepancy{
+			Kind:     models.DiscrepancyBalanceMismatch,
+			UserID:   &userID,
+			Expected: &expected,
+			Actual:   &actual,
+			Message:  fmt.Sprintf("cached balance %s does not match ledger balance %s", actual, expected),
+		}
+		if b.entries == 0 {
+			d.Kind = models.DiscrepancyUnledgeredBalance
+			d.Message = fmt.Sprintf("cached balance %s has no ledger entries", actual)
+		}
+		discrepancies = append(discrepancies, d)
+	}
+	return checked, discrepancies, rows.Err()
+}
+
+// ChainBreaks คืนจำนวน ledger entry ที่ตรวจ และ entry ที่ balance_after ไม่เท่ากับ balance_after ก่อนหน้า + change
+// entry ที่ถูก repair แล้วไม่นับ: drift คือ balance_after ลบยอดตาม ledger ถึง entry นั้น ถ้ามี adjust entry
+// ของ reconciliation ตั้งแต่ entry นั้นเป็นต้นไปที่ drift = 0 แปลว่า ledger ถูกปรับให้ตรงกับยอดจริงแล้ว
+func (r *ReconciliationRepository) ChainBreaks() (int, []models.ReconciliationDiscrepancy, error) {
+	var checked int
+	if err := r.db.QueryRow(`SELECT COUNT(*) FROM point_ledger`).Scan(&checked); err != nil {
+		return 0, nil, err
+	}
+
+	rows, err := r.db.Query(`
+		WITH chain AS (
+			SELECT id, user_id, change, balance_after, reference,
+			       LAG(balance_after) OVER w AS previous_balance,
+			       balance_after - FIRST_VALUE(balance_after - change) OVER w - SUM(change) OVER w AS drift
+			FROM point_ledger
+			WINDOW w AS (PARTITION BY user_id ORDER BY id)
+		)
+		SELECT c.id, c.user_id, c.change, c.balance_after, c.previous_balance
+		FROM chain c
+		WHERE c.previous_balance IS NOT NULL AND c.balance_after != c.previous_balance + c.change
+		  AND NOT EXISTS (
+			SELECT 1 FROM chain r
+			WHERE r.user_id = c.user_id AND r.id >= c.id AND r.reference = ? AND r.drift = 0
+		  )
+		ORDER BY c.id`, ReconciliationReference)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	var discrepancies []models.ReconciliationDiscrepancy
+	for rows.Next() {
+		var entryID, userID int
+		var change, balanceAfter, previous models.Points
+		if err := rows.Scan(&entryID, &userID, &change, &balanceAfter, &previous); err != nil {
+			return 0, nil, err
+		}
+		expected := previous + change
+		discrepancies = append(discrepancies, models.ReconciliationDiscrepancy{
+			Kind:          models.DiscrepancyChainBreak,
+			UserID:        &userID,
+			LedgerEntryID: &entryID,
+			Expected:      &expected,
+			Actual:        &balanceAfter,
+			Message: fmt.Sprintf("entry %d has balance_after %s but the previous entry left %s and change is %s",
+				entryID, balanceAfter, previous, change),
+		})
+	}
+	return checked, discrepancies, rows.Err()
+}
+
+// TransferPairMismatches คืนจำนวน transfer ที่ตรวจ และ transfer ที่ ledger entry ไม่ตรงกับสถานะ
+// completed และ reversed ต้องมี transfer_out ของผู้โอน (amount + fee) และ transfer_in ของผู้รับ (amount) อย่างละหนึ่ง
+// reversed ต้องมีคู่ชดเชย (reference reversal) อีกหนึ่งคู่ ส่วนสถานะอื่นต้องไม่มีเลย
+// entry ค่าธรรมเนียมของบัญชี house ไม่นับ
+func (r *ReconciliationRepository) TransferPairMismatches() (int, []models.ReconciliationDiscrepancy, error) {
+	rows, err := r.db.Query(`
+		SELECT t.id, t.status,
+			COUNT(CASE WHEN `+primaryLeg+` AND l.user_id = t.from_user_id AND l.event_type = 'transfer_out' THEN 1 END),
+			COUNT(CASE WHEN `+primaryLeg+` AND l.user_id = t.from_user_id AND l.event_type = 'transfer_out' AND l.change = -(t.amount + t.fee) THEN 1 END),
+			COUNT(CASE WHEN `+primaryLeg+` AND l.user_id = t.to_user_id AND l.event_type = 'transfer_in' THEN 1 END),
+			COUNT(CASE WHEN `+primaryLeg+` AND l.user_id = t.to_user_id AND l.event_type = 'transfer_in' AND l.change = t.amount THEN 1 END),
+			COUNT(CASE WHEN l.reference = ? AND l.user_id = t.to_user_id AND l.event_type = 'transfer_out' THEN 1 END),
+			COUNT(CASE WHEN l.reference = ? AND l.user_id = t.to_user_id AND l.event_type = 'transfer_out' AND l.change = -t.amount THEN 1 END),
+			COUNT(CASE WHEN l.reference = ? AND l.user_id = t.from_user_id AND l.event_type = 'transfer_in' THEN 1 END),
+			COUNT(CASE WHEN l.reference = ? AND l.user_id = t.from_user_id AND l.event_type = 'transfer_in' AND l.change = t.amount + t.fee THEN 1 END)
+		FROM transfers t
+		LEFT JOIN point_ledger l ON l.transfer_id = t.id
+		GROUP BY t.id
+		ORDER BY t.id`,
+		FeeReference, ReversalReference, FeeReference, ReversalReference, FeeReference, ReversalReference,
+		FeeReference, ReversalReference, ReversalReference, ReversalReference, ReversalReference, ReversalReference)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer rows.Close()
+
+	checked := 0
+	var discrepancies []models.ReconciliationDiscrepancy
+	for rows.Next() {
+		var transferID int
+		var status models.TransferStatus
+		var legs [8]int
+		if err := rows.Scan(&transferID, &status, &legs[0], &legs[1], &legs[2], &legs[3],
+			&legs[4], &legs[5], &legs[6], &legs[7]); err != nil {
+			return 0, nil, err
+		}
+		checked++
+
+		settled, reversed := 0, 0
+		if status == models.TransferStatusCompleted || status == models.TransferStatusReversed {
+			settled = 1
+		}
+		if status == models.TransferStatusReversed {
+			reversed = 1
+		}
+
+		var problems []string
+		for i, leg := range []struct {
+			name     string
+			expected int
+		}{
+			{"sender transfer_out", settled},
+			{"recipient transfer_in", settled},
+			{"reversal transfer_out", reversed},
+			{"reversal transfer_in", reversed},
+		} {
+			found, matching := legs[i*2], legs[i*2+1]
+			if found != leg.expected || matching != leg.expected {
+				problems = append(problems, fmt.Sprintf("%s: expected %d, found %d (%d with the right amount)",
+					leg.name, leg.expected, found, matching))
+			}
+		}
+		if len(problems) == 0 {
+			continue
+		}
+		id := transferID
+		discrepancies = append(discrepancies, models.ReconciliationDiscrepancy{
+			Kind:       models.DiscrepancyTransferPair,
+			TransferID: &id,
+			Message:    fmt.Sprintf("%s transfer: %s", status, strings.Join(problems, "; ")),
+		})
+	}
+	return checked, discrepancies, rows.Err()
+}
+
+// primaryLeg คือ entry หลักของ transfer (ไม่ใช่ค่าธรรมเนียมของ house หรือ entry ชดเชยการ reverse)
+const primaryLeg = `l.id IS NOT NULL AND COALESCE(l.reference, '') NOT IN (?, ?)`
+
+// RepairBalance เขียน adjust entry ให้ยอดตาม ledger ของ user เท่ากับ users.points คืน nil ถ้ายอดตรงกันอยู่แล้ว
+// balance_after ของ entry คือ users.points เสมอ แม้ chain ก่อนหน้าจะขาด (เช่น แก้แต้มผ่าน PUT /users/:id แล้วมีการโอนต่อ)
+func (r *ReconciliationRepository) RepairBalance(userID int, now time.Time) (*models.PointLedger, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// ตรวจซ้ำใน transaction เพราะอาจมีการโอนเกิดขึ้นหลังจากตรวจรอบแรก
+	b, err := scanLedgerBalance(tx.QueryRow(`SELECT u.id, u.points,`+ledgerBalanceColumns+` FROM users u WHERE u.id = ?`, userID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	expected := b.expected()
+	if b.points == expected {
+		return nil, nil
+	}
+
+	reference := ReconciliationReference
+	inserted := &models.PointLedger{
+		UserID: userID, Change: b.points - expected, BalanceAfter: b.points, EventType: models.EventTypeAdjust,
+		Reference: &reference, CreatedAt: now,
+		Metadata: ledgerMetadata(map[string]any{
+			"ledgerBalance": expected.String(), "cachedBalance": b.points.String(),
+		}),
+	}
+	if err := insertLedgerEntry(tx, inserted); err != nil {
+		return nil, err
+	}
+	entry, err := scanLedgerEntry(tx.QueryRow(`SELECT `+ledgerColumns+` FROM point_ledger WHERE id = ?`, inserted.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+const reconciliationReportColumns = `id, triggered_by, repair, users_checked, entries_checked, transfers_checked,
+	discrepancy_count, repaired_count, discrepancies, started_at, finished_at`
+
+func scanReconciliationReport(row rowScanner) (*models.ReconciliationReport, error) {
+	var report models.ReconciliationReport
+	var discrepancies string
+	err := row.Scan(&report.ID, &report.Trigger, &report.Repair, &report.UsersChecked, &report.EntriesChecked,
+		&report.TransfersChecked, &report.DiscrepancyCount, &report.RepairedCount, &discrepancies,
+		&report.StartedAt, &report.FinishedAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(discrepancies), &report.Discrepancies); err != nil {
+		return nil, fmt.Errorf("failed to decode discrepancies of report %d: %w", report.ID, err)
+	}
+	return &report, nil
+}
+
+// SaveReport บันทึกผลการรันและตั้ง report.ID
+func (r *ReconciliationRepository) SaveReport(report *models.ReconciliationReport) error {
+	discrepancies, err := json.Marshal(report.Discrepancies)
+	if err != nil {
+		return err
+	}
+	return r.db.QueryRow(`
+		INSERT INTO reconciliation_reports (triggered_by, repair, users_checked, entries_checked, transfers_checked,
+		                                    discrepancy_count, repaired_count, discrepancies, started_at, finished_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		RETURNING id`,
+		report.Trigger, report.Repair, report.UsersChecked, report.EntriesChecked, report.TransfersChecked,
+		report.DiscrepancyCount, report.RepairedCount, string(discrepancies), report.StartedAt, report.FinishedAt,
+	).Scan(&report.ID)
+}
+
+// GetReport ดึงผลการรันตาม id (nil ถ้าไม่มี)
+func (r *ReconciliationRepository) GetReport(id int) (*models.ReconciliationReport, error) {
+	report, err := scanReconciliationReport(r.db.QueryRow(
+		`SELECT `+reconciliationReportColumns+` FROM reconciliation_reports WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return report, err
+}
+
+// ListReports ดึงผลการรันล่าสุดก่อน
+func (r *ReconciliationRepository) ListReports(limit int) ([]models.ReconciliationReport, error) {
+	rows, err := r.db.Query(`SELECT `+reconciliationReportColumns+` FROM reconciliation_reports ORDER BY id DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reports []models.ReconciliationReport
+	for rows.Next() {
+		report, err := scanReconciliationReport(rows)
+		if err != nil {
+			return nil, err
+		}
+		reports = append(reports, *report)
+	}
+	return reports, rows.Err()
+}
@@ -0,0 +1,108 @@
+package repositories
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+// ErrDuplicateRuleName เกิดเมื่อสร้าง rule ด้วยชื่อที่มีอยู่แล้ว
+var ErrDuplicateRuleName = errors.New("rule name already exists")
+
+type RuleRepository struct {
+	db *sql.DB
+}
+
+func NewRuleRepository(db *sql.DB) *RuleRepository {
+	return &RuleRepository{db: db}
+}
+
+const ruleColumns = `name, position, description, expression, error_code, message, enabled, mode,
+	created_at, updated_at`
+
+func scanRule(row rowScanner) (*models.TransferRule, error) {
+	var rule models.TransferRule
+	err := row.Scan(
+		&rule.Name,
+		&rule.Position,
+		&rule.Description,
+		&rule.Expression,
+		&rule.ErrorCode,
+		&rule.Message,
+		&rule.Enabled,
+		&rule.Mode,
+		&rule.CreatedAt,
+		&rule.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+// GetAll ดึง rule ทั้งหมดตามลำดับที่ใช้ประเมิน
+func (r *RuleRepository) GetAll() ([]models.TransferRule, error) {
+	rows, err := r.db.Query(`SELECT ` + ruleColumns + ` FROM transfer_rules ORDER BY position, name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.TransferRule
+	for rows.Next() {
+		rule, err := scanRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, *rule)
+	}
+	return rules, rows.Err()
+}
+
+// GetByName ดึง rule ตามชื่อ (nil ถ้าไม่มี)
+func (r *RuleRepository) GetByName(name string) (*models.TransferRule, error) {
+	rule, err := scanRule(r.db.QueryRow(`SELECT `+ruleColumns+` FROM transfer_rules WHERE name = ?`, name))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return rule, err
+}
+
+// Create เพิ่ม rule ใหม่ คืน ErrDuplicateRuleName ถ้าชื่อซ้ำ
+func (r *RuleRepository) Create(rule *models.TransferRule) error {
+	now := time.Now()
+	rule.CreatedAt, rule.UpdatedAt = now, now
+	_, err := r.db.Exec(`
+		INSERT INTO transfer_rules (`+ruleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.Name, rule.Position, rule.Description, rule.Expression, rule.ErrorCode, rule.Message,
+		rule.Enabled, rule.Mode, rule.CreatedAt, rule.UpdatedAt)
+	if isUniqueViolation(err, "transfer_rules.name") {
+		return ErrDuplicateRuleName
+	}
+	return err
+}
+
+func (r *RuleRepository) Update(rule *models.TransferRule) error {
+	rule.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+		UPDATE transfer_rules
+		SET position = ?, description = ?, expression = ?, error_code = ?, message = ?,
+		    enabled = ?, mode = ?, updated_at = ?
+		WHERE name = ?`,
+		rule.Position, rule.Description, rule.Expression, rule.ErrorCode, rule.Message,
+		rule.Enabled, rule.Mode, rule.UpdatedAt, rule.Name)
+	return err
+}
+
+// Delete ลบ rule คืน false ถ้าไม่มี rule ชื่อนี้
+func (r *RuleRepository) Delete(name string) (bool, error) {
+	result, err := r.db.Exec(`DELETE FROM transfer_rules WHERE name = ?`, name)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
@@ -2,7 +2,9 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"kbtg-backend/internal/models"
@@ -10,6 +12,55 @@ import (
 	"github.com/google/uuid"
 )
 
+var (
+	// ErrDuplicateIdemKey ถูกคืนเมื่อผู้โอนใช้ Idempotency-Key นี้ไปแล้ว (UNIQUE constraint ของ client_key)
+	ErrDuplicateIdemKey = errors.New("idempotency key already exists")
+	// ErrTransferNotFound ถูกคืนเมื่อไม่พบ transfer ที่ต้องการแก้สถานะ
+	ErrTransferNotFound = errors.New("transfer not found")
+	// ErrTransferNotReversible ถูกคืนเมื่อ transfer ไม่ได้อยู่ในสถานะ completed
+	ErrTransferNotReversible = errors.New("transfer cannot be reversed")
+	// ErrStatusConflict ถูกคืนเมื่อสถานะ transfer เปลี่ยนไปก่อนที่จะ update ได้
+	ErrStatusConflict = errors.New("transfer status changed")
+	// ErrInsufficientPointsForReversal ถูกคืนเมื่อผู้รับมีแต้มไม่พอให้ดึงคืน
+	ErrInsufficientPointsForReversal = errors.New("insufficient points to reverse")
+)
+
+// clientKeyIndex คือ column ของ UNIQUE index idx_transfers_client_key ตามที่อยู่ใน error ของ SQLite
+const clientKeyIndex = "transfers.from_user_id, transfers.client_key"
+
+// ReversalReference ใช้เป็น reference ของ ledger entry ที่ชดเชยการ reverse
+const ReversalReference = "reversal"
+
+// FeeReference ใช้เป็น reference ของ ledger entry ที่บัญชี house ได้รับค่าธรรมเนียม
+const FeeReference = "fee"
+
+// transferColumns คือ column ที่ใช้ select transfer ทุกที่ ต้องตรงกับลำดับใน scanTransfer
+const transferColumns = `id, idempotency_key, from_user_id, to_user_id, amount, status, note,
+		       created_at, updated_at, completed_at, fail_reason, request_hash,
+		       reversed_at, reversal_reason, expires_at, scheduled_at, mandate_id, batch_id,
+		       fail_code, fail_rule, fee, client_key`
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanTransfer(row rowScanner) (*models.Transfer, error) {
+	var transfer models.Transfer
+	err := row.Scan(
+		&transfer.ID, &transfer.IdemKey, &transfer.FromUserID, &transfer.ToUserID,
+		&transfer.Amount, &transfer.Status, &transfer.Note, &transfer.CreatedAt,
+		&transfer.UpdatedAt, &transfer.CompletedAt, &transfer.FailReason, &transfer.RequestHash,
+		&transfer.ReversedAt, &transfer.ReversalReason, &transfer.ExpiresAt,
+		&transfer.ScheduledAt, &transfer.MandateID, &transfer.BatchID,
+		&transfer.FailCode, &transfer.FailRule, &transfer.Fee, &transfer.ClientKey,
+	)
+	if err != nil {
+		return nil, err
+	}
+	transfer.TotalDebited = transfer.Amount + transfer.Fee
+	return &transfer, nil
+}
+
 type TransferRepository struct {
 	db *sql.DB
 }
@@ -27,114 +78,680 @@ func (r *TransferRepository) Create(req models.TransferCreateRequest) (*models.T
 	}
 	defer tx.Rollback()
 
-	// Generate idempotency key
 	idemKey := uuid.New().String()
 	now := time.Now()
 
-	// ตรวจสอบว่า fromUser มีแต้มพอหรือไม่
-	var fromUserPoints float64
-	err = tx.QueryRow("SELECT points FROM users WHERE id = ?", req.FromUserID).Scan(&fromUserPoints)
+	// ตรวจสอบว่า toUser มีอยู่จริง (แต้มของ fromUser ถูกตรวจตอนหักใน movePoints)
+	if err := checkUserExists(tx, req.ToUserID, "to user not found"); err != nil {
+		return nil, err
+	}
+
+	// สร้าง transfer record
+	transferQuery := `
+		INSERT INTO transfers (idempotency_key, client_key, request_hash, from_user_id, to_user_id, amount, fee, status, note,
+		                      created_at, updated_at, completed_at, mandate_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+
+	result, err := tx.Exec(transferQuery, idemKey, nullableString(req.IdemKey), nullableString(req.RequestHash),
+		req.FromUserID, req.ToUserID, req.Amount, req.Fee, models.TransferStatusCompleted, req.Note, now, now, now, req.MandateID)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("from user not found")
+		if isUniqueViolation(err, clientKeyIndex) {
+			return nil, ErrDuplicateIdemKey
 		}
 		return nil, err
 	}
 
-	if fromUserPoints < req.Amount {
-		return nil, fmt.Errorf("insufficient points: have %.2f, need %.2f", fromUserPoints, req.Amount)
+	transferID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	// ย้ายแต้มและเขียน ledger
+	if err := movePoints(tx, int(transferID), req.FromUserID, req.ToUserID, req.Amount, req.Fee, nil, now); err != nil {
+		return nil, err
 	}
 
-	// ตรวจสอบว่า toUser มีอยู่จริง
-	var toUserPoints float64
-	err = tx.QueryRow("SELECT points FROM users WHERE id = ?", req.ToUserID).Scan(&toUserPoints)
+	// Commit transaction
+	if err := tx.Commit(); err != nil {
+		if isUniqueViolation(err, clientKeyIndex) {
+			return nil, ErrDuplicateIdemKey
+		}
+		return nil, err
+	}
+
+	// ดึงข้อมูล transfer ที่สร้างเสร็จแล้ว
+	transfer, err := r.GetByIdemKey(idemKey)
 	if err != nil {
-		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("to user not found")
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// BatchLegError บอกว่า leg ไหนของ batch ทำให้ทั้ง batch ถูก rollback
+type BatchLegError struct {
+	Index int
+	Err   error
+}
+
+func (e *BatchLegError) Error() string {
+	return fmt.Sprintf("leg %d: %v", e.Index, e.Err)
+}
+
+func (e *BatchLegError) Unwrap() error {
+	return e.Err
+}
+
+// CreateBatch โอนจากผู้โอนคนเดียวไปหลายผู้รับภายใน transaction เดียว
+// ทุก leg ได้ transfer ของตัวเองที่มี batch_id ร่วมกัน และ ledger ใช้ reference "batch:<batchID>"
+//
+// allOrNothing = true: leg แรกที่ไม่ผ่าน (แต้มไม่พอ, ไม่พบผู้รับ) จะ rollback ทั้งหมดและคืน *BatchLegError
+// allOrNothing = false: leg ที่ไม่ผ่านจะถูกข้าม และ error ของ leg นั้นอยู่ใน legErrs ตามตำแหน่ง
+func (r *TransferRepository) CreateBatch(batchID string, legs []models.TransferCreateRequest, allOrNothing bool) (transfers []*models.Transfer, legErrs []error, err error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	reference := "batch:" + batchID
+	idemKeys := make([]string, len(legs))
+	legErrs = make([]error, len(legs))
+
+	for i, leg := range legs {
+		// แต่ละ leg อยู่ใน savepoint ของตัวเอง leg ที่แต้มไม่พอ (เพราะ leg ก่อนหน้าหักไปแล้ว)
+		// หรือไม่มีผู้รับจะถูกย้อนเฉพาะ leg นั้น
+		legErr, err := createBatchLeg(tx, batchID, i, leg, &reference, now)
+		if err != nil {
+			return nil, nil, err
+		}
+		if legErr != nil {
+			if allOrNothing {
+				return nil, nil, &BatchLegError{Index: i, Err: legErr}
+			}
+			legErrs[i] = legErr
+			continue
+		}
+		idemKeys[i] = batchLegIdemKey(batchID, i)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, nil, err
+	}
+
+	transfers = make([]*models.Transfer, len(legs))
+	for i, key := range idemKeys {
+		if key == "" {
+			continue
+		}
+		if transfers[i], err = r.GetByIdemKey(key); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return transfers, legErrs, nil
+}
+
+func batchLegIdemKey(batchID string, index int) string {
+	return fmt.Sprintf("%s-%d", batchID, index)
+}
+
+// createBatchLeg สร้าง transfer ของ leg หนึ่งภายใน savepoint
+// คืน legErr ถ้า leg ถูกปฏิเสธ (แต้มไม่พอ หรือไม่มี user) ส่วน err คือความผิดพลาดที่ต้องยกเลิกทั้ง batch
+func createBatchLeg(tx *sql.Tx, batchID string, index int, leg models.TransferCreateRequest, reference *string, now time.Time) (legErr, err error) {
+	if _, err := tx.Exec("SAVEPOINT batch_leg"); err != nil {
+		return nil, err
+	}
+
+	legErr, err = func() (error, error) {
+		if err := checkUserExists(tx, leg.ToUserID, "to user not found"); err != nil {
+			return err, nil
+		}
+
+		result, err := tx.Exec(`
+			INSERT INTO transfers (idempotency_key, from_user_id, to_user_id, amount, fee, status, note,
+			                      created_at, updated_at, completed_at, batch_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			batchLegIdemKey(batchID, index), leg.FromUserID, leg.ToUserID, leg.Amount, leg.Fee,
+			models.TransferStatusCompleted, leg.Note, now, now, now, batchID)
+		if err != nil {
+			return nil, err
+		}
+		transferID, err := result.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+
+		err = movePoints(tx, int(transferID), leg.FromUserID, leg.ToUserID, leg.Amount, leg.Fee, reference, now)
+		if errors.Is(err, ErrInsufficientPoints) || isUserNotFound(err) {
+			return err, nil
 		}
 		return nil, err
+	}()
+
+	if legErr != nil || err != nil {
+		if _, rbErr := tx.Exec("ROLLBACK TO batch_leg"); rbErr != nil && err == nil {
+			err = rbErr
+		}
+	}
+	if _, relErr := tx.Exec("RELEASE batch_leg"); relErr != nil && err == nil {
+		err = relErr
 	}
+	return legErr, err
+}
 
-	// สร้าง transfer record
-	transferQuery := `
-		INSERT INTO transfers (idempotency_key, from_user_id, to_user_id, amount, status, note, 
-		                      created_at, updated_at, completed_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
+func isUserNotFound(err error) bool {
+	return err != nil && strings.HasSuffix(err.Error(), "user not found")
+}
 
-	result, err := tx.Exec(transferQuery, idemKey, req.FromUserID, req.ToUserID, req.Amount,
-		models.TransferStatusCompleted, req.Note, now, now, now)
+// CreatePending สร้าง transfer สถานะ pending และ hold แต้มของผู้โอน (amount + fee) ไว้จนถึง expiresAt
+// ถ้ามี review จะเพิ่ม transfer เข้าคิวตรวจ fraud ใน transaction เดียวกัน (confirm เองไม่ได้จนกว่าจะอนุมัติ)
+// expires_at ของ transfer และ hold เก็บเป็น UTC เพราะ ListExpiredPending เทียบกับ now.UTC() แบบ string
+func (r *TransferRepository) CreatePending(req models.TransferCreateRequest, expiresAt time.Time, review *models.FraudAssessment) (*models.Transfer, error) {
+	expiresAt = expiresAt.UTC()
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	idemKey := uuid.New().String()
+	now := time.Now()
+	total := req.Amount + req.Fee
+
+	if err := checkUserExists(tx, req.ToUserID, "to user not found"); err != nil {
+		return nil, err
+	}
+
+	result, err := tx.Exec(`
+		INSERT INTO transfers (idempotency_key, client_key, request_hash, from_user_id, to_user_id, amount, fee, status, note,
+		                      created_at, updated_at, expires_at, mandate_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		idemKey, nullableString(req.IdemKey), nullableString(req.RequestHash), req.FromUserID, req.ToUserID, req.Amount, req.Fee,
+		models.TransferStatusPending, req.Note, now, now, expiresAt, req.MandateID)
+	if err != nil {
+		if isUniqueViolation(err, clientKeyIndex) {
+			return nil, ErrDuplicateIdemKey
+		}
+		return nil, err
+	}
 
 	transferID, err := result.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
 
-	// Update points ของ sender
-	newFromBalance := fromUserPoints - req.Amount
-	_, err = tx.Exec("UPDATE users SET points = ?, updated_at = ? WHERE id = ?",
-		newFromBalance, now, req.FromUserID)
+	// hold ได้เฉพาะเมื่อแต้มที่ใช้ได้พอสำหรับ amount + fee
+	id := int(transferID)
+	if _, err := reserveHold(tx, req.FromUserID, total, &id, nil, expiresAt, now); err != nil {
+		return nil, err
+	}
+
+	if review != nil {
+		if err := insertFraudReview(tx, id, *review, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		if isUniqueViolation(err, clientKeyIndex) {
+			return nil, ErrDuplicateIdemKey
+		}
+		return nil, err
+	}
+
+	return r.GetByIdemKey(idemKey)
+}
+
+// RecordFailed บันทึกการโอนที่ถูกปฏิเสธเป็น transfer สถานะ failed
+// เรียกหลังจาก transaction ของการโอนถูก rollback ไปแล้ว จึงไม่แตะแต้มหรือ ledger
+// ใช้ idempotency key ใหม่เสมอ เพื่อให้ client retry ด้วย key เดิมแล้วโอนได้จริงเมื่อแก้ปัญหาแล้ว
+func (r *TransferRepository) RecordFailed(req models.TransferCreateRequest, batchID *string, failure models.TransferFailure) (*models.Transfer, error) {
+	now := time.Now()
+	transfer := &models.Transfer{
+		IdemKey:    uuid.New().String(),
+		FromUserID: req.FromUserID,
+		ToUserID:   req.ToUserID,
+		Amount:     req.Amount,
+		Fee:        req.Fee,
+		Status:     models.TransferStatusFailed,
+		Note:       req.Note,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+		FailReason: &failure.Reason,
+		FailCode:   &failure.Code,
+		FailRule:   failure.Rule,
+		MandateID:  req.MandateID,
+		BatchID:    batchID,
+	}
+
+	result, err := r.db.Exec(`
+		INSERT INTO transfers (idempotency_key, from_user_id, to_user_id, amount, fee, status, note,
+		                      created_at, updated_at, fail_reason, fail_code, fail_rule, mandate_id, batch_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		transfer.IdemKey, transfer.FromUserID, transfer.ToUserID, transfer.Amount, transfer.Fee, transfer.Status, transfer.Note,
+		now, now, transfer.FailReason, transfer.FailCode, transfer.FailRule, transfer.MandateID, batchID)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := result.LastInsertId()
 	if err != nil {
 		return nil, err
 	}
+	transfer.ID = int(id)
+	return transfer, nil
+}
 
-	// Update points ของ receiver
-	newToBalance := toUserPoints + req.Amount
-	_, err = tx.Exec("UPDATE users SET points = ?, updated_at = ? WHERE id = ?",
-		newToBalance, now, req.ToUserID)
+// CreateScheduled สร้าง transfer ล่วงหน้าในสถานะ pending โดยยังไม่ hold แต้ม
+// ยอดคงเหลือจะถูกตรวจตอนถึงเวลาโอนจริง scheduled_at เก็บเป็น UTC เพื่อให้เทียบเวลาใน SQLite ได้ถูกต้อง
+func (r *TransferRepository) CreateScheduled(req models.TransferCreateRequest) (*models.Transfer, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
 
-	// เพิ่ม ledger entry สำหรับ sender (ลบแต้ม)
-	ledgerQuery := `
-		INSERT INTO point_ledger (user_id, change, balance_after, event_type, transfer_id, created_at)
-		VALUES (?, ?, ?, ?, ?, ?)`
+	idemKey := uuid.New().String()
+	now := time.Now()
 
-	_, err = tx.Exec(ledgerQuery, req.FromUserID, -req.Amount, newFromBalance,
-		models.EventTypeTransferOut, transferID, now)
+	if err := checkUserExists(tx, req.FromUserID, "from user not found"); err != nil {
+		return nil, err
+	}
+	if err := checkUserExists(tx, req.ToUserID, "to user not found"); err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO transfers (idempotency_key, client_key, request_hash, from_user_id, to_user_id, amount, fee, status, note,
+		                      created_at, updated_at, scheduled_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		idemKey, nullableString(req.IdemKey), nullableString(req.RequestHash), req.FromUserID, req.ToUserID, req.Amount, req.Fee,
+		models.TransferStatusPending, req.Note, now, now, req.ScheduledAt.UTC())
 	if err != nil {
+		if isUniqueViolation(err, clientKeyIndex) {
+			return nil, ErrDuplicateIdemKey
+		}
 		return nil, err
 	}
 
-	// เพิ่ม ledger entry สำหรับ receiver (เพิ่มแต้ม)
-	_, err = tx.Exec(ledgerQuery, req.ToUserID, req.Amount, newToBalance,
-		models.EventTypeTransferIn, transferID, now)
+	if err := tx.Commit(); err != nil {
+		if isUniqueViolation(err, clientKeyIndex) {
+			return nil, ErrDuplicateIdemKey
+		}
+		return nil, err
+	}
+
+	return r.GetByIdemKey(idemKey)
+}
+
+// ListDueScheduled ดึง transfer ล่วงหน้าที่ถึงเวลาโอนแล้ว
+func (r *TransferRepository) ListDueScheduled(now time.Time, limit int) ([]models.Transfer, error) {
+	return r.queryTransfers(`
+		SELECT `+transferColumns+`
+		FROM transfers
+		WHERE status = ? AND scheduled_at IS NOT NULL AND scheduled_at <= ?
+		ORDER BY scheduled_at, id
+		LIMIT ?`,
+		models.TransferStatusPending, now.UTC(), limit)
+}
+
+// ListScheduledByUser ดึง transfer ล่วงหน้าที่ user ตั้งไว้และยังไม่ถูกโอน
+func (r *TransferRepository) ListScheduledByUser(userID int) ([]models.Transfer, error) {
+	return r.queryTransfers(`
+		SELECT `+transferColumns+`
+		FROM transfers
+		WHERE from_user_id = ? AND status = ? AND scheduled_at IS NOT NULL
+		ORDER BY scheduled_at, id`,
+		userID, models.TransferStatusPending)
+}
+
+// TransitionStatus เปลี่ยนสถานะ transfer จาก from เป็น to แบบมีเงื่อนไข
+// ถ้าสถานะใหม่เป็น cancelled หรือ failed จะปล่อย hold ที่ค้างอยู่ใน transaction เดียวกัน
+// และ review fraud ที่ยังรอของ transfer ที่ถูกยกเลิกจะถูกปิด
+func (r *TransferRepository) TransitionStatus(id int, from, to models.TransferStatus, failure *models.TransferFailure) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var failReason, failCode, failRule *string
+	if failure != nil {
+		failReason, failCode, failRule = &failure.Reason, &failure.Code, failure.Rule
+	}
+
+	now := time.Now()
+	result, err := tx.Exec(`
+		UPDATE transfers
+		SET status = ?, fail_reason = COALESCE(?, fail_reason), fail_code = COALESCE(?, fail_code),
+		    fail_rule = COALESCE(?, fail_rule), updated_at = ?
+		WHERE id = ? AND status = ?`,
+		to, failReason, failCode, failRule, now, id, from)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return fmt.Errorf("%w: transfer %d is no longer %s", ErrStatusConflict, id, from)
+	}
+
+	if to == models.TransferStatusCancelled || to == models.TransferStatusFailed {
+		if err := closeTransferHold(tx, id, models.HoldStatusReleased, now); err != nil {
+			return err
+		}
+	}
+	if to == models.TransferStatusCancelled {
+		if err := closeFraudReview(tx, id, now); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// Settle ทำขั้นสุดท้ายของ transfer ที่อยู่ในสถานะ processing:
+// capture hold, ย้ายแต้ม, เขียน ledger และเปลี่ยนเป็น completed
+func (r *TransferRepository) Settle(id int) (*models.Transfer, error) {
+	tx, err := r.db.Begin()
 	if err != nil {
 		return nil, err
 	}
+	defer tx.Rollback()
+
+	transfer, err := scanTransfer(tx.QueryRow(`
+		SELECT `+transferColumns+`
+		FROM transfers
+		WHERE id = ?`, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+	if transfer.Status != models.TransferStatusProcessing {
+		return nil, fmt.Errorf("%w: transfer %d is %s", ErrStatusConflict, id, transfer.Status)
+	}
+
+	now := time.Now()
+
+	// แต้มถูก hold ไว้แล้ว ปิด hold ก่อนแล้วค่อยหักจริง
+	if err := closeTransferHold(tx, id, models.HoldStatusCaptured, now); err != nil {
+		return nil, err
+	}
+	if err := movePoints(tx, id, transfer.FromUserID, transfer.ToUserID, transfer.Amount, transfer.Fee, nil, now); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.Exec(`
+		UPDATE transfers
+		SET status = ?, updated_at = ?, completed_at = ?
+		WHERE id = ?`,
+		models.TransferStatusCompleted, now, now, id); err != nil {
+		return nil, err
+	}
 
-	// Commit transaction
 	if err := tx.Commit(); err != nil {
 		return nil, err
 	}
 
-	// ดึงข้อมูล transfer ที่สร้างเสร็จแล้ว
-	transfer, err := r.GetByIdemKey(idemKey)
+	return r.GetByIdemKey(transfer.IdemKey)
+}
+
+// ListExpiredPending ดึง transfer ที่ยัง pending แต่เลยเวลา expires_at แล้ว (expires_at เป็น UTC)
+func (r *TransferRepository) ListExpiredPending(now time.Time, limit int) ([]models.Transfer, error) {
+	return r.queryTransfers(`
+		SELECT `+transferColumns+`
+		FROM transfers
+		WHERE status = ? AND expires_at IS NOT NULL AND expires_at <= ?
+		ORDER BY expires_at
+		LIMIT ?`,
+		models.TransferStatusPending, now.UTC(), limit)
+}
+
+// queryTransfers รัน query ที่ select transferColumns แล้วคืนเป็น slice
+func (r *TransferRepository) queryTransfers(query string, args ...interface{}) ([]models.Transfer, error) {
+	rows, err := r.db.Query(query, args...)
 	if err != nil {
 		return nil, err
 	}
+	defer rows.Close()
 
-	return transfer, nil
+	var transfers []models.Transfer
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, err
+		}
+		transfers = append(transfers, *transfer)
+	}
+
+	return transfers, rows.Err()
+}
+
+// GetLedgerEntries ดึง ledger entry ทั้งหมดของ transfer ตามลำดับที่เขียน
+func (r *TransferRepository) GetLedgerEntries(transferID int) ([]models.PointLedger, error) {
+	rows, err := r.db.Query(`
+		SELECT `+ledgerColumns+`
+		FROM point_ledger
+		WHERE transfer_id = ?
+		ORDER BY id`, transferID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []models.PointLedger
+	for rows.Next() {
+		entry, err := scanLedgerEntry(rows)
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, *entry)
+	}
+	return entries, rows.Err()
+}
+
+// movePoints หักแต้มผู้โอน (amount + fee) เพิ่มแต้มผู้รับ (amount) และเขียน ledger ทั้งสองฝั่ง
+// reference จะถูกเขียนลง ledger ทั้งสองฝั่ง (nil ถ้าไม่มี)
+// ถ้ามี fee จะเขียน ledger ขาที่สามให้บัญชี house ใน transaction เดียวกัน
+func movePoints(tx *sql.Tx, transferID, fromUserID, toUserID int, amount, fee models.Points, reference *string, now time.Time) error {
+	// หัก sender ก่อน ถ้าแต้มไม่พอ UPDATE จะไม่มีผลและคืน error
+	debited := amount + fee
+	newFromBalance, err := applyPointChange(tx, fromUserID, -debited, false, now)
+	if err != nil {
+		return err
+	}
+
+	newToBalance, err := applyPointChange(tx, toUserID, amount, false, now)
+	if err != nil {
+		return err
+	}
+
+	// เพิ่ม ledger entry สำหรับ sender (ลบแต้ม)
+	err = insertLedgerEntry(tx, &models.PointLedger{
+		UserID: fromUserID, Change: -debited, BalanceAfter: newFromBalance, EventType: models.EventTypeTransferOut,
+		TransferID: &transferID, Reference: reference, CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	// เพิ่ม ledger entry สำหรับ receiver (เพิ่มแต้ม)
+	err = insertLedgerEntry(tx, &models.PointLedger{
+		UserID: toUserID, Change: amount, BalanceAfter: newToBalance, EventType: models.EventTypeTransferIn,
+		TransferID: &transferID, Reference: reference, CreatedAt: now,
+	})
+	if err != nil {
+		return err
+	}
+
+	if fee > 0 {
+		return moveHousePoints(tx, transferID, fee, FeeReference, nil, now)
+	}
+	return nil
+}
+
+// moveHousePoints เปลี่ยนแต้มของบัญชี house และเขียน ledger (change > 0 รับค่าธรรมเนียม, < 0 คืนค่าธรรมเนียม)
+// reason ถูกเก็บใน metadata ของ ledger เหมือน ledger ชดเชยของการ reverse (nil ถ้าไม่มี)
+// บัญชี house ติดลบได้ชั่วคราวตอนคืนค่าธรรมเนียม เพราะเป็นบัญชีของระบบ
+func moveHousePoints(tx *sql.Tx, transferID int, change models.Points, reference string, reason *string, now time.Time) error {
+	var houseID int
+	err := tx.QueryRow("SELECT id FROM users WHERE member_id = ?", models.HouseMemberID).Scan(&houseID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return errors.New("house account not found")
+		}
+		return err
+	}
+
+	newBalance, err := applyPointChange(tx, houseID, change, true, now)
+	if err != nil {
+		return err
+	}
+
+	eventType := models.EventTypeTransferIn
+	if change < 0 {
+		eventType = models.EventTypeTransferOut
+	}
+	entry := &models.PointLedger{
+		UserID: houseID, Change: change, BalanceAfter: newBalance, EventType: eventType,
+		TransferID: &transferID, Reference: &reference, CreatedAt: now,
+	}
+	if reason != nil {
+		entry.Metadata = ledgerMetadata(map[string]any{"reason": *reason})
+	}
+	return insertLedgerEntry(tx, entry)
+}
+
+func checkUserExists(tx *sql.Tx, userID int, notFoundMsg string) error {
+	var id int
+	err := tx.QueryRow("SELECT id FROM users WHERE id = ?", userID).Scan(&id)
+	if err == sql.ErrNoRows {
+		return errors.New(notFoundMsg)
+	}
+	return err
+}
+
+func nullableString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// Reverse ดึงแต้มคืนจากผู้รับไปให้ผู้โอน พร้อมเขียน ledger ชดเชยภายใน transaction เดียว
+// ค่าธรรมเนียมถูกคืนให้ผู้โอนจากบัญชี house ด้วย
+// ถ้า transfer ถูก reverse ไปแล้วจะคืน transfer เดิมโดยไม่ทำซ้ำ
+// allowNegative = true ยอมให้ผู้รับติดลบได้เมื่อใช้แต้มไปแล้ว
+// dispute != nil คือการ reverse จากการตัดสิน dispute: สถานะ dispute เปลี่ยนใน transaction เดียวกัน
+// ถ้าไม่ระบุ dispute ที่ยังเปิดอยู่ของ transfer จะถูกปิดเป็น resolved (reversed)
+func (r *TransferRepository) Reverse(idemKey, reason string, allowNegative bool, dispute *DisputeTransition) (*models.Transfer, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	transfer, err := scanTransfer(tx.QueryRow(`
+		SELECT `+transferColumns+`
+		FROM transfers
+		WHERE idempotency_key = ?`, idemKey))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, ErrTransferNotFound
+		}
+		return nil, err
+	}
+
+	// reverse ซ้ำให้ผลเหมือนเดิม (idempotent) ยกเว้นจาก dispute ซึ่งต้องเปลี่ยนสถานะ dispute ด้วย
+	if transfer.Status == models.TransferStatusReversed && dispute == nil {
+		return transfer, nil
+	}
+	if transfer.Status != models.TransferStatusCompleted {
+		return nil, fmt.Errorf("%w: status is %s", ErrTransferNotReversible, transfer.Status)
+	}
+
+	now := time.Now()
+
+	// เปลี่ยนสถานะแบบมีเงื่อนไข กัน request reverse ที่วิ่งพร้อมกัน
+	result, err := tx.Exec(`
+		UPDATE transfers
+		SET status = ?, reversed_at = ?, reversal_reason = ?, updated_at = ?
+		WHERE id = ? AND status = ?`,
+		models.TransferStatusReversed, now, reason, now, transfer.ID, models.TransferStatusCompleted)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, err
+	} else if affected == 0 {
+		return nil, fmt.Errorf("%w: status changed concurrently", ErrTransferNotReversible)
+	}
+
+	// ดึงแต้มคืนจากผู้รับ: ถ้าไม่ยอมให้ติดลบ ต้องมีแต้มที่ใช้ได้พอ (ไม่นับแต้มที่ถูก hold)
+	newReceiverBalance, err := applyPointChange(tx, transfer.ToUserID, -transfer.Amount, allowNegative, now)
+	if err != nil {
+		if errors.Is(err, ErrInsufficientPoints) {
+			return nil, fmt.Errorf("%w: %v", ErrInsufficientPointsForReversal, strings.TrimPrefix(err.Error(), ErrInsufficientPoints.Error()+": "))
+		}
+		return nil, err
+	}
+	newSenderBalance, err := applyPointChange(tx, transfer.FromUserID, transfer.TotalDebited, false, now)
+	if err != nil {
+		return nil, err
+	}
+
+	// ledger ชดเชย: ผู้รับถูกหักคืน ผู้โอนได้แต้มคืน โดยผูกกับ transfer เดิม
+	reference, metadata := ReversalReference, ledgerMetadata(map[string]any{"reason": reason})
+	if err := insertLedgerEntry(tx, &models.PointLedger{
+		UserID: transfer.ToUserID, Change: -transfer.Amount, BalanceAfter: newReceiverBalance,
+		EventType: models.EventTypeTransferOut, TransferID: &transfer.ID, Reference: &reference,
+		Metadata: metadata, CreatedAt: now,
+	}); err != nil {
+		return nil, err
+	}
+	if err := insertLedgerEntry(tx, &models.PointLedger{
+		UserID: transfer.FromUserID, Change: transfer.TotalDebited, BalanceAfter: newSenderBalance,
+		EventType: models.EventTypeTransferIn, TransferID: &transfer.ID, Reference: &reference,
+		Metadata: metadata, CreatedAt: now,
+	}); err != nil {
+		return nil, err
+	}
+	if transfer.Fee > 0 {
+		if err := moveHousePoints(tx, transfer.ID, -transfer.Fee, ReversalReference, &reason, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if dispute != nil {
+		err = transitionDispute(tx, *dispute, now)
+	} else {
+		err = resolveActiveDisputes(tx, transfer.ID, reason, now)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return r.GetByIdemKey(idemKey)
 }
 
 // GetByIdemKey ดึงข้อมูล transfer ด้วย idempotency key
 func (r *TransferRepository) GetByIdemKey(idemKey string) (*models.Transfer, error) {
 	query := `
-		SELECT id, idempotency_key, from_user_id, to_user_id, amount, status, note,
-		       created_at, updated_at, completed_at, fail_reason
+		SELECT ` + transferColumns + `
 		FROM transfers
 		WHERE idempotency_key = ?`
 
-	var transfer models.Transfer
-	err := r.db.QueryRow(query, idemKey).Scan(
-		&transfer.ID, &transfer.IdemKey, &transfer.FromUserID, &transfer.ToUserID,
-		&transfer.Amount, &transfer.Status, &transfer.Note, &transfer.CreatedAt,
-		&transfer.UpdatedAt, &transfer.CompletedAt, &transfer.FailReason,
-	)
-
+	transfer, err := scanTransfer(r.db.QueryRow(query, idemKey))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -142,73 +759,163 @@ func (r *TransferRepository) GetByIdemKey(idemKey string) (*models.Transfer, err
 		return nil, err
 	}
 
-	return &transfer, nil
+	return transfer, nil
 }
 
-// GetByUserID ดึงรายการ transfer ที่เกี่ยวข้องกับ user (ทั้งโอนออกและรับเข้า)
-func (r *TransferRepository) GetByUserID(userID, page, pageSize int) ([]models.Transfer, int, error) {
-	// นับจำนวนทั้งหมด
-	countQuery := `
-		SELECT COUNT(*) 
-		FROM transfers 
-		WHERE from_user_id = ? OR to_user_id = ?`
+// GetByClientKey ดึง transfer ที่ผู้โอนสร้างด้วย Idempotency-Key นี้ (nil ถ้าไม่มีหรือ key ถูกปล่อยแล้ว)
+func (r *TransferRepository) GetByClientKey(fromUserID int, clientKey string) (*models.Transfer, error) {
+	transfer, err := scanTransfer(r.db.QueryRow(`
+		SELECT `+transferColumns+`
+		FROM transfers
+		WHERE from_user_id = ? AND client_key = ?`, fromUserID, clientKey))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return transfer, err
+}
 
-	var total int
-	err := r.db.QueryRow(countQuery, userID, userID).Scan(&total)
-	if err != nil {
-		return nil, 0, err
+// ReleaseClientKey ปล่อย Idempotency-Key ของ transfer ให้ผู้โอนใช้กับ transfer ใหม่ได้
+// transfer เดิมยังอยู่และเรียกด้วย idempotency_key ได้ตามเดิม
+func (r *TransferRepository) ReleaseClientKey(id int) error {
+	_, err := r.db.Exec("UPDATE transfers SET client_key = NULL WHERE id = ?", id)
+	return err
+}
+
+// transferSortKeys คือ ORDER BY และ column ของ keyset ของแต่ละ sort (ทิศทางเดียวกันทุก column)
+var transferSortKeys = map[models.TransferSort]struct {
+	orderBy string
+	keyset  string
+	after   string
+}{
+	models.TransferSortNewest:     {"created_at DESC, id DESC", "(created_at, id)", "<"},
+	models.TransferSortOldest:     {"created_at ASC, id ASC", "(created_at, id)", ">"},
+	models.TransferSortAmountDesc: {"amount DESC, created_at DESC, id DESC", "(amount, created_at, id)", "<"},
+	models.TransferSortAmountAsc:  {"amount ASC, created_at ASC, id ASC", "(amount, created_at, id)", ">"},
+}
+
+// Search ดึง transfer ของ user ตามเงื่อนไขใน q เรียงตาม q.Sort
+// after != nil ใช้ keyset pagination (ต่อจาก transfer ใน cursor) ไม่อย่างนั้นใช้ offset
+func (r *TransferRepository) Search(q models.TransferQuery, after *models.TransferCursor, limit, offset int) ([]models.Transfer, error) {
+	sort, ok := transferSortKeys[q.Sort]
+	if !ok {
+		return nil, fmt.Errorf("unknown sort %q", q.Sort)
+	}
+	where, args := transferQueryWhere(q)
+
+	if after != nil {
+		// เวลาใน database ถูกเขียนด้วยเวลา local จึงต้องเทียบในรูปแบบเดียวกัน
+		if strings.HasPrefix(sort.keyset, "(amount") {
+			where += " AND " + sort.keyset + " " + sort.after + " (?, ?, ?)"
+			args = append(args, after.Amount, after.CreatedAt.Local(), after.ID)
+		} else {
+			where += " AND " + sort.keyset + " " + sort.after + " (?, ?)"
+			args = append(args, after.CreatedAt.Local(), after.ID)
+		}
+		offset = 0
 	}
 
-	// ดึงข้อมูลแบบ paginated
-	offset := (page - 1) * pageSize
-	query := `
-		SELECT id, idempotency_key, from_user_id, to_user_id, amount, status, note,
-		       created_at, updated_at, completed_at, fail_reason
+	return r.queryTransfers(`
+		SELECT `+transferColumns+`
 		FROM transfers
-		WHERE from_user_id = ? OR to_user_id = ?
-		ORDER BY created_at DESC
-		LIMIT ? OFFSET ?`
+		WHERE `+where+`
+		ORDER BY `+sort.orderBy+`
+		LIMIT ? OFFSET ?`,
+		append(args, limit, offset)...)
+}
 
-	rows, err := r.db.Query(query, userID, userID, pageSize, offset)
-	if err != nil {
-		return nil, 0, err
-	}
-	defer rows.Close()
+// Count นับ transfer ทั้งหมดที่ตรงกับเงื่อนไขใน q
+func (r *TransferRepository) Count(q models.TransferQuery) (int, error) {
+	where, args := transferQueryWhere(q)
 
-	var transfers []models.Transfer
-	for rows.Next() {
-		var transfer models.Transfer
-		err := rows.Scan(
-			&transfer.ID, &transfer.IdemKey, &transfer.FromUserID, &transfer.ToUserID,
-			&transfer.Amount, &transfer.Status, &transfer.Note, &transfer.CreatedAt,
-			&transfer.UpdatedAt, &transfer.CompletedAt, &transfer.FailReason,
-		)
-		if err != nil {
-			return nil, 0, err
+	var total int
+	err := r.db.QueryRow(`SELECT COUNT(*) FROM transfers WHERE `+where, args...).Scan(&total)
+	return total, err
+}
+
+// transferQueryWhere สร้างเงื่อนไข WHERE จาก filter ของ q
+func transferQueryWhere(q models.TransferQuery) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+
+	switch q.Direction {
+	case models.TransferDirectionSent:
+		conditions = append(conditions, "from_user_id = ?")
+		args = append(args, q.UserID)
+		if q.CounterpartyID > 0 {
+			conditions = append(conditions, "to_user_id = ?")
+			args = append(args, q.CounterpartyID)
+		}
+	case models.TransferDirectionReceived:
+		conditions = append(conditions, "to_user_id = ?")
+		args = append(args, q.UserID)
+		if q.CounterpartyID > 0 {
+			conditions = append(conditions, "from_user_id = ?")
+			args = append(args, q.CounterpartyID)
+		}
+	default:
+		if q.CounterpartyID > 0 {
+			conditions = append(conditions, "((from_user_id = ? AND to_user_id = ?) OR (from_user_id = ? AND to_user_id = ?))")
+			args = append(args, q.UserID, q.CounterpartyID, q.CounterpartyID, q.UserID)
+		} else {
+			conditions = append(conditions, "(from_user_id = ? OR to_user_id = ?)")
+			args = append(args, q.UserID, q.UserID)
 		}
-		transfers = append(transfers, transfer)
 	}
 
-	return transfers, total, nil
+	if q.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, q.Status)
+	}
+	if q.CreatedFrom != nil {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, q.CreatedFrom.Local())
+	}
+	if q.CreatedTo != nil {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, q.CreatedTo.Local())
+	}
+	if q.MinAmount != nil {
+		conditions = append(conditions, "amount >= ?")
+		args = append(args, *q.MinAmount)
+	}
+	if q.MaxAmount != nil {
+		conditions = append(conditions, "amount <= ?")
+		args = append(args, *q.MaxAmount)
+	}
+
+	return strings.Join(conditions, " AND "), args
 }
 
 // GetLastTransferFromUser ดึง transfer ล่าสุดที่ user โอนออก
 func (r *TransferRepository) GetLastTransferFromUser(fromUserID int) (*models.Transfer, error) {
 	query := `
-		SELECT id, idempotency_key, from_user_id, to_user_id, amount, status, note,
-		       created_at, updated_at, completed_at, fail_reason
+		SELECT ` + transferColumns + `
 		FROM transfers
 		WHERE from_user_id = ? AND status = 'completed'
 		ORDER BY created_at DESC
 		LIMIT 1`
 
-	var transfer models.Transfer
-	err := r.db.QueryRow(query, fromUserID).Scan(
-		&transfer.ID, &transfer.IdemKey, &transfer.FromUserID, &transfer.ToUserID,
-		&transfer.Amount, &transfer.Status, &transfer.Note, &transfer.CreatedAt,
-		&transfer.UpdatedAt, &transfer.CompletedAt, &transfer.FailReason,
-	)
+	transfer, err := scanTransfer(r.db.QueryRow(query, fromUserID))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return transfer, nil
+}
+
+// GetLastMandateTransfer ดึง transfer ล่าสุดที่ mandate โอนสำเร็จ คืน nil ถ้ายังไม่เคยสำเร็จ
+func (r *TransferRepository) GetLastMandateTransfer(mandateID int) (*models.Transfer, error) {
+	query := `
+		SELECT ` + transferColumns + `
+		FROM transfers
+		WHERE mandate_id = ? AND status = 'completed'
+		ORDER BY id DESC
+		LIMIT 1`
 
+	transfer, err := scanTransfer(r.db.QueryRow(query, mandateID))
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -216,5 +923,15 @@ func (r *TransferRepository) GetLastTransferFromUser(fromUserID int) (*models.Tr
 		return nil, err
 	}
 
-	return &transfer, nil
+	return transfer, nil
+}
+
+// isUniqueViolation ตรวจว่า error มาจาก UNIQUE constraint ของ column ที่ระบุ
+func isUniqueViolation(err error, column string) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed: "+column)
+}
+
+// isForeignKeyViolation บอกว่า err เกิดจาก foreign key (เช่น ลบ user ที่ยังมี transfer อ้างถึง)
+func isForeignKeyViolation(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "FOREIGN KEY constraint failed")
 }
@@ -2,7 +2,9 @@ package repositories
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"kbtg-backend/internal/models"
@@ -20,9 +22,10 @@ func (r *UserRepository) GetAll() ([]models.User, error) {
 	query := `
 		SELECT id, member_id, first_name, last_name, phone, email, 
 		       membership_date, membership_level, points, created_at, updated_at 
-		FROM users ORDER BY created_at DESC`
+		FROM users WHERE member_id != ? ORDER BY created_at DESC`
 
-	rows, err := r.db.Query(query)
+	// บัญชีระบบ (house) ไม่ใช่สมาชิก จึงไม่แสดงในรายชื่อ
+	rows, err := r.db.Query(query, models.HouseMemberID)
 	if err != nil {
 		return nil, err
 	}
@@ -68,10 +71,88 @@ func (r *UserRepository) GetByID(id int) (*models.User, error) {
 	return &user, nil
 }
 
+// FindByMemberID หา user จาก member id (ไม่สนตัวพิมพ์เล็กใหญ่)
+// คืนได้มากกว่าหนึ่งคนเพื่อให้ผู้เรียกตรวจการซ้ำได้ และไม่รวมบัญชี house
+func (r *UserRepository) FindByMemberID(memberID string) ([]models.User, error) {
+	return r.findContacts("UPPER(member_id) = ?", strings.ToUpper(memberID))
+}
+
+// FindByEmail หา user จาก email (ไม่สนตัวพิมพ์เล็กใหญ่)
+func (r *UserRepository) FindByEmail(email string) ([]models.User, error) {
+	return r.findContacts("LOWER(email) = ?", strings.ToLower(email))
+}
+
+// FindByPhone หา user จากเบอร์โทรที่เป็นตัวเลขล้วน โดยตัด - ช่องว่าง วงเล็บ และ + ของเบอร์ที่เก็บไว้ก่อนเทียบ
+// phones คือรูปแบบที่เทียบได้ของเบอร์เดียวกัน (เช่น 0812345678 และ 66812345678)
+func (r *UserRepository) FindByPhone(phones ...string) ([]models.User, error) {
+	if len(phones) == 0 {
+		return nil, nil
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(phones)), ", ")
+	args := make([]interface{}, len(phones))
+	for i, phone := range phones {
+		args[i] = phone
+	}
+	return r.findContacts(`REPLACE(REPLACE(REPLACE(REPLACE(REPLACE(phone, '-', ''), ' ', ''), '(', ''), ')', ''), '+', '')
+		IN (`+placeholders+`)`, args...)
+}
+
+// findContacts ดึงเฉพาะข้อมูลที่ใช้ระบุตัวผู้รับ (ไม่รวมแต้ม) สูงสุด 2 คน
+func (r *UserRepository) findContacts(where string, args ...interface{}) ([]models.User, error) {
+	rows, err := r.db.Query(`
+		SELECT id, member_id, first_name, last_name, phone, email
+		FROM users
+		WHERE member_id != ? AND `+where+`
+		ORDER BY id
+		LIMIT 2`, append([]interface{}{models.HouseMemberID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		var user models.User
+		if err := rows.Scan(&user.ID, &user.MemberID, &user.FirstName, &user.LastName, &user.Phone, &user.Email); err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// GetBalance ดึงแต้มทั้งหมด แต้มที่ถูก hold ณ now และแต้มที่ใช้ได้ของ user
+func (r *UserRepository) GetBalance(id int, now time.Time) (*models.UserBalance, error) {
+	balance := models.UserBalance{UserID: id}
+	err := r.db.QueryRow("SELECT points FROM users WHERE id = ?", id).Scan(&balance.Points)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	balance.Held, err = heldPoints(r.db, id, now)
+	if err != nil {
+		return nil, err
+	}
+	balance.Available = balance.Points - balance.Held
+
+	return &balance, nil
+}
+
+// Create สร้าง user ถ้ามีแต้มตั้งต้นจะเขียน adjust entry (reference opening_balance) ใน transaction เดียวกัน
+// เพื่อให้ยอดตาม ledger ตรงกับ users.points ตั้งแต่แรก
 func (r *UserRepository) Create(req models.CreateUserRequest) (*models.User, error) {
 	memberID := r.generateMemberID()
 	now := time.Now()
 
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
 	query := `
 		INSERT INTO users (member_id, first_name, last_name, phone, email, 
 		                  membership_date, membership_level, points, created_at, updated_at)
@@ -80,7 +161,7 @@ func (r *UserRepository) Create(req models.CreateUserRequest) (*models.User, err
 		          membership_date, membership_level, points, created_at, updated_at`
 
 	var user models.User
-	err := r.db.QueryRow(
+	err = tx.QueryRow(
 		query, memberID, req.FirstName, req.LastName, req.Phone, req.Email,
 		now, req.MembershipLevel, req.Points, now, now,
 	).Scan(
@@ -93,9 +174,26 @@ func (r *UserRepository) Create(req models.CreateUserRequest) (*models.User, err
 		return nil, err
 	}
 
+	if user.Points != 0 {
+		reference := models.OpeningBalanceReference
+		if err := insertLedgerEntry(tx, &models.PointLedger{
+			UserID: user.ID, Change: user.Points, BalanceAfter: user.Points, EventType: models.EventTypeAdjust,
+			Reference: &reference, CreatedAt: now,
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
 	return &user, nil
 }
 
+// ErrHouseAccount ถูกคืนเมื่อแก้หรือลบบัญชีระบบที่รับค่าธรรมเนียม (member_id = models.HouseMemberID)
+// แต้มของบัญชีนี้ต้องเปลี่ยนผ่าน ledger เท่านั้น และการโอนที่มีค่าธรรมเนียมต้องใช้บัญชีนี้
+var ErrHouseAccount = errors.New("the house account cannot be modified or deleted")
+
 func (r *UserRepository) Update(id int, req models.UpdateUserRequest) (*models.User, error) {
 	// First, get the current user
 	user, err := r.GetByID(id)
@@ -105,6 +203,9 @@ func (r *UserRepository) Update(id int, req models.UpdateUserRequest) (*models.U
 	if user == nil {
 		return nil, nil
 	}
+	if user.MemberID == models.HouseMemberID {
+		return nil, ErrHouseAccount
+	}
 
 	// Build dynamic update query
 	setParts := []string{}
@@ -144,11 +245,9 @@ func (r *UserRepository) Update(id int, req models.UpdateUserRequest) (*models.U
 	args = append(args, time.Now())
 	args = append(args, id)
 
-	query := fmt.Sprintf("UPDATE users SET %s WHERE id = ?",
-		fmt.Sprintf("%s", setParts[0]))
-	for i := 1; i < len(setParts); i++ {
-		query = fmt.Sprintf("%s, %s", query, setParts[i])
-	}
+	args = append(args, models.HouseMemberID)
+
+	query := fmt.Sprintf("UPDATE users SET %s WHERE id = ? AND member_id != ?", strings.Join(setParts, ", "))
 
 	_, err = r.db.Exec(query, args...)
 	if err != nil {
@@ -158,10 +257,24 @@ func (r *UserRepository) Update(id int, req models.UpdateUserRequest) (*models.U
 	return r.GetByID(id)
 }
 
+// ErrUserHasHistory ถูกคืนเมื่อลบ user ที่ยังมี transfer, ledger หรือข้อมูลอื่นอ้างถึง
+var ErrUserHasHistory = errors.New("user has transfers or ledger entries and cannot be deleted")
+
 func (r *UserRepository) Delete(id int) error {
-	query := "DELETE FROM users WHERE id = ?"
-	result, err := r.db.Exec(query, id)
+	var memberID string
+	if err := r.db.QueryRow("SELECT member_id FROM users WHERE id = ?", id).Scan(&memberID); err != nil {
+		return err
+	}
+	if memberID == models.HouseMemberID {
+		return ErrHouseAccount
+	}
+
+	query := "DELETE FROM users WHERE id = ? AND member_id != ?"
+	result, err := r.db.Exec(query, id, models.HouseMemberID)
 	if err != nil {
+		if isForeignKeyViolation(err) {
+			return ErrUserHasHistory
+		}
 		return err
 	}
 
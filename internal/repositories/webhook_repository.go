@@ -0,0 +1,252 @@
+package repositories
+
+import (
+	"database/sql"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+)
+
+type WebhookRepository struct {
+	db *sql.DB
+}
+
+func NewWebhookRepository(db *sql.DB) *WebhookRepository {
+	return &WebhookRepository{db: db}
+}
+
+// event_types เก็บเป็นรายการคั่นด้วย comma
+const webhookSubscriptionColumns = `id, url, secret, event_types, description, is_active, created_at, updated_at`
+
+func scanWebhookSubscription(row rowScanner) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	var eventTypes string
+	err := row.Scan(
+		&sub.ID,
+		&sub.URL,
+		&sub.Secret,
+		&eventTypes,
+		&sub.Description,
+		&sub.IsActive,
+		&sub.CreatedAt,
+		&sub.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	sub.EventTypes = strings.Split(eventTypes, ",")
+	return &sub, nil
+}
+
+const webhookDeliveryColumns = `id, subscription_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_status_code, last_error, redelivery_of, delivered_at, created_at, updated_at`
+
+func scanWebhookDelivery(row rowScanner) (*models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+	err := row.Scan(
+		&d.ID,
+		&d.SubscriptionID,
+		&d.EventID,
+		&d.EventType,
+		&payload,
+		&d.Status,
+		&d.Attempts,
+		&d.NextAttemptAt,
+		&d.LastStatusCode,
+		&d.LastError,
+		&d.RedeliveryOf,
+		&d.DeliveredAt,
+		&d.CreatedAt,
+		&d.UpdatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	d.Payload = []byte(payload)
+	return &d, nil
+}
+
+func (r *WebhookRepository) CreateSubscription(sub *models.WebhookSubscription) error {
+	now := time.Now()
+	sub.CreatedAt, sub.UpdatedAt = now, now
+	result, err := r.db.Exec(`
+		INSERT INTO webhook_subscriptions (url, secret, event_types, description, is_active, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sub.URL, sub.Secret, strings.Join(sub.EventTypes, ","), sub.Description, sub.IsActive, now, now)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	sub.ID = int(id)
+	return nil
+}
+
+// GetSubscription ดึง subscription ตาม id (nil ถ้าไม่มี)
+func (r *WebhookRepository) GetSubscription(id int) (*models.WebhookSubscription, error) {
+	sub, err := scanWebhookSubscription(r.db.QueryRow(`SELECT `+webhookSubscriptionColumns+` FROM webhook_subscriptions WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return sub, err
+}
+
+// ListSubscriptions ดึง subscription ทั้งหมด หรือเฉพาะที่ active ถ้า activeOnly
+func (r *WebhookRepository) ListSubscriptions(activeOnly bool) ([]models.WebhookSubscription, error) {
+	query := `SELECT ` + webhookSubscriptionColumns + ` FROM webhook_subscriptions`
+	if activeOnly {
+		query += ` WHERE is_active = 1`
+	}
+	rows, err := r.db.Query(query + ` ORDER BY id`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var subs []models.WebhookSubscription
+	for rows.Next() {
+		sub, err := scanWebhookSubscription(rows)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, *sub)
+	}
+	return subs, rows.Err()
+}
+
+func (r *WebhookRepository) UpdateSubscription(sub *models.WebhookSubscription) error {
+	sub.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+		UPDATE webhook_subscriptions
+		SET url = ?, event_types = ?, description = ?, is_active = ?, updated_at = ?
+		WHERE id = ?`,
+		sub.URL, strings.Join(sub.EventTypes, ","), sub.Description, sub.IsActive, sub.UpdatedAt, sub.ID)
+	return err
+}
+
+// DeleteSubscription ลบ subscription พร้อม log การส่งของมัน คืน false ถ้าไม่มี subscription นี้
+func (r *WebhookRepository) DeleteSubscription(id int) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM webhook_deliveries WHERE subscription_id = ?`, id); err != nil {
+		return false, err
+	}
+	result, err := tx.Exec(`DELETE FROM webhook_subscriptions WHERE id = ?`, id)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	if err != nil || n == 0 {
+		return false, err
+	}
+	return true, tx.Commit()
+}
+
+// CreateDeliveries สร้าง delivery สถานะ pending ของ event เดียวให้ทุก subscription ใน subscriptionIDs
+// ส่งได้ทันทีตั้งแต่เวลาของ event
+func (r *WebhookRepository) CreateDeliveries(event models.WebhookEvent, payload []byte, subscriptionIDs []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	now := event.CreatedAt
+	for _, subscriptionID := range subscriptionIDs {
+		if _, err := tx.Exec(`
+			INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, attempts,
+			                                next_attempt_at, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?)`,
+			subscriptionID, event.ID, event.Type, string(payload), models.WebhookDeliveryPending, now, now, now); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// Redeliver สร้าง delivery ใหม่ที่ส่ง payload เดิมของ original อีกครั้ง
+func (r *WebhookRepository) Redeliver(original models.WebhookDelivery, now time.Time) (*models.WebhookDelivery, error) {
+	result, err := r.db.Exec(`
+		INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, status, attempts,
+		                                next_attempt_at, redelivery_of, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?, ?)`,
+		original.SubscriptionID, original.EventID, original.EventType, string(original.Payload),
+		models.WebhookDeliveryPending, now, original.ID, now, now)
+	if err != nil {
+		return nil, err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	return r.GetDelivery(int(id))
+}
+
+// GetDelivery ดึง delivery ตาม id (nil ถ้าไม่มี)
+func (r *WebhookRepository) GetDelivery(id int) (*models.WebhookDelivery, error) {
+	d, err := scanWebhookDelivery(r.db.QueryRow(`SELECT `+webhookDeliveryColumns+` FROM webhook_deliveries WHERE id = ?`, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return d, err
+}
+
+// ListDeliveries ดึง log การส่งของ subscription ล่าสุดก่อน กรองตาม status ถ้าระบุ
+func (r *WebhookRepository) ListDeliveries(subscriptionID int, status models.WebhookDeliveryStatus, limit int) ([]models.WebhookDelivery, error) {
+	query := `SELECT ` + webhookDeliveryColumns + ` FROM webhook_deliveries WHERE subscription_id = ?`
+	args := []interface{}{subscriptionID}
+	if status != "" {
+		query += ` AND status = ?`
+		args = append(args, status)
+	}
+	return r.queryDeliveries(query+` ORDER BY id DESC LIMIT ?`, append(args, limit)...)
+}
+
+// ListDueDeliveries ดึง delivery pending ที่ถึงเวลาส่ง
+func (r *WebhookRepository) ListDueDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return r.queryDeliveries(`
+		SELECT `+webhookDeliveryColumns+`
+		FROM webhook_deliveries
+		WHERE status = ? AND next_attempt_at <= ?
+		ORDER BY next_attempt_at, id
+		LIMIT ?`,
+		models.WebhookDeliveryPending, now, limit)
+}
+
+// RecordAttempt บันทึกผลการส่งหนึ่งครั้ง (attempts, status, เวลาส่งครั้งถัดไป และ response ล่าสุด)
+func (r *WebhookRepository) RecordAttempt(d *models.WebhookDelivery) error {
+	d.UpdatedAt = time.Now()
+	_, err := r.db.Exec(`
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?,
+		    delivered_at = ?, updated_at = ?
+		WHERE id = ?`,
+		d.Status, d.Attempts, d.NextAttemptAt, d.LastStatusCode, d.LastError, d.DeliveredAt, d.UpdatedAt, d.ID)
+	return err
+}
+
+func (r *WebhookRepository) queryDeliveries(query string, args ...interface{}) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
@@ -0,0 +1,229 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// ErrInvalidBalanceQuery ครอบ error ของเงื่อนไขขอแต้มย้อนหลังที่ไม่ถูกต้อง
+var ErrInvalidBalanceQuery = errors.New("invalid balance query")
+
+const (
+	// balanceHistoryDefaultDays คือจำนวนวันเมื่อไม่ระบุ from
+	balanceHistoryDefaultDays = 30
+	// balanceHistoryMaxDays จำกัดจำนวนจุดใน response หนึ่งครั้ง
+	balanceHistoryMaxDays = 366
+)
+
+// BalanceHistoryService คำนวณแต้มของ user ณ เวลาใดๆ และยอดปิดรายวันจาก point_ledger
+// snapshot ยอดปิดรายวัน (balance_snapshots) ถูกสร้างเป็นระยะเพื่อไม่ต้องไล่ ledger ทั้งหมดเมื่อ ledger ใหญ่ขึ้น
+type BalanceHistoryService struct {
+	repo    *repositories.BalanceSnapshotRepository
+	users   *UserService
+	clock   Clock
+	running sync.Mutex
+}
+
+func NewBalanceHistoryService(repo *repositories.BalanceSnapshotRepository, users *UserService) *BalanceHistoryService {
+	return &BalanceHistoryService{
+		repo:  repo,
+		users: users,
+		clock: SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้ตัดสินว่าวันไหนจบแล้ว
+func (s *BalanceHistoryService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// GetBalanceAt คือแต้มของ user ณ เวลา at (at ต้องไม่อยู่ในอนาคต)
+func (s *BalanceHistoryService) GetBalanceAt(userID int, at time.Time) (*models.PointInTimeBalance, error) {
+	if userID <= 0 {
+		return nil, invalidBalanceQuery("invalid user ID")
+	}
+	if at.After(s.clock.Now()) {
+		return nil, invalidBalanceQuery("at cannot be in the future")
+	}
+	if err := s.requireUser(userID); err != nil {
+		return nil, err
+	}
+
+	points, err := s.repo.BalanceAt(userID, at)
+	if err != nil {
+		return nil, err
+	}
+	return &models.PointInTimeBalance{UserID: userID, At: at, Points: points}, nil
+}
+
+// GetHistory คืนยอดปิดของทุกวันใน [q.From, q.To] วันที่ไม่มี entry ใช้ยอดของวันก่อนหน้า
+// ค่าเริ่มต้นคือ 30 วันล่าสุดจนถึงวันนี้
+func (s *BalanceHistoryService) GetHistory(q models.BalanceHistoryQuery) (*models.BalanceHistoryResponse, error) {
+	if q.UserID <= 0 {
+		return nil, invalidBalanceQuery("invalid user ID")
+	}
+	if q.Interval == "" {
+		q.Interval = models.BalanceIntervalDay
+	}
+	if q.Interval != models.BalanceIntervalDay {
+		return nil, invalidBalanceQuery("unknown interval %q", q.Interval)
+	}
+
+	now := s.clock.Now()
+	today := startOfDay(now)
+	to := today
+	if q.To != "" {
+		t, err := time.ParseInLocation(models.BalanceDayLayout, q.To, time.Local)
+		if err != nil {
+			return nil, invalidBalanceQuery("to must be a date (YYYY-MM-DD)")
+		}
+		to = t
+	}
+	from := to.AddDate(0, 0, -(balanceHistoryDefaultDays - 1))
+	if q.From != "" {
+		t, err := time.ParseInLocation(models.BalanceDayLayout, q.From, time.Local)
+		if err != nil {
+			return nil, invalidBalanceQuery("from must be a date (YYYY-MM-DD)")
+		}
+		from = t
+	}
+	if to.After(today) {
+		return nil, invalidBalanceQuery("to cannot be in the future")
+	}
+	if from.After(to) {
+		return nil, invalidBalanceQuery("from must not be after to")
+	}
+	if from.AddDate(0, 0, balanceHistoryMaxDays-1).Before(to) {
+		return nil, invalidBalanceQuery("range cannot exceed %d days", balanceHistoryMaxDays)
+	}
+	if err := s.requireUser(q.UserID); err != nil {
+		return nil, err
+	}
+
+	opening, closings, err := s.repo.DailyClosings(q.UserID, from, to.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.BalanceHistoryResponse{
+		UserID:         q.UserID,
+		Interval:       q.Interval,
+		From:           from.Format(models.BalanceDayLayout),
+		To:             to.Format(models.BalanceDayLayout),
+		OpeningBalance: opening,
+		Data:           []models.BalancePoint{},
+	}
+	balance := opening
+	for day := from; !day.After(to); day = day.AddDate(0, 0, 1) {
+		date := day.Format(models.BalanceDayLayout)
+		previous := balance
+		if closing, ok := closings[date]; ok {
+			balance = closing
+		}
+		response.Data = append(response.Data, models.BalancePoint{
+			Date:           date,
+			ClosingBalance: balance,
+			Change:         balance - previous,
+			Partial:        day.Equal(today),
+		})
+	}
+	return response, nil
+}
+
+// CreateSnapshots บันทึกยอดปิดของทุกวันที่จบแล้วและยังไม่มี snapshot คืนจำนวน snapshot ที่เขียน
+// เริ่มจากวันถัดจาก snapshot ล่าสุด (หรือวันของ entry แรก) และข้ามช่วงที่ไม่มี entry
+func (s *BalanceHistoryService) CreateSnapshots() (int, error) {
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	now := s.clock.Now()
+	today := startOfDay(now)
+
+	latestDay, err := s.repo.LatestDay()
+	if err != nil {
+		return 0, err
+	}
+	var next time.Time
+	if latestDay == "" {
+		first, err := s.repo.NextActivity(nil)
+		if err != nil || first == nil {
+			return 0, err
+		}
+		next = startOfDay(*first)
+	} else {
+		latest, err := time.ParseInLocation(models.BalanceDayLayout, latestDay, time.Local)
+		if err != nil {
+			return 0, fmt.Errorf("invalid balance snapshot day %q: %w", latestDay, err)
+		}
+		next = latest.AddDate(0, 0, 1)
+	}
+
+	created := 0
+	for next.Before(today) {
+		end := next.AddDate(0, 0, 1)
+		count, err := s.repo.CreateDay(next.Format(models.BalanceDayLayout), next, end, now)
+		if err != nil {
+			return created, err
+		}
+		created += count
+
+		activity, err := s.repo.NextActivity(&end)
+		if err != nil {
+			return created, err
+		}
+		if activity == nil {
+			break
+		}
+		next = startOfDay(*activity)
+	}
+	return created, nil
+}
+
+// StartSnapshotter สร้าง snapshot ของวันที่จบแล้วเป็นระยะจนกว่า stop จะถูกปิด
+func (s *BalanceHistoryService) StartSnapshotter(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				count, err := s.CreateSnapshots()
+				if err != nil {
+					log.Printf("Balance snapshot failed: %v", err)
+				} else if count > 0 {
+					log.Printf("Created %d balance snapshot(s)", count)
+				}
+			}
+		}
+	}()
+}
+
+func (s *BalanceHistoryService) requireUser(userID int) error {
+	user, err := s.users.GetUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user == nil {
+		return errors.New("user not found")
+	}
+	return nil
+}
+
+// startOfDay คือเที่ยงคืนของวันที่ t อยู่ตามเวลาของ server
+func startOfDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
+
+func invalidBalanceQuery(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidBalanceQuery, fmt.Sprintf(format, args...))
+}
@@ -0,0 +1,228 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// MaxBatchLegs คือจำนวนผู้รับสูงสุดต่อ batch
+const MaxBatchLegs = 100
+
+// BatchTransfer โอนจากผู้โอนคนเดียวไปหลายผู้รับใน transaction เดียว
+// ทุก leg ผ่าน transfer rule (ด้วย source "batch") และ limit ของผู้โอน
+// rule ห้ามโอนซ้ำผู้รับล่าสุดไม่ใช้กับ batch เพราะผู้รับใน batch เดียวกันต้องไม่ซ้ำกันอยู่แล้ว
+func (s *TransferService) BatchTransfer(req models.BatchTransferRequest) (*models.BatchTransferResponse, error) {
+	if req.FromUserID <= 0 {
+		return nil, errors.New("invalid fromUserId")
+	}
+	if len(req.Legs) == 0 {
+		return nil, errors.New("at least one leg is required")
+	}
+	if len(req.Legs) > MaxBatchLegs {
+		return nil, fmt.Errorf("a batch cannot have more than %d legs", MaxBatchLegs)
+	}
+	if req.Mode == "" {
+		req.Mode = models.BatchModeAllOrNothing
+	}
+	if req.Mode != models.BatchModeAllOrNothing && req.Mode != models.BatchModeBestEffort {
+		return nil, fmt.Errorf("unknown batch mode %q", req.Mode)
+	}
+
+	response := &models.BatchTransferResponse{
+		BatchID: uuid.New().String(),
+		Mode:    req.Mode,
+		Legs:    make([]models.BatchLegResult, len(req.Legs)),
+	}
+
+	// leg ที่ถูกปฏิเสธจะถูกบันทึกเป็น transfer สถานะ failed ภายใต้ batch เดียวกันด้วย
+	legReq := func(i int) models.TransferCreateRequest {
+		return models.TransferCreateRequest{
+			FromUserID: req.FromUserID,
+			ToUserID:   req.Legs[i].ToUserID,
+			Amount:     req.Legs[i].Amount,
+			Note:       req.Legs[i].Note,
+		}
+	}
+	fail := func(i int, err error) {
+		response.Legs[i].Status = models.BatchLegStatusFailed
+		response.Legs[i].ErrorCode, response.Legs[i].Message = TransferErrorCode(err), err.Error()
+		s.recordFailure(legReq(i), &response.BatchID, err)
+	}
+
+	// ตรวจทุก leg ก่อนแตะ database
+	var valid []models.TransferCreateRequest
+	var validIndex []int
+	seen := make(map[int]bool, len(req.Legs))
+	for i, leg := range req.Legs {
+		response.Legs[i] = models.BatchLegResult{Index: i, ToUserID: leg.ToUserID, Amount: leg.Amount}
+		response.TotalRequested += leg.Amount
+
+		transferReq := legReq(i)
+
+		var err error
+		switch {
+		case seen[leg.ToUserID]:
+			err = fmt.Errorf("duplicate recipient %d in batch", leg.ToUserID)
+		case leg.Note != nil && len(*leg.Note) > 512:
+			err = errors.New("note cannot exceed 512 characters")
+		default:
+			err = s.checkRules(transferReq, RuleSourceBatch)
+		}
+		seen[leg.ToUserID] = true
+
+		if err != nil {
+			fail(i, err)
+			continue
+		}
+		valid = append(valid, transferReq)
+		validIndex = append(validIndex, i)
+	}
+
+	// limit ของผู้โอนนับรวมทุก leg ใน batch ตามลำดับ
+	if len(valid) > 0 {
+		amounts := make([]models.Points, len(valid))
+		for j, leg := range valid {
+			amounts[j] = leg.Amount
+		}
+		limitErrs, err := s.limits.CheckTransfers(req.FromUserID, amounts)
+		if err != nil {
+			return nil, err
+		}
+		var withinLimit []models.TransferCreateRequest
+		var withinIndex []int
+		for j, i := range validIndex {
+			if limitErrs[j] != nil {
+				fail(i, limitErrs[j])
+				continue
+			}
+			// batch พักรอตรวจทีละ leg ไม่ได้ leg ที่ต้อง review จึงถูกปฏิเสธเหมือน block
+			if _, err := s.screenTransfer(valid[j], false); err != nil {
+				var fraudErr *FraudBlockedError
+				if !errors.As(err, &fraudErr) {
+					return nil, err
+				}
+				fail(i, err)
+				continue
+			}
+			withinLimit = append(withinLimit, valid[j])
+			withinIndex = append(withinIndex, i)
+		}
+		valid, validIndex = withinLimit, withinIndex
+	}
+
+	allOrNothing := req.Mode == models.BatchModeAllOrNothing
+	if allOrNothing && len(valid) != len(req.Legs) {
+		markSkipped(response)
+		return summarize(response), nil
+	}
+
+	if len(valid) > 0 {
+		// ค่าธรรมเนียมคิดแยกทีละ leg
+		for j := range valid {
+			fee, err := s.fees.Quote(req.FromUserID, valid[j].Amount)
+			if err != nil {
+				return nil, err
+			}
+			valid[j].Fee = fee
+		}
+
+		transfers, legErrs, err := s.transferRepo.CreateBatch(response.BatchID, valid, allOrNothing)
+		if err != nil {
+			var legErr *repositories.BatchLegError
+			if !errors.As(err, &legErr) {
+				return nil, err
+			}
+			fail(validIndex[legErr.Index], legErr.Err)
+			markSkipped(response)
+			return summarize(response), nil
+		}
+
+		for j, i := range validIndex {
+			if legErrs[j] != nil {
+				fail(i, legErrs[j])
+				continue
+			}
+			response.Legs[i].Status = models.BatchLegStatusCompleted
+			response.Legs[i].Transfer = transfers[j]
+			s.notifyTransfer(models.WebhookEventTransferCompleted, transfers[j])
+		}
+	}
+
+	return summarize(response), nil
+}
+
+// markSkipped เปลี่ยน leg ที่ยังไม่มีผลเป็น skipped (ใช้เมื่อ batch แบบ all_or_nothing ถูกยกเลิก)
+func markSkipped(response *models.BatchTransferResponse) {
+	for i := range response.Legs {
+		if response.Legs[i].Status == "" {
+			response.Legs[i].Status = models.BatchLegStatusSkipped
+		}
+	}
+}
+
+func summarize(response *models.BatchTransferResponse) *models.BatchTransferResponse {
+	for _, leg := range response.Legs {
+		switch leg.Status {
+		case models.BatchLegStatusCompleted:
+			response.Succeeded++
+			response.TotalFees += leg.Transfer.Fee
+			response.TotalDebited += leg.Transfer.TotalDebited
+		case models.BatchLegStatusFailed:
+			response.Failed++
+		}
+	}
+
+	switch {
+	case response.Succeeded == len(response.Legs):
+		response.Status = "completed"
+	case response.Succeeded == 0:
+		response.Status = "failed"
+	default:
+		response.Status = "partial"
+	}
+	return response
+}
+
+// TransferErrorCode แปลง error ของการโอนเป็น error code เดียวกับที่ handler ตอบกลับ
+func TransferErrorCode(err error) string {
+	var limitErr *LimitExceededError
+	if errors.As(err, &limitErr) {
+		return "LIMIT_EXCEEDED"
+	}
+	var ruleErr *RuleViolationError
+	if errors.As(err, &ruleErr) {
+		return ruleErr.Code
+	}
+	var fraudErr *FraudBlockedError
+	if errors.As(err, &fraudErr) {
+		return fraudErr.Code()
+	}
+	switch {
+	case errors.Is(err, ErrRecipientNotFound):
+		return "RECIPIENT_NOT_FOUND"
+	case errors.Is(err, ErrRecipientAmbiguous):
+		return "RECIPIENT_AMBIGUOUS"
+	}
+
+	msg := err.Error()
+	switch {
+	case strings.HasPrefix(msg, "insufficient points"):
+		return "INSUFFICIENT_POINTS"
+	case msg == "cannot transfer to yourself":
+		return "INVALID_TRANSFER"
+	case strings.HasPrefix(msg, "cannot transfer to user"):
+		return "DUPLICATE_TRANSFER"
+	case strings.HasPrefix(msg, "duplicate recipient"):
+		return "DUPLICATE_RECIPIENT"
+	case strings.HasSuffix(msg, "user not found"):
+		return "USER_NOT_FOUND"
+	default:
+		return "VALIDATION_ERROR"
+	}
+}
@@ -0,0 +1,41 @@
+package services
+
+import (
+	"sync"
+	"time"
+)
+
+// Clock ให้เวลาปัจจุบัน แยกออกมาเพื่อให้ทดสอบงานที่ขึ้นกับเวลาได้
+type Clock interface {
+	Now() time.Time
+}
+
+// SystemClock ใช้เวลาจริงของเครื่อง
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
+
+// FixedClock คืนเวลาที่ตั้งไว้ และเลื่อนได้ด้วย Advance
+type FixedClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func NewFixedClock(now time.Time) *FixedClock {
+	return &FixedClock{now: now}
+}
+
+func (c *FixedClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// Advance เลื่อนเวลาไปข้างหน้า d
+func (c *FixedClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
@@ -0,0 +1,332 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// DisputeWindow คือระยะเวลาหลังโอนสำเร็จที่ผู้โอนยังเปิด dispute ได้
+const DisputeWindow = 30 * 24 * time.Hour
+
+var (
+	ErrDisputeNotFound = errors.New("dispute not found")
+	// ErrDisputeClosed ถูกคืนเมื่อ dispute ถูกปิดไปแล้ว หรืออยู่ในสถานะที่ทำ action นั้นไม่ได้
+	ErrDisputeClosed = errors.New("dispute cannot be changed in its current status")
+	// ErrTransferNotDisputable ถูกคืนเมื่อ transfer ไม่ได้ completed หรือเลย DisputeWindow แล้ว
+	ErrTransferNotDisputable = errors.New("transfer cannot be disputed")
+)
+
+// DisputeService ดูแล dispute ของ transfer: ผู้โอนเปิด, ผู้รับยอมคืน (reverse) หรือโต้แย้ง, admin ตัดสิน
+// ทุก action ถูกบันทึกใน audit trail (transfer_dispute_events) ใน transaction เดียวกับการเปลี่ยนสถานะ
+type DisputeService struct {
+	disputeRepo     *repositories.DisputeRepository
+	transferService *TransferService
+	webhooks        *WebhookService
+	clock           Clock
+}
+
+func NewDisputeService(disputeRepo *repositories.DisputeRepository, transferService *TransferService) *DisputeService {
+	return &DisputeService{
+		disputeRepo:     disputeRepo,
+		transferService: transferService,
+		clock:           SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้ตรวจ DisputeWindow
+func (s *DisputeService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetWebhooks ตั้ง WebhookService ที่แจ้ง dispute.opened และ dispute.updated (ไม่ตั้งก็ได้)
+func (s *DisputeService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// OpenDispute ให้ผู้โอนเปิด dispute กับ transfer ที่ completed แล้ว ผู้รับเป็นคู่กรณี
+func (s *DisputeService) OpenDispute(idemKey string, req models.DisputeCreateRequest) (*models.TransferDispute, error) {
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, errors.New("dispute reason is required")
+	}
+	if len(reason) > 512 {
+		return nil, errors.New("dispute reason cannot exceed 512 characters")
+	}
+
+	transfer, err := s.transferService.GetTransferByIdemKey(idemKey)
+	if err != nil {
+		return nil, err
+	}
+	// ไม่บอกว่ามี transfer อยู่ถ้าไม่ใช่ผู้โอน
+	if transfer.FromUserID != req.UserID {
+		return nil, errors.New("transfer not found")
+	}
+	if transfer.Status != models.TransferStatusCompleted {
+		return nil, fmt.Errorf("%w: status is %s", ErrTransferNotDisputable, transfer.Status)
+	}
+	if transfer.CompletedAt != nil && s.clock.Now().Sub(*transfer.CompletedAt) > DisputeWindow {
+		return nil, fmt.Errorf("%w: disputes must be opened within %d days of the transfer",
+			ErrTransferNotDisputable, int(DisputeWindow.Hours()/24))
+	}
+
+	dispute, err := s.disputeRepo.Create(*transfer, req.UserID, reason)
+	if err != nil {
+		return nil, err
+	}
+	s.webhooks.Publish(models.WebhookEventDisputeOpened, dispute)
+	return dispute, nil
+}
+
+// AcceptDispute ให้คู่กรณียอมคืนแต้ม: transfer ถูก reverse และ dispute เป็น accepted ใน transaction เดียวกัน
+func (s *DisputeService) AcceptDispute(id int, req models.DisputeRespondRequest) (*models.DisputeResponse, error) {
+	dispute, note, err := s.activeDispute(id, req.UserID, models.DisputeActorCounterparty, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	resolution := models.DisputeResolutionReversed
+	return s.reverse(dispute, repositories.DisputeTransition{
+		DisputeID:   dispute.ID,
+		From:        []models.DisputeStatus{models.DisputeStatusOpen, models.DisputeStatusContested},
+		To:          models.DisputeStatusAccepted,
+		Resolution:  &resolution,
+		Action:      models.DisputeActionAccepted,
+		Actor:       models.DisputeActorCounterparty,
+		ActorUserID: &req.UserID,
+		Note:        note,
+	})
+}
+
+// ContestDispute ให้คู่กรณีโต้แย้ง dispute จะรอ admin ตัดสิน
+func (s *DisputeService) ContestDispute(id int, req models.DisputeRespondRequest) (*models.DisputeResponse, error) {
+	dispute, note, err := s.activeDispute(id, req.UserID, models.DisputeActorCounterparty, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transition(dispute, repositories.DisputeTransition{
+		DisputeID:   dispute.ID,
+		From:        []models.DisputeStatus{models.DisputeStatusOpen},
+		To:          models.DisputeStatusContested,
+		Action:      models.DisputeActionContested,
+		Actor:       models.DisputeActorCounterparty,
+		ActorUserID: &req.UserID,
+		Note:        note,
+	})
+}
+
+// WithdrawDispute ให้ผู้เปิดถอน dispute ที่ยังไม่ปิด
+func (s *DisputeService) WithdrawDispute(id int, req models.DisputeRespondRequest) (*models.DisputeResponse, error) {
+	dispute, note, err := s.activeDispute(id, req.UserID, models.DisputeActorOpener, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.transition(dispute, repositories.DisputeTransition{
+		DisputeID:   dispute.ID,
+		From:        []models.DisputeStatus{models.DisputeStatusOpen, models.DisputeStatusContested},
+		To:          models.DisputeStatusWithdrawn,
+		Action:      models.DisputeActionWithdrawn,
+		Actor:       models.DisputeActorOpener,
+		ActorUserID: &req.UserID,
+		Note:        note,
+	})
+}
+
+// ResolveDispute ให้ admin ตัดสิน: "reverse" คืนแต้มให้ผู้โอน หรือ "reject" ปิด dispute โดยไม่คืน
+func (s *DisputeService) ResolveDispute(id int, req models.DisputeResolveRequest) (*models.DisputeResponse, error) {
+	var resolution models.DisputeResolution
+	switch req.Outcome {
+	case "reverse":
+		resolution = models.DisputeResolutionReversed
+	case "reject":
+		resolution = models.DisputeResolutionRejected
+	default:
+		return nil, errors.New("outcome must be reverse or reject")
+	}
+
+	dispute, note, err := s.activeDispute(id, 0, models.DisputeActorAdmin, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	transition := repositories.DisputeTransition{
+		DisputeID:  dispute.ID,
+		From:       []models.DisputeStatus{models.DisputeStatusOpen, models.DisputeStatusContested},
+		To:         models.DisputeStatusResolved,
+		Resolution: &resolution,
+		Action:     models.DisputeActionResolved,
+		Actor:      models.DisputeActorAdmin,
+		Note:       note,
+	}
+	if resolution == models.DisputeResolutionReversed {
+		return s.reverse(dispute, transition)
+	}
+	return s.transition(dispute, transition)
+}
+
+// activeDispute ดึง dispute ที่ยังไม่ปิด และตรวจว่า userID เป็นคู่กรณีตาม actor (admin ไม่ต้องตรวจ)
+// คืน note ที่ตัดช่องว่างแล้ว (nil ถ้าว่าง)
+func (s *DisputeService) activeDispute(id, userID int, actor string, note *string) (*models.TransferDispute, *string, error) {
+	if note != nil {
+		trimmed := strings.TrimSpace(*note)
+		if len(trimmed) > 512 {
+			return nil, nil, errors.New("note cannot exceed 512 characters")
+		}
+		note = &trimmed
+		if trimmed == "" {
+			note = nil
+		}
+	}
+
+	dispute, err := s.disputeRepo.GetByID(id)
+	if err != nil {
+		return nil, nil, err
+	}
+	// ไม่บอกว่ามี dispute อยู่ถ้า user ไม่ใช่คู่กรณีที่ทำ action นี้ได้
+	if dispute == nil ||
+		(actor == models.DisputeActorOpener && dispute.OpenedBy != userID) ||
+		(actor == models.DisputeActorCounterparty && dispute.CounterpartyID != userID) {
+		return nil, nil, ErrDisputeNotFound
+	}
+	if dispute.Status != models.DisputeStatusOpen && dispute.Status != models.DisputeStatusContested {
+		return nil, nil, fmt.Errorf("%w: status is %s", ErrDisputeClosed, dispute.Status)
+	}
+	return dispute, note, nil
+}
+
+// transition เปลี่ยนสถานะ dispute ที่ไม่ต้องย้ายแต้ม
+func (s *DisputeService) transition(dispute *models.TransferDispute, t repositories.DisputeTransition) (*models.DisputeResponse, error) {
+	if err := s.disputeRepo.Transition(t); err != nil {
+		return nil, disputeConflict(err)
+	}
+	return s.updated(dispute.ID, nil)
+}
+
+// reverse คืนแต้มของ transfer ให้ผู้โอนพร้อมเปลี่ยนสถานะ dispute ใน transaction เดียวกัน
+// ใช้ policy การติดลบของผู้รับเดียวกับการ reverse โดยทีม support
+func (s *DisputeService) reverse(dispute *models.TransferDispute, t repositories.DisputeTransition) (*models.DisputeResponse, error) {
+	ts := s.transferService
+	reason := fmt.Sprintf("dispute %d: %s", dispute.ID, dispute.Reason)
+	reversed, err := ts.transferRepo.Reverse(dispute.IdemKey, reason, ts.reversalPolicy == ReversalPolicyAllowNegative, &t)
+	if err != nil {
+		return nil, disputeConflict(err)
+	}
+	ts.notifyTransfer(models.WebhookEventTransferReversed, reversed)
+	return s.updated(dispute.ID, reversed)
+}
+
+// updated โหลด dispute หลังเปลี่ยนสถานะและแจ้ง dispute.updated
+func (s *DisputeService) updated(id int, transfer *models.Transfer) (*models.DisputeResponse, error) {
+	dispute, err := s.disputeRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	s.webhooks.Publish(models.WebhookEventDisputeUpdated, dispute)
+	return &models.DisputeResponse{Dispute: *dispute, Transfer: transfer}, nil
+}
+
+func disputeConflict(err error) error {
+	if errors.Is(err, repositories.ErrDisputeConflict) {
+		return fmt.Errorf("%w: %v", ErrDisputeClosed, err)
+	}
+	return err
+}
+
+// GetDispute ดึง dispute พร้อม audit trail และ transfer
+func (s *DisputeService) GetDispute(id int) (*models.DisputeResponse, error) {
+	dispute, err := s.disputeRepo.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+	if dispute == nil {
+		return nil, ErrDisputeNotFound
+	}
+
+	events, err := s.disputeRepo.GetEvents(id)
+	if err != nil {
+		return nil, err
+	}
+	transfer, err := s.transferService.GetTransferByIdemKey(dispute.IdemKey)
+	if err != nil {
+		return nil, err
+	}
+	return &models.DisputeResponse{Dispute: *dispute, Events: events, Transfer: transfer}, nil
+}
+
+// GetUserDisputes ดึง dispute ของ user ตามบทบาท: opened, received หรือ all (ค่าเริ่มต้น)
+func (s *DisputeService) GetUserDisputes(userID int, role string, status models.DisputeStatus) (*models.DisputeListResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	switch role {
+	case "", "opened", "received", "all":
+	default:
+		return nil, errors.New("role must be opened, received or all")
+	}
+	if err := validateDisputeStatus(status); err != nil {
+		return nil, err
+	}
+
+	disputes, err := s.disputeRepo.GetByUserID(userID, role, status)
+	if err != nil {
+		return nil, err
+	}
+	if disputes == nil {
+		disputes = []models.TransferDispute{}
+	}
+	return &models.DisputeListResponse{Data: disputes}, nil
+}
+
+// GetDisputes ดึง dispute ทั้งหมดสำหรับ admin เก่าสุดก่อน (ใช้ ?status=contested เป็นคิวที่รอตัดสิน)
+func (s *DisputeService) GetDisputes(status models.DisputeStatus, limit int) (*models.DisputeListResponse, error) {
+	if err := validateDisputeStatus(status); err != nil {
+		return nil, err
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	disputes, err := s.disputeRepo.List(status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if disputes == nil {
+		disputes = []models.TransferDispute{}
+	}
+	return &models.DisputeListResponse{Data: disputes}, nil
+}
+
+func validateDisputeStatus(status models.DisputeStatus) error {
+	switch status {
+	case "", models.DisputeStatusOpen, models.DisputeStatusContested, models.DisputeStatusAccepted,
+		models.DisputeStatusWithdrawn, models.DisputeStatusResolved:
+		return nil
+	}
+	return fmt.Errorf("unknown dispute status %q", status)
+}
+
+// annotate ใส่ dispute ล่าสุดให้ transfer แต่ละรายการ (ใช้ตอนแสดงประวัติการโอน)
+func (s *DisputeService) annotate(transfers []models.Transfer) {
+	ids := make([]int, len(transfers))
+	for i, t := range transfers {
+		ids[i] = t.ID
+	}
+	latest, err := s.disputeRepo.LatestByTransfers(ids)
+	if err != nil {
+		log.Printf("Failed to load disputes of transfers: %v", err)
+		return
+	}
+	for i := range transfers {
+		if d, ok := latest[transfers[i].ID]; ok {
+			id, status := d.ID, d.Status
+			transfers[i].DisputeID, transfers[i].DisputeStatus = &id, &status
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package services
+
+import (
+	"database/sql"
+	"errors"
+	"math"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+type FeeService struct {
+	feeRepo *repositories.FeeRepository
+}
+
+func NewFeeService(feeRepo *repositories.FeeRepository) *FeeService {
+	return &FeeService{feeRepo: feeRepo}
+}
+
+func (s *FeeService) GetFees() (*models.TransferFeeListResponse, error) {
+	fees, err := s.feeRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if fees == nil {
+		fees = []models.TransferFee{}
+	}
+
+	houseUserID, err := s.feeRepo.GetHouseUserID()
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TransferFeeListResponse{Data: fees, HouseUserID: houseUserID}, nil
+}
+
+// UpdateFee แก้ค่าธรรมเนียมของ membership level (nil ถ้าไม่มี level นี้)
+// transfer ที่สร้างไว้แล้ว (two-phase หรือล่วงหน้า) ยังใช้ค่าธรรมเนียมเดิม
+func (s *FeeService) UpdateFee(level string, req models.TransferFeeUpdateRequest) (*models.TransferFee, error) {
+	fee, err := s.feeRepo.GetByLevel(level)
+	if err != nil || fee == nil {
+		return nil, err
+	}
+
+	if req.FlatFee != nil {
+		fee.FlatFee = *req.FlatFee
+	}
+	if req.PercentFee != nil {
+		fee.PercentFee = *req.PercentFee
+	}
+
+	if fee.FlatFee < 0 || fee.PercentFee < 0 {
+		return nil, errors.New("fees cannot be negative")
+	}
+	if fee.PercentFee > 100 {
+		return nil, errors.New("percentFee cannot exceed 100")
+	}
+
+	if err := s.feeRepo.Update(fee); err != nil {
+		return nil, err
+	}
+	return fee, nil
+}
+
+// Quote คำนวณค่าธรรมเนียมที่ผู้โอนต้องจ่ายเพิ่มจาก amount
+// คืน 0 ถ้าไม่พบผู้โอน (การโอนจะถูกปฏิเสธตอนตรวจ user อยู่แล้ว)
+func (s *FeeService) Quote(fromUserID int, amount models.Points) (models.Points, error) {
+	fee, err := s.feeRepo.GetForUser(fromUserID)
+	if err != nil || fee == nil {
+		return 0, err
+	}
+	return computeFee(fee, amount), nil
+}
+
+// HouseUserID คืน user id ของบัญชีที่รับค่าธรรมเนียม (0 ถ้ายังไม่มี)
+func (s *FeeService) HouseUserID() (int, error) {
+	id, err := s.feeRepo.GetHouseUserID()
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return id, err
+}
+
+// computeFee = flat + amount * percent / 100 ปัดเป็นหน่วย 0.01 แต้ม (ปัดครึ่งขึ้น)
+// percent เป็น float จึงบวก epsilon ก่อนปัด เพราะผลคูณอย่าง 1.5 อาจออกมาเป็น 1.4999... และจะถูกปัดลง
+func computeFee(fee *models.TransferFee, amount models.Points) models.Points {
+	return fee.FlatFee + models.Points(math.Round(float64(amount)*fee.PercentFee/100+1e-9))
+}
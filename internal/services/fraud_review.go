@@ -0,0 +1,62 @@
+package services
+
+import (
+	"errors"
+
+	"kbtg-backend/internal/models"
+)
+
+// ErrFraudDisabled ถูกคืนเมื่อเรียก API ของ review โดยไม่ได้ตั้ง FraudService
+var ErrFraudDisabled = errors.New("fraud screening is not configured")
+
+// ApproveFraudReview อนุมัติ transfer ที่ถูกพักไว้ แล้วโอนจาก hold ที่จองไว้ทันที
+// ถ้าโอนไม่สำเร็จ (เช่น hold หมดอายุไปแล้ว) review ยังถูกบันทึกว่า approved และ transfer เป็น failed
+func (s *TransferService) ApproveFraudReview(reviewID int, req models.FraudReviewDecisionRequest) (*models.FraudReviewResponse, error) {
+	if s.fraud == nil {
+		return nil, ErrFraudDisabled
+	}
+	review, err := s.fraud.decide(reviewID, models.FraudReviewApproved, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer, err := s.transferRepo.GetByIdemKey(review.IdemKey)
+	if err != nil {
+		return nil, err
+	}
+	if transfer == nil {
+		return nil, errors.New("transfer not found")
+	}
+
+	completed, err := s.settlePending(*transfer)
+	if err != nil {
+		return nil, err
+	}
+	return &models.FraudReviewResponse{Review: *review, Transfer: completed}, nil
+}
+
+// RejectFraudReview ปฏิเสธ transfer ที่ถูกพักไว้ transfer ถูกยกเลิกและ hold ถูกปล่อยคืนผู้โอน
+func (s *TransferService) RejectFraudReview(reviewID int, req models.FraudReviewDecisionRequest) (*models.FraudReviewResponse, error) {
+	if s.fraud == nil {
+		return nil, ErrFraudDisabled
+	}
+	review, err := s.fraud.decide(reviewID, models.FraudReviewRejected, req.Note)
+	if err != nil {
+		return nil, err
+	}
+
+	reason := "transfer rejected by fraud review"
+	if review.DecisionNote != nil {
+		reason = *review.DecisionNote
+	}
+	failure := &models.TransferFailure{Code: "FRAUD_REJECTED", Reason: reason}
+	if err := s.transferRepo.TransitionStatus(review.TransferID, models.TransferStatusPending, models.TransferStatusCancelled, failure); err != nil {
+		return nil, err
+	}
+
+	transfer, err := s.transferRepo.GetByIdemKey(review.IdemKey)
+	if err != nil {
+		return nil, err
+	}
+	return &models.FraudReviewResponse{Review: *review, Transfer: transfer}, nil
+}
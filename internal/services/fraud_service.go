@@ -0,0 +1,295 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// FraudReviewTTL คือเวลาที่ transfer ที่ถูกพักรอตรวจได้ก่อน hold หมดอายุและ transfer ถูกยกเลิก
+const FraudReviewTTL = 72 * time.Hour
+
+// คะแนนของแต่ละสัญญาณ รวมกันแล้วเทียบกับ reviewScore และ blockScore ใน FraudSettings
+var fraudSignalScores = map[string]int{
+	models.FraudSignalSenderVelocity:   30,
+	models.FraudSignalReceiverVelocity: 20,
+	models.FraudSignalFunnel:           35,
+	models.FraudSignalRing:             60,
+	models.FraudSignalNewAccount:       30,
+}
+
+var (
+	ErrFraudReviewNotFound = errors.New("fraud review not found")
+	// ErrFraudReviewClosed ถูกคืนเมื่อ review ถูกตัดสินไปแล้ว หรือ transfer ถูกยกเลิกก่อน
+	ErrFraudReviewClosed = errors.New("fraud review is no longer pending")
+)
+
+// FraudBlockedError คือการโอนที่ถูกปฏิเสธจากการตรวจ fraud
+// ReviewRequired = true เมื่อคะแนนถึงเกณฑ์พักรอตรวจ แต่การโอนประเภทนั้นพักไว้ไม่ได้ (batch, transfer ล่วงหน้า)
+type FraudBlockedError struct {
+	Assessment     models.FraudAssessment
+	ReviewRequired bool
+}
+
+func (e *FraudBlockedError) Error() string {
+	names := make([]string, len(e.Assessment.Signals))
+	for i, signal := range e.Assessment.Signals {
+		names[i] = signal.Name
+	}
+	if e.ReviewRequired {
+		return fmt.Sprintf("transfer requires fraud review and cannot be held (score %d: %s)",
+			e.Assessment.Score, strings.Join(names, ", "))
+	}
+	return fmt.Sprintf("transfer blocked by fraud checks (score %d: %s)", e.Assessment.Score, strings.Join(names, ", "))
+}
+
+// Code คือ error code ที่ API ตอบ
+func (e *FraudBlockedError) Code() string {
+	if e.ReviewRequired {
+		return "FRAUD_REVIEW_REQUIRED"
+	}
+	return "FRAUD_BLOCKED"
+}
+
+// Signal คือชื่อสัญญาณที่มีคะแนนสูงสุด ใช้บันทึกเป็น fail_rule ของ transfer
+func (e *FraudBlockedError) Signal() string {
+	if len(e.Assessment.Signals) == 0 {
+		return ""
+	}
+	return e.Assessment.Signals[0].Name
+}
+
+// FraudService ให้คะแนนความเสี่ยงของการโอนจากประวัติใน transfers ก่อน commit
+// และดูแลเกณฑ์กับคิว review (การตัดสิน review อยู่ที่ TransferService เพราะต้องโอนหรือยกเลิก transfer)
+type FraudService struct {
+	fraudRepo *repositories.FraudRepository
+	clock     Clock
+}
+
+func NewFraudService(fraudRepo *repositories.FraudRepository) *FraudService {
+	return &FraudService{
+		fraudRepo: fraudRepo,
+		clock:     SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้คำนวณ window และอายุบัญชี
+func (s *FraudService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+func (s *FraudService) GetSettings() (*models.FraudSettings, error) {
+	return s.fraudRepo.GetSettings()
+}
+
+// UpdateSettings แก้เกณฑ์การตรวจ มีผลกับการโอนถัดไปทันที (review ที่ค้างอยู่ไม่เปลี่ยน)
+func (s *FraudService) UpdateSettings(req models.FraudSettingsUpdateRequest) (*models.FraudSettings, error) {
+	settings, err := s.fraudRepo.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Enabled != nil {
+		settings.Enabled = *req.Enabled
+	}
+	fields := []struct {
+		value *int
+		dst   *int
+	}{
+		{req.WindowSeconds, &settings.WindowSeconds},
+		{req.SenderVelocity, &settings.SenderVelocity},
+		{req.ReceiverVelocity, &settings.ReceiverVelocity},
+		{req.FunnelSenders, &settings.FunnelSenders},
+		{req.RingMaxHops, &settings.RingMaxHops},
+		{req.NewAccountDays, &settings.NewAccountDays},
+		{req.NewAccountTransfers, &settings.NewAccountTransfers},
+		{req.ReviewScore, &settings.ReviewScore},
+		{req.BlockScore, &settings.BlockScore},
+	}
+	for _, f := range fields {
+		if f.value != nil {
+			*f.dst = *f.value
+		}
+	}
+
+	if err := validateFraudSettings(settings); err != nil {
+		return nil, err
+	}
+	if err := s.fraudRepo.UpdateSettings(settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+func validateFraudSettings(settings *models.FraudSettings) error {
+	switch {
+	case settings.WindowSeconds < 60:
+		return errors.New("windowSeconds must be at least 60")
+	case settings.SenderVelocity < 1 || settings.ReceiverVelocity < 1 || settings.NewAccountTransfers < 1:
+		return errors.New("velocity thresholds must be at least 1")
+	case settings.FunnelSenders < 2:
+		return errors.New("funnelSenders must be at least 2")
+	case settings.RingMaxHops < 3 || settings.RingMaxHops > 6:
+		return errors.New("ringMaxHops must be between 3 and 6")
+	case settings.NewAccountDays < 0:
+		return errors.New("newAccountDays cannot be negative")
+	case settings.ReviewScore < 1 || settings.BlockScore > 100:
+		return errors.New("scores must be between 1 and 100")
+	case settings.ReviewScore > settings.BlockScore:
+		return errors.New("reviewScore cannot exceed blockScore")
+	}
+	return nil
+}
+
+// Assess ให้คะแนนการโอน req (ยังไม่ถูกบันทึก) จากการโอนใน window ล่าสุด
+// ถ้าปิดการตรวจไว้จะคืน allow เสมอ
+func (s *FraudService) Assess(req models.TransferCreateRequest) (*models.FraudAssessment, error) {
+	settings, err := s.fraudRepo.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	assessment := &models.FraudAssessment{Decision: models.FraudDecisionAllow, Signals: []models.FraudSignal{}}
+	if !settings.Enabled {
+		return assessment, nil
+	}
+
+	now := s.clock.Now()
+	window := time.Duration(settings.WindowSeconds) * time.Second
+	since := now.Add(-window)
+	add := func(name, format string, args ...interface{}) {
+		assessment.Signals = append(assessment.Signals, models.FraudSignal{
+			Name:   name,
+			Score:  fraudSignalScores[name],
+			Detail: fmt.Sprintf(format, args...),
+		})
+	}
+
+	// นับรายการนี้ด้วย (+1) เพื่อให้เกณฑ์หมายถึง "จำนวนสูงสุดที่ยอมให้ใน window"
+	sent, err := s.fraudRepo.SentCount(req.FromUserID, since)
+	if err != nil {
+		return nil, err
+	}
+	if sent+1 > settings.SenderVelocity {
+		add(models.FraudSignalSenderVelocity, "sender made %d transfers in %s (max %d)", sent+1, window, settings.SenderVelocity)
+	}
+
+	received, otherSenders, err := s.fraudRepo.ReceivedStats(req.ToUserID, req.FromUserID, since)
+	if err != nil {
+		return nil, err
+	}
+	if received+1 > settings.ReceiverVelocity {
+		add(models.FraudSignalReceiverVelocity, "receiver got %d transfers in %s (max %d)", received+1, window, settings.ReceiverVelocity)
+	}
+	if otherSenders+1 >= settings.FunnelSenders {
+		add(models.FraudSignalFunnel, "receiver got transfers from %d different senders in %s", otherSenders+1, window)
+	}
+
+	ring, err := s.fraudRepo.RingLength(req.FromUserID, req.ToUserID, since, settings.RingMaxHops)
+	if err != nil {
+		return nil, err
+	}
+	if ring > 0 {
+		add(models.FraudSignalRing, "transfer closes a loop of %d users within %s", ring, window)
+	}
+
+	createdAt, err := s.fraudRepo.AccountCreatedAt(req.FromUserID)
+	if err != nil {
+		return nil, err
+	}
+	newAccount := createdAt != nil && now.Sub(*createdAt) < time.Duration(settings.NewAccountDays)*24*time.Hour
+	if newAccount && sent+1 >= settings.NewAccountTransfers {
+		add(models.FraudSignalNewAccount, "sender account is %d day(s) old and made %d transfers in %s",
+			int(now.Sub(*createdAt).Hours()/24), sent+1, window)
+	}
+
+	sort.SliceStable(assessment.Signals, func(i, j int) bool {
+		return assessment.Signals[i].Score > assessment.Signals[j].Score
+	})
+	for _, signal := range assessment.Signals {
+		assessment.Score += signal.Score
+	}
+	if assessment.Score > 100 {
+		assessment.Score = 100
+	}
+
+	switch {
+	case assessment.Score >= settings.BlockScore:
+		assessment.Decision = models.FraudDecisionBlock
+	case assessment.Score >= settings.ReviewScore:
+		assessment.Decision = models.FraudDecisionReview
+	}
+	return assessment, nil
+}
+
+// GetReviews ดึงคิว review (ค่าเริ่มต้นคือรายการที่ยังรอตัดสิน)
+func (s *FraudService) GetReviews(status models.FraudReviewStatus, limit int) (*models.FraudReviewListResponse, error) {
+	if status == "" {
+		status = models.FraudReviewPending
+	}
+	switch status {
+	case models.FraudReviewPending, models.FraudReviewApproved, models.FraudReviewRejected, models.FraudReviewClosed:
+	case "all":
+		status = ""
+	default:
+		return nil, fmt.Errorf("unknown review status %q", status)
+	}
+	if limit <= 0 || limit > 200 {
+		limit = 50
+	}
+
+	reviews, err := s.fraudRepo.ListReviews(status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if reviews == nil {
+		reviews = []models.FraudReview{}
+	}
+	return &models.FraudReviewListResponse{Data: reviews}, nil
+}
+
+func (s *FraudService) GetReview(id int) (*models.FraudReview, error) {
+	review, err := s.fraudRepo.GetReview(id)
+	if err != nil {
+		return nil, err
+	}
+	if review == nil {
+		return nil, ErrFraudReviewNotFound
+	}
+	return review, nil
+}
+
+// IsUnderReview บอกว่า transfer ถูกพักไว้รอ admin ตัดสินอยู่หรือไม่
+func (s *FraudService) IsUnderReview(transferID int) (bool, error) {
+	return s.fraudRepo.IsUnderReview(transferID)
+}
+
+// decide ตัดสิน review ที่ยังรออยู่ (เรียกจาก TransferService)
+func (s *FraudService) decide(id int, status models.FraudReviewStatus, note *string) (*models.FraudReview, error) {
+	if _, err := s.GetReview(id); err != nil {
+		return nil, err
+	}
+	if note != nil {
+		trimmed := strings.TrimSpace(*note)
+		if len(trimmed) > 512 {
+			return nil, errors.New("note cannot exceed 512 characters")
+		}
+		note = &trimmed
+		if trimmed == "" {
+			note = nil
+		}
+	}
+
+	ok, err := s.fraudRepo.DecideReview(id, status, note)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrFraudReviewClosed
+	}
+	return s.GetReview(id)
+}
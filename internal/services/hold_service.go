@@ -0,0 +1,182 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+const (
+	// DefaultHoldTTL คืออายุของ hold ร้านค้าถ้าไม่ได้ระบุ expiresInMinutes
+	DefaultHoldTTL = 24 * time.Hour
+	// MaxHoldTTL คืออายุสูงสุดของ hold ร้านค้า (เหมือน authorization ของบัตร)
+	MaxHoldTTL = 7 * 24 * time.Hour
+)
+
+// HoldService ให้ร้านค้าพันธมิตรกันแต้มของสมาชิกตอน checkout แล้ว capture หรือ void ภายหลัง
+// แต้มที่ถูก hold ไม่ถูกหักจริงจนกว่าจะ capture แต่ใช้โอนหรือ hold ซ้ำไม่ได้
+type HoldService struct {
+	holdRepo *repositories.HoldRepository
+	webhooks *WebhookService
+	clock    Clock
+}
+
+func NewHoldService(holdRepo *repositories.HoldRepository) *HoldService {
+	return &HoldService{
+		holdRepo: holdRepo,
+		clock:    SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้คำนวณเวลาหมดอายุ
+func (s *HoldService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetWebhooks ตั้ง WebhookService ที่รับ ledger.entry_created ของการ capture (ไม่ตั้งก็ได้)
+func (s *HoldService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// CreateHold กันแต้มของ user ให้ร้านค้า ถ้าแต้มที่ใช้ได้ไม่พอจะคืน repositories.ErrInsufficientPoints
+func (s *HoldService) CreateHold(userID int, req models.HoldCreateRequest) (*models.PointHold, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	if req.Amount <= 0 {
+		return nil, errors.New("amount must be greater than 0")
+	}
+	merchantID := strings.TrimSpace(req.MerchantID)
+	if merchantID == "" {
+		return nil, errors.New("merchantId is required")
+	}
+	if len(merchantID) > 64 {
+		return nil, errors.New("merchantId cannot exceed 64 characters")
+	}
+	reference := strings.TrimSpace(req.Reference)
+	if len(reference) > 128 {
+		return nil, errors.New("reference cannot exceed 128 characters")
+	}
+
+	ttl := DefaultHoldTTL
+	if req.ExpiresInMinutes != nil {
+		ttl = time.Duration(*req.ExpiresInMinutes) * time.Minute
+		if ttl <= 0 || ttl > MaxHoldTTL {
+			return nil, fmt.Errorf("expiresInMinutes must be between 1 and %d", int(MaxHoldTTL.Minutes()))
+		}
+	}
+
+	now := s.clock.Now()
+	hold, err := s.holdRepo.Create(userID, req.Amount, merchantID, reference, now.Add(ttl), now)
+	if err != nil {
+		if err.Error() == "from user not found" {
+			return nil, errors.New("user not found")
+		}
+		return nil, err
+	}
+	return hold, nil
+}
+
+// CaptureHold ตัดแต้มจาก hold ทั้งหมดหรือบางส่วนเป็น ledger entry redeem ส่วนที่ไม่ได้ตัดถูกปล่อยคืน
+func (s *HoldService) CaptureHold(userID, holdID int, req models.HoldCaptureRequest) (*models.HoldResponse, error) {
+	hold, err := s.userHold(userID, holdID)
+	if err != nil {
+		return nil, err
+	}
+
+	amount := hold.Amount
+	if req.Amount != nil {
+		amount = *req.Amount
+		if amount <= 0 {
+			return nil, errors.New("amount must be greater than 0")
+		}
+		if amount > hold.Amount {
+			return nil, fmt.Errorf("cannot capture %s from a hold of %s", amount, hold.Amount)
+		}
+	}
+
+	captured, entry, err := s.holdRepo.Capture(hold.ID, amount, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.webhooks.Publish(models.WebhookEventLedgerEntryCreated, entry)
+	return &models.HoldResponse{Hold: *captured, LedgerEntry: entry}, nil
+}
+
+// VoidHold ปล่อยแต้มที่ hold ไว้คืนทั้งหมด
+func (s *HoldService) VoidHold(userID, holdID int) (*models.PointHold, error) {
+	hold, err := s.userHold(userID, holdID)
+	if err != nil {
+		return nil, err
+	}
+	return s.holdRepo.Void(hold.ID, s.clock.Now())
+}
+
+// GetHold ดึง hold ของร้านค้าที่เป็นของ user
+func (s *HoldService) GetHold(userID, holdID int) (*models.PointHold, error) {
+	return s.userHold(userID, holdID)
+}
+
+// GetHolds ดึง hold ของร้านค้าของ user กรองตาม status ถ้าระบุ
+func (s *HoldService) GetHolds(userID int, status models.HoldStatus) (*models.HoldListResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	switch status {
+	case "", models.HoldStatusActive, models.HoldStatusCaptured, models.HoldStatusReleased, models.HoldStatusExpired:
+	default:
+		return nil, fmt.Errorf("unknown hold status %q", status)
+	}
+
+	holds, err := s.holdRepo.GetByUserID(userID, status)
+	if err != nil {
+		return nil, err
+	}
+	if holds == nil {
+		holds = []models.PointHold{}
+	}
+	return &models.HoldListResponse{Data: holds}, nil
+}
+
+// userHold ดึง hold และตรวจว่าเป็นของ user (ถ้าไม่ใช่ ตอบเหมือนไม่มี hold)
+func (s *HoldService) userHold(userID, holdID int) (*models.PointHold, error) {
+	hold, err := s.holdRepo.GetByID(holdID)
+	if err != nil {
+		return nil, err
+	}
+	if hold == nil || hold.UserID != userID {
+		return nil, repositories.ErrHoldNotFound
+	}
+	return hold, nil
+}
+
+// ExpireHolds เปลี่ยน hold ของร้านค้าที่เลยเวลาหมดอายุ ณ now เป็น expired คืนจำนวนที่เปลี่ยน
+func (s *HoldService) ExpireHolds(now time.Time) (int64, error) {
+	return s.holdRepo.ExpireDue(now)
+}
+
+// StartExpirySweeper เปลี่ยน hold ของร้านค้าที่หมดอายุเป็น expired เป็นระยะจนกว่า stop จะถูกปิด
+func (s *HoldService) StartExpirySweeper(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := s.ExpireHolds(s.clock.Now()); err != nil {
+					log.Printf("Failed to expire holds: %v", err)
+				} else if n > 0 {
+					log.Printf("Expired %d holds", n)
+				}
+			}
+		}
+	}()
+}
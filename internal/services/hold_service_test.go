@@ -0,0 +1,168 @@
+package services
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/testutil"
+)
+
+// TestHoldExpiryFollowsClockInAnyTimeZone ตรวจว่า hold หมดอายุตรงเวลาไม่ว่า server จะอยู่ time zone ไหน
+// (expires_at ถูกเทียบแบบ string กับเวลา UTC ถ้าเก็บเป็นเวลาท้องถิ่น hold จะหมดอายุช้าหรือเร็วไปเท่ากับ offset)
+func TestHoldExpiryFollowsClockInAnyTimeZone(t *testing.T) {
+	zones := []*time.Location{
+		time.FixedZone("ICT", 7*60*60),
+		time.FixedZone("PST", -8*60*60),
+		time.UTC,
+	}
+	for _, zone := range zones {
+		t.Run(zone.String(), func(t *testing.T) {
+			local := time.Local
+			time.Local = zone
+			defer func() { time.Local = local }()
+
+			db := testutil.NewDB(t)
+			user := testutil.NewUser(t, db, 10*models.PointsScale)
+			service := NewHoldService(repositories.NewHoldRepository(db.DB))
+			clock := NewFixedClock(time.Now())
+			service.SetClock(clock)
+
+			ttl := 30
+			swept, err := service.CreateHold(user.ID, models.HoldCreateRequest{
+				Amount: 2 * models.PointsScale, MerchantID: "shop-1", ExpiresInMinutes: &ttl,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			captured, err := service.CreateHold(user.ID, models.HoldCreateRequest{
+				Amount: 3 * models.PointsScale, MerchantID: "shop-1", ExpiresInMinutes: &ttl,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if want := clock.Now().Add(30 * time.Minute); !swept.ExpiresAt.Equal(want) {
+				t.Fatalf("hold expires at %v, want %v", swept.ExpiresAt, want)
+			}
+
+			// ก่อนหมดอายุ: sweeper ไม่แตะ
+			clock.Advance(29 * time.Minute)
+			if n, err := service.ExpireHolds(clock.Now()); err != nil || n != 0 {
+				t.Fatalf("expired %d holds before their expiry (err %v)", n, err)
+			}
+
+			// เลยเวลาแล้วแต่ sweeper ยังไม่รัน: capture ต้องถูกปฏิเสธ
+			clock.Advance(2 * time.Minute)
+			if _, err := service.CaptureHold(user.ID, captured.ID, models.HoldCaptureRequest{}); !errors.Is(err, repositories.ErrHoldExpired) {
+				t.Fatalf("capture after expiry: got %v, want %v", err, repositories.ErrHoldExpired)
+			}
+
+			if n, err := service.ExpireHolds(clock.Now()); err != nil || n != 2 {
+				t.Fatalf("expired %d holds after their expiry (err %v), want 2", n, err)
+			}
+			for _, id := range []int{swept.ID, captured.ID} {
+				hold, err := service.GetHold(user.ID, id)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if hold.Status != models.HoldStatusExpired {
+					t.Fatalf("hold %d is %s, want expired", id, hold.Status)
+				}
+				if !hold.UpdatedAt.Equal(clock.Now()) {
+					t.Fatalf("hold %d updated at %v, want the sweep time %v", id, hold.UpdatedAt, clock.Now())
+				}
+			}
+			if got := testutil.UserPoints(t, db, user.ID); got != 10*models.PointsScale {
+				t.Fatalf("user has %s points after holds expired, want 10.00", got)
+			}
+		})
+	}
+}
+
+func TestHoldCaptureBeforeExpiry(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("ICT", 7*60*60)
+	defer func() { time.Local = local }()
+
+	db := testutil.NewDB(t)
+	user := testutil.NewUser(t, db, 10*models.PointsScale)
+	service := NewHoldService(repositories.NewHoldRepository(db.DB))
+	clock := NewFixedClock(time.Now())
+	service.SetClock(clock)
+
+	ttl := 10
+	hold, err := service.CreateHold(user.ID, models.HoldCreateRequest{
+		Amount: 4 * models.PointsScale, MerchantID: "shop-1", ExpiresInMinutes: &ttl,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(9 * time.Minute)
+	amount := models.Points(150)
+	response, err := service.CaptureHold(user.ID, hold.ID, models.HoldCaptureRequest{Amount: &amount})
+	if err != nil {
+		t.Fatalf("capture before expiry: %v", err)
+	}
+	if response.Hold.Status != models.HoldStatusCaptured {
+		t.Fatalf("hold is %s, want captured", response.Hold.Status)
+	}
+	if got, want := testutil.UserPoints(t, db, user.ID), 10*models.PointsScale-amount; got != want {
+		t.Fatalf("user has %s points, want %s", got, want)
+	}
+
+	clock.Advance(time.Hour)
+	if n, err := service.ExpireHolds(clock.Now()); err != nil || n != 0 {
+		t.Fatalf("sweeper expired %d captured holds (err %v)", n, err)
+	}
+}
+
+// TestExpiredHoldNoLongerBlocksPoints: hold ที่เลย expires_at แล้วไม่ถูกนับเป็นแต้มที่ถูก hold
+// แม้ sweeper ยังไม่ได้เปลี่ยนสถานะ ทั้งในยอดคงเหลือและตอนกันแต้มครั้งใหม่
+func TestExpiredHoldNoLongerBlocksPoints(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("ICT", 7*60*60)
+	defer func() { time.Local = local }()
+
+	db := testutil.NewDB(t)
+	user := testutil.NewUser(t, db, 10*models.PointsScale)
+	service := NewHoldService(repositories.NewHoldRepository(db.DB))
+	users := NewUserService(repositories.NewUserRepository(db.DB))
+	clock := NewFixedClock(time.Now())
+	service.SetClock(clock)
+	users.SetClock(clock)
+
+	ttl := 30
+	if _, err := service.CreateHold(user.ID, models.HoldCreateRequest{
+		Amount: 8 * models.PointsScale, MerchantID: "shop-1", ExpiresInMinutes: &ttl,
+	}); err != nil {
+		t.Fatal(err)
+	}
+	balance, err := users.GetBalance(user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if balance.Held != 8*models.PointsScale || balance.Available != 2*models.PointsScale {
+		t.Fatalf("before expiry held %s and available %s, want 8.00 and 2.00", balance.Held, balance.Available)
+	}
+	if _, err := service.CreateHold(user.ID, models.HoldCreateRequest{
+		Amount: 3 * models.PointsScale, MerchantID: "shop-2", ExpiresInMinutes: &ttl,
+	}); !errors.Is(err, repositories.ErrInsufficientPoints) {
+		t.Fatalf("hold beyond available points: got %v, want %v", err, repositories.ErrInsufficientPoints)
+	}
+
+	clock.Advance(31 * time.Minute)
+	if balance, err = users.GetBalance(user.ID); err != nil {
+		t.Fatal(err)
+	}
+	if balance.Held != 0 || balance.Available != 10*models.PointsScale {
+		t.Fatalf("after expiry held %s and available %s, want 0 and 10.00", balance.Held, balance.Available)
+	}
+	if _, err := service.CreateHold(user.ID, models.HoldCreateRequest{
+		Amount: 9 * models.PointsScale, MerchantID: "shop-2", ExpiresInMinutes: &ttl,
+	}); err != nil {
+		t.Fatalf("hold after the earlier hold expired: %v", err)
+	}
+}
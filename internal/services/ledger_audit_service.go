@@ -0,0 +1,277 @@
+package services
+
+import (
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+var (
+	// ErrLedgerSigningKeyMissing ถูกคืนเมื่อสร้าง checkpoint โดยไม่ได้ตั้ง LEDGER_SIGNING_KEY
+	ErrLedgerSigningKeyMissing = errors.New("ledger signing key is not configured")
+	// ErrLedgerChainInvalid ถูกคืนเมื่อสร้าง checkpoint ขณะที่ hash chain ขาด (ไม่ sign chain ที่ถูกแก้)
+	ErrLedgerChainInvalid = errors.New("ledger hash chain is broken")
+)
+
+// ledgerVerifyPageSize คือจำนวน entry ที่อ่านต่อครั้งระหว่างไล่ตรวจ chain
+const ledgerVerifyPageSize = 500
+
+// LedgerAuditService ไล่ตรวจ hash chain ของ point_ledger และสร้าง checkpoint ที่ sign ด้วย Ed25519
+// signing key ตั้งจาก LEDGER_SIGNING_KEY ไม่ตั้งก็ได้ แต่จะสร้าง checkpoint ไม่ได้และไม่ตรวจ signature
+type LedgerAuditService struct {
+	repo     *repositories.LedgerAuditRepository
+	webhooks *WebhookService
+	clock    Clock
+	key      ed25519.PrivateKey
+	keyID    string
+}
+
+func NewLedgerAuditService(repo *repositories.LedgerAuditRepository) *LedgerAuditService {
+	return &LedgerAuditService{
+		repo:  repo,
+		clock: SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้บันทึกเวลาของการตรวจและ checkpoint
+func (s *LedgerAuditService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetWebhooks ตั้ง WebhookService ที่รับ ledger.checkpoint_created (ไม่ตั้งก็ได้)
+func (s *LedgerAuditService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// SetSigningKey ตั้ง Ed25519 key จาก seed 32 byte ที่ encode ด้วย base64
+func (s *LedgerAuditService) SetSigningKey(encoded string) error {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return fmt.Errorf("signing key must be base64: %w", err)
+	}
+	if len(seed) != ed25519.SeedSize {
+		return fmt.Errorf("signing key must be a %d-byte seed, got %d bytes", ed25519.SeedSize, len(seed))
+	}
+	s.key = ed25519.NewKeyFromSeed(seed)
+	s.keyID = ledgerKeyID(s.key.Public().(ed25519.PublicKey))
+	return nil
+}
+
+// ledgerKeyID คือ 8 byte แรกของ SHA-256 ของ public key (hex) ใช้บอกว่า checkpoint ถูก sign ด้วย key ไหน
+func ledgerKeyID(publicKey ed25519.PublicKey) string {
+	sum := sha256.Sum256(publicKey)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Verify ไล่ตรวจทุก entry ตามลำดับ id ว่า PrevHash ชี้ไปที่ entry ก่อนหน้าและ Hash ตรงกับเนื้อหา
+// แล้วเทียบกับทุก checkpoint (และ signature ถ้าตั้ง key ไว้) หยุดที่จุดแรกที่ขาด
+func (s *LedgerAuditService) Verify() (*models.LedgerVerification, error) {
+	// อ่าน checkpoint ก่อน entry: checkpoint ที่สร้างระหว่างตรวจจะไม่ถูกนับ แทนที่จะอ้างถึง entry ที่ยังไม่ได้อ่าน
+	checkpoints, err := s.repo.Checkpoints()
+	if err != nil {
+		return nil, err
+	}
+
+	v := &models.LedgerVerification{
+		LastHash:           models.LedgerGenesisHash,
+		SignaturesVerified: s.key != nil,
+	}
+	next := 0
+	afterID := 0
+	for v.FirstBrokenLink == nil {
+		entries, err := s.repo.ChainEntries(afterID, ledgerVerifyPageSize)
+		if err != nil {
+			return nil, err
+		}
+		if len(entries) == 0 {
+			break
+		}
+
+		for i := range entries {
+			e := &entries[i]
+			v.EntriesChecked++
+			if link := checkLedgerLink(e, v.LastHash); link != nil {
+				v.FirstBrokenLink = link
+				break
+			}
+			// checkpoint ที่อ้างถึง entry ก่อนหน้านี้แต่ไม่เจอระหว่างทาง แปลว่า entry นั้นหายไป
+			for next < len(checkpoints) && checkpoints[next].LedgerEntryID <= e.ID && v.FirstBrokenLink == nil {
+				v.FirstBrokenLink = s.checkCheckpoint(&checkpoints[next], e, v.EntriesChecked)
+				v.CheckpointsChecked++
+				next++
+			}
+			if v.FirstBrokenLink != nil {
+				break
+			}
+			v.LastEntryID, v.LastHash = e.ID, e.Hash
+		}
+		afterID = entries[len(entries)-1].ID
+	}
+
+	// checkpoint ที่เหลือหลัง entry สุดท้าย: entry ท้าย chain ถูกลบ
+	if v.FirstBrokenLink == nil && next < len(checkpoints) {
+		c := &checkpoints[next]
+		v.CheckpointsChecked++
+		v.FirstBrokenLink = &models.LedgerBrokenLink{
+			LedgerEntryID: c.LedgerEntryID,
+			CheckpointID:  &c.ID,
+			Reason:        models.LedgerBreakCheckpointMissing,
+			Expected:      c.Hash,
+			Message:       fmt.Sprintf("checkpoint %d covers ledger entry %d, which no longer exists", c.ID, c.LedgerEntryID),
+		}
+	}
+
+	v.Valid = v.FirstBrokenLink == nil
+	v.VerifiedAt = s.clock.Now()
+	return v, nil
+}
+
+// checkLedgerLink ตรวจ entry หนึ่งรายการเทียบกับ hash ของ entry ก่อนหน้า คืน nil ถ้าถูกต้อง
+func checkLedgerLink(e *models.PointLedger, prevHash string) *models.LedgerBrokenLink {
+	if e.PrevHash != prevHash {
+		return &models.LedgerBrokenLink{
+			LedgerEntryID: e.ID,
+			Reason:        models.LedgerBreakPrevHash,
+			Expected:      prevHash,
+			Actual:        e.PrevHash,
+			Message:       fmt.Sprintf("ledger entry %d does not link to the previous entry", e.ID),
+		}
+	}
+	if hash := e.ComputeHash(); e.Hash != hash {
+		return &models.LedgerBrokenLink{
+			LedgerEntryID: e.ID,
+			Reason:        models.LedgerBreakHash,
+			Expected:      hash,
+			Actual:        e.Hash,
+			Message:       fmt.Sprintf("ledger entry %d was modified after it was written", e.ID),
+		}
+	}
+	return nil
+}
+
+// checkCheckpoint ตรวจ checkpoint เทียบกับ entry e ซึ่งเป็น entry ที่ count ใน chain คืน nil ถ้าถูกต้อง
+func (s *LedgerAuditService) checkCheckpoint(c *models.LedgerCheckpoint, e *models.PointLedger, count int) *models.LedgerBrokenLink {
+	link := &models.LedgerBrokenLink{LedgerEntryID: c.LedgerEntryID, CheckpointID: &c.ID}
+	switch {
+	case c.LedgerEntryID != e.ID:
+		link.Reason = models.LedgerBreakCheckpointMissing
+		link.Expected = c.Hash
+		link.Message = fmt.Sprintf("checkpoint %d covers ledger entry %d, which no longer exists", c.ID, c.LedgerEntryID)
+	case c.Hash != e.Hash:
+		link.Reason = models.LedgerBreakCheckpoint
+		link.Expected, link.Actual = c.Hash, e.Hash
+		link.Message = fmt.Sprintf("ledger entry %d does not match the hash in checkpoint %d", e.ID, c.ID)
+	case c.EntryCount != count:
+		link.Reason = models.LedgerBreakCheckpoint
+		link.Expected, link.Actual = fmt.Sprint(c.EntryCount), fmt.Sprint(count)
+		link.Message = fmt.Sprintf("checkpoint %d counted %d entries up to ledger entry %d, found %d", c.ID, c.EntryCount, e.ID, count)
+	case s.key != nil && !s.validSignature(c):
+		link.Reason = models.LedgerBreakSignature
+		link.Expected, link.Actual = s.keyID, c.KeyID
+		link.Message = fmt.Sprintf("checkpoint %d is not signed by the configured key", c.ID)
+	default:
+		return nil
+	}
+	return link
+}
+
+// validSignature ตรวจ signature ด้วย key ที่ตั้งไว้ ไม่ใช้ public key ที่เก็บใน database เพราะถูกแก้ได้
+func (s *LedgerAuditService) validSignature(c *models.LedgerCheckpoint) bool {
+	signature, err := base64.StdEncoding.DecodeString(c.Signature)
+	if err != nil {
+		return false
+	}
+	return ed25519.Verify(s.key.Public().(ed25519.PublicKey), c.SigningPayload(), signature)
+}
+
+// CreateCheckpoint ตรวจ chain ทั้งหมดแล้ว sign hash ของ entry ล่าสุด
+// คืน created = false พร้อม checkpoint เดิมถ้าไม่มี entry ใหม่ตั้งแต่ checkpoint ล่าสุด
+// และคืน nil ถ้ายังไม่มี entry เลย
+func (s *LedgerAuditService) CreateCheckpoint() (*models.LedgerCheckpoint, bool, error) {
+	if s.key == nil {
+		return nil, false, ErrLedgerSigningKeyMissing
+	}
+
+	v, err := s.Verify()
+	if err != nil {
+		return nil, false, err
+	}
+	if !v.Valid {
+		return nil, false, fmt.Errorf("%w: %s", ErrLedgerChainInvalid, v.FirstBrokenLink.Message)
+	}
+	if v.EntriesChecked == 0 {
+		return nil, false, nil
+	}
+
+	existing, err := s.repo.GetCheckpointByEntry(v.LastEntryID)
+	if err != nil || existing != nil {
+		return existing, false, err
+	}
+
+	checkpoint := &models.LedgerCheckpoint{
+		LedgerEntryID: v.LastEntryID,
+		EntryCount:    v.EntriesChecked,
+		Hash:          v.LastHash,
+		KeyID:         s.keyID,
+		PublicKey:     base64.StdEncoding.EncodeToString(s.key.Public().(ed25519.PublicKey)),
+		CreatedAt:     s.clock.Now(),
+	}
+	checkpoint.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(s.key, checkpoint.SigningPayload()))
+
+	created, err := s.repo.CreateCheckpoint(checkpoint)
+	if err != nil {
+		return nil, false, err
+	}
+	if !created {
+		// อีก request สร้าง checkpoint ของ entry เดียวกันไปก่อน
+		existing, err := s.repo.GetCheckpointByEntry(v.LastEntryID)
+		return existing, false, err
+	}
+
+	log.Printf("Ledger checkpoint %d: entry %d hash %s signature %s",
+		checkpoint.ID, checkpoint.LedgerEntryID, checkpoint.Hash, checkpoint.Signature)
+	s.webhooks.Publish(models.WebhookEventLedgerCheckpoint, checkpoint)
+	return checkpoint, true, nil
+}
+
+// GetCheckpoints ดึง checkpoint ล่าสุดก่อน
+func (s *LedgerAuditService) GetCheckpoints(limit int) (*models.LedgerCheckpointListResponse, error) {
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	checkpoints, err := s.repo.ListCheckpoints(limit)
+	if err != nil {
+		return nil, err
+	}
+	if checkpoints == nil {
+		checkpoints = []models.LedgerCheckpoint{}
+	}
+	return &models.LedgerCheckpointListResponse{Data: checkpoints}, nil
+}
+
+// StartCheckpointer สร้าง checkpoint เป็นระยะจนกว่า stop จะถูกปิด (ข้ามถ้าไม่มี entry ใหม่)
+func (s *LedgerAuditService) StartCheckpointer(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if _, _, err := s.CreateCheckpoint(); err != nil {
+					log.Printf("Ledger checkpoint failed: %v", err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,122 @@
+package services
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// ErrInvalidLedgerQuery ครอบ error ของเงื่อนไขค้น ledger ที่ไม่ถูกต้อง
+var ErrInvalidLedgerQuery = errors.New("invalid ledger query")
+
+// LedgerService อ่านประวัติการเปลี่ยนแต้มของ user จาก point_ledger
+type LedgerService struct {
+	ledgerRepo *repositories.LedgerRepository
+	users      *UserService
+}
+
+func NewLedgerService(ledgerRepo *repositories.LedgerRepository, users *UserService) *LedgerService {
+	return &LedgerService{
+		ledgerRepo: ledgerRepo,
+		users:      users,
+	}
+}
+
+// GetLedger ดึง ledger entry ของ user ทีละหน้าแบบ keyset พร้อมยอดต้นงวดและปลายงวดของช่วงที่เลือก
+func (s *LedgerService) GetLedger(q models.LedgerQuery) (*models.LedgerListResponse, error) {
+	if q.UserID <= 0 {
+		return nil, invalidLedgerQuery("invalid user ID")
+	}
+	for _, eventType := range q.EventTypes {
+		if !isEventType(eventType) {
+			return nil, invalidLedgerQuery("unknown eventType %q", eventType)
+		}
+	}
+	if q.Sort == "" {
+		q.Sort = models.LedgerSortNewest
+	}
+	if q.Sort != models.LedgerSortNewest && q.Sort != models.LedgerSortOldest {
+		return nil, invalidLedgerQuery("unknown sort %q", q.Sort)
+	}
+	if q.CreatedFrom != nil && q.CreatedTo != nil && !q.CreatedFrom.Before(*q.CreatedTo) {
+		return nil, invalidLedgerQuery("createdFrom must be before createdTo")
+	}
+	if q.PageSize < 1 || q.PageSize > 200 {
+		q.PageSize = 50
+	}
+
+	afterID := 0
+	if q.Cursor != "" {
+		cursor, err := decodeLedgerCursor(q.Cursor)
+		if err != nil {
+			return nil, invalidLedgerQuery("invalid cursor")
+		}
+		if cursor.Sort != q.Sort {
+			return nil, invalidLedgerQuery("cursor was issued for a different sort")
+		}
+		afterID = cursor.ID
+	}
+
+	user, err := s.users.GetUserByID(q.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, errors.New("user not found")
+	}
+
+	// ดึงเกินมาหนึ่งรายการเพื่อรู้ว่ามีหน้าถัดไปหรือไม่
+	entries, err := s.ledgerRepo.Search(q, afterID, q.PageSize+1)
+	if err != nil {
+		return nil, err
+	}
+	summary, err := s.ledgerRepo.Summary(q.UserID, q.CreatedFrom, q.CreatedTo)
+	if err != nil {
+		return nil, err
+	}
+
+	response := &models.LedgerListResponse{Summary: *summary, PageSize: q.PageSize}
+	if len(entries) > q.PageSize {
+		entries = entries[:q.PageSize]
+		response.NextCursor = encodeLedgerCursor(models.LedgerCursor{Sort: q.Sort, ID: entries[len(entries)-1].ID})
+	}
+	if entries == nil {
+		entries = []models.PointLedger{}
+	}
+	response.Data = entries
+	return response, nil
+}
+
+func isEventType(eventType models.EventType) bool {
+	switch eventType {
+	case models.EventTypeTransferOut, models.EventTypeTransferIn, models.EventTypeAdjust,
+		models.EventTypeEarn, models.EventTypeRedeem:
+		return true
+	}
+	return false
+}
+
+func invalidLedgerQuery(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidLedgerQuery, fmt.Sprintf(format, args...))
+}
+
+func encodeLedgerCursor(cursor models.LedgerCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeLedgerCursor(s string) (*models.LedgerCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var cursor models.LedgerCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID <= 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,286 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// Limit ที่ถูกใช้ใน LimitExceededError.Limit
+const (
+	LimitPerTransaction = "per_transaction"
+	LimitDailyAmount    = "daily_amount"
+	LimitDailyCount     = "daily_count"
+	LimitMonthlyAmount  = "monthly_amount"
+	LimitMonthlyCount   = "monthly_count"
+)
+
+// LimitExceededError บอกว่าการโอนชน limit ไหน และยังเหลือโอนได้อีกเท่าไร
+// Max, Used และ Remaining เป็นจำนวนแต้มหรือจำนวนครั้งตามชนิดของ limit จึงเก็บเป็นตัวเลขทั่วไป
+type LimitExceededError struct {
+	Limit     string  `json:"limit"`
+	Max       float64 `json:"max"`
+	Used      float64 `json:"used"`
+	Remaining float64 `json:"remaining"`
+}
+
+func (e *LimitExceededError) Error() string {
+	return fmt.Sprintf("transfer limit exceeded: %s (max %g, used %g, remaining %g)",
+		e.Limit, e.Max, e.Used, e.Remaining)
+}
+
+type LimitService struct {
+	limitRepo *repositories.LimitRepository
+	clock     Clock
+}
+
+func NewLimitService(limitRepo *repositories.LimitRepository) *LimitService {
+	return &LimitService{
+		limitRepo: limitRepo,
+		clock:     SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้คำนวณรอบวันและรอบเดือน
+func (s *LimitService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+func (s *LimitService) GetLimits() (*models.TransferLimitListResponse, error) {
+	limits, err := s.limitRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if limits == nil {
+		limits = []models.TransferLimit{}
+	}
+	return &models.TransferLimitListResponse{Data: limits}, nil
+}
+
+// UpdateLimit แก้ limit ของ membership level (nil ถ้าไม่มี level นี้)
+func (s *LimitService) UpdateLimit(level string, req models.TransferLimitUpdateRequest) (*models.TransferLimit, error) {
+	limit, err := s.limitRepo.GetByLevel(level)
+	if err != nil || limit == nil {
+		return nil, err
+	}
+
+	if req.PerTransaction != nil {
+		limit.PerTransaction = *req.PerTransaction
+	}
+	if req.DailyAmount != nil {
+		limit.DailyAmount = *req.DailyAmount
+	}
+	if req.DailyCount != nil {
+		limit.DailyCount = *req.DailyCount
+	}
+	if req.MonthlyAmount != nil {
+		limit.MonthlyAmount = *req.MonthlyAmount
+	}
+	if req.MonthlyCount != nil {
+		limit.MonthlyCount = *req.MonthlyCount
+	}
+
+	if err := validateLimit(limit); err != nil {
+		return nil, err
+	}
+
+	if err := s.limitRepo.Update(limit); err != nil {
+		return nil, err
+	}
+	return limit, nil
+}
+
+func validateLimit(limit *models.TransferLimit) error {
+	if limit.PerTransaction <= 0 || limit.DailyAmount <= 0 || limit.MonthlyAmount <= 0 {
+		return errors.New("limit amounts must be greater than 0")
+	}
+	if limit.DailyCount < 1 || limit.MonthlyCount < 1 {
+		return errors.New("limit counts must be at least 1")
+	}
+	if limit.PerTransaction > limit.DailyAmount {
+		return errors.New("perTransaction cannot exceed dailyAmount")
+	}
+	if limit.DailyAmount > limit.MonthlyAmount || limit.DailyCount > limit.MonthlyCount {
+		return errors.New("daily limits cannot exceed monthly limits")
+	}
+	return nil
+}
+
+// GetUserLimits ดึง limit ของ tier ของ user พร้อมยอดที่ใช้ไปในวันนี้และเดือนนี้ (nil ถ้าไม่พบ user)
+func (s *LimitService) GetUserLimits(userID int) (*models.UserLimitsResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+
+	limit, err := s.limitRepo.GetForUser(userID)
+	if err != nil || limit == nil {
+		return nil, err
+	}
+
+	daily, monthly, err := s.usage(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.UserLimitsResponse{
+		UserID:          userID,
+		MembershipLevel: limit.MembershipLevel,
+		Limits:          *limit,
+		Daily:           *daily,
+		Monthly:         *monthly,
+	}, nil
+}
+
+// CheckPerTransaction ตรวจเฉพาะเพดานต่อครั้งของผู้โอน ใช้กับรายการที่จะโอนในอนาคต
+// (transfer ล่วงหน้า, mandate, คำขอแต้ม) ส่วนยอดรายวัน/รายเดือนตรวจตอนโอนจริง
+func (s *LimitService) CheckPerTransaction(userID int, amount models.Points) error {
+	limit, err := s.limitRepo.GetForUser(userID)
+	if err != nil {
+		return fmt.Errorf("failed to load transfer limits: %w", err)
+	}
+	if limit == nil {
+		return errors.New("from user not found")
+	}
+	if amount > limit.PerTransaction {
+		return perTransactionExceeded(limit.PerTransaction)
+	}
+	return nil
+}
+
+// CheckTransfer ตรวจว่าการโอนจำนวนนี้ยังอยู่ในทุก limit ของผู้โอน
+func (s *LimitService) CheckTransfer(userID int, amount models.Points) error {
+	errs, err := s.CheckTransfers(userID, []models.Points{amount})
+	if err != nil {
+		return err
+	}
+	return errs[0]
+}
+
+// CheckTransfers ตรวจหลายรายการของผู้โอนคนเดียวตามลำดับ โดยนับรายการก่อนหน้าที่ผ่านแล้วเป็นยอดที่ใช้ไป
+// คืน error ของแต่ละรายการ (nil ถ้าผ่าน)
+func (s *LimitService) CheckTransfers(userID int, amounts []models.Points) ([]error, error) {
+	limit, err := s.limitRepo.GetForUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transfer limits: %w", err)
+	}
+	if limit == nil {
+		return nil, errors.New("from user not found")
+	}
+
+	daily, monthly, err := s.usage(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	errs := make([]error, len(amounts))
+	for i, amount := range amounts {
+		if err := checkLimit(limit, daily, monthly, amount); err != nil {
+			errs[i] = err
+			continue
+		}
+		daily.UsedAmount += amount
+		daily.UsedCount++
+		monthly.UsedAmount += amount
+		monthly.UsedCount++
+	}
+	return errs, nil
+}
+
+// TransferViolations คืนทุก limit ที่การโอนจำนวนนี้จะเกิน (ไม่หยุดที่ limit แรก ใช้กับ quote)
+func (s *LimitService) TransferViolations(userID int, amount models.Points) ([]*LimitExceededError, error) {
+	limit, err := s.limitRepo.GetForUser(userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load transfer limits: %w", err)
+	}
+	if limit == nil {
+		return nil, errors.New("from user not found")
+	}
+
+	daily, monthly, err := s.usage(userID, limit)
+	if err != nil {
+		return nil, err
+	}
+	return limitViolations(limit, daily, monthly, amount), nil
+}
+
+// checkLimit คืน limit แรกที่เกินตามลำดับของ limitViolations (nil ถ้าผ่าน)
+func checkLimit(limit *models.TransferLimit, daily, monthly *models.LimitUsage, amount models.Points) error {
+	if violations := limitViolations(limit, daily, monthly, amount); len(violations) > 0 {
+		return violations[0]
+	}
+	return nil
+}
+
+func limitViolations(limit *models.TransferLimit, daily, monthly *models.LimitUsage, amount models.Points) []*LimitExceededError {
+	var violations []*LimitExceededError
+	if amount > limit.PerTransaction {
+		violations = append(violations, perTransactionExceeded(limit.PerTransaction))
+	}
+	if daily.UsedCount+1 > limit.DailyCount {
+		violations = append(violations, countExceeded(LimitDailyCount, limit.DailyCount, daily.UsedCount))
+	}
+	if monthly.UsedCount+1 > limit.MonthlyCount {
+		violations = append(violations, countExceeded(LimitMonthlyCount, limit.MonthlyCount, monthly.UsedCount))
+	}
+	if daily.UsedAmount+amount > limit.DailyAmount {
+		violations = append(violations, amountExceeded(LimitDailyAmount, limit.DailyAmount, daily.UsedAmount))
+	}
+	if monthly.UsedAmount+amount > limit.MonthlyAmount {
+		violations = append(violations, amountExceeded(LimitMonthlyAmount, limit.MonthlyAmount, monthly.UsedAmount))
+	}
+	return violations
+}
+
+func perTransactionExceeded(max models.Points) *LimitExceededError {
+	return &LimitExceededError{Limit: LimitPerTransaction, Max: max.Float64(), Remaining: max.Float64()}
+}
+
+func amountExceeded(name string, max, used models.Points) *LimitExceededError {
+	return &LimitExceededError{Limit: name, Max: max.Float64(), Used: used.Float64(), Remaining: remainingPoints(max, used).Float64()}
+}
+
+func countExceeded(name string, max, used int) *LimitExceededError {
+	return &LimitExceededError{Limit: name, Max: float64(max), Used: float64(used), Remaining: float64(max - used)}
+}
+
+// usage คำนวณยอดที่ใช้ไปตั้งแต่ต้นวันและต้นเดือน (ตามเวลาของ server)
+func (s *LimitService) usage(userID int, limit *models.TransferLimit) (*models.LimitUsage, *models.LimitUsage, error) {
+	now := s.clock.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	daily := models.LimitUsage{Since: startOfDay}
+	var err error
+	daily.UsedAmount, daily.UsedCount, err = s.limitRepo.SentSince(userID, startOfDay)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load transfer usage: %w", err)
+	}
+	monthly := models.LimitUsage{Since: startOfMonth}
+	monthly.UsedAmount, monthly.UsedCount, err = s.limitRepo.SentSince(userID, startOfMonth)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load transfer usage: %w", err)
+	}
+
+	fillRemaining(&daily, limit.DailyAmount, limit.DailyCount)
+	fillRemaining(&monthly, limit.MonthlyAmount, limit.MonthlyCount)
+	return &daily, &monthly, nil
+}
+
+func fillRemaining(usage *models.LimitUsage, maxAmount models.Points, maxCount int) {
+	usage.RemainingAmount = remainingPoints(maxAmount, usage.UsedAmount)
+	usage.RemainingCount = maxCount - usage.UsedCount
+	if usage.RemainingCount < 0 {
+		usage.RemainingCount = 0
+	}
+}
+
+// remainingPoints คือยอดที่ยังโอนได้ (ไม่ติดลบ)
+func remainingPoints(max, used models.Points) models.Points {
+	if used >= max {
+		return 0
+	}
+	return max - used
+}
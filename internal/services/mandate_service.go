@@ -0,0 +1,386 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// MandateMaxConsecutiveFailures คือจำนวนครั้งที่โอนไม่สำเร็จติดกันก่อน mandate จะถูก pause อัตโนมัติ
+const MandateMaxConsecutiveFailures = 3
+
+// MinMandateInterval คือระยะห่างขั้นต่ำระหว่างรอบของ mandate แบบ interval
+const MinMandateInterval = time.Hour
+
+var ErrMandateNotFound = errors.New("mandate not found")
+
+type MandateService struct {
+	mandateRepo     *repositories.MandateRepository
+	transferService *TransferService
+	clock           Clock
+	location        *time.Location
+}
+
+func NewMandateService(mandateRepo *repositories.MandateRepository, transferService *TransferService) *MandateService {
+	return &MandateService{
+		mandateRepo:     mandateRepo,
+		transferService: transferService,
+		clock:           SystemClock{},
+		location:        time.Local,
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้ตัดสินเวลา
+func (s *MandateService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetLocation กำหนด time zone ที่ใช้ตีความ cron expression
+func (s *MandateService) SetLocation(loc *time.Location) {
+	s.location = loc
+}
+
+func (s *MandateService) CreateMandate(userID int, req models.MandateCreateRequest) (*models.Mandate, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+
+	// ใช้ transfer rule เดียวกับ TransferService (ด้วย source "mandate")
+	if err := s.transferService.checkRules(models.TransferCreateRequest{
+		FromUserID: userID,
+		ToUserID:   req.ToUserID,
+		Amount:     req.Amount,
+		Note:       req.Note,
+	}, RuleSourceMandate); err != nil {
+		return nil, err
+	}
+	if err := s.transferService.limits.CheckPerTransaction(userID, req.Amount); err != nil {
+		return nil, err
+	}
+	if req.Note != nil && len(*req.Note) > 512 {
+		return nil, errors.New("note cannot exceed 512 characters")
+	}
+
+	if (req.Cron == nil) == (req.IntervalSeconds == nil) {
+		return nil, errors.New("exactly one of cron or intervalSeconds is required")
+	}
+	if req.Cron != nil {
+		cron := strings.TrimSpace(*req.Cron)
+		if _, err := ParseCron(cron); err != nil {
+			return nil, err
+		}
+		req.Cron = &cron
+	}
+	if req.IntervalSeconds != nil && time.Duration(*req.IntervalSeconds)*time.Second < MinMandateInterval {
+		return nil, fmt.Errorf("intervalSeconds must be at least %d", int(MinMandateInterval.Seconds()))
+	}
+	if req.MaxOccurrences != nil && *req.MaxOccurrences < 1 {
+		return nil, errors.New("maxOccurrences must be at least 1")
+	}
+
+	now := s.clock.Now()
+	startAt := now
+	if req.StartAt != nil {
+		startAt = *req.StartAt
+	}
+	if req.EndAt != nil && !req.EndAt.After(startAt) {
+		return nil, errors.New("endAt must be after startAt")
+	}
+
+	mandate := models.Mandate{
+		FromUserID:      userID,
+		ToUserID:        req.ToUserID,
+		Amount:          req.Amount,
+		Note:            req.Note,
+		Cron:            req.Cron,
+		IntervalSeconds: req.IntervalSeconds,
+		StartAt:         startAt,
+		EndAt:           req.EndAt,
+		MaxOccurrences:  req.MaxOccurrences,
+		Status:          models.MandateStatusActive,
+	}
+
+	next, err := s.firstRun(mandate, now)
+	if err != nil {
+		return nil, err
+	}
+	if next == nil {
+		return nil, errors.New("schedule has no run before endAt")
+	}
+	mandate.NextRunAt = next
+
+	return s.mandateRepo.Create(mandate)
+}
+
+func (s *MandateService) GetMandates(userID int) (*models.MandateListResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+
+	mandates, err := s.mandateRepo.GetByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mandates == nil {
+		mandates = []models.Mandate{}
+	}
+
+	return &models.MandateListResponse{Data: mandates}, nil
+}
+
+// GetMandate ดึง mandate ของ user (ไม่พบถ้าเป็นของ user อื่น)
+func (s *MandateService) GetMandate(userID, mandateID int) (*models.Mandate, error) {
+	mandate, err := s.mandateRepo.GetByID(mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if mandate == nil || mandate.FromUserID != userID {
+		return nil, ErrMandateNotFound
+	}
+	return mandate, nil
+}
+
+func (s *MandateService) UpdateMandate(userID, mandateID int, req models.MandateUpdateRequest) (*models.Mandate, error) {
+	mandate, err := s.GetMandate(userID, mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if mandate.Status == models.MandateStatusCancelled || mandate.Status == models.MandateStatusCompleted {
+		return nil, fmt.Errorf("mandate is %s and cannot be changed", mandate.Status)
+	}
+
+	if req.Amount != nil {
+		if err := s.transferService.checkRules(models.TransferCreateRequest{
+			FromUserID: mandate.FromUserID,
+			ToUserID:   mandate.ToUserID,
+			Amount:     *req.Amount,
+		}, RuleSourceMandate); err != nil {
+			return nil, err
+		}
+		if err := s.transferService.limits.CheckPerTransaction(mandate.FromUserID, *req.Amount); err != nil {
+			return nil, err
+		}
+		mandate.Amount = *req.Amount
+	}
+	if req.Note != nil {
+		if len(*req.Note) > 512 {
+			return nil, errors.New("note cannot exceed 512 characters")
+		}
+		mandate.Note = req.Note
+	}
+	if req.EndAt != nil {
+		if !req.EndAt.After(mandate.StartAt) {
+			return nil, errors.New("endAt must be after startAt")
+		}
+		mandate.EndAt = req.EndAt
+	}
+	if req.MaxOccurrences != nil {
+		if *req.MaxOccurrences < 1 {
+			return nil, errors.New("maxOccurrences must be at least 1")
+		}
+		mandate.MaxOccurrences = req.MaxOccurrences
+	}
+	if req.Status != nil {
+		switch *req.Status {
+		case models.MandateStatusActive:
+			// resume: เริ่มนับความล้มเหลวใหม่และคำนวณรอบถัดไปจากตอนนี้
+			if mandate.Status == models.MandateStatusPaused {
+				mandate.ConsecutiveFailures = 0
+				next, err := s.firstRun(*mandate, s.clock.Now())
+				if err != nil {
+					return nil, err
+				}
+				mandate.NextRunAt = next
+			}
+		case models.MandateStatusPaused:
+		default:
+			return nil, errors.New("status must be active or paused")
+		}
+		mandate.Status = *req.Status
+	}
+
+	if s.finished(*mandate) {
+		mandate.Status = models.MandateStatusCompleted
+		mandate.NextRunAt = nil
+	}
+
+	return s.mandateRepo.Update(*mandate)
+}
+
+// CancelMandate หยุด mandate ถาวร transfer ที่สร้างไปแล้วยังอยู่เหมือนเดิม
+func (s *MandateService) CancelMandate(userID, mandateID int) (*models.Mandate, error) {
+	mandate, err := s.GetMandate(userID, mandateID)
+	if err != nil {
+		return nil, err
+	}
+	if mandate.Status == models.MandateStatusCancelled {
+		return mandate, nil
+	}
+
+	mandate.Status = models.MandateStatusCancelled
+	mandate.NextRunAt = nil
+	return s.mandateRepo.Update(*mandate)
+}
+
+// RunDueMandates สร้าง transfer ให้ mandate ที่ถึงรอบแล้ว คืนจำนวนรอบที่โอนสำเร็จ
+func (s *MandateService) RunDueMandates(now time.Time) (int, error) {
+	due, err := s.mandateRepo.ListDue(now, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	succeeded := 0
+	for _, mandate := range due {
+		ok, err := s.runMandate(mandate, now)
+		if err != nil {
+			return succeeded, err
+		}
+		if ok {
+			succeeded++
+		}
+	}
+
+	return succeeded, nil
+}
+
+func (s *MandateService) runMandate(mandate models.Mandate, now time.Time) (bool, error) {
+	runAt := *mandate.NextRunAt
+	mandateID := mandate.ID
+
+	// โอนผ่าน TransferService เพื่อให้ผ่านกฎเดียวกับการโอนปกติ
+	// idempotency key ผูกกับรอบ จึงรันซ้ำได้โดยไม่โอนซ้ำ
+	_, transferErr := s.transferService.CreateTransfer(models.TransferCreateRequest{
+		FromUserID: mandate.FromUserID,
+		ToUserID:   mandate.ToUserID,
+		Amount:     mandate.Amount,
+		Note:       mandate.Note,
+		IdemKey:    repositories.MandateIdemKey(mandateID, runAt),
+		MandateID:  &mandateID,
+	})
+
+	if transferErr != nil {
+		reason := transferErr.Error()
+		mandate.LastError = &reason
+		mandate.ConsecutiveFailures++
+		if mandate.ConsecutiveFailures >= MandateMaxConsecutiveFailures {
+			mandate.Status = models.MandateStatusPaused
+			log.Printf("Mandate %d paused after %d consecutive failures: %s", mandateID, mandate.ConsecutiveFailures, reason)
+		}
+	} else {
+		mandate.LastError = nil
+		mandate.ConsecutiveFailures = 0
+		mandate.Occurrences++
+	}
+
+	next, err := s.nextRun(mandate, runAt, now)
+	if err != nil {
+		return false, err
+	}
+	mandate.NextRunAt = next
+	if s.finished(mandate) {
+		mandate.Status = models.MandateStatusCompleted
+		mandate.NextRunAt = nil
+	}
+
+	if _, err := s.mandateRepo.RecordRun(mandate, runAt, now); err != nil {
+		return false, err
+	}
+
+	return transferErr == nil, nil
+}
+
+// firstRun หารอบแรกที่ไม่เร็วกว่า start_at และไม่ก่อน now
+func (s *MandateService) firstRun(mandate models.Mandate, now time.Time) (*time.Time, error) {
+	from := mandate.StartAt
+	if from.Before(now) {
+		from = now
+	}
+
+	if mandate.IntervalSeconds != nil {
+		// รอบของ interval นับจาก start_at เสมอ
+		interval := time.Duration(*mandate.IntervalSeconds) * time.Second
+		next := mandate.StartAt
+		if next.Before(from) {
+			steps := (from.Sub(next) + interval - 1) / interval
+			next = next.Add(steps * interval)
+		}
+		return s.withinEnd(mandate, next), nil
+	}
+
+	schedule, err := ParseCron(*mandate.Cron)
+	if err != nil {
+		return nil, err
+	}
+	next := schedule.Next(from.In(s.location).Add(-time.Minute))
+	if next.IsZero() {
+		return nil, nil
+	}
+	return s.withinEnd(mandate, next), nil
+}
+
+// nextRun หารอบถัดไปหลังรอบที่เพิ่งรัน ข้ามรอบที่เลยมาแล้ว (เช่น server ปิดไปนาน)
+func (s *MandateService) nextRun(mandate models.Mandate, lastRun, now time.Time) (*time.Time, error) {
+	if mandate.IntervalSeconds != nil {
+		interval := time.Duration(*mandate.IntervalSeconds) * time.Second
+		next := lastRun.Add(interval)
+		for !next.After(now) {
+			next = next.Add(interval)
+		}
+		return s.withinEnd(mandate, next), nil
+	}
+
+	schedule, err := ParseCron(*mandate.Cron)
+	if err != nil {
+		return nil, err
+	}
+	from := lastRun
+	if now.After(from) {
+		from = now
+	}
+	next := schedule.Next(from.In(s.location))
+	if next.IsZero() {
+		return nil, nil
+	}
+	return s.withinEnd(mandate, next), nil
+}
+
+func (s *MandateService) withinEnd(mandate models.Mandate, next time.Time) *time.Time {
+	if mandate.EndAt != nil && next.After(*mandate.EndAt) {
+		return nil
+	}
+	return &next
+}
+
+// finished บอกว่า mandate ครบจำนวนครั้งหรือไม่มีรอบถัดไปแล้ว
+func (s *MandateService) finished(mandate models.Mandate) bool {
+	if mandate.MaxOccurrences != nil && mandate.Occurrences >= *mandate.MaxOccurrences {
+		return true
+	}
+	return mandate.Status == models.MandateStatusActive && mandate.NextRunAt == nil
+}
+
+// StartRunner รัน RunDueMandates เป็นระยะใน background จนกว่า stop จะถูกปิด
+func (s *MandateService) StartRunner(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := s.RunDueMandates(s.clock.Now()); err != nil {
+					log.Printf("Failed to run mandates: %v", err)
+				} else if n > 0 {
+					log.Printf("Ran %d mandate transfers", n)
+				}
+			}
+		}
+	}()
+}
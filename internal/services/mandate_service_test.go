@@ -0,0 +1,83 @@
+package services
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/testutil"
+)
+
+// TestMandateRunsAreScreenedWhenNew ตรวจว่ารอบแรกของ mandate และรอบที่จำนวนเปลี่ยนถูกตรวจ fraud
+// ส่วนรอบที่ซ้ำกับรอบล่าสุดที่สำเร็จไม่ถูกตรวจ (sender velocity = 1 จึงถูก block ทุกครั้งที่ตรวจหลังโอนไปแล้ว)
+func TestMandateRunsAreScreenedWhenNew(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUser(t, db, 20*models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	other := testutil.NewUser(t, db, 0)
+	if _, err := db.Exec("UPDATE fraud_settings SET window_seconds = 86400, sender_velocity = 1, review_score = 30, block_score = 30"); err != nil {
+		t.Fatal(err)
+	}
+
+	transfers := newTestTransferService(t, db)
+	transfers.SetFraud(NewFraudService(repositories.NewFraudRepository(db.DB)))
+	service := NewMandateService(repositories.NewMandateRepository(db.DB), transfers)
+	clock := NewFixedClock(time.Now())
+	service.SetClock(clock)
+
+	interval := int(time.Hour / time.Second)
+	create := func(toUserID int) *models.Mandate {
+		t.Helper()
+		mandate, err := service.CreateMandate(sender.ID, models.MandateCreateRequest{
+			ToUserID: toUserID, Amount: models.PointsScale, IntervalSeconds: &interval,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mandate
+	}
+	run := func(mandateID int) *models.Mandate {
+		t.Helper()
+		if _, err := service.RunDueMandates(clock.Now()); err != nil {
+			t.Fatal(err)
+		}
+		mandate, err := service.GetMandate(sender.ID, mandateID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return mandate
+	}
+	expectBlocked := func(mandate *models.Mandate) {
+		t.Helper()
+		if mandate.LastError == nil || !strings.Contains(*mandate.LastError, "fraud") {
+			t.Fatalf("run was not blocked by fraud checks: last error %v", mandate.LastError)
+		}
+	}
+
+	mandate := create(receiver.ID)
+	if m := run(mandate.ID); m.Occurrences != 1 || m.LastError != nil {
+		t.Fatalf("first run: %d occurrences, last error %v", m.Occurrences, m.LastError)
+	}
+
+	// รอบเดิมซ้ำ: ไม่ถูกตรวจ แม้ sender จะโอนเกิน velocity แล้ว
+	clock.Advance(time.Hour)
+	if m := run(mandate.ID); m.Occurrences != 2 || m.LastError != nil {
+		t.Fatalf("repeated run: %d occurrences, last error %v", m.Occurrences, m.LastError)
+	}
+
+	// จำนวนเปลี่ยน: ตรวจใหม่
+	amount := 2 * models.PointsScale
+	if _, err := service.UpdateMandate(sender.ID, mandate.ID, models.MandateUpdateRequest{Amount: &amount}); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	expectBlocked(run(mandate.ID))
+	if _, err := service.CancelMandate(sender.ID, mandate.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	// mandate ใหม่ให้ผู้รับอื่น: รอบแรกถูกตรวจ
+	expectBlocked(run(create(other.ID).ID))
+}
@@ -0,0 +1,218 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// DefaultPointRequestTTL คืออายุของคำขอแต้มที่ยังไม่มีคนตอบ ถ้าไม่ได้ระบุ expiresInHours
+const DefaultPointRequestTTL = 72 * time.Hour
+
+var (
+	ErrPointRequestNotFound = errors.New("point request not found")
+	ErrPointRequestClosed   = errors.New("point request is no longer pending")
+	ErrPointRequestExpired  = errors.New("point request has expired")
+)
+
+type PointRequestService struct {
+	requestRepo     *repositories.PointRequestRepository
+	transferService *TransferService
+	clock           Clock
+}
+
+func NewPointRequestService(requestRepo *repositories.PointRequestRepository, transferService *TransferService) *PointRequestService {
+	return &PointRequestService{
+		requestRepo:     requestRepo,
+		transferService: transferService,
+		clock:           SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้ตัดสินเวลา
+func (s *PointRequestService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+func (s *PointRequestService) CreateRequest(req models.PointRequestCreateRequest) (*models.PointRequest, error) {
+	if req.PayerID == req.RequesterID {
+		return nil, errors.New("cannot request points from yourself")
+	}
+	// คำขอจะกลายเป็น transfer จาก payer ไป requester จึงใช้ transfer rule เดียวกัน (ด้วย source "point_request")
+	if err := s.transferService.checkRules(models.TransferCreateRequest{
+		FromUserID: req.PayerID,
+		ToUserID:   req.RequesterID,
+		Amount:     req.Amount,
+		Note:       req.Note,
+	}, RuleSourcePointRequest); err != nil {
+		return nil, err
+	}
+	// เพดานต่อครั้งของ payer ซึ่งเป็นผู้โอนจริง ส่วนยอดรายวัน/รายเดือนตรวจตอนอนุมัติ
+	if err := s.transferService.limits.CheckPerTransaction(req.PayerID, req.Amount); err != nil {
+		return nil, err
+	}
+	if req.Note != nil && len(*req.Note) > 512 {
+		return nil, errors.New("note cannot exceed 512 characters")
+	}
+
+	ttl := DefaultPointRequestTTL
+	if req.ExpiresInHours != nil {
+		if *req.ExpiresInHours < 1 || *req.ExpiresInHours > 720 {
+			return nil, errors.New("expiresInHours must be between 1 and 720")
+		}
+		ttl = time.Duration(*req.ExpiresInHours) * time.Hour
+	}
+
+	now := s.clock.Now()
+	return s.requestRepo.Create(req, now.Add(ttl), now)
+}
+
+func (s *PointRequestService) GetRequests(userID int, role, status string) (*models.PointRequestListResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	switch role {
+	case "", "incoming", "outgoing", "all":
+	default:
+		return nil, errors.New("role must be incoming, outgoing or all")
+	}
+	switch models.PointRequestStatus(status) {
+	case "", models.PointRequestStatusPending, models.PointRequestStatusApproved,
+		models.PointRequestStatusDeclined, models.PointRequestStatusExpired:
+	default:
+		return nil, fmt.Errorf("unknown status %q", status)
+	}
+
+	// ปิดคำขอที่หมดอายุก่อน เพื่อให้สถานะที่เห็นเป็นปัจจุบัน
+	if _, err := s.requestRepo.ExpirePending(s.clock.Now()); err != nil {
+		return nil, err
+	}
+
+	requests, err := s.requestRepo.GetByUserID(userID, role, status)
+	if err != nil {
+		return nil, err
+	}
+	if requests == nil {
+		requests = []models.PointRequest{}
+	}
+
+	return &models.PointRequestListResponse{Data: requests}, nil
+}
+
+// ApproveRequest ให้ payer อนุมัติคำขอ แล้วโอนแต้มผ่าน TransferService ตามกฎปกติ
+// ถ้าโอนไม่สำเร็จ คำขอจะกลับเป็น pending เพื่อให้ลองใหม่ได้
+func (s *PointRequestService) ApproveRequest(requestID int, req models.PointRequestRespondRequest) (*models.PointRequest, *models.Transfer, error) {
+	pr, err := s.openRequest(requestID, req.PayerID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ok, err := s.requestRepo.TransitionStatus(pr.ID, models.PointRequestStatusPending, models.PointRequestStatusApproved, nil, s.clock.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ok {
+		return nil, nil, ErrPointRequestClosed
+	}
+
+	transfer, err := s.transferService.CreateTransfer(models.TransferCreateRequest{
+		FromUserID: pr.PayerID,
+		ToUserID:   pr.RequesterID,
+		Amount:     pr.Amount,
+		Note:       pr.Note,
+		IdemKey:    fmt.Sprintf("point-request-%d", pr.ID),
+	})
+	if err != nil {
+		if _, revertErr := s.requestRepo.TransitionStatus(pr.ID, models.PointRequestStatusApproved, models.PointRequestStatusPending, nil, s.clock.Now()); revertErr != nil {
+			log.Printf("Failed to revert point request %d to pending: %v", pr.ID, revertErr)
+		}
+		return nil, nil, err
+	}
+
+	if err := s.requestRepo.SetTransfer(pr.ID, transfer.ID, s.clock.Now()); err != nil {
+		return nil, nil, err
+	}
+
+	updated, err := s.requestRepo.GetByID(pr.ID)
+	if err != nil {
+		return nil, nil, err
+	}
+	return updated, transfer, nil
+}
+
+// DeclineRequest ให้ payer ปฏิเสธคำขอ
+func (s *PointRequestService) DeclineRequest(requestID int, req models.PointRequestRespondRequest) (*models.PointRequest, error) {
+	pr, err := s.openRequest(requestID, req.PayerID)
+	if err != nil {
+		return nil, err
+	}
+
+	var reason *string
+	if req.Reason != nil {
+		trimmed := strings.TrimSpace(*req.Reason)
+		if len(trimmed) > 512 {
+			return nil, errors.New("reason cannot exceed 512 characters")
+		}
+		if trimmed != "" {
+			reason = &trimmed
+		}
+	}
+
+	ok, err := s.requestRepo.TransitionStatus(pr.ID, models.PointRequestStatusPending, models.PointRequestStatusDeclined, reason, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrPointRequestClosed
+	}
+
+	return s.requestRepo.GetByID(pr.ID)
+}
+
+// openRequest ดึงคำขอที่ยัง pending ของ payer และปิดคำขอถ้าหมดอายุแล้ว
+func (s *PointRequestService) openRequest(requestID, payerID int) (*models.PointRequest, error) {
+	pr, err := s.requestRepo.GetByID(requestID)
+	if err != nil {
+		return nil, err
+	}
+	// ไม่บอกว่ามีคำขออยู่ถ้า payer ไม่ตรง
+	if pr == nil || pr.PayerID != payerID {
+		return nil, ErrPointRequestNotFound
+	}
+	if pr.Status != models.PointRequestStatusPending {
+		return nil, fmt.Errorf("%w: status is %s", ErrPointRequestClosed, pr.Status)
+	}
+	if !s.clock.Now().Before(pr.ExpiresAt) {
+		if _, err := s.requestRepo.TransitionStatus(pr.ID, models.PointRequestStatusPending, models.PointRequestStatusExpired, nil, s.clock.Now()); err != nil {
+			return nil, err
+		}
+		return nil, ErrPointRequestExpired
+	}
+	return pr, nil
+}
+
+// StartExpirySweeper ปิดคำขอที่หมดอายุเป็นระยะใน background จนกว่า stop จะถูกปิด
+func (s *PointRequestService) StartExpirySweeper(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := s.requestRepo.ExpirePending(s.clock.Now()); err != nil {
+					log.Printf("Failed to expire point requests: %v", err)
+				} else if n > 0 {
+					log.Printf("Expired %d point requests", n)
+				}
+			}
+		}
+	}()
+}
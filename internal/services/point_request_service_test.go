@@ -0,0 +1,60 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/testutil"
+)
+
+// TestPointRequestTimestampsFollowClock ตรวจว่า created_at, responded_at และ updated_at มาจากนาฬิกาเดียวกับที่ตัดสินการหมดอายุ
+func TestPointRequestTimestampsFollowClock(t *testing.T) {
+	db := testutil.NewDB(t)
+	requester := testutil.NewUser(t, db, 0)
+	payer := testutil.NewUser(t, db, 10*models.PointsScale)
+	service := NewPointRequestService(repositories.NewPointRequestRepository(db.DB), newTestTransferService(t, db))
+	clock := NewFixedClock(time.Date(2024, 3, 1, 9, 0, 0, 0, time.FixedZone("ICT", 7*60*60)))
+	service.SetClock(clock)
+
+	create := func() *models.PointRequest {
+		t.Helper()
+		pr, err := service.CreateRequest(models.PointRequestCreateRequest{
+			RequesterID: requester.ID, PayerID: payer.ID, Amount: models.PointsScale,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !pr.CreatedAt.Equal(clock.Now()) || !pr.UpdatedAt.Equal(clock.Now()) {
+			t.Fatalf("request created at %v, updated at %v, want %v", pr.CreatedAt, pr.UpdatedAt, clock.Now())
+		}
+		return pr
+	}
+
+	declined := create()
+	expired := create()
+
+	clock.Advance(time.Hour)
+	pr, err := service.DeclineRequest(declined.ID, models.PointRequestRespondRequest{PayerID: payer.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pr.RespondedAt == nil || !pr.RespondedAt.Equal(clock.Now()) || !pr.UpdatedAt.Equal(clock.Now()) {
+		t.Fatalf("decline responded at %v, updated at %v, want %v", pr.RespondedAt, pr.UpdatedAt, clock.Now())
+	}
+
+	clock.Advance(DefaultPointRequestTTL)
+	if _, err := service.GetRequests(payer.ID, "incoming", ""); err != nil {
+		t.Fatal(err)
+	}
+	var status models.PointRequestStatus
+	var updatedAt time.Time
+	if err := db.QueryRow("SELECT status, updated_at FROM point_requests WHERE id = ?", expired.ID).Scan(&status, &updatedAt); err != nil {
+		t.Fatal(err)
+	}
+	if status != models.PointRequestStatusExpired || !updatedAt.Equal(clock.Now()) {
+		t.Fatalf("expired request has status %s and updated_at %v, want %s at %v",
+			status, updatedAt, models.PointRequestStatusExpired, clock.Now())
+	}
+}
@@ -0,0 +1,155 @@
+package services
+
+import (
+	"errors"
+	"log"
+	"sync"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// ErrReconciliationRunning ถูกคืนเมื่อมีการรัน reconciliation อื่นค้างอยู่
+var ErrReconciliationRunning = errors.New("a reconciliation run is already in progress")
+
+// ReconciliationService ตรวจว่า users.points ตรงกับ point_ledger, balance_after ของ ledger ต่อเนื่อง
+// และทุก transfer มีคู่ ledger entry ที่ตรงกับสถานะ repair mode แก้ได้เฉพาะยอดของ user
+// โดยเขียน adjust entry ให้ ledger ตรงกับ users.points ส่วนปัญหาอื่นต้องให้คนตรวจ
+type ReconciliationService struct {
+	repo     *repositories.ReconciliationRepository
+	webhooks *WebhookService
+	clock    Clock
+	running  sync.Mutex
+}
+
+func NewReconciliationService(repo *repositories.ReconciliationRepository) *ReconciliationService {
+	return &ReconciliationService{
+		repo:  repo,
+		clock: SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้บันทึกเวลาของการรันและ adjust entry
+func (s *ReconciliationService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetWebhooks ตั้ง WebhookService ที่รับ ledger.entry_created ของ adjust entry (ไม่ตั้งก็ได้)
+func (s *ReconciliationService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// Run ตรวจทั้งระบบหนึ่งรอบและบันทึกผล ถ้า repair = true เขียน adjust entry ให้ user ที่ยอดไม่ตรง
+// รันได้ทีละรอบ ถ้ามีรอบอื่นค้างอยู่จะคืน ErrReconciliationRunning
+func (s *ReconciliationService) Run(trigger models.ReconciliationTrigger, repair bool) (*models.ReconciliationReport, error) {
+	if !s.running.TryLock() {
+		return nil, ErrReconciliationRunning
+	}
+	defer s.running.Unlock()
+
+	report := &models.ReconciliationReport{
+		Trigger:       trigger,
+		Repair:        repair,
+		StartedAt:     s.clock.Now(),
+		Discrepancies: []models.ReconciliationDiscrepancy{},
+	}
+
+	users, balances, err := s.repo.BalanceDiscrepancies()
+	if err != nil {
+		return nil, err
+	}
+	entries, breaks, err := s.repo.ChainBreaks()
+	if err != nil {
+		return nil, err
+	}
+	transfers, pairs, err := s.repo.TransferPairMismatches()
+	if err != nil {
+		return nil, err
+	}
+	report.UsersChecked, report.EntriesChecked, report.TransfersChecked = users, entries, transfers
+
+	if repair {
+		for i := range balances {
+			if s.repairBalance(&balances[i]) {
+				report.RepairedCount++
+			}
+		}
+	}
+
+	report.Discrepancies = append(report.Discrepancies, balances...)
+	report.Discrepancies = append(report.Discrepancies, breaks...)
+	report.Discrepancies = append(report.Discrepancies, pairs...)
+	report.DiscrepancyCount = len(report.Discrepancies)
+	report.FinishedAt = s.clock.Now()
+
+	if err := s.repo.SaveReport(report); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// repairBalance เขียน adjust entry ให้ discrepancy ด้านยอดของ user คืน true ถ้าแก้แล้ว
+func (s *ReconciliationService) repairBalance(d *models.ReconciliationDiscrepancy) bool {
+	entry, err := s.repo.RepairBalance(*d.UserID, s.clock.Now())
+	if err != nil {
+		d.RepairError = err.Error()
+		return false
+	}
+	// ยอดตรงกันแล้วระหว่างตรวจกับแก้ (เช่น มีคนแก้ไปก่อน) ถือว่าแก้แล้ว
+	d.Repaired = true
+	if entry != nil {
+		d.AdjustmentEntryID = &entry.ID
+		s.webhooks.Publish(models.WebhookEventLedgerEntryCreated, entry)
+	}
+	return true
+}
+
+// GetReport ดึงผลการรันตาม id
+func (s *ReconciliationService) GetReport(id int) (*models.ReconciliationReport, error) {
+	report, err := s.repo.GetReport(id)
+	if err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, errors.New("reconciliation report not found")
+	}
+	return report, nil
+}
+
+// GetReports ดึงผลการรันล่าสุดก่อน
+func (s *ReconciliationService) GetReports(limit int) (*models.ReconciliationReportListResponse, error) {
+	if limit < 1 || limit > 100 {
+		limit = 20
+	}
+	reports, err := s.repo.ListReports(limit)
+	if err != nil {
+		return nil, err
+	}
+	if reports == nil {
+		reports = []models.ReconciliationReport{}
+	}
+	return &models.ReconciliationReportListResponse{Data: reports}, nil
+}
+
+// StartScheduler ตรวจ (ไม่ repair) เป็นระยะจนกว่า stop จะถูกปิด และ log เมื่อพบความไม่ตรงกัน
+func (s *ReconciliationService) StartScheduler(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				report, err := s.Run(models.ReconciliationTriggerScheduled, false)
+				if err != nil {
+					log.Printf("Reconciliation failed: %v", err)
+				} else if report.DiscrepancyCount > 0 {
+					log.Printf("Reconciliation report %d found %d discrepancies", report.ID, report.DiscrepancyCount)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,183 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/database"
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/testutil"
+)
+
+func newTestReconciliationService(db *database.DB) *ReconciliationService {
+	return NewReconciliationService(repositories.NewReconciliationRepository(db.DB))
+}
+
+// runReconciliation รันหนึ่งรอบและคืน discrepancy แยกตามประเภท
+func runReconciliation(t *testing.T, service *ReconciliationService, repair bool) (*models.ReconciliationReport, map[models.DiscrepancyKind]int) {
+	t.Helper()
+	report, err := service.Run(models.ReconciliationTriggerManual, repair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	kinds := make(map[models.DiscrepancyKind]int)
+	for _, d := range report.Discrepancies {
+		kinds[d.Kind]++
+	}
+	return report, kinds
+}
+
+// assertLedgerChainValid ตรวจว่า hash chain ยังต่อเนื่องหลังเขียน adjust entry
+func assertLedgerChainValid(t *testing.T, db *database.DB) {
+	t.Helper()
+	v, err := NewLedgerAuditService(repositories.NewLedgerAuditRepository(db.DB)).Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !v.Valid {
+		t.Fatalf("ledger hash chain is broken at %+v", v.FirstBrokenLink)
+	}
+}
+
+// TestReconciliationFreshInstallIsClean จำลอง user ที่ seed ด้วย INSERT ตรง (ไม่มี ledger) แบบ main
+// หลัง EnsureOpeningBalances ต้องไม่มี discrepancy และ user ที่สร้างผ่าน repository ก็ต้องไม่มีเช่นกัน
+func TestReconciliationFreshInstallIsClean(t *testing.T) {
+	db := testutil.NewDB(t)
+	now := time.Now()
+	if _, err := db.Exec(`
+		INSERT INTO users (member_id, first_name, last_name, phone, email, membership_date, membership_level, points, created_at, updated_at)
+		VALUES ('LBK900001', 'Sd', 'One', '0900000001', 'seed1@example.com', ?, 'Gold', 1500000, ?, ?),
+		       ('LBK900002', 'Sd', 'Two', '0900000002', 'seed2@example.com', ?, 'Silver', 0, ?, ?)`,
+		now, now, now, now, now, now); err != nil {
+		t.Fatal(err)
+	}
+	testutil.NewUser(t, db, 25*models.PointsScale)
+	testutil.NewUser(t, db, 0)
+
+	service := newTestReconciliationService(db)
+	if _, kinds := runReconciliation(t, service, false); kinds[models.DiscrepancyUnledgeredBalance] != 1 {
+		t.Fatalf("before opening balances got %v, want one unledgered seeded user", kinds)
+	}
+
+	if err := db.EnsureOpeningBalances(); err != nil {
+		t.Fatal(err)
+	}
+	// เรียกซ้ำต้องไม่เขียน entry เพิ่ม
+	if err := db.EnsureOpeningBalances(); err != nil {
+		t.Fatal(err)
+	}
+	var openings int
+	if err := db.QueryRow("SELECT COUNT(*) FROM point_ledger WHERE reference = ?", models.OpeningBalanceReference).Scan(&openings); err != nil {
+		t.Fatal(err)
+	}
+	if openings != 2 {
+		t.Fatalf("%d opening balance entries, want 2 (seeded user and created user with points)", openings)
+	}
+
+	report, kinds := runReconciliation(t, service, false)
+	if report.DiscrepancyCount != 0 {
+		t.Fatalf("fresh install reports %d discrepancies: %v", report.DiscrepancyCount, kinds)
+	}
+	assertLedgerChainValid(t, db)
+}
+
+// TestReconciliationRepairsBalanceSetOutsideLedger: แก้แต้มผ่าน UpdateUser (ไม่มี ledger) แล้วโอนต่อ
+// ทำให้ทั้งยอดไม่ตรงและ chain ขาด repair ต้องยึด users.points และรอบถัดไปต้องสะอาด
+func TestReconciliationRepairsBalanceSetOutsideLedger(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUser(t, db, 10*models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	transfers := newTestTransferService(t, db, "repeat_recipient")
+	users := NewUserService(repositories.NewUserRepository(db.DB))
+	service := newTestReconciliationService(db)
+
+	transfer := func() {
+		t.Helper()
+		if _, err := transfers.CreateTransfer(models.TransferCreateRequest{
+			FromUserID: sender.ID, ToUserID: receiver.ID, Amount: models.PointsScale,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	transfer()
+	drifted := models.Points(50 * models.PointsScale)
+	if _, err := users.UpdateUser(sender.ID, models.UpdateUserRequest{Points: &drifted}); err != nil {
+		t.Fatal(err)
+	}
+	transfer()
+
+	_, kinds := runReconciliation(t, service, false)
+	if kinds[models.DiscrepancyBalanceMismatch] != 1 || kinds[models.DiscrepancyChainBreak] != 1 {
+		t.Fatalf("got %v, want one balance_mismatch and one chain_break", kinds)
+	}
+
+	report, _ := runReconciliation(t, service, true)
+	if report.RepairedCount != 1 {
+		t.Fatalf("repaired %d balances, want 1: %+v", report.RepairedCount, report.Discrepancies)
+	}
+	var adjustment *models.ReconciliationDiscrepancy
+	for i, d := range report.Discrepancies {
+		if d.Kind == models.DiscrepancyBalanceMismatch {
+			adjustment = &report.Discrepancies[i]
+		}
+	}
+	if adjustment == nil || !adjustment.Repaired || adjustment.AdjustmentEntryID == nil {
+		t.Fatalf("balance mismatch was not repaired: %+v", adjustment)
+	}
+
+	want := drifted - models.PointsScale
+	var change, balanceAfter models.Points
+	if err := db.QueryRow("SELECT change, balance_after FROM point_ledger WHERE id = ?", *adjustment.AdjustmentEntryID).
+		Scan(&change, &balanceAfter); err != nil {
+		t.Fatal(err)
+	}
+	if balanceAfter != want || change != drifted-9*models.PointsScale {
+		t.Fatalf("adjust entry has change %s and balance_after %s, want %s and %s",
+			change, balanceAfter, drifted-9*models.PointsScale, want)
+	}
+	if got := testutil.UserPoints(t, db, sender.ID); got != want {
+		t.Fatalf("repair changed users.points to %s, want %s", got, want)
+	}
+
+	report, kinds = runReconciliation(t, service, false)
+	if report.DiscrepancyCount != 0 {
+		t.Fatalf("after repair got %v", kinds)
+	}
+
+	// โอนต่อหลัง repair แล้วยังต้องสะอาด
+	transfer()
+	if report, kinds = runReconciliation(t, service, false); report.DiscrepancyCount != 0 {
+		t.Fatalf("after another transfer got %v", kinds)
+	}
+	assertLedgerChainValid(t, db)
+}
+
+// TestReconciliationRepairsUnledgeredBalance: user ที่มีแต้มแต่ไม่มี entry (เช่น INSERT ตรงหลัง migrate)
+// repair ต้องเขียน entry แรกที่ยอดยกมาเป็น 0
+func TestReconciliationRepairsUnledgeredBalance(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.NewUser(t, db, 0)
+	if _, err := db.Exec("UPDATE users SET points = ? WHERE id = ?", 7*models.PointsScale, user.ID); err != nil {
+		t.Fatal(err)
+	}
+	service := newTestReconciliationService(db)
+
+	report, kinds := runReconciliation(t, service, true)
+	if kinds[models.DiscrepancyUnledgeredBalance] != 1 || report.RepairedCount != 1 {
+		t.Fatalf("got %v with %d repaired, want one repaired unledgered_balance", kinds, report.RepairedCount)
+	}
+	var change, balanceAfter models.Points
+	if err := db.QueryRow("SELECT change, balance_after FROM point_ledger WHERE user_id = ?", user.ID).
+		Scan(&change, &balanceAfter); err != nil {
+		t.Fatal(err)
+	}
+	if change != 7*models.PointsScale || balanceAfter != 7*models.PointsScale {
+		t.Fatalf("adjust entry has change %s and balance_after %s, want 7.00 and 7.00", change, balanceAfter)
+	}
+
+	if report, kinds = runReconciliation(t, service, false); report.DiscrepancyCount != 0 {
+		t.Fatalf("after repair got %v", kinds)
+	}
+}
@@ -0,0 +1,568 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ภาษา expression ของ transfer rule
+//
+//	literal:   1.5  "text"  true  false  null  [1, 2, "a"]
+//	ตัวแปร:    from  to  amount  ...  (ดู ruleVariables)
+//	function:  round(x)  abs(x)  len(s)  lower(s)
+//	operator:  !  -  * / %  + -  < <= > >=  == !=  in  &&  ||  (เรียงจากผูกแน่นที่สุด)
+//
+// การเปรียบเทียบ < <= > >= กับ null ได้ false เสมอ จึงเขียน `seconds_since_last < 10` ได้
+// โดยไม่ต้องเช็คว่ามี transfer ก่อนหน้าหรือไม่
+
+type exprNode interface {
+	eval(env ruleEnv) (interface{}, error)
+}
+
+// ruleEnv คืนค่าของตัวแปรตามชื่อ
+type ruleEnv func(name string) (interface{}, error)
+
+type ruleExpr struct {
+	root      exprNode
+	variables map[string]bool
+}
+
+// compileRuleExpr parse expression และตรวจว่าใช้เฉพาะตัวแปรที่รู้จัก
+func compileRuleExpr(src string) (*ruleExpr, error) {
+	p := &exprParser{src: src, variables: map[string]bool{}}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	root, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok.kind != tokEOF {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.tok.text, p.tok.pos)
+	}
+	for name := range p.variables {
+		if _, ok := ruleVariables[name]; !ok {
+			return nil, fmt.Errorf("unknown variable %q", name)
+		}
+	}
+	return &ruleExpr{root: root, variables: p.variables}, nil
+}
+
+// match ประเมิน expression ซึ่งต้องได้ค่า bool
+func (e *ruleExpr) match(env ruleEnv) (bool, error) {
+	v, err := e.root.eval(env)
+	if err != nil {
+		return false, err
+	}
+	b, ok := v.(bool)
+	if !ok {
+		return false, fmt.Errorf("expression must evaluate to true or false, got %s", typeName(v))
+	}
+	return b, nil
+}
+
+// ---- tokenizer ----
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokNumber
+	tokString
+	tokIdent
+	tokOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+type exprParser struct {
+	src       string
+	pos       int
+	tok       token
+	variables map[string]bool
+}
+
+var twoCharOps = []string{"&&", "||", "==", "!=", "<=", ">="}
+
+func (p *exprParser) next() error {
+	for p.pos < len(p.src) && unicode.IsSpace(rune(p.src[p.pos])) {
+		p.pos++
+	}
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokEOF, pos: start}
+		return nil
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case c >= '0' && c <= '9' || c == '.':
+		for p.pos < len(p.src) && (p.src[p.pos] >= '0' && p.src[p.pos] <= '9' || p.src[p.pos] == '.') {
+			p.pos++
+		}
+		text := p.src[start:p.pos]
+		n, err := strconv.ParseFloat(text, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q at position %d", text, start)
+		}
+		p.tok = token{kind: tokNumber, text: text, num: n, pos: start}
+	case c == '"':
+		p.pos++
+		var sb strings.Builder
+		for {
+			if p.pos >= len(p.src) {
+				return fmt.Errorf("unterminated string at position %d", start)
+			}
+			ch := p.src[p.pos]
+			if ch == '"' {
+				p.pos++
+				break
+			}
+			if ch == '\\' && p.pos+1 < len(p.src) {
+				p.pos++
+				ch = p.src[p.pos]
+			}
+			sb.WriteByte(ch)
+			p.pos++
+		}
+		p.tok = token{kind: tokString, text: sb.String(), pos: start}
+	case c == '_' || unicode.IsLetter(rune(c)):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || unicode.IsLetter(rune(p.src[p.pos])) || unicode.IsDigit(rune(p.src[p.pos]))) {
+			p.pos++
+		}
+		p.tok = token{kind: tokIdent, text: p.src[start:p.pos], pos: start}
+	default:
+		for _, op := range twoCharOps {
+			if strings.HasPrefix(p.src[p.pos:], op) {
+				p.pos += 2
+				p.tok = token{kind: tokOp, text: op, pos: start}
+				return nil
+			}
+		}
+		if !strings.ContainsRune("!<>+-*/%()[],", rune(c)) {
+			return fmt.Errorf("unexpected character %q at position %d", c, start)
+		}
+		p.pos++
+		p.tok = token{kind: tokOp, text: string(c), pos: start}
+	}
+	return nil
+}
+
+func (p *exprParser) isOp(ops ...string) bool {
+	if p.tok.kind != tokOp && !(p.tok.kind == tokIdent && p.tok.text == "in") {
+		return false
+	}
+	for _, op := range ops {
+		if p.tok.text == op {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *exprParser) expect(op string) error {
+	if !p.isOp(op) {
+		return fmt.Errorf("expected %q at position %d", op, p.tok.pos)
+	}
+	return p.next()
+}
+
+// ---- parser (recursive descent ตามลำดับ precedence) ----
+
+func (p *exprParser) parseBinary(sub func() (exprNode, error), ops ...string) (exprNode, error) {
+	left, err := sub()
+	if err != nil {
+		return nil, err
+	}
+	for p.isOp(ops...) {
+		op := p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		right, err := sub()
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryNode{op: op, left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *exprParser) parseOr() (exprNode, error)  { return p.parseBinary(p.parseAnd, "||") }
+func (p *exprParser) parseAnd() (exprNode, error) { return p.parseBinary(p.parseIn, "&&") }
+func (p *exprParser) parseIn() (exprNode, error)  { return p.parseBinary(p.parseEq, "in") }
+func (p *exprParser) parseEq() (exprNode, error)  { return p.parseBinary(p.parseCmp, "==", "!=") }
+func (p *exprParser) parseCmp() (exprNode, error) {
+	return p.parseBinary(p.parseAdd, "<", "<=", ">", ">=")
+}
+func (p *exprParser) parseAdd() (exprNode, error) { return p.parseBinary(p.parseMul, "+", "-") }
+func (p *exprParser) parseMul() (exprNode, error) { return p.parseBinary(p.parseUnary, "*", "/", "%") }
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.isOp("!", "-") {
+		op := p.tok.text
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		operand, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &unaryNode{op: op, operand: operand}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	tok := p.tok
+	switch {
+	case tok.kind == tokNumber:
+		return &literalNode{value: tok.num}, p.next()
+	case tok.kind == tokString:
+		return &literalNode{value: tok.text}, p.next()
+	case p.isOp("("):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	case p.isOp("["):
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		list := &listNode{}
+		for !p.isOp("]") {
+			item, err := p.parseOr()
+			if err != nil {
+				return nil, err
+			}
+			list.items = append(list.items, item)
+			if !p.isOp(",") {
+				break
+			}
+			if err := p.next(); err != nil {
+				return nil, err
+			}
+		}
+		return list, p.expect("]")
+	case tok.kind == tokIdent:
+		if err := p.next(); err != nil {
+			return nil, err
+		}
+		switch tok.text {
+		case "true":
+			return &literalNode{value: true}, nil
+		case "false":
+			return &literalNode{value: false}, nil
+		case "null":
+			return &literalNode{value: nil}, nil
+		}
+		if p.isOp("(") {
+			return p.parseCall(tok)
+		}
+		p.variables[tok.text] = true
+		return &varNode{name: tok.text}, nil
+	case tok.kind == tokEOF:
+		return nil, errors.New("unexpected end of expression")
+	default:
+		return nil, fmt.Errorf("unexpected %q at position %d", tok.text, tok.pos)
+	}
+}
+
+func (p *exprParser) parseCall(name token) (exprNode, error) {
+	fn, ok := ruleFunctions[name.text]
+	if !ok {
+		return nil, fmt.Errorf("unknown function %q", name.text)
+	}
+	if err := p.next(); err != nil {
+		return nil, err
+	}
+	arg, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+	return &callNode{name: name.text, fn: fn, arg: arg}, nil
+}
+
+// ---- AST ----
+
+type literalNode struct{ value interface{} }
+
+func (n *literalNode) eval(ruleEnv) (interface{}, error) { return n.value, nil }
+
+type varNode struct{ name string }
+
+func (n *varNode) eval(env ruleEnv) (interface{}, error) { return env(n.name) }
+
+type listNode struct{ items []exprNode }
+
+func (n *listNode) eval(env ruleEnv) (interface{}, error) {
+	values := make([]interface{}, len(n.items))
+	for i, item := range n.items {
+		v, err := item.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+type callNode struct {
+	name string
+	fn   func(interface{}) (interface{}, error)
+	arg  exprNode
+}
+
+func (n *callNode) eval(env ruleEnv) (interface{}, error) {
+	v, err := n.arg.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	out, err := n.fn(v)
+	if err != nil {
+		return nil, fmt.Errorf("%s(): %w", n.name, err)
+	}
+	return out, nil
+}
+
+var ruleFunctions = map[string]func(interface{}) (interface{}, error){
+	"round": func(v interface{}) (interface{}, error) {
+		n, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected number, got %s", typeName(v))
+		}
+		return math.Round(n), nil
+	},
+	"abs": func(v interface{}) (interface{}, error) {
+		n, ok := v.(float64)
+		if !ok {
+			return nil, fmt.Errorf("expected number, got %s", typeName(v))
+		}
+		return math.Abs(n), nil
+	},
+	"len": func(v interface{}) (interface{}, error) {
+		switch x := v.(type) {
+		case string:
+			return float64(len(x)), nil
+		case []interface{}:
+			return float64(len(x)), nil
+		}
+		return nil, fmt.Errorf("expected string or list, got %s", typeName(v))
+	},
+	"lower": func(v interface{}) (interface{}, error) {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("expected string, got %s", typeName(v))
+		}
+		return strings.ToLower(s), nil
+	},
+}
+
+type unaryNode struct {
+	op      string
+	operand exprNode
+}
+
+func (n *unaryNode) eval(env ruleEnv) (interface{}, error) {
+	v, err := n.operand.eval(env)
+	if err != nil {
+		return nil, err
+	}
+	if n.op == "!" {
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("! expects true or false, got %s", typeName(v))
+		}
+		return !b, nil
+	}
+	f, ok := v.(float64)
+	if !ok {
+		return nil, fmt.Errorf("- expects a number, got %s", typeName(v))
+	}
+	return -f, nil
+}
+
+type binaryNode struct {
+	op          string
+	left, right exprNode
+}
+
+func (n *binaryNode) eval(env ruleEnv) (interface{}, error) {
+	left, err := n.left.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	// && และ || หยุดประเมินฝั่งขวาเมื่อรู้ผลแล้ว
+	if n.op == "&&" || n.op == "||" {
+		lb, ok := left.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s expects true or false, got %s", n.op, typeName(left))
+		}
+		if n.op == "&&" && !lb || n.op == "||" && lb {
+			return lb, nil
+		}
+		right, err := n.right.eval(env)
+		if err != nil {
+			return nil, err
+		}
+		rb, ok := right.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s expects true or false, got %s", n.op, typeName(right))
+		}
+		return rb, nil
+	}
+
+	right, err := n.right.eval(env)
+	if err != nil {
+		return nil, err
+	}
+
+	switch n.op {
+	case "==":
+		return valuesEqual(left, right), nil
+	case "!=":
+		return !valuesEqual(left, right), nil
+	case "in":
+		list, ok := right.([]interface{})
+		if !ok {
+			return nil, fmt.Errorf("in expects a list, got %s", typeName(right))
+		}
+		for _, item := range list {
+			if valuesEqual(left, item) {
+				return true, nil
+			}
+		}
+		return false, nil
+	case "<", "<=", ">", ">=":
+		if left == nil || right == nil {
+			return false, nil
+		}
+		return compareValues(n.op, left, right)
+	}
+
+	if n.op == "+" {
+		if ls, ok := left.(string); ok {
+			if rs, ok := right.(string); ok {
+				return ls + rs, nil
+			}
+		}
+	}
+	lf, lok := left.(float64)
+	rf, rok := right.(float64)
+	if !lok || !rok {
+		return nil, fmt.Errorf("%s expects numbers, got %s and %s", n.op, typeName(left), typeName(right))
+	}
+	switch n.op {
+	case "+":
+		return lf + rf, nil
+	case "-":
+		return lf - rf, nil
+	case "*":
+		return lf * rf, nil
+	case "/":
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return lf / rf, nil
+	default: // %
+		if rf == 0 {
+			return nil, errors.New("division by zero")
+		}
+		return math.Mod(lf, rf), nil
+	}
+}
+
+func valuesEqual(a, b interface{}) bool {
+	switch x := a.(type) {
+	case []interface{}:
+		y, ok := b.([]interface{})
+		if !ok || len(x) != len(y) {
+			return false
+		}
+		for i := range x {
+			if !valuesEqual(x[i], y[i]) {
+				return false
+			}
+		}
+		return true
+	case float64:
+		// เทียบแต้มที่ทศนิยมละเอียดพอ กันเศษจาก float
+		y, ok := b.(float64)
+		return ok && math.Abs(x-y) < 1e-9
+	default:
+		if _, ok := b.([]interface{}); ok {
+			return false
+		}
+		return a == b
+	}
+}
+
+func compareValues(op string, a, b interface{}) (bool, error) {
+	var cmp int
+	switch x := a.(type) {
+	case float64:
+		y, ok := b.(float64)
+		if !ok {
+			return false, fmt.Errorf("cannot compare number with %s", typeName(b))
+		}
+		switch {
+		case x < y:
+			cmp = -1
+		case x > y:
+			cmp = 1
+		}
+	case string:
+		y, ok := b.(string)
+		if !ok {
+			return false, fmt.Errorf("cannot compare string with %s", typeName(b))
+		}
+		cmp = strings.Compare(x, y)
+	default:
+		return false, fmt.Errorf("cannot compare %s", typeName(a))
+	}
+
+	switch op {
+	case "<":
+		return cmp < 0, nil
+	case "<=":
+		return cmp <= 0, nil
+	case ">":
+		return cmp > 0, nil
+	default:
+		return cmp >= 0, nil
+	}
+}
+
+func typeName(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "list"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
@@ -0,0 +1,416 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// แหล่งที่มาของการโอน ใช้เป็นตัวแปร source ใน expression
+const (
+	RuleSourceTransfer     = "transfer"      // POST /transfers และ transfer ล่วงหน้าตอนถึงเวลาโอน
+	RuleSourceBatch        = "batch"         // แต่ละ leg ของ batch
+	RuleSourceMandate      = "mandate"       // สร้าง/แก้ mandate และแต่ละรอบที่ mandate โอน
+	RuleSourceScheduled    = "scheduled"     // ตอนสร้าง transfer ล่วงหน้า
+	RuleSourcePointRequest = "point_request" // ตอนสร้างคำขอแต้ม (from คือ payer)
+)
+
+// ruleVariables คือตัวแปรที่ใช้ใน expression ได้ พร้อมคำอธิบายที่แสดงใน admin API
+var ruleVariables = map[string]string{
+	"from":               "sender user ID",
+	"to":                 "receiver user ID",
+	"amount":             "transfer amount",
+	"note":               `transfer note ("" when empty)`,
+	"source":             "transfer, batch, mandate, scheduled or point_request",
+	"pair":               `"<from>:<to>", e.g. "1:2"`,
+	"last_to":            "receiver of the sender's last completed transfer (null if none)",
+	"last_amount":        "amount of the sender's last completed transfer (null if none)",
+	"seconds_since_last": "seconds since the sender's last completed transfer (null if none)",
+}
+
+// ตัวแปรที่ต้องดึงประวัติจาก database
+var historyVariables = map[string]bool{"last_to": true, "last_amount": true, "seconds_since_last": true}
+
+var (
+	ErrRuleNotFound = errors.New("rule not found")
+
+	ruleNamePattern      = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	ruleErrorCodePattern = regexp.MustCompile(`^[A-Z][A-Z0-9_]{0,63}$`)
+	messageVarPattern    = regexp.MustCompile(`\{([a-z_]+)\}`)
+)
+
+// RuleViolationError คือการโอนที่ถูกปฏิเสธโดย rule ชื่อ Rule
+type RuleViolationError struct {
+	Rule    string
+	Code    string
+	Message string
+}
+
+func (e *RuleViolationError) Error() string {
+	return e.Message
+}
+
+// RuleInput คือข้อมูลการโอนที่ rule ใช้ประเมิน
+type RuleInput struct {
+	FromUserID int
+	ToUserID   int
+	Amount     models.Points
+	Note       *string
+	Source     string
+}
+
+// transferHistory ดึงประวัติการโอนที่ rule ใช้ (TransferRepository)
+type transferHistory interface {
+	GetLastTransferFromUser(fromUserID int) (*models.Transfer, error)
+}
+
+type compiledRule struct {
+	models.TransferRule
+	expr *ruleExpr
+}
+
+// RuleService เก็บ rule ที่ compile แล้วไว้ใน memory และ reload จาก database ได้โดยไม่ต้อง restart
+type RuleService struct {
+	ruleRepo *repositories.RuleRepository
+	history  transferHistory
+	clock    Clock
+
+	mu    sync.RWMutex
+	rules []compiledRule
+}
+
+func NewRuleService(ruleRepo *repositories.RuleRepository, history transferHistory) *RuleService {
+	return &RuleService{
+		ruleRepo: ruleRepo,
+		history:  history,
+		clock:    SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้คำนวณ seconds_since_last
+func (s *RuleService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// Reload โหลด rule ทั้งหมดจาก database ใหม่ ถ้ามี rule ที่ compile ไม่ผ่านจะคง rule ชุดเดิมไว้
+func (s *RuleService) Reload() error {
+	rules, err := s.ruleRepo.GetAll()
+	if err != nil {
+		return err
+	}
+
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		expr, err := compileRuleExpr(rule.Expression)
+		if err != nil {
+			return fmt.Errorf("rule %s: %w", rule.Name, err)
+		}
+		compiled = append(compiled, compiledRule{TransferRule: rule, expr: expr})
+	}
+
+	s.mu.Lock()
+	s.rules = compiled
+	s.mu.Unlock()
+	return nil
+}
+
+// StartReloader reload rule เป็นระยะ เพื่อให้ rule ที่แก้ใน database ตรงๆ มีผลโดยไม่ต้อง restart
+func (s *RuleService) StartReloader(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if err := s.Reload(); err != nil {
+					log.Printf("Failed to reload transfer rules: %v", err)
+				}
+			}
+		}
+	}()
+}
+
+// Evaluate ประเมิน rule ที่เปิดอยู่ตามลำดับ และคืน RuleViolationError ของ rule แรกที่ปฏิเสธ
+// rule แบบ shadow จะแค่ log ส่วน rule ที่ประเมินไม่ได้จะปฏิเสธการโอนไว้ก่อน
+func (s *RuleService) Evaluate(input RuleInput) error {
+	violations, err := s.evaluate(input, true)
+	if err != nil {
+		return err
+	}
+	if len(violations) > 0 {
+		return violations[0]
+	}
+	return nil
+}
+
+// EvaluateAll เหมือน Evaluate แต่ไม่หยุดที่ rule แรก คืนทุก rule ที่ปฏิเสธการโอน (ใช้กับ quote)
+func (s *RuleService) EvaluateAll(input RuleInput) ([]*RuleViolationError, error) {
+	return s.evaluate(input, false)
+}
+
+func (s *RuleService) evaluate(input RuleInput, firstOnly bool) ([]*RuleViolationError, error) {
+	s.mu.RLock()
+	rules := s.rules
+	s.mu.RUnlock()
+
+	var violations []*RuleViolationError
+	env := s.env(input)
+	for _, rule := range rules {
+		if !rule.Enabled {
+			continue
+		}
+
+		matched, err := rule.expr.match(env)
+		if err != nil {
+			log.Printf("Transfer rule %s failed to evaluate: %v", rule.Name, err)
+			if rule.Mode == models.RuleModeShadow {
+				continue
+			}
+			return nil, fmt.Errorf("transfer rule %s failed to evaluate: %w", rule.Name, err)
+		}
+		if !matched {
+			continue
+		}
+
+		message := renderRuleMessage(rule.Message, env)
+		if rule.Mode == models.RuleModeShadow {
+			log.Printf("Transfer rule %s (shadow) would reject %d -> %d (source %s): %s",
+				rule.Name, input.FromUserID, input.ToUserID, input.Source, message)
+			continue
+		}
+		violations = append(violations, &RuleViolationError{Rule: rule.Name, Code: rule.ErrorCode, Message: message})
+		if firstOnly {
+			break
+		}
+	}
+	return violations, nil
+}
+
+// env คืนค่าตัวแปรของการโอน โดยดึงประวัติจาก database เฉพาะเมื่อ rule ใช้ และดึงครั้งเดียวต่อการประเมิน
+func (s *RuleService) env(input RuleInput) ruleEnv {
+	var (
+		loaded bool
+		last   *models.Transfer
+	)
+	return func(name string) (interface{}, error) {
+		if historyVariables[name] && !loaded {
+			var err error
+			last, err = s.history.GetLastTransferFromUser(input.FromUserID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to check last transfer: %w", err)
+			}
+			loaded = true
+		}
+
+		switch name {
+		case "from":
+			return float64(input.FromUserID), nil
+		case "to":
+			return float64(input.ToUserID), nil
+		case "amount":
+			return input.Amount.Float64(), nil
+		case "note":
+			if input.Note == nil {
+				return "", nil
+			}
+			return *input.Note, nil
+		case "source":
+			return input.Source, nil
+		case "pair":
+			return fmt.Sprintf("%d:%d", input.FromUserID, input.ToUserID), nil
+		case "last_to":
+			if last == nil {
+				return nil, nil
+			}
+			return float64(last.ToUserID), nil
+		case "last_amount":
+			if last == nil {
+				return nil, nil
+			}
+			return last.Amount.Float64(), nil
+		case "seconds_since_last":
+			if last == nil {
+				return nil, nil
+			}
+			at := last.CreatedAt
+			if last.CompletedAt != nil {
+				at = *last.CompletedAt
+			}
+			return s.clock.Now().Sub(at).Seconds(), nil
+		}
+		return nil, fmt.Errorf("unknown variable %q", name)
+	}
+}
+
+// renderRuleMessage แทน {ตัวแปร} ใน message ด้วยค่าของการโอน
+func renderRuleMessage(message string, env ruleEnv) string {
+	return messageVarPattern.ReplaceAllStringFunc(message, func(match string) string {
+		name := match[1 : len(match)-1]
+		if _, ok := ruleVariables[name]; !ok {
+			return match
+		}
+		v, err := env(name)
+		if err != nil {
+			return match
+		}
+		switch x := v.(type) {
+		case nil:
+			return "null"
+		case float64:
+			return strconv.FormatFloat(x, 'f', -1, 64)
+		default:
+			return fmt.Sprint(x)
+		}
+	})
+}
+
+func (s *RuleService) GetRules() (*models.TransferRuleListResponse, error) {
+	rules, err := s.ruleRepo.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	if rules == nil {
+		rules = []models.TransferRule{}
+	}
+	return &models.TransferRuleListResponse{Data: rules, Variables: ruleVariables}, nil
+}
+
+func (s *RuleService) CreateRule(req models.TransferRuleCreateRequest) (*models.TransferRule, error) {
+	if !ruleNamePattern.MatchString(req.Name) {
+		return nil, errors.New("name must be lowercase letters, digits or underscores (max 64 characters)")
+	}
+
+	rule := &models.TransferRule{
+		Name:        req.Name,
+		Position:    req.Position,
+		Description: req.Description,
+		Expression:  strings.TrimSpace(req.Expression),
+		ErrorCode:   req.ErrorCode,
+		Message:     req.Message,
+		Enabled:     true,
+		Mode:        models.RuleModeEnforce,
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.Mode != "" {
+		rule.Mode = req.Mode
+	}
+	if err := validateRule(rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.ruleRepo.Create(rule); err != nil {
+		return nil, err
+	}
+	return rule, s.Reload()
+}
+
+func (s *RuleService) UpdateRule(name string, req models.TransferRuleUpdateRequest) (*models.TransferRule, error) {
+	rule, err := s.ruleRepo.GetByName(name)
+	if err != nil {
+		return nil, err
+	}
+	if rule == nil {
+		return nil, ErrRuleNotFound
+	}
+
+	if req.Position != nil {
+		rule.Position = *req.Position
+	}
+	if req.Description != nil {
+		rule.Description = req.Description
+	}
+	if req.Expression != nil {
+		rule.Expression = strings.TrimSpace(*req.Expression)
+	}
+	if req.ErrorCode != nil {
+		rule.ErrorCode = *req.ErrorCode
+	}
+	if req.Message != nil {
+		rule.Message = *req.Message
+	}
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	if req.Mode != nil {
+		rule.Mode = *req.Mode
+	}
+	if err := validateRule(rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.ruleRepo.Update(rule); err != nil {
+		return nil, err
+	}
+	return rule, s.Reload()
+}
+
+func (s *RuleService) DeleteRule(name string) error {
+	deleted, err := s.ruleRepo.Delete(name)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return ErrRuleNotFound
+	}
+	return s.Reload()
+}
+
+// validateRule ตรวจ rule ก่อนบันทึก รวมถึงลองประเมิน expression ทั้งกรณีที่มีและไม่มีประวัติการโอน
+// เพื่อจับ type error ที่จะทำให้การโอนถูกปฏิเสธทั้งหมด
+func validateRule(rule *models.TransferRule) error {
+	if rule.Mode != models.RuleModeEnforce && rule.Mode != models.RuleModeShadow {
+		return fmt.Errorf("mode must be %s or %s", models.RuleModeEnforce, models.RuleModeShadow)
+	}
+	if !ruleErrorCodePattern.MatchString(rule.ErrorCode) {
+		return errors.New("errorCode must be uppercase letters, digits or underscores (e.g. BLOCKED_TRANSFER)")
+	}
+	if strings.TrimSpace(rule.Message) == "" || len(rule.Message) > 512 {
+		return errors.New("message is required and cannot exceed 512 characters")
+	}
+	if rule.Expression == "" {
+		return errors.New("expression is required")
+	}
+
+	expr, err := compileRuleExpr(rule.Expression)
+	if err != nil {
+		return fmt.Errorf("invalid expression: %w", err)
+	}
+	for _, withHistory := range []bool{true, false} {
+		if _, err := expr.match(sampleRuleEnv(withHistory)); err != nil {
+			return fmt.Errorf("invalid expression: %w", err)
+		}
+	}
+	return nil
+}
+
+func sampleRuleEnv(withHistory bool) ruleEnv {
+	return func(name string) (interface{}, error) {
+		switch name {
+		case "from":
+			return float64(1), nil
+		case "to":
+			return float64(2), nil
+		case "amount":
+			return 1.5, nil
+		case "note", "source", "pair":
+			return "", nil
+		}
+		if !withHistory {
+			return nil, nil
+		}
+		return float64(1), nil
+	}
+}
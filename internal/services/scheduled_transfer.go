@@ -0,0 +1,173 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// MaxScheduleAhead คือระยะเวลาล่วงหน้าสูงสุดที่ตั้งโอนได้
+const MaxScheduleAhead = 365 * 24 * time.Hour
+
+// scheduleTransfer เก็บ transfer ล่วงหน้าเป็น pending
+func (s *TransferService) scheduleTransfer(req models.TransferCreateRequest) (*models.Transfer, error) {
+	if req.TwoPhase {
+		return nil, errors.New("scheduled transfers cannot be two-phase")
+	}
+
+	now := s.clock.Now()
+	if !req.ScheduledAt.After(now) {
+		return nil, errors.New("scheduledAt must be in the future")
+	}
+	if req.ScheduledAt.Sub(now) > MaxScheduleAhead {
+		return nil, errors.New("scheduledAt cannot be more than 365 days ahead")
+	}
+
+	if err := s.checkRules(req, RuleSourceScheduled); err != nil {
+		return nil, err
+	}
+	if err := s.limits.CheckPerTransaction(req.FromUserID, req.Amount); err != nil {
+		return nil, err
+	}
+
+	// ค่าธรรมเนียมถูกกำหนดตอนตั้งโอน และถูกหักพร้อม amount ตอนถึงเวลาโอน
+	var err error
+	if req.Fee, err = s.fees.Quote(req.FromUserID, req.Amount); err != nil {
+		return nil, err
+	}
+
+	return s.transferRepo.CreateScheduled(req)
+}
+
+// GetScheduledTransfers ดึง transfer ล่วงหน้าที่ยังไม่ถึงเวลาของ user
+func (s *TransferService) GetScheduledTransfers(userID int) (*models.ScheduledTransferListResponse, error) {
+	if userID <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+
+	transfers, err := s.transferRepo.ListScheduledByUser(userID)
+	if err != nil {
+		return nil, err
+	}
+	if transfers == nil {
+		transfers = []models.Transfer{}
+	}
+
+	return &models.ScheduledTransferListResponse{Data: transfers}, nil
+}
+
+// CancelScheduledTransfer ยกเลิก transfer ล่วงหน้าของ user ก่อนถึงเวลาโอน
+func (s *TransferService) CancelScheduledTransfer(userID int, idemKey string) (*models.Transfer, error) {
+	transfer, err := s.GetTransferByIdemKey(idemKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// ไม่บอกว่ามี transfer อยู่ถ้าไม่ใช่ของ user นี้
+	if transfer.FromUserID != userID || transfer.ScheduledAt == nil {
+		return nil, errors.New("transfer not found")
+	}
+
+	if err := ValidateTransition(transfer.Status, models.TransferStatusCancelled); err != nil {
+		return nil, err
+	}
+
+	if err := s.transferRepo.TransitionStatus(transfer.ID, models.TransferStatusPending, models.TransferStatusCancelled, nil); err != nil {
+		return nil, err
+	}
+
+	return s.transferRepo.GetByIdemKey(idemKey)
+}
+
+// ExecuteDueTransfers โอน transfer ล่วงหน้าที่ถึงเวลาแล้ว คืนจำนวนที่โอนสำเร็จ
+// transfer ที่ผิดกฎหรือแต้มไม่พอจะถูกเปลี่ยนเป็น failed พร้อม fail_reason
+func (s *TransferService) ExecuteDueTransfers(now time.Time) (int, error) {
+	due, err := s.transferRepo.ListDueScheduled(now, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	completed := 0
+	for _, transfer := range due {
+		ok, err := s.executeScheduled(transfer)
+		if err != nil {
+			return completed, err
+		}
+		if ok {
+			completed++
+		}
+	}
+
+	return completed, nil
+}
+
+// executeScheduled รัน transfer ล่วงหน้าหนึ่งรายการด้วยกฎเดียวกับ CreateTransfer
+// คืน error เฉพาะกรณีที่ทำต่อไม่ได้ (เช่น database ใช้งานไม่ได้)
+func (s *TransferService) executeScheduled(transfer models.Transfer) (bool, error) {
+	// จองรายการก่อน กัน worker หรือการ cancel ที่ทำพร้อมกัน
+	err := s.transferRepo.TransitionStatus(transfer.ID, models.TransferStatusPending, models.TransferStatusProcessing, nil)
+	if err != nil {
+		if errors.Is(err, repositories.ErrStatusConflict) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	req := models.TransferCreateRequest{
+		FromUserID: transfer.FromUserID,
+		ToUserID:   transfer.ToUserID,
+		Amount:     transfer.Amount,
+		Note:       transfer.Note,
+	}
+
+	if err := s.validateTransfer(req); err != nil {
+		return false, s.failScheduled(transfer, err)
+	}
+	// transfer ล่วงหน้าพักรอตรวจไม่ได้เพราะถึงเวลาโอนแล้ว คะแนนที่ถึงเกณฑ์ review จึงทำให้ failed
+	if _, err := s.screenTransfer(req, false); err != nil {
+		return false, s.failScheduled(transfer, err)
+	}
+
+	completed, err := s.transferRepo.Settle(transfer.ID)
+	if err != nil {
+		return false, s.failScheduled(transfer, err)
+	}
+	s.notifyTransfer(models.WebhookEventTransferCompleted, completed)
+
+	return true, nil
+}
+
+func (s *TransferService) failScheduled(transfer models.Transfer, cause error) error {
+	failure := settlementFailure(cause)
+	if err := s.transferRepo.TransitionStatus(transfer.ID, models.TransferStatusProcessing, models.TransferStatusFailed, failure); err != nil {
+		return fmt.Errorf("failed to mark scheduled transfer %d as failed: %w", transfer.ID, err)
+	}
+	log.Printf("Scheduled transfer %d failed (%s): %s", transfer.ID, failure.Code, failure.Reason)
+	s.notifyFailedByIdemKey(transfer.IdemKey)
+	return nil
+}
+
+// StartScheduledExecutor รัน ExecuteDueTransfers เป็นระยะใน background จนกว่า stop จะถูกปิด
+func (s *TransferService) StartScheduledExecutor(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := s.ExecuteDueTransfers(s.clock.Now()); err != nil {
+					log.Printf("Failed to execute scheduled transfers: %v", err)
+				} else if n > 0 {
+					log.Printf("Executed %d scheduled transfers", n)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,198 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/testutil"
+)
+
+func scheduledRequest(from, to *models.User, at time.Time) models.TransferCreateRequest {
+	return models.TransferCreateRequest{
+		FromUserID:  from.ID,
+		ToUserID:    to.ID,
+		Amount:      models.PointsScale,
+		ScheduledAt: &at,
+	}
+}
+
+func TestScheduledTransferRunsOnlyWhenDue(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUser(t, db, 10*models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	service := newTestTransferService(t, db)
+	clock := NewFixedClock(time.Now())
+	service.SetClock(clock)
+
+	transfer, err := service.CreateTransfer(scheduledRequest(sender, receiver, clock.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if transfer.Status != models.TransferStatusPending {
+		t.Fatalf("scheduled transfer is %s, want pending", transfer.Status)
+	}
+
+	clock.Advance(59 * time.Minute)
+	executed, err := service.ExecuteDueTransfers(clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executed != 0 {
+		t.Fatalf("executed %d transfers before they were due", executed)
+	}
+	if got := testutil.UserPoints(t, db, sender.ID); got != 10*models.PointsScale {
+		t.Fatalf("sender has %s points before the transfer was due", got)
+	}
+
+	clock.Advance(time.Minute)
+	executed, err = service.ExecuteDueTransfers(clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executed != 1 {
+		t.Fatalf("executed %d due transfers, want 1", executed)
+	}
+
+	completed, err := service.GetTransferByIdemKey(transfer.IdemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if completed.Status != models.TransferStatusCompleted {
+		t.Fatalf("due transfer is %s, want completed", completed.Status)
+	}
+	if got, want := testutil.UserPoints(t, db, sender.ID), 9*models.PointsScale; got != want {
+		t.Fatalf("sender has %s points, want %s", got, want)
+	}
+	if got, want := testutil.UserPoints(t, db, receiver.ID), models.PointsScale; got != want {
+		t.Fatalf("receiver has %s points, want %s", got, want)
+	}
+
+	// รอบถัดไปต้องไม่โอนซ้ำ
+	if executed, err = service.ExecuteDueTransfers(clock.Now().Add(time.Hour)); err != nil || executed != 0 {
+		t.Fatalf("second run executed %d transfers (err %v), want 0", executed, err)
+	}
+}
+
+func TestScheduledTransferRejectsPastTime(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUser(t, db, 10*models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	service := newTestTransferService(t, db)
+	clock := NewFixedClock(time.Now().Add(48 * time.Hour))
+	service.SetClock(clock)
+
+	// เวลาจริงยังไม่ถึง แต่ตามนาฬิกาของ service ผ่านไปแล้ว
+	if _, err := service.CreateTransfer(scheduledRequest(sender, receiver, time.Now().Add(time.Hour))); err == nil {
+		t.Fatal("scheduling in the past of the service clock succeeded")
+	}
+	if _, err := service.CreateTransfer(scheduledRequest(sender, receiver, clock.Now().Add(MaxScheduleAhead+time.Minute))); err == nil {
+		t.Fatal("scheduling beyond MaxScheduleAhead succeeded")
+	}
+}
+
+func TestScheduledTransferListAndCancel(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUser(t, db, 10*models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	other := testutil.NewUser(t, db, 0)
+	service := newTestTransferService(t, db)
+	clock := NewFixedClock(time.Now())
+	service.SetClock(clock)
+
+	later, err := service.CreateTransfer(scheduledRequest(sender, receiver, clock.Now().Add(2*time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sooner, err := service.CreateTransfer(scheduledRequest(sender, other, clock.Now().Add(time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	list, err := service.GetScheduledTransfers(sender.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 2 || list.Data[0].ID != sooner.ID || list.Data[1].ID != later.ID {
+		t.Fatalf("got %d scheduled transfers, want [%d %d] ordered by scheduledAt", len(list.Data), sooner.ID, later.ID)
+	}
+	if list, err := service.GetScheduledTransfers(receiver.ID); err != nil || len(list.Data) != 0 {
+		t.Fatalf("receiver sees %d scheduled transfers (err %v), want 0", len(list.Data), err)
+	}
+
+	if _, err := service.CancelScheduledTransfer(receiver.ID, later.IdemKey); err == nil {
+		t.Fatal("another user cancelled the scheduled transfer")
+	}
+	cancelled, err := service.CancelScheduledTransfer(sender.ID, later.IdemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cancelled.Status != models.TransferStatusCancelled {
+		t.Fatalf("cancelled transfer is %s", cancelled.Status)
+	}
+	if _, err := service.CancelScheduledTransfer(sender.ID, later.IdemKey); err == nil {
+		t.Fatal("cancelling twice succeeded")
+	}
+
+	list, err = service.GetScheduledTransfers(sender.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 || list.Data[0].ID != sooner.ID {
+		t.Fatalf("after cancel got %d scheduled transfers, want only %d", len(list.Data), sooner.ID)
+	}
+
+	clock.Advance(3 * time.Hour)
+	executed, err := service.ExecuteDueTransfers(clock.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if executed != 1 {
+		t.Fatalf("executed %d transfers, want only the one not cancelled", executed)
+	}
+	if got := testutil.UserPoints(t, db, receiver.ID); got != 0 {
+		t.Fatalf("receiver of the cancelled transfer has %s points", got)
+	}
+	if _, err := service.CancelScheduledTransfer(sender.ID, sooner.IdemKey); err == nil {
+		t.Fatal("cancelled a transfer that was already executed")
+	}
+}
+
+func TestScheduledExecutorUsesServiceClock(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUser(t, db, 10*models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	service := newTestTransferService(t, db)
+	clock := NewFixedClock(time.Now())
+	service.SetClock(clock)
+
+	transfer, err := service.CreateTransfer(scheduledRequest(sender, receiver, clock.Now().Add(24*time.Hour)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stop := make(chan struct{})
+	defer close(stop)
+	service.StartScheduledExecutor(10*time.Millisecond, stop)
+
+	time.Sleep(50 * time.Millisecond)
+	if got, _ := service.GetTransferByIdemKey(transfer.IdemKey); got.Status != models.TransferStatusPending {
+		t.Fatalf("executor ran the transfer early: %s", got.Status)
+	}
+
+	clock.Advance(24 * time.Hour)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		got, err := service.GetTransferByIdemKey(transfer.IdemKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Status == models.TransferStatusCompleted {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("executor did not run the due transfer, status %s", got.Status)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,30 @@
+package services
+
+import (
+	"testing"
+
+	"kbtg-backend/internal/database"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/testutil"
+)
+
+// newTestTransferService ต่อ TransferService แบบเดียวกับ main (ไม่มี fraud และ webhook)
+// rule ใน disabledRules ถูกปิดก่อนโหลด เช่น repeat_recipient สำหรับ test ที่โอนให้คนเดิมซ้ำ
+// อยู่ที่นี่แทน testutil เพราะ testutil import services ไม่ได้ (import cycle กับ test ภายใน package)
+func newTestTransferService(t *testing.T, db *database.DB, disabledRules ...string) *TransferService {
+	t.Helper()
+	testutil.DisableRules(t, db, disabledRules...)
+
+	transferRepo := repositories.NewTransferRepository(db.DB)
+	rules := NewRuleService(repositories.NewRuleRepository(db.DB), transferRepo)
+	if err := rules.Reload(); err != nil {
+		t.Fatalf("load rules: %v", err)
+	}
+	return NewTransferService(
+		transferRepo,
+		NewUserService(repositories.NewUserRepository(db.DB)),
+		NewLimitService(repositories.NewLimitRepository(db.DB)),
+		rules,
+		NewFeeService(repositories.NewFeeRepository(db.DB)),
+	)
+}
@@ -0,0 +1,127 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// QuoteTransfer จำลอง CreateTransfer ทุกขั้น (rule, limit, ผู้รับ, ค่าธรรมเนียม, แต้มคงเหลือ, fraud)
+// โดยไม่บันทึกอะไรและไม่ส่ง webhook และคืนทุก violation แทนการหยุดที่ข้อแรก
+// error ที่คืนหมายถึง quote ไม่ได้ (เช่น ไม่พบผู้โอน) ไม่ใช่เหตุผลที่การโอนจะถูกปฏิเสธ
+func (s *TransferService) QuoteTransfer(req models.TransferCreateRequest) (*models.TransferQuote, error) {
+	// transfer ล่วงหน้าถูกตรวจอีกรอบตอนถึงเวลาโอน ผลของ quote ตอนนี้จึงไม่มีความหมาย
+	if req.ScheduledAt != nil {
+		return nil, errors.New("scheduled transfers cannot be quoted")
+	}
+	if err := s.resolveRecipient(&req); err != nil {
+		return nil, err
+	}
+
+	balance, err := s.users.GetBalance(req.FromUserID)
+	if err != nil {
+		return nil, err
+	}
+	if balance == nil {
+		return nil, errors.New("from user not found")
+	}
+
+	quote := &models.TransferQuote{
+		FromUserID:    req.FromUserID,
+		ToUserID:      req.ToUserID,
+		Amount:        req.Amount,
+		BalanceBefore: *balance,
+		Violations:    []models.TransferQuoteViolation{},
+	}
+
+	// ลำดับเดียวกับ validateTransfer: บัญชี house, rule แล้วจึง limit
+	var houseErr *RuleViolationError
+	if err := s.checkHouseAccount(req); errors.As(err, &houseErr) {
+		quote.Violations = append(quote.Violations, models.TransferQuoteViolation{
+			Code: houseErr.Code, Message: houseErr.Message, Rule: houseErr.Rule,
+		})
+	} else if err != nil {
+		return nil, err
+	}
+
+	ruleViolations, err := s.rules.EvaluateAll(RuleInput{
+		FromUserID: req.FromUserID,
+		ToUserID:   req.ToUserID,
+		Amount:     req.Amount,
+		Note:       req.Note,
+		Source:     RuleSourceTransfer,
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range ruleViolations {
+		quote.Violations = append(quote.Violations, models.TransferQuoteViolation{
+			Code: v.Code, Message: v.Message, Rule: v.Rule,
+		})
+	}
+
+	limitViolations, err := s.limits.TransferViolations(req.FromUserID, req.Amount)
+	if err != nil {
+		return nil, err
+	}
+	for _, v := range limitViolations {
+		quote.Violations = append(quote.Violations, models.TransferQuoteViolation{
+			Code: "LIMIT_EXCEEDED", Message: v.Error(), Rule: v.Limit, Details: v,
+		})
+	}
+
+	// POST /transfers ตรวจว่าผู้รับมีอยู่จริงใน repository ตอนสร้าง
+	var recipient *models.User
+	if req.ToUserID > 0 {
+		if recipient, err = s.users.GetUserByID(req.ToUserID); err != nil {
+			return nil, err
+		}
+	}
+	if recipient == nil {
+		quote.Violations = append(quote.Violations, models.TransferQuoteViolation{
+			Code: "USER_NOT_FOUND", Message: "to user not found",
+		})
+	}
+
+	if quote.Fee, err = s.fees.Quote(req.FromUserID, req.Amount); err != nil {
+		return nil, err
+	}
+	quote.TotalDebited = quote.Amount + quote.Fee
+	if balance.Available < quote.TotalDebited {
+		insufficient := fmt.Errorf("%w: have %s, need %s", repositories.ErrInsufficientPoints, balance.Available, quote.TotalDebited)
+		quote.Violations = append(quote.Violations, models.TransferQuoteViolation{
+			Code: "INSUFFICIENT_POINTS", Message: insufficient.Error(),
+		})
+	}
+
+	req.Fee = quote.Fee
+	assessment, err := s.screenTransfer(req, true)
+	var fraudErr *FraudBlockedError
+	switch {
+	case errors.As(err, &fraudErr):
+		quote.Violations = append(quote.Violations, models.TransferQuoteViolation{
+			Code: fraudErr.Code(), Message: fraudErr.Error(), Rule: fraudErr.Signal(), Details: fraudErr.Assessment,
+		})
+	case err != nil:
+		return nil, err
+	case assessment != nil && assessment.Decision == models.FraudDecisionReview:
+		quote.UnderReview = true
+	}
+
+	quote.Allowed = len(quote.Violations) == 0
+	quote.BalanceAfter = projectBalance(*balance, quote.TotalDebited, req.TwoPhase || quote.UnderReview)
+	return quote, nil
+}
+
+// projectBalance คือแต้มของผู้โอนหลังการโอน total ถ้า held = true แต้มถูก hold ไว้ก่อน (two-phase หรือรอตรวจ fraud)
+func projectBalance(balance models.UserBalance, total models.Points, held bool) models.UserBalance {
+	if held {
+		balance.Held += total
+	} else {
+		balance.Points -= total
+	}
+	balance.Available -= total
+	return balance
+}
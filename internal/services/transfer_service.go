@@ -1,50 +1,290 @@
 package services
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"math"
+	"log"
+	"strings"
+	"time"
 
 	"kbtg-backend/internal/models"
 	"kbtg-backend/internal/repositories"
 )
 
+// IdempotencyKeyRetention คือระยะเวลาที่ replay ด้วย Idempotency-Key เดิมได้
+// หลังจากนั้น key ถูกปล่อย request ที่ใช้ key เดิมจะสร้าง transfer ใหม่
+const IdempotencyKeyRetention = 24 * time.Hour
+
+// PendingTransferTTL คือเวลาที่ transfer แบบ two-phase รอ confirm ได้ก่อน hold จะถูกปล่อย
+const PendingTransferTTL = 15 * time.Minute
+
+var (
+	ErrIdempotencyKeyInvalid  = errors.New("idempotency key must be between 8 and 128 characters")
+	ErrIdempotencyKeyMismatch = errors.New("idempotency key was already used with a different request payload")
+	// ErrInvalidTransferQuery ครอบ error ของเงื่อนไขค้นประวัติการโอนที่ไม่ถูกต้อง
+	ErrInvalidTransferQuery = errors.New("invalid transfer query")
+	// ErrHouseAccountTransfer ถูกคืนเมื่อบัญชี house เป็นผู้โอนหรือผู้รับ ตอบแบบเดียวกับ rule ที่ปฏิเสธการโอน
+	ErrHouseAccountTransfer = &RuleViolationError{
+		Rule: "house_account", Code: "HOUSE_ACCOUNT", Message: "the house account cannot send or receive transfers",
+	}
+)
+
+// ReversalPolicy กำหนดว่าการ reverse ทำให้ผู้รับติดลบได้หรือไม่
+type ReversalPolicy string
+
+const (
+	// ReversalPolicyRejectNegative ปฏิเสธการ reverse ถ้าผู้รับมีแต้มไม่พอ (ค่าเริ่มต้น)
+	ReversalPolicyRejectNegative ReversalPolicy = "reject"
+	// ReversalPolicyAllowNegative ยอมให้ผู้รับติดลบหลัง reverse
+	ReversalPolicyAllowNegative ReversalPolicy = "allow"
+)
+
 type TransferService struct {
-	transferRepo *repositories.TransferRepository
+	transferRepo   *repositories.TransferRepository
+	users          *UserService
+	limits         *LimitService
+	rules          *RuleService
+	fees           *FeeService
+	webhooks       *WebhookService
+	fraud          *FraudService
+	disputes       *DisputeService
+	reversalPolicy ReversalPolicy
+	clock          Clock
+}
+
+func NewTransferService(transferRepo *repositories.TransferRepository, users *UserService, limits *LimitService, rules *RuleService, fees *FeeService) *TransferService {
+	return &TransferService{
+		transferRepo:   transferRepo,
+		users:          users,
+		limits:         limits,
+		rules:          rules,
+		fees:           fees,
+		reversalPolicy: ReversalPolicyRejectNegative,
+		clock:          SystemClock{},
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้ตัดสินเวลา (เช่น ใช้ FixedClock ตอนทดสอบ)
+func (s *TransferService) SetClock(clock Clock) {
+	s.clock = clock
 }
 
-func NewTransferService(transferRepo *repositories.TransferRepository) *TransferService {
-	return &TransferService{transferRepo: transferRepo}
+// SetWebhooks ตั้ง WebhookService ที่รับ event ของ transfer และ ledger (ไม่ตั้งก็ได้)
+func (s *TransferService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
+}
+
+// SetFraud ตั้ง FraudService ที่ให้คะแนนการโอนก่อน commit (ไม่ตั้งก็ได้ จะไม่ตรวจ fraud)
+func (s *TransferService) SetFraud(fraud *FraudService) {
+	s.fraud = fraud
+}
+
+// SetDisputes ตั้ง DisputeService เพื่อแสดงสถานะ dispute ล่าสุดในประวัติการโอน (ไม่ตั้งก็ได้)
+func (s *TransferService) SetDisputes(disputes *DisputeService) {
+	s.disputes = disputes
+}
+
+// SetReversalPolicy เปลี่ยน policy การติดลบของผู้รับตอน reverse
+func (s *TransferService) SetReversalPolicy(policy ReversalPolicy) error {
+	switch policy {
+	case ReversalPolicyRejectNegative, ReversalPolicyAllowNegative:
+		s.reversalPolicy = policy
+		return nil
+	default:
+		return fmt.Errorf("unknown reversal policy %q", policy)
+	}
 }
 
 func (s *TransferService) CreateTransfer(req models.TransferCreateRequest) (*models.Transfer, error) {
-	// Validation 1: ชื่อไม่ต้องเกิน 3 ตัวอักษร (ตรวจที่ user service แล้ว)
+	// แปลง toMemberId / toPhone / toEmail เป็น user id ก่อน เพื่อให้ทุกขั้นต่อไป (รวมถึง idempotency hash) ใช้ผู้รับตัวจริง
+	if err := s.resolveRecipient(&req); err != nil {
+		return nil, err
+	}
+
+	// ถ้า client ส่ง Idempotency-Key มาและเคยใช้แล้ว ให้คืน transfer เดิมแทนการโอนซ้ำ
+	if req.IdemKey != "" {
+		if len(req.IdemKey) < 8 || len(req.IdemKey) > 128 {
+			return nil, ErrIdempotencyKeyInvalid
+		}
+		req.RequestHash = hashTransferRequest(req)
+
+		existing, err := s.replayIdempotent(req)
+		if err != nil || existing != nil {
+			return existing, err
+		}
+	}
+
+	transfer, err := s.createTransfer(req)
+	if err != nil && req.IdemKey != "" {
+		// request ซ้ำที่ยิงมาพร้อมกันอาจถูก reject (เช่น ชน UNIQUE constraint หรือกฎโอนซ้ำ)
+		// เพราะ request แรกเพิ่ง commit ไป ให้คืนผลของ request แรกแทน
+		if existing, replayErr := s.replayIdempotent(req); replayErr != nil || existing != nil {
+			return existing, replayErr
+		}
+	}
+	// transfer ล่วงหน้าที่ถูกปฏิเสธตอนสร้างไม่ถูกบันทึก ส่วนตอนถึงเวลาโอนจะถูกบันทึกเป็น failed อยู่แล้ว
+	if err != nil && req.ScheduledAt == nil {
+		s.recordFailure(req, nil, err)
+	}
+	return transfer, err
+}
+
+// resolveRecipient ตั้ง ToUserID จาก ToMemberID, ToPhone หรือ ToEmail ถ้ามี
+func (s *TransferService) resolveRecipient(req *models.TransferCreateRequest) error {
+	lookup := models.RecipientLookup{MemberID: req.ToMemberID, Phone: req.ToPhone, Email: req.ToEmail}
+	if lookup == (models.RecipientLookup{}) {
+		return nil
+	}
+	if req.ToUserID != 0 {
+		return errors.New("use either toUserId or one of toMemberId, toPhone, toEmail")
+	}
+
+	user, _, err := s.users.ResolveRecipient(lookup)
+	if err != nil {
+		return err
+	}
+	req.ToUserID = user.ID
+	return nil
+}
+
+// recordFailure บันทึกการโอนที่ถูกปฏิเสธเป็น transfer สถานะ failed เพื่อใช้ตรวจสอบย้อนหลังและวัดอัตราการปฏิเสธ
+// บันทึกเฉพาะการปฏิเสธด้วยเหตุผลทางธุรกิจ ที่จำนวนแต้มมากกว่า 0 (เก็บลง transfers ได้)
+func (s *TransferService) recordFailure(req models.TransferCreateRequest, batchID *string, cause error) {
+	failure, ok := transferFailure(cause)
+	if !ok || req.FromUserID <= 0 || req.ToUserID <= 0 || req.Amount <= 0 {
+		return
+	}
+	// transfers อ้างถึง users ด้วย foreign key จึงบันทึกการโอนที่ผู้โอนหรือผู้รับไม่มีอยู่จริงไม่ได้
+	if failure.Code == "USER_NOT_FOUND" {
+		return
+	}
+	// การโอนที่ถูกปฏิเสธก่อนคิดค่าธรรมเนียม (rule, limit, leg ของ batch) ยังต้องบันทึกยอดรวมที่ผู้โอนจะถูกหัก
+	if req.Fee == 0 {
+		fee, err := s.fees.Quote(req.FromUserID, req.Amount)
+		if err != nil {
+			log.Printf("Failed to quote fee of rejected transfer %d -> %d: %v", req.FromUserID, req.ToUserID, err)
+		}
+		req.Fee = fee
+	}
+	transfer, err := s.transferRepo.RecordFailed(req, batchID, failure)
+	if err != nil {
+		log.Printf("Failed to record rejected transfer %d -> %d: %v", req.FromUserID, req.ToUserID, err)
+		return
+	}
+	s.notifyTransfer(models.WebhookEventTransferFailed, transfer)
+}
+
+// notifyTransfer ส่ง event ของ transfer ไปยัง webhook พร้อม ledger.entry_created ของ entry ที่เพิ่งเขียน
+// (entry ของการโอนสำหรับ transfer.completed และ entry ชดเชยสำหรับ transfer.reversed)
+func (s *TransferService) notifyTransfer(eventType string, transfer *models.Transfer) {
+	if s.webhooks == nil || transfer == nil {
+		return
+	}
+	s.webhooks.Publish(eventType, transfer)
+	if eventType == models.WebhookEventTransferFailed {
+		return
+	}
+
+	entries, err := s.transferRepo.GetLedgerEntries(transfer.ID)
+	if err != nil {
+		log.Printf("Failed to load ledger entries of transfer %d for webhooks: %v", transfer.ID, err)
+		return
+	}
+	reversed := eventType == models.WebhookEventTransferReversed
+	for _, entry := range entries {
+		isReversal := entry.Reference != nil && *entry.Reference == repositories.ReversalReference
+		if isReversal == reversed {
+			s.webhooks.Publish(models.WebhookEventLedgerEntryCreated, entry)
+		}
+	}
+}
 
-	// Validation 2: transfer โอนได้สูงสุดครั้งละไม่เกิน 2 แต้ม และทศนิยมไม่เกิน 2 ตำแหน่ง
-	if req.Amount <= 0 {
-		return nil, errors.New("amount must be greater than 0")
+// notifyFailedByIdemKey ส่ง transfer.failed ของ transfer ที่เพิ่งถูกเปลี่ยนเป็น failed
+func (s *TransferService) notifyFailedByIdemKey(idemKey string) {
+	if s.webhooks == nil {
+		return
 	}
-	if req.Amount > 2.0 {
-		return nil, errors.New("amount cannot exceed 2.00 points per transfer")
+	transfer, err := s.transferRepo.GetByIdemKey(idemKey)
+	if err != nil {
+		log.Printf("Failed to load failed transfer %s for webhooks: %v", idemKey, err)
+		return
 	}
-	// ตรวจสอบทศนิยมไม่เกิน 2 ตำแหน่ง
-	if math.Round(req.Amount*100) != req.Amount*100 {
-		return nil, errors.New("amount can have at most 2 decimal places")
+	s.notifyTransfer(models.WebhookEventTransferFailed, transfer)
+}
+
+// transferFailure แปลง error ที่ปฏิเสธการโอนเป็น code และ rule ที่บันทึกได้
+// คืน false ถ้าไม่ใช่เหตุผลทางธุรกิจ (เช่น database ใช้งานไม่ได้)
+func transferFailure(err error) (models.TransferFailure, bool) {
+	failure := models.TransferFailure{Reason: err.Error()}
+
+	var ruleErr *RuleViolationError
+	var limitErr *LimitExceededError
+	var fraudErr *FraudBlockedError
+	switch {
+	case errors.As(err, &ruleErr):
+		failure.Code, failure.Rule = ruleErr.Code, &ruleErr.Rule
+	case errors.As(err, &limitErr):
+		failure.Code, failure.Rule = "LIMIT_EXCEEDED", &limitErr.Limit
+	case errors.As(err, &fraudErr):
+		signal := fraudErr.Signal()
+		failure.Code, failure.Rule = fraudErr.Code(), &signal
+	default:
+		failure.Code = TransferErrorCode(err)
+		switch failure.Code {
+		case "INSUFFICIENT_POINTS", "USER_NOT_FOUND", "DUPLICATE_RECIPIENT":
+		default:
+			return failure, false
+		}
 	}
+	return failure, true
+}
+
+// settlementFailure คือเหตุผลที่บันทึกเมื่อ transfer ที่สร้างไว้แล้วโอนไม่สำเร็จ
+func settlementFailure(err error) *models.TransferFailure {
+	failure, ok := transferFailure(err)
+	if !ok {
+		failure.Code = "SETTLEMENT_FAILED"
+	}
+	return &failure
+}
 
-	// ตรวจสอบว่าไม่ได้โอนให้ตัวเอง
-	if req.FromUserID == req.ToUserID {
-		return nil, errors.New("cannot transfer to yourself")
+func (s *TransferService) createTransfer(req models.TransferCreateRequest) (*models.Transfer, error) {
+	// transfer ล่วงหน้า: ตรวจเฉพาะกฎที่ไม่ขึ้นกับเวลา กฎที่เหลือตรวจตอนถึงเวลาโอน
+	if req.ScheduledAt != nil {
+		return s.scheduleTransfer(req)
 	}
 
-	// Validation 3: ห้ามโอนซ้ำกับ user ที่พึ่งโอนไปครั้งล่าสุด
-	lastTransfer, err := s.transferRepo.GetLastTransferFromUser(req.FromUserID)
+	if err := s.validateTransfer(req); err != nil {
+		return nil, err
+	}
+
+	// ค่าธรรมเนียมตาม tier ของผู้โอน ถูกหักเพิ่มจาก amount และรวมในการตรวจแต้มคงเหลือ
+	var err error
+	if req.Fee, err = s.fees.Quote(req.FromUserID, req.Amount); err != nil {
+		return nil, err
+	}
+
+	// คะแนน fraud ถึงเกณฑ์พักรอตรวจ: สร้างเป็น pending พร้อม hold แต้ม และเข้าคิว review
+	assessment, err := s.screenTransfer(req, true)
 	if err != nil {
-		return nil, fmt.Errorf("failed to check last transfer: %w", err)
+		return nil, err
+	}
+	if assessment != nil && assessment.Decision == models.FraudDecisionReview {
+		transfer, err := s.transferRepo.CreatePending(req, s.clock.Now().Add(FraudReviewTTL), assessment)
+		if err != nil {
+			return nil, err
+		}
+		transfer.UnderReview = true
+		return transfer, nil
 	}
 
-	if lastTransfer != nil && lastTransfer.ToUserID == req.ToUserID {
-		return nil, fmt.Errorf("cannot transfer to user %d again - last transfer was also to this user", req.ToUserID)
+	// two-phase: สร้างเป็น pending และ hold แต้มไว้ก่อน
+	if req.TwoPhase {
+		return s.transferRepo.CreatePending(req, s.clock.Now().Add(PendingTransferTTL), nil)
 	}
 
 	// สร้าง transfer
@@ -52,10 +292,273 @@ func (s *TransferService) CreateTransfer(req models.TransferCreateRequest) (*mod
 	if err != nil {
 		return nil, err
 	}
+	s.notifyTransfer(models.WebhookEventTransferCompleted, transfer)
 
 	return transfer, nil
 }
 
+// validateTransfer ตรวจกฎทางธุรกิจทั้งหมดของการโอน: rule ที่ตั้งค่าได้ แล้วจึง limit ของ tier
+func (s *TransferService) validateTransfer(req models.TransferCreateRequest) error {
+	// transfer จากคำสั่งโอนประจำตั้งใจโอนให้คนเดิมทุกรอบ rule จึงแยกกรณีนี้ด้วย source
+	source := RuleSourceTransfer
+	if req.MandateID != nil {
+		source = RuleSourceMandate
+	}
+	if err := s.checkRules(req, source); err != nil {
+		return err
+	}
+
+	// เพดานต่อครั้ง รายวัน และรายเดือน ตาม membership level ของผู้โอน
+	return s.limits.CheckTransfer(req.FromUserID, req.Amount)
+}
+
+// screenTransfer ให้คะแนน fraud ของการโอน คืน FraudBlockedError ถ้าถูก block
+// หรือถ้าต้องพักรอตรวจแต่ canHold = false (การโอนที่พักไว้ไม่ได้ เช่น leg ของ batch)
+// transfer จากคำสั่งโอนประจำถูกตรวจเฉพาะรอบแรก และรอบที่จำนวนหรือผู้รับต่างจากรอบล่าสุดที่โอนสำเร็จ
+func (s *TransferService) screenTransfer(req models.TransferCreateRequest, canHold bool) (*models.FraudAssessment, error) {
+	if s.fraud == nil {
+		return nil, nil
+	}
+	if req.MandateID != nil {
+		repeat, err := s.repeatsMandateRun(req)
+		if err != nil {
+			return nil, err
+		}
+		if repeat {
+			return nil, nil
+		}
+	}
+	assessment, err := s.fraud.Assess(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to assess transfer: %w", err)
+	}
+	switch assessment.Decision {
+	case models.FraudDecisionBlock:
+		return nil, &FraudBlockedError{Assessment: *assessment}
+	case models.FraudDecisionReview:
+		if !canHold {
+			return nil, &FraudBlockedError{Assessment: *assessment, ReviewRequired: true}
+		}
+	}
+	return assessment, nil
+}
+
+// repeatsMandateRun บอกว่ารอบนี้ของ mandate โอนจำนวนเดิมให้ผู้รับเดิมกับรอบล่าสุดที่สำเร็จหรือไม่
+// รอบนั้นผ่านการตรวจ fraud (หรือ admin อนุมัติ) มาแล้ว จึงไม่ต้องตรวจซ้ำทุกรอบ
+func (s *TransferService) repeatsMandateRun(req models.TransferCreateRequest) (bool, error) {
+	last, err := s.transferRepo.GetLastMandateTransfer(*req.MandateID)
+	if err != nil {
+		return false, fmt.Errorf("failed to load last mandate run: %w", err)
+	}
+	return last != nil && last.ToUserID == req.ToUserID && last.Amount == req.Amount, nil
+}
+
+// checkRules ประเมิน transfer rule (จำนวนแต้ม, โอนให้ตัวเอง, โอนซ้ำผู้รับล่าสุด ฯลฯ) กับการโอน
+// หลังตรวจว่าไม่ใช่บัญชี house ซึ่งปิดไม่ได้เหมือน rule ใน database
+func (s *TransferService) checkRules(req models.TransferCreateRequest, source string) error {
+	if err := s.checkHouseAccount(req); err != nil {
+		return err
+	}
+	return s.rules.Evaluate(RuleInput{
+		FromUserID: req.FromUserID,
+		ToUserID:   req.ToUserID,
+		Amount:     req.Amount,
+		Note:       req.Note,
+		Source:     source,
+	})
+}
+
+// checkHouseAccount ปฏิเสธการโอนที่บัญชี house เป็นผู้โอนหรือผู้รับ
+// แต้มของบัญชีนี้เข้าได้ทางค่าธรรมเนียมเท่านั้น (ResolveRecipient ไม่คืนบัญชีนี้ แต่ toUserId ระบุตรงได้)
+func (s *TransferService) checkHouseAccount(req models.TransferCreateRequest) error {
+	houseID, err := s.fees.HouseUserID()
+	if err != nil {
+		return fmt.Errorf("failed to check house account: %w", err)
+	}
+	if houseID != 0 && (req.FromUserID == houseID || req.ToUserID == houseID) {
+		return ErrHouseAccountTransfer
+	}
+	return nil
+}
+
+// replayIdempotent คืน transfer เดิมที่ผู้โอนสร้างด้วย Idempotency-Key นี้ (nil ถ้ายังไม่เคยใช้)
+// key เป็นของผู้โอนแต่ละคน และถ้าพ้น IdempotencyKeyRetention แล้วจะถูกปล่อยให้สร้าง transfer ใหม่ได้
+func (s *TransferService) replayIdempotent(req models.TransferCreateRequest) (*models.Transfer, error) {
+	existing, err := s.transferRepo.GetByClientKey(req.FromUserID, req.IdemKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check idempotency key: %w", err)
+	}
+	if existing == nil {
+		return nil, nil
+	}
+
+	if s.clock.Now().Sub(existing.CreatedAt) > IdempotencyKeyRetention {
+		if err := s.transferRepo.ReleaseClientKey(existing.ID); err != nil {
+			return nil, fmt.Errorf("failed to release idempotency key: %w", err)
+		}
+		return nil, nil
+	}
+	if existing.RequestHash == nil || *existing.RequestHash != req.RequestHash {
+		return nil, ErrIdempotencyKeyMismatch
+	}
+	if err := s.markUnderReview(existing); err != nil {
+		return nil, err
+	}
+
+	return existing, nil
+}
+
+// hashTransferRequest สร้าง fingerprint ของ payload เพื่อตรวจการใช้ key ซ้ำกับ payload อื่น
+func hashTransferRequest(req models.TransferCreateRequest) string {
+	note := ""
+	if req.Note != nil {
+		note = *req.Note
+	}
+	scheduledAt := ""
+	if req.ScheduledAt != nil {
+		scheduledAt = req.ScheduledAt.UTC().Format(time.RFC3339)
+	}
+	payload := fmt.Sprintf("%d|%d|%s|%s|%t|%s", req.FromUserID, req.ToUserID, req.Amount, note, req.TwoPhase, scheduledAt)
+	sum := sha256.Sum256([]byte(payload))
+	return hex.EncodeToString(sum[:])
+}
+
+// ConfirmTransfer ยืนยัน transfer ที่ pending: pending → processing → completed
+// ถ้าขั้น settle ล้มเหลว transfer จะถูกเปลี่ยนเป็น failed พร้อม fail_reason
+func (s *TransferService) ConfirmTransfer(idemKey string) (*models.Transfer, error) {
+	transfer, err := s.GetTransferByIdemKey(idemKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(transfer.Status, models.TransferStatusProcessing); err != nil {
+		return nil, err
+	}
+	if transfer.ScheduledAt != nil {
+		return nil, fmt.Errorf("%w: scheduled transfers are executed automatically", ErrIllegalTransition)
+	}
+	if transfer.UnderReview {
+		return nil, fmt.Errorf("%w: transfer is held for fraud review", ErrIllegalTransition)
+	}
+
+	// hold หมดอายุแล้วแต่ sweeper ยังไม่ได้ปล่อย ให้ยกเลิกเลย
+	if transfer.ExpiresAt != nil && !s.clock.Now().Before(*transfer.ExpiresAt) {
+		if err := s.expireTransfer(*transfer); err != nil {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: pending transfer has expired", ErrIllegalTransition)
+	}
+
+	return s.settlePending(*transfer)
+}
+
+// settlePending โอน transfer ที่ pending และ hold แต้มไว้แล้ว: pending → processing → completed
+func (s *TransferService) settlePending(transfer models.Transfer) (*models.Transfer, error) {
+	if err := s.transferRepo.TransitionStatus(transfer.ID, models.TransferStatusPending, models.TransferStatusProcessing, nil); err != nil {
+		return nil, err
+	}
+
+	completed, err := s.transferRepo.Settle(transfer.ID)
+	if err != nil {
+		failure := settlementFailure(err)
+		if failErr := s.transferRepo.TransitionStatus(transfer.ID, models.TransferStatusProcessing, models.TransferStatusFailed, failure); failErr != nil {
+			return nil, fmt.Errorf("failed to settle transfer: %v (marking failed: %w)", err, failErr)
+		}
+		s.notifyFailedByIdemKey(transfer.IdemKey)
+		return nil, err
+	}
+	s.notifyTransfer(models.WebhookEventTransferCompleted, completed)
+
+	return completed, nil
+}
+
+// CancelTransfer ยกเลิก transfer ที่ยัง pending และปล่อย hold
+func (s *TransferService) CancelTransfer(idemKey string) (*models.Transfer, error) {
+	transfer, err := s.GetTransferByIdemKey(idemKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := ValidateTransition(transfer.Status, models.TransferStatusCancelled); err != nil {
+		return nil, err
+	}
+
+	if err := s.transferRepo.TransitionStatus(transfer.ID, models.TransferStatusPending, models.TransferStatusCancelled, nil); err != nil {
+		return nil, err
+	}
+
+	return s.transferRepo.GetByIdemKey(idemKey)
+}
+
+// ExpirePendingTransfers ยกเลิก transfer pending ที่หมดเวลาแล้ว คืนจำนวนที่ยกเลิกได้
+func (s *TransferService) ExpirePendingTransfers(now time.Time) (int, error) {
+	expired, err := s.transferRepo.ListExpiredPending(now, 100)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, transfer := range expired {
+		if err := s.expireTransfer(transfer); err != nil {
+			// ถูก confirm หรือ cancel ไปพร้อมกัน ข้ามไป
+			if errors.Is(err, repositories.ErrStatusConflict) {
+				continue
+			}
+			return count, err
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// StartExpirySweeper รัน ExpirePendingTransfers เป็นระยะใน background จนกว่า stop จะถูกปิด
+func (s *TransferService) StartExpirySweeper(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				if n, err := s.ExpirePendingTransfers(s.clock.Now()); err != nil {
+					log.Printf("Failed to expire pending transfers: %v", err)
+				} else if n > 0 {
+					log.Printf("Expired %d pending transfers", n)
+				}
+			}
+		}
+	}()
+}
+
+func (s *TransferService) expireTransfer(transfer models.Transfer) error {
+	return s.transferRepo.TransitionStatus(transfer.ID, models.TransferStatusPending, models.TransferStatusCancelled,
+		&models.TransferFailure{Code: "HOLD_EXPIRED", Reason: "hold expired before confirmation"})
+}
+
+// ReverseTransfer ยกเลิก transfer ที่ completed แล้วโดยโอนแต้มคืนผู้โอน
+func (s *TransferService) ReverseTransfer(idemKey string, req models.TransferReverseRequest) (*models.Transfer, error) {
+	if idemKey == "" {
+		return nil, errors.New("idempotency key is required")
+	}
+	req.Reason = strings.TrimSpace(req.Reason)
+	if req.Reason == "" {
+		return nil, errors.New("reversal reason is required")
+	}
+	if len(req.Reason) > 512 {
+		return nil, errors.New("reversal reason cannot exceed 512 characters")
+	}
+
+	reversed, err := s.transferRepo.Reverse(idemKey, req.Reason, s.reversalPolicy == ReversalPolicyAllowNegative, nil)
+	if err != nil {
+		return nil, err
+	}
+	s.notifyTransfer(models.WebhookEventTransferReversed, reversed)
+	return reversed, nil
+}
+
 func (s *TransferService) GetTransferByIdemKey(idemKey string) (*models.Transfer, error) {
 	if idemKey == "" {
 		return nil, errors.New("idempotency key is required")
@@ -69,35 +572,141 @@ func (s *TransferService) GetTransferByIdemKey(idemKey string) (*models.Transfer
 	if transfer == nil {
 		return nil, errors.New("transfer not found")
 	}
+	if err := s.markUnderReview(transfer); err != nil {
+		return nil, err
+	}
+	if s.disputes != nil {
+		annotated := []models.Transfer{*transfer}
+		s.disputes.annotate(annotated)
+		transfer = &annotated[0]
+	}
 
 	return transfer, nil
 }
 
-func (s *TransferService) GetTransfersByUserID(userID, page, pageSize int) (*models.TransferListResponse, error) {
-	if userID <= 0 {
-		return nil, errors.New("invalid user ID")
+// markUnderReview ตั้ง UnderReview ของ transfer pending ที่ถูกพักรอตรวจ fraud
+func (s *TransferService) markUnderReview(transfer *models.Transfer) error {
+	if s.fraud == nil || transfer.Status != models.TransferStatusPending || transfer.ScheduledAt != nil {
+		return nil
+	}
+	underReview, err := s.fraud.IsUnderReview(transfer.ID)
+	if err != nil {
+		return err
+	}
+	transfer.UnderReview = underReview
+	return nil
+}
+
+// GetTransfers ค้นประวัติการโอนของ user ตามเงื่อนไขใน q
+// หน้าแรกใช้ page/pageSize ได้เหมือนเดิม ทุกหน้ามี nextCursor สำหรับขอหน้าถัดไปแบบ keyset
+// ซึ่งไม่เลื่อนเมื่อมี transfer ใหม่เข้ามาระหว่างเปิดดู
+func (s *TransferService) GetTransfers(q models.TransferQuery) (*models.TransferListResponse, error) {
+	if q.UserID <= 0 {
+		return nil, invalidQuery("invalid user ID")
+	}
+	if q.Status != "" && !isTransferStatus(q.Status) {
+		return nil, invalidQuery("unknown status %q", q.Status)
+	}
+	switch q.Direction {
+	case models.TransferDirectionAll, models.TransferDirectionSent, models.TransferDirectionReceived:
+	default:
+		return nil, invalidQuery("unknown direction %q", q.Direction)
+	}
+	if q.Sort == "" {
+		q.Sort = models.TransferSortNewest
+	}
+	switch q.Sort {
+	case models.TransferSortNewest, models.TransferSortOldest, models.TransferSortAmountDesc, models.TransferSortAmountAsc:
+	default:
+		return nil, invalidQuery("unknown sort %q", q.Sort)
+	}
+	if q.CounterpartyID < 0 {
+		return nil, invalidQuery("invalid counterpartyId")
+	}
+	if q.CreatedFrom != nil && q.CreatedTo != nil && !q.CreatedFrom.Before(*q.CreatedTo) {
+		return nil, invalidQuery("createdFrom must be before createdTo")
+	}
+	if q.MinAmount != nil && q.MaxAmount != nil && *q.MinAmount > *q.MaxAmount {
+		return nil, invalidQuery("minAmount cannot exceed maxAmount")
 	}
 
-	if page < 1 {
-		page = 1
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.PageSize < 1 || q.PageSize > 200 {
+		q.PageSize = 20
 	}
-	if pageSize < 1 || pageSize > 200 {
-		pageSize = 20
+
+	var after *models.TransferCursor
+	if q.Cursor != "" {
+		cursor, err := decodeTransferCursor(q.Cursor)
+		if err != nil {
+			return nil, invalidQuery("invalid cursor")
+		}
+		if cursor.Sort != q.Sort {
+			return nil, invalidQuery("cursor was issued for a different sort")
+		}
+		after = cursor
 	}
 
-	transfers, total, err := s.transferRepo.GetByUserID(userID, page, pageSize)
+	// ดึงเกินมาหนึ่งรายการเพื่อรู้ว่ามีหน้าถัดไปหรือไม่ โดยไม่ต้อง COUNT(*)
+	transfers, err := s.transferRepo.Search(q, after, q.PageSize+1, (q.Page-1)*q.PageSize)
 	if err != nil {
 		return nil, err
 	}
 
+	response := &models.TransferListResponse{PageSize: q.PageSize}
+	if after == nil {
+		response.Page = q.Page
+	}
+	if len(transfers) > q.PageSize {
+		transfers = transfers[:q.PageSize]
+		last := transfers[len(transfers)-1]
+		response.NextCursor = encodeTransferCursor(models.TransferCursor{
+			Sort:      q.Sort,
+			CreatedAt: last.CreatedAt,
+			Amount:    last.Amount,
+			ID:        last.ID,
+		})
+	}
 	if transfers == nil {
 		transfers = []models.Transfer{}
 	}
+	if s.disputes != nil {
+		s.disputes.annotate(transfers)
+	}
+	response.Data = transfers
+
+	// total นับเมื่อขอ หรือเป็นค่าเริ่มต้นของแบบ offset เพื่อให้ client เดิมใช้ได้
+	if (q.IncludeTotal == nil && after == nil) || (q.IncludeTotal != nil && *q.IncludeTotal) {
+		total, err := s.transferRepo.Count(q)
+		if err != nil {
+			return nil, err
+		}
+		response.Total = &total
+	}
+
+	return response, nil
+}
+
+func invalidQuery(format string, args ...interface{}) error {
+	return fmt.Errorf("%w: %s", ErrInvalidTransferQuery, fmt.Sprintf(format, args...))
+}
+
+// encodeTransferCursor แปลง cursor เป็น string ทึบที่ client ส่งกลับมาได้ตรงๆ
+func encodeTransferCursor(cursor models.TransferCursor) string {
+	data, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
 
-	return &models.TransferListResponse{
-		Data:     transfers,
-		Page:     page,
-		PageSize: pageSize,
-		Total:    total,
-	}, nil
+func decodeTransferCursor(s string) (*models.TransferCursor, error) {
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, errors.New("invalid cursor")
+	}
+	var cursor models.TransferCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || cursor.ID <= 0 {
+		return nil, errors.New("invalid cursor")
+	}
+	return &cursor, nil
 }
@@ -0,0 +1,274 @@
+package services
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/testutil"
+)
+
+func TestCreateTransferConcurrentSameIdempotencyKey(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUser(t, db, 100*models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	service := newTestTransferService(t, db)
+
+	const requests = 50
+	req := models.TransferCreateRequest{
+		FromUserID: sender.ID,
+		ToUserID:   receiver.ID,
+		Amount:     models.PointsScale,
+		IdemKey:    "concurrent-key-1",
+	}
+
+	results := make([]*models.Transfer, requests)
+	errs := make([]error, requests)
+	var wg sync.WaitGroup
+	start := make(chan struct{})
+	for i := 0; i < requests; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			<-start
+			results[i], errs[i] = service.CreateTransfer(req)
+		}(i)
+	}
+	close(start)
+	wg.Wait()
+
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("request %d: %v", i, errs[i])
+		}
+		if results[i].ID != results[0].ID || results[i].IdemKey != results[0].IdemKey ||
+			results[i].Status != results[0].Status || results[i].Amount != results[0].Amount {
+			t.Fatalf("request %d got transfer %d (%s), want %d (%s)",
+				i, results[i].ID, results[i].IdemKey, results[0].ID, results[0].IdemKey)
+		}
+	}
+
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM transfers WHERE from_user_id = ?", sender.ID).Scan(&count); err != nil {
+		t.Fatal(err)
+	}
+	if count != 1 {
+		t.Fatalf("got %d transfers, want 1", count)
+	}
+	if got, want := testutil.UserPoints(t, db, sender.ID), 99*models.PointsScale; got != want {
+		t.Fatalf("sender has %s points, want %s", got, want)
+	}
+	if got, want := testutil.UserPoints(t, db, receiver.ID), models.PointsScale; got != want {
+		t.Fatalf("receiver has %s points, want %s", got, want)
+	}
+}
+
+func TestCreateTransferIdempotencyKeyScopedToSender(t *testing.T) {
+	db := testutil.NewDB(t)
+	alice := testutil.NewUser(t, db, 10*models.PointsScale)
+	bob := testutil.NewUser(t, db, 10*models.PointsScale)
+	carol := testutil.NewUser(t, db, 0)
+	service := newTestTransferService(t, db)
+
+	first, err := service.CreateTransfer(models.TransferCreateRequest{
+		FromUserID: alice.ID, ToUserID: carol.ID, Amount: models.PointsScale, IdemKey: "shared-key-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := service.CreateTransfer(models.TransferCreateRequest{
+		FromUserID: bob.ID, ToUserID: carol.ID, Amount: models.PointsScale, IdemKey: "shared-key-1",
+	})
+	if err != nil {
+		t.Fatalf("same key from another sender: %v", err)
+	}
+	if first.ID == second.ID {
+		t.Fatalf("both senders got transfer %d", first.ID)
+	}
+
+	_, err = service.CreateTransfer(models.TransferCreateRequest{
+		FromUserID: alice.ID, ToUserID: carol.ID, Amount: 2 * models.PointsScale, IdemKey: "shared-key-1",
+	})
+	if !errors.Is(err, ErrIdempotencyKeyMismatch) {
+		t.Fatalf("reused key with another payload: got %v, want %v", err, ErrIdempotencyKeyMismatch)
+	}
+}
+
+func TestCreateTransferReleasesIdempotencyKeyAfterRetention(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUser(t, db, 10*models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	service := newTestTransferService(t, db, "repeat_recipient")
+	clock := NewFixedClock(time.Now())
+	service.SetClock(clock)
+
+	req := models.TransferCreateRequest{
+		FromUserID: sender.ID, ToUserID: receiver.ID, Amount: models.PointsScale, IdemKey: "retained-key-1",
+	}
+	first, err := service.CreateTransfer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	clock.Advance(IdempotencyKeyRetention - time.Minute)
+	replay, err := service.CreateTransfer(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.ID != first.ID {
+		t.Fatalf("replay within retention created transfer %d, want %d", replay.ID, first.ID)
+	}
+
+	clock.Advance(2 * time.Minute)
+	second, err := service.CreateTransfer(req)
+	if err != nil {
+		t.Fatalf("reuse after retention: %v", err)
+	}
+	if second.ID == first.ID {
+		t.Fatal("key was not released after retention")
+	}
+
+	released, err := service.GetTransferByIdemKey(first.IdemKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if released.ClientKey != nil {
+		t.Fatalf("old transfer still holds client key %q", *released.ClientKey)
+	}
+	if got, want := testutil.UserPoints(t, db, sender.ID), 8*models.PointsScale; got != want {
+		t.Fatalf("sender has %s points, want %s", got, want)
+	}
+}
+
+func TestHouseAccountCannotSendOrReceive(t *testing.T) {
+	db := testutil.NewDB(t)
+	user := testutil.NewUser(t, db, 10*models.PointsScale)
+	service := newTestTransferService(t, db)
+	houseID, err := service.fees.HouseUserID()
+	if err != nil || houseID == 0 {
+		t.Fatalf("house account id %d (err %v)", houseID, err)
+	}
+
+	for _, req := range []models.TransferCreateRequest{
+		{FromUserID: user.ID, ToUserID: houseID, Amount: models.PointsScale},
+		{FromUserID: houseID, ToUserID: user.ID, Amount: models.PointsScale},
+	} {
+		if _, err := service.CreateTransfer(req); !errors.Is(err, ErrHouseAccountTransfer) {
+			t.Fatalf("transfer %d -> %d: got %v, want %v", req.FromUserID, req.ToUserID, err, ErrHouseAccountTransfer)
+		}
+
+		quote, err := service.QuoteTransfer(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if quote.Allowed || len(quote.Violations) == 0 || quote.Violations[0].Code != "HOUSE_ACCOUNT" {
+			t.Fatalf("quote %d -> %d allowed %v with violations %+v", req.FromUserID, req.ToUserID, quote.Allowed, quote.Violations)
+		}
+	}
+
+	batch, err := service.BatchTransfer(models.BatchTransferRequest{
+		FromUserID: user.ID,
+		Mode:       models.BatchModeBestEffort,
+		Legs:       []models.BatchTransferLeg{{ToUserID: houseID, Amount: models.PointsScale}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if leg := batch.Legs[0]; leg.Status != models.BatchLegStatusFailed || leg.ErrorCode != "HOUSE_ACCOUNT" {
+		t.Fatalf("batch leg to the house account is %s (%s)", leg.Status, leg.ErrorCode)
+	}
+
+	if got := testutil.UserPoints(t, db, user.ID); got != 10*models.PointsScale {
+		t.Fatalf("user has %s points, want 10.00", got)
+	}
+	if got := testutil.UserPoints(t, db, houseID); got != 0 {
+		t.Fatalf("house account has %s points, want 0", got)
+	}
+}
+
+func TestRejectedTransferRecordsFee(t *testing.T) {
+	db := testutil.NewDB(t)
+	sender := testutil.NewUserAtLevel(t, db, "Silver", models.PointsScale)
+	receiver := testutil.NewUser(t, db, 0)
+	service := newTestTransferService(t, db)
+
+	amount := 2 * models.PointsScale
+	fee, err := service.fees.Quote(sender.ID, amount)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fee == 0 {
+		t.Fatal("Silver transfers have no fee; the test needs a fee-bearing tier")
+	}
+
+	// แต้มไม่พอ: ถูกปฏิเสธหลังคิดค่าธรรมเนียม / โอนให้ตัวเอง: ถูกปฏิเสธโดย rule ก่อนคิดค่าธรรมเนียม
+	for _, to := range []int{receiver.ID, sender.ID} {
+		if _, err := service.CreateTransfer(models.TransferCreateRequest{
+			FromUserID: sender.ID, ToUserID: to, Amount: amount,
+		}); err == nil {
+			t.Fatalf("transfer to %d succeeded", to)
+		}
+		var code string
+		var recorded models.Points
+		if err := db.QueryRow(`
+			SELECT fail_code, fee FROM transfers
+			WHERE from_user_id = ? AND to_user_id = ? AND status = 'failed'`, sender.ID, to).Scan(&code, &recorded); err != nil {
+			t.Fatalf("failed transfer to %d was not recorded: %v", to, err)
+		}
+		if recorded != fee {
+			t.Fatalf("failed transfer (%s) recorded fee %s, want %s", code, recorded, fee)
+		}
+	}
+}
+
+// TestPendingTransferExpiryFollowsClockInAnyTimeZone: expires_at ถูกเทียบแบบ string ใน SQLite
+// ถ้าเก็บเป็นเวลาท้องถิ่นแต่เทียบกับเวลาใน zone อื่น transfer จะหมดอายุช้าหรือเร็วไปเท่ากับ offset
+// จึงตรวจด้วยเวลาเดียวกันหลาย zone: ผลต้องขึ้นกับเวลาเท่านั้น
+func TestPendingTransferExpiryFollowsClockInAnyTimeZone(t *testing.T) {
+	for _, zone := range []*time.Location{time.FixedZone("ICT", 7*60*60), time.FixedZone("PST", -8*60*60), time.UTC} {
+		t.Run(zone.String(), func(t *testing.T) {
+			local := time.Local
+			time.Local = zone
+			defer func() { time.Local = local }()
+
+			db := testutil.NewDB(t)
+			sender := testutil.NewUser(t, db, 10*models.PointsScale)
+			receiver := testutil.NewUser(t, db, 0)
+			service := newTestTransferService(t, db)
+			clock := NewFixedClock(time.Now())
+			service.SetClock(clock)
+
+			transfer, err := service.CreateTransfer(models.TransferCreateRequest{
+				FromUserID: sender.ID, ToUserID: receiver.ID, Amount: models.PointsScale, TwoPhase: true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			clock.Advance(PendingTransferTTL - time.Minute)
+			now := clock.Now()
+			for _, at := range []time.Time{now, now.UTC(), now.In(time.FixedZone("JST", 9*60*60)), now.In(time.FixedZone("EST", -5*60*60))} {
+				if n, err := service.ExpirePendingTransfers(at); err != nil || n != 0 {
+					t.Fatalf("expired %d transfers before their expiry at %v (err %v)", n, at, err)
+				}
+			}
+
+			clock.Advance(2 * time.Minute)
+			if n, err := service.ExpirePendingTransfers(clock.Now().In(time.FixedZone("EST", -5*60*60))); err != nil || n != 1 {
+				t.Fatalf("expired %d transfers after their expiry (err %v), want 1", n, err)
+			}
+			expired, err := service.GetTransferByIdemKey(transfer.IdemKey)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if expired.Status != models.TransferStatusCancelled {
+				t.Fatalf("expired transfer is %s, want cancelled", expired.Status)
+			}
+			if got := testutil.UserPoints(t, db, sender.ID); got != 10*models.PointsScale {
+				t.Fatalf("sender has %s points after the transfer expired, want 10.00", got)
+			}
+		})
+	}
+}
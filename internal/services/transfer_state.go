@@ -0,0 +1,58 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+
+	"kbtg-backend/internal/models"
+)
+
+// ErrIllegalTransition ถูกคืนเมื่อพยายามเปลี่ยนสถานะ transfer ที่ไม่อนุญาต
+var ErrIllegalTransition = errors.New("illegal transfer status transition")
+
+// transferTransitions คือ state machine ของ transfer
+//
+//	pending → processing → completed → reversed
+//	   ↓           ↓
+//	cancelled    failed
+var transferTransitions = map[models.TransferStatus][]models.TransferStatus{
+	models.TransferStatusPending: {
+		models.TransferStatusProcessing,
+		models.TransferStatusCancelled,
+	},
+	models.TransferStatusProcessing: {
+		models.TransferStatusCompleted,
+		models.TransferStatusFailed,
+	},
+	models.TransferStatusCompleted: {
+		models.TransferStatusReversed,
+	},
+}
+
+// CanTransition บอกว่าเปลี่ยนจากสถานะ from ไป to ได้หรือไม่
+func CanTransition(from, to models.TransferStatus) bool {
+	for _, next := range transferTransitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// ValidateTransition คืน ErrIllegalTransition ถ้าเปลี่ยนสถานะไม่ได้
+func ValidateTransition(from, to models.TransferStatus) error {
+	if !CanTransition(from, to) {
+		return fmt.Errorf("%w: %s → %s", ErrIllegalTransition, from, to)
+	}
+	return nil
+}
+
+// isTransferStatus บอกว่า status เป็นสถานะ transfer ที่รู้จักหรือไม่
+func isTransferStatus(status models.TransferStatus) bool {
+	switch status {
+	case models.TransferStatusPending, models.TransferStatusProcessing, models.TransferStatusCompleted,
+		models.TransferStatusFailed, models.TransferStatusCancelled, models.TransferStatusReversed:
+		return true
+	}
+	return false
+}
@@ -2,16 +2,38 @@ package services
 
 import (
 	"errors"
+	"strings"
+	"unicode"
+
 	"kbtg-backend/internal/models"
 	"kbtg-backend/internal/repositories"
 )
 
+var (
+	// ErrRecipientNotFound ถูกคืนเมื่อไม่มีสมาชิกที่ตรงกับ member id, เบอร์โทร หรือ email ที่ให้มา
+	ErrRecipientNotFound = errors.New("recipient not found")
+	// ErrRecipientAmbiguous ถูกคืนเมื่อข้อมูลที่ให้มาตรงกับสมาชิกมากกว่าหนึ่งคน (เช่น เบอร์โทรที่ใช้ร่วมกัน)
+	ErrRecipientAmbiguous = errors.New("recipient identifier matches more than one member")
+)
+
 type UserService struct {
-	repo *repositories.UserRepository
+	repo     *repositories.UserRepository
+	webhooks *WebhookService
+	clock    Clock
 }
 
 func NewUserService(repo *repositories.UserRepository) *UserService {
-	return &UserService{repo: repo}
+	return &UserService{repo: repo, clock: SystemClock{}}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้ตัดสินว่า hold ไหนหมดอายุแล้วตอนคำนวณแต้มที่ใช้ได้
+func (s *UserService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetWebhooks ตั้ง WebhookService ที่รับ event user.updated (ไม่ตั้งก็ได้)
+func (s *UserService) SetWebhooks(webhooks *WebhookService) {
+	s.webhooks = webhooks
 }
 
 func (s *UserService) GetAllUsers() ([]models.User, error) {
@@ -25,6 +47,13 @@ func (s *UserService) GetUserByID(id int) (*models.User, error) {
 	return s.repo.GetByID(id)
 }
 
+func (s *UserService) GetBalance(id int) (*models.UserBalance, error) {
+	if id <= 0 {
+		return nil, errors.New("invalid user ID")
+	}
+	return s.repo.GetBalance(id, s.clock.Now())
+}
+
 func (s *UserService) CreateUser(req models.CreateUserRequest) (*models.User, error) {
 	// Validate business rules
 	if len(req.FirstName) > 3 {
@@ -68,7 +97,14 @@ func (s *UserService) UpdateUser(id int, req models.UpdateUserRequest) (*models.
 		return nil, errors.New("points cannot be negative")
 	}
 
-	return s.repo.Update(id, req)
+	user, err := s.repo.Update(id, req)
+	if err != nil {
+		return nil, err
+	}
+	if user != nil {
+		s.webhooks.Publish(models.WebhookEventUserUpdated, user)
+	}
+	return user, nil
 }
 
 func (s *UserService) DeleteUser(id int) error {
@@ -77,3 +113,102 @@ func (s *UserService) DeleteUser(id int) error {
 	}
 	return s.repo.Delete(id)
 }
+
+// ResolveRecipient หาสมาชิกจาก member id, เบอร์โทร หรือ email (ต้องระบุอย่างเดียว)
+// คืน user และชื่อ field ที่ใช้หา
+func (s *UserService) ResolveRecipient(lookup models.RecipientLookup) (*models.User, string, error) {
+	memberID := strings.TrimSpace(lookup.MemberID)
+	phone := strings.TrimSpace(lookup.Phone)
+	email := strings.TrimSpace(lookup.Email)
+
+	given := 0
+	for _, v := range []string{memberID, phone, email} {
+		if v != "" {
+			given++
+		}
+	}
+	if given != 1 {
+		return nil, "", errors.New("exactly one of memberId, phone or email is required")
+	}
+
+	var (
+		users     []models.User
+		matchedBy string
+		err       error
+	)
+	switch {
+	case memberID != "":
+		matchedBy = "memberId"
+		users, err = s.repo.FindByMemberID(memberID)
+	case phone != "":
+		matchedBy = "phone"
+		local, ok := normalizePhone(phone)
+		if !ok {
+			return nil, "", errors.New("phone must be a Thai number with 9 or 10 digits")
+		}
+		users, err = s.repo.FindByPhone(local, "66"+local[1:])
+	default:
+		matchedBy = "email"
+		if !strings.Contains(email, "@") {
+			return nil, "", errors.New("email is not valid")
+		}
+		users, err = s.repo.FindByEmail(email)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch len(users) {
+	case 0:
+		return nil, "", ErrRecipientNotFound
+	case 1:
+		return &users[0], matchedBy, nil
+	default:
+		return nil, "", ErrRecipientAmbiguous
+	}
+}
+
+// ResolveDisplay หาสมาชิกแบบ ResolveRecipient แล้วคืนชื่อที่ปิดนามสกุลไว้ ให้ผู้โอนยืนยันก่อนส่ง
+func (s *UserService) ResolveDisplay(lookup models.RecipientLookup) (*models.ResolvedRecipient, error) {
+	user, matchedBy, err := s.ResolveRecipient(lookup)
+	if err != nil {
+		return nil, err
+	}
+	return &models.ResolvedRecipient{
+		MemberID:    user.MemberID,
+		DisplayName: maskName(user.FirstName, user.LastName),
+		MatchedBy:   matchedBy,
+	}, nil
+}
+
+// normalizePhone เหลือเฉพาะตัวเลขและแปลงรหัสประเทศ 66 เป็น 0 เช่น "+66 81-234-5678" → "0812345678"
+func normalizePhone(phone string) (string, bool) {
+	digits := strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		if unicode.IsSpace(r) || strings.ContainsRune("-+().", r) {
+			return -1
+		}
+		return 'x'
+	}, phone)
+	if strings.ContainsRune(digits, 'x') {
+		return "", false
+	}
+	if strings.HasPrefix(digits, "66") && (len(digits) == 10 || len(digits) == 11) {
+		digits = "0" + digits[2:]
+	}
+	if !strings.HasPrefix(digits, "0") || len(digits) < 9 || len(digits) > 10 {
+		return "", false
+	}
+	return digits, true
+}
+
+// maskName แสดงชื่อจริงเต็มและนามสกุลเฉพาะตัวอักษรแรก เช่น "สมชาย ใ***"
+func maskName(firstName, lastName string) string {
+	last := []rune(strings.TrimSpace(lastName))
+	if len(last) == 0 {
+		return firstName
+	}
+	return firstName + " " + string(last[0]) + "***"
+}
@@ -0,0 +1,508 @@
+package services
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+
+	"github.com/google/uuid"
+)
+
+// WebhookMaxAttempts คือจำนวนครั้งที่ส่ง delivery หนึ่งก่อนเลิกและเปลี่ยนเป็น failed
+const WebhookMaxAttempts = 8
+
+// webhookRetryBase และ webhookRetryMax กำหนด exponential backoff: 30s, 1m, 2m, ... สูงสุด 6 ชั่วโมง
+const (
+	webhookRetryBase = 30 * time.Second
+	webhookRetryMax  = 6 * time.Hour
+)
+
+// header ที่ส่งไปกับทุก delivery
+const (
+	WebhookHeaderEvent     = "X-Webhook-Event"
+	WebhookHeaderEventID   = "X-Webhook-Id"
+	WebhookHeaderDelivery  = "X-Webhook-Delivery"
+	WebhookHeaderTimestamp = "X-Webhook-Timestamp"
+	WebhookHeaderSignature = "X-Webhook-Signature"
+)
+
+var (
+	ErrWebhookSubscriptionNotFound = errors.New("webhook subscription not found")
+	ErrWebhookDeliveryNotFound     = errors.New("webhook delivery not found")
+)
+
+// WebhookService เก็บ event ลง webhook_deliveries ทันทีที่เกิด แล้วให้ dispatcher ใน background ส่งและ retry
+// การเกิด event จึงไม่รอ receiver และ event ไม่หายถ้า receiver ล่ม
+type WebhookService struct {
+	repo   *repositories.WebhookRepository
+	client *http.Client
+	clock  Clock
+	// wake ปลุก dispatcher ให้ส่งทันทีเมื่อมี delivery ใหม่ แทนการรอรอบถัดไป
+	wake chan struct{}
+}
+
+func NewWebhookService(repo *repositories.WebhookRepository) *WebhookService {
+	return &WebhookService{
+		repo:   repo,
+		client: &http.Client{Timeout: 10 * time.Second},
+		clock:  SystemClock{},
+		wake:   make(chan struct{}, 1),
+	}
+}
+
+// SetClock เปลี่ยนนาฬิกาที่ใช้ตัดสินเวลา retry
+func (s *WebhookService) SetClock(clock Clock) {
+	s.clock = clock
+}
+
+// SetHTTPClient เปลี่ยน client ที่ใช้ส่ง (เช่น ชี้ไป receiver จำลองตอนทดสอบ)
+func (s *WebhookService) SetHTTPClient(client *http.Client) {
+	s.client = client
+}
+
+// SignWebhookPayload คืนค่า X-Webhook-Signature ของ body: "sha256=" + hex(HMAC-SHA256(secret, "<timestamp>.<body>"))
+// receiver คำนวณค่าเดียวกันจาก X-Webhook-Timestamp และ body ที่ได้รับเพื่อยืนยันว่ามาจากระบบนี้
+func SignWebhookPayload(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookRetryDelay คือเวลารอก่อนส่งครั้งถัดไป หลังส่งไม่สำเร็จมาแล้ว attempts ครั้ง
+func webhookRetryDelay(attempts int) time.Duration {
+	delay := webhookRetryBase
+	for i := 1; i < attempts && delay < webhookRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > webhookRetryMax {
+		delay = webhookRetryMax
+	}
+	return delay
+}
+
+// Publish สร้าง delivery ของ event ให้ทุก subscription ที่ active และรับ event นี้
+// error ถูก log ไว้ ไม่ทำให้งานที่เกิด event ล้มเหลว; เรียกบน nil ได้ (ไม่ได้ตั้ง webhook)
+func (s *WebhookService) Publish(eventType string, data interface{}) {
+	if s == nil {
+		return
+	}
+	if err := s.publish(eventType, data); err != nil {
+		log.Printf("Failed to publish webhook event %s: %v", eventType, err)
+	}
+}
+
+func (s *WebhookService) publish(eventType string, data interface{}) error {
+	subs, err := s.repo.ListSubscriptions(true)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for _, sub := range subs {
+		if subscribesTo(sub, eventType) {
+			ids = append(ids, sub.ID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	return s.enqueue(eventType, data, ids)
+}
+
+func (s *WebhookService) enqueue(eventType string, data interface{}, subscriptionIDs []int) error {
+	event := models.WebhookEvent{
+		ID:        uuid.New().String(),
+		Type:      eventType,
+		CreatedAt: s.clock.Now(),
+		Data:      data,
+	}
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.CreateDeliveries(event, payload, subscriptionIDs); err != nil {
+		return err
+	}
+	s.notify()
+	return nil
+}
+
+func (s *WebhookService) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func subscribesTo(sub models.WebhookSubscription, eventType string) bool {
+	for _, t := range sub.EventTypes {
+		if t == eventType || t == models.WebhookEventAll {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *WebhookService) CreateSubscription(req models.WebhookSubscriptionCreateRequest) (*models.WebhookSubscription, error) {
+	sub := &models.WebhookSubscription{IsActive: true}
+	if err := applyWebhookURL(sub, req.URL); err != nil {
+		return nil, err
+	}
+	if err := applyWebhookEventTypes(sub, req.EventTypes); err != nil {
+		return nil, err
+	}
+	if err := applyWebhookDescription(sub, req.Description); err != nil {
+		return nil, err
+	}
+
+	if req.Secret != nil {
+		if len(*req.Secret) < 16 || len(*req.Secret) > 128 {
+			return nil, errors.New("secret must be between 16 and 128 characters")
+		}
+		sub.Secret = *req.Secret
+	} else {
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			return nil, err
+		}
+		sub.Secret = secret
+	}
+
+	if err := s.repo.CreateSubscription(sub); err != nil {
+		return nil, err
+	}
+	// secret แสดงครั้งเดียวตอนสร้าง
+	return sub, nil
+}
+
+func (s *WebhookService) GetSubscriptions() (*models.WebhookSubscriptionListResponse, error) {
+	subs, err := s.repo.ListSubscriptions(false)
+	if err != nil {
+		return nil, err
+	}
+	if subs == nil {
+		subs = []models.WebhookSubscription{}
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return &models.WebhookSubscriptionListResponse{Data: subs, EventTypes: models.WebhookEventTypes}, nil
+}
+
+func (s *WebhookService) GetSubscription(id int) (*models.WebhookSubscription, error) {
+	sub, err := s.subscription(id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+func (s *WebhookService) subscription(id int) (*models.WebhookSubscription, error) {
+	if id <= 0 {
+		return nil, ErrWebhookSubscriptionNotFound
+	}
+	sub, err := s.repo.GetSubscription(id)
+	if err != nil {
+		return nil, err
+	}
+	if sub == nil {
+		return nil, ErrWebhookSubscriptionNotFound
+	}
+	return sub, nil
+}
+
+func (s *WebhookService) UpdateSubscription(id int, req models.WebhookSubscriptionUpdateRequest) (*models.WebhookSubscription, error) {
+	sub, err := s.subscription(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		if err := applyWebhookURL(sub, *req.URL); err != nil {
+			return nil, err
+		}
+	}
+	if req.EventTypes != nil {
+		if err := applyWebhookEventTypes(sub, req.EventTypes); err != nil {
+			return nil, err
+		}
+	}
+	if req.Description != nil {
+		if err := applyWebhookDescription(sub, req.Description); err != nil {
+			return nil, err
+		}
+	}
+	if req.IsActive != nil {
+		sub.IsActive = *req.IsActive
+	}
+
+	if err := s.repo.UpdateSubscription(sub); err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+func (s *WebhookService) DeleteSubscription(id int) error {
+	ok, err := s.repo.DeleteSubscription(id)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrWebhookSubscriptionNotFound
+	}
+	return nil
+}
+
+// Ping ส่ง event webhook.ping ไปยัง subscription เดียว เพื่อทดสอบ receiver และการตรวจ signature
+func (s *WebhookService) Ping(id int) error {
+	sub, err := s.subscription(id)
+	if err != nil {
+		return err
+	}
+	data := map[string]interface{}{"subscriptionId": sub.ID, "eventTypes": sub.EventTypes}
+	return s.enqueue(models.WebhookEventPing, data, []int{sub.ID})
+}
+
+// GetDeliveries ดึง log การส่งของ subscription ล่าสุดก่อน
+func (s *WebhookService) GetDeliveries(subscriptionID int, status models.WebhookDeliveryStatus, limit int) (*models.WebhookDeliveryListResponse, error) {
+	if _, err := s.subscription(subscriptionID); err != nil {
+		return nil, err
+	}
+	switch status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliverySucceeded, models.WebhookDeliveryFailed:
+	default:
+		return nil, fmt.Errorf("unknown delivery status %q", status)
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	deliveries, err := s.repo.ListDeliveries(subscriptionID, status, limit)
+	if err != nil {
+		return nil, err
+	}
+	if deliveries == nil {
+		deliveries = []models.WebhookDelivery{}
+	}
+	return &models.WebhookDeliveryListResponse{Data: deliveries}, nil
+}
+
+// Redeliver ส่ง event ของ delivery เดิมอีกครั้งเป็น delivery ใหม่ (ใช้ได้ทุกสถานะ เช่น หลัง receiver แก้ bug แล้ว)
+func (s *WebhookService) Redeliver(subscriptionID, deliveryID int) (*models.WebhookDelivery, error) {
+	if _, err := s.subscription(subscriptionID); err != nil {
+		return nil, err
+	}
+	original, err := s.repo.GetDelivery(deliveryID)
+	if err != nil {
+		return nil, err
+	}
+	if original == nil || original.SubscriptionID != subscriptionID {
+		return nil, ErrWebhookDeliveryNotFound
+	}
+
+	delivery, err := s.repo.Redeliver(*original, s.clock.Now())
+	if err != nil {
+		return nil, err
+	}
+	s.notify()
+	return delivery, nil
+}
+
+// DeliverDue ส่ง delivery ที่ถึงเวลา คืนจำนวนที่ส่งสำเร็จ
+func (s *WebhookService) DeliverDue(now time.Time) (int, error) {
+	due, err := s.repo.ListDueDeliveries(now, 50)
+	if err != nil {
+		return 0, err
+	}
+
+	subs := make(map[int]*models.WebhookSubscription)
+	succeeded := 0
+	for i := range due {
+		delivery := &due[i]
+		sub, ok := subs[delivery.SubscriptionID]
+		if !ok {
+			if sub, err = s.repo.GetSubscription(delivery.SubscriptionID); err != nil {
+				return succeeded, err
+			}
+			subs[delivery.SubscriptionID] = sub
+		}
+
+		if err := s.attempt(delivery, sub); err != nil {
+			return succeeded, err
+		}
+		if delivery.Status == models.WebhookDeliverySucceeded {
+			succeeded++
+		}
+	}
+	return succeeded, nil
+}
+
+// attempt ส่ง delivery หนึ่งครั้งและบันทึกผล: 2xx คือสำเร็จ นอกนั้น retry ตาม backoff จนครบ WebhookMaxAttempts
+func (s *WebhookService) attempt(delivery *models.WebhookDelivery, sub *models.WebhookSubscription) error {
+	delivery.Attempts++
+	delivery.LastStatusCode, delivery.LastError = nil, nil
+
+	var sendErr error
+	if sub == nil || !sub.IsActive {
+		// subscription ถูกปิดระหว่างรอส่ง ไม่ต้อง retry
+		sendErr = errors.New("subscription is inactive")
+		delivery.Attempts = WebhookMaxAttempts
+	} else {
+		var statusCode int
+		statusCode, sendErr = s.send(delivery, sub)
+		if statusCode != 0 {
+			delivery.LastStatusCode = &statusCode
+		}
+	}
+
+	now := s.clock.Now()
+	switch {
+	case sendErr == nil:
+		delivery.Status = models.WebhookDeliverySucceeded
+		delivery.NextAttemptAt = nil
+		delivery.DeliveredAt = &now
+	case delivery.Attempts >= WebhookMaxAttempts:
+		delivery.Status = models.WebhookDeliveryFailed
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(webhookRetryDelay(delivery.Attempts))
+		delivery.NextAttemptAt = &next
+	}
+	if sendErr != nil {
+		msg := sendErr.Error()
+		if len(msg) > 512 {
+			msg = msg[:512]
+		}
+		delivery.LastError = &msg
+	}
+
+	return s.repo.RecordAttempt(delivery)
+}
+
+func (s *WebhookService) send(delivery *models.WebhookDelivery, sub *models.WebhookSubscription) (int, error) {
+	timestamp := s.clock.Now().Unix()
+	req, err := http.NewRequest(http.MethodPost, sub.URL, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "KBTG-Webhooks/1.0")
+	req.Header.Set(WebhookHeaderEvent, delivery.EventType)
+	req.Header.Set(WebhookHeaderEventID, delivery.EventID)
+	req.Header.Set(WebhookHeaderDelivery, strconv.Itoa(delivery.ID))
+	req.Header.Set(WebhookHeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookHeaderSignature, SignWebhookPayload(sub.Secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with HTTP %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// StartDispatcher ส่ง delivery ที่ถึงเวลาเป็นระยะ และทันทีเมื่อมี event ใหม่ จนกว่า stop จะถูกปิด
+func (s *WebhookService) StartDispatcher(interval time.Duration, stop <-chan struct{}) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+			case <-s.wake:
+			}
+			if _, err := s.DeliverDue(s.clock.Now()); err != nil {
+				log.Printf("Failed to deliver webhooks: %v", err)
+			}
+		}
+	}()
+}
+
+func applyWebhookURL(sub *models.WebhookSubscription, rawURL string) error {
+	u, err := url.Parse(strings.TrimSpace(rawURL))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if len(u.String()) > 2048 {
+		return errors.New("url cannot exceed 2048 characters")
+	}
+	sub.URL = u.String()
+	return nil
+}
+
+func applyWebhookEventTypes(sub *models.WebhookSubscription, eventTypes []string) error {
+	if len(eventTypes) == 0 {
+		return errors.New("at least one event type is required")
+	}
+	seen := make(map[string]bool, len(eventTypes))
+	var types []string
+	for _, t := range eventTypes {
+		t = strings.TrimSpace(t)
+		if !isWebhookEventType(t) {
+			return fmt.Errorf("unknown event type %q", t)
+		}
+		if !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	sub.EventTypes = types
+	return nil
+}
+
+func isWebhookEventType(eventType string) bool {
+	if eventType == models.WebhookEventAll {
+		return true
+	}
+	for _, t := range models.WebhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func applyWebhookDescription(sub *models.WebhookSubscription, description *string) error {
+	if description == nil {
+		return nil
+	}
+	if len(*description) > 256 {
+		return errors.New("description cannot exceed 256 characters")
+	}
+	sub.Description = description
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 24)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,294 @@
+package services
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+	"kbtg-backend/internal/testutil"
+)
+
+const testWebhookSecret = "test-webhook-secret-0123456789"
+
+// webhookReceiver เป็น receiver จำลองที่ตรวจ signature แบบเดียวกับ receiver จริง
+// และตอบตาม statuses ทีละครั้ง (หมดแล้วตอบ 200)
+type webhookReceiver struct {
+	t        *testing.T
+	mu       sync.Mutex
+	statuses []int
+	received []*http.Request
+	bodies   [][]byte
+}
+
+func (rcv *webhookReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		rcv.t.Errorf("read webhook body: %v", err)
+	}
+	timestamp, err := strconv.ParseInt(r.Header.Get(WebhookHeaderTimestamp), 10, 64)
+	if err != nil {
+		rcv.t.Errorf("invalid %s header: %v", WebhookHeaderTimestamp, err)
+	}
+	if got, want := r.Header.Get(WebhookHeaderSignature), SignWebhookPayload(testWebhookSecret, timestamp, body); got != want {
+		rcv.t.Errorf("signature %q, want %q", got, want)
+	}
+	if SignWebhookPayload("another-secret-0123456789", timestamp, body) == r.Header.Get(WebhookHeaderSignature) {
+		rcv.t.Error("signature does not depend on the secret")
+	}
+
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	rcv.received = append(rcv.received, r)
+	rcv.bodies = append(rcv.bodies, body)
+	status := http.StatusOK
+	if len(rcv.statuses) > 0 {
+		status, rcv.statuses = rcv.statuses[0], rcv.statuses[1:]
+	}
+	w.WriteHeader(status)
+}
+
+func (rcv *webhookReceiver) count() int {
+	rcv.mu.Lock()
+	defer rcv.mu.Unlock()
+	return len(rcv.received)
+}
+
+func newTestWebhookService(t *testing.T, statuses ...int) (*WebhookService, *FixedClock, *webhookReceiver, *models.WebhookSubscription) {
+	t.Helper()
+	db := testutil.NewDB(t)
+	receiver := &webhookReceiver{t: t, statuses: statuses}
+	server := httptest.NewServer(receiver)
+	t.Cleanup(server.Close)
+
+	service := NewWebhookService(repositories.NewWebhookRepository(db.DB))
+	service.SetHTTPClient(server.Client())
+	clock := NewFixedClock(time.Now())
+	service.SetClock(clock)
+
+	secret := testWebhookSecret
+	sub, err := service.CreateSubscription(models.WebhookSubscriptionCreateRequest{
+		URL:        server.URL + "/hooks",
+		EventTypes: []string{models.WebhookEventTransferCompleted},
+		Secret:     &secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return service, clock, receiver, sub
+}
+
+// onlyDelivery คืน delivery เดียวของ subscription
+func onlyDelivery(t *testing.T, service *WebhookService, subscriptionID int) models.WebhookDelivery {
+	t.Helper()
+	list, err := service.GetDeliveries(subscriptionID, "", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.Data) != 1 {
+		t.Fatalf("got %d deliveries, want 1", len(list.Data))
+	}
+	return list.Data[0]
+}
+
+func TestWebhookDeliverySignedAndRetriedWithBackoff(t *testing.T) {
+	service, clock, receiver, sub := newTestWebhookService(t, http.StatusInternalServerError, http.StatusBadGateway)
+
+	service.Publish(models.WebhookEventTransferCompleted, map[string]int{"transferId": 7})
+	service.Publish(models.WebhookEventUserUpdated, map[string]int{"userId": 1}) // ไม่ได้ subscribe
+
+	// ครั้งแรก: 500 → pending รอ 30 วินาที
+	if n, err := service.DeliverDue(clock.Now()); err != nil || n != 0 {
+		t.Fatalf("first attempt: %d succeeded, err %v", n, err)
+	}
+	delivery := onlyDelivery(t, service, sub.ID)
+	if delivery.Status != models.WebhookDeliveryPending || delivery.Attempts != 1 {
+		t.Fatalf("after 500: status %s attempts %d", delivery.Status, delivery.Attempts)
+	}
+	if delivery.LastStatusCode == nil || *delivery.LastStatusCode != http.StatusInternalServerError || delivery.LastError == nil {
+		t.Fatalf("after 500: lastStatusCode %v lastError %v", delivery.LastStatusCode, delivery.LastError)
+	}
+	if delivery.NextAttemptAt == nil || !delivery.NextAttemptAt.Equal(clock.Now().Add(30*time.Second)) {
+		t.Fatalf("next attempt at %v, want %v", delivery.NextAttemptAt, clock.Now().Add(30*time.Second))
+	}
+
+	// ยังไม่ถึงเวลา retry ต้องไม่ส่ง
+	clock.Advance(29 * time.Second)
+	if _, err := service.DeliverDue(clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := receiver.count(); got != 1 {
+		t.Fatalf("receiver got %d requests before the retry was due", got)
+	}
+
+	// ครั้งที่สอง: 502 → backoff เพิ่มเป็น 1 นาที
+	clock.Advance(time.Second)
+	if _, err := service.DeliverDue(clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+	delivery = onlyDelivery(t, service, sub.ID)
+	if delivery.Attempts != 2 || *delivery.LastStatusCode != http.StatusBadGateway {
+		t.Fatalf("after 502: attempts %d lastStatusCode %d", delivery.Attempts, *delivery.LastStatusCode)
+	}
+	if !delivery.NextAttemptAt.Equal(clock.Now().Add(time.Minute)) {
+		t.Fatalf("next attempt at %v, want %v", delivery.NextAttemptAt, clock.Now().Add(time.Minute))
+	}
+
+	// ครั้งที่สาม: 200 → succeeded
+	clock.Advance(time.Minute)
+	if n, err := service.DeliverDue(clock.Now()); err != nil || n != 1 {
+		t.Fatalf("third attempt: %d succeeded, err %v", n, err)
+	}
+	delivery = onlyDelivery(t, service, sub.ID)
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 3 {
+		t.Fatalf("after 200: status %s attempts %d", delivery.Status, delivery.Attempts)
+	}
+	if delivery.NextAttemptAt != nil || delivery.LastError != nil || delivery.DeliveredAt == nil {
+		t.Fatalf("after 200: nextAttemptAt %v lastError %v deliveredAt %v",
+			delivery.NextAttemptAt, delivery.LastError, delivery.DeliveredAt)
+	}
+
+	// ทุกครั้งส่ง event เดิม (id เดิม) ไปที่ delivery เดิม
+	if got := receiver.count(); got != 3 {
+		t.Fatalf("receiver got %d requests, want 3", got)
+	}
+	for i, r := range receiver.received {
+		if r.URL.Path != "/hooks" || r.Header.Get(WebhookHeaderEvent) != models.WebhookEventTransferCompleted ||
+			r.Header.Get(WebhookHeaderEventID) != delivery.EventID ||
+			r.Header.Get(WebhookHeaderDelivery) != strconv.Itoa(delivery.ID) {
+			t.Fatalf("request %d: path %s headers %v", i, r.URL.Path, r.Header)
+		}
+		var event models.WebhookEvent
+		if err := json.Unmarshal(receiver.bodies[i], &event); err != nil || event.ID != delivery.EventID {
+			t.Fatalf("request %d: body %s (err %v)", i, receiver.bodies[i], err)
+		}
+	}
+
+	// ไม่มีอะไรค้างส่ง
+	clock.Advance(24 * time.Hour)
+	if _, err := service.DeliverDue(clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := receiver.count(); got != 3 {
+		t.Fatalf("succeeded delivery was sent again (%d requests)", got)
+	}
+}
+
+func TestWebhookDeliveryFailsAfterMaxAttempts(t *testing.T) {
+	statuses := make([]int, WebhookMaxAttempts)
+	for i := range statuses {
+		statuses[i] = http.StatusServiceUnavailable
+	}
+	service, clock, receiver, sub := newTestWebhookService(t, statuses...)
+
+	service.Publish(models.WebhookEventTransferCompleted, map[string]int{"transferId": 7})
+	for attempt := 1; attempt <= WebhookMaxAttempts; attempt++ {
+		if _, err := service.DeliverDue(clock.Now()); err != nil {
+			t.Fatal(err)
+		}
+		delivery := onlyDelivery(t, service, sub.ID)
+		if delivery.Attempts != attempt {
+			t.Fatalf("attempt %d recorded as %d", attempt, delivery.Attempts)
+		}
+		if attempt < WebhookMaxAttempts {
+			if delivery.Status != models.WebhookDeliveryPending {
+				t.Fatalf("attempt %d: status %s, want pending", attempt, delivery.Status)
+			}
+			clock.Advance(webhookRetryDelay(attempt))
+		}
+	}
+
+	delivery := onlyDelivery(t, service, sub.ID)
+	if delivery.Status != models.WebhookDeliveryFailed || delivery.NextAttemptAt != nil {
+		t.Fatalf("after %d attempts: status %s nextAttemptAt %v", WebhookMaxAttempts, delivery.Status, delivery.NextAttemptAt)
+	}
+	clock.Advance(webhookRetryMax)
+	if _, err := service.DeliverDue(clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if got := receiver.count(); got != WebhookMaxAttempts {
+		t.Fatalf("receiver got %d requests, want %d", got, WebhookMaxAttempts)
+	}
+
+	// redeliver สร้าง delivery ใหม่ของ event เดิมและส่งได้ทันที
+	redelivery, err := service.Redeliver(sub.ID, delivery.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := service.DeliverDue(clock.Now()); err != nil || n != 1 {
+		t.Fatalf("redelivery: %d succeeded, err %v", n, err)
+	}
+	if last := receiver.received[len(receiver.received)-1]; last.Header.Get(WebhookHeaderEventID) != delivery.EventID ||
+		last.Header.Get(WebhookHeaderDelivery) != strconv.Itoa(redelivery.ID) {
+		t.Fatalf("redelivery sent headers %v", last.Header)
+	}
+}
+
+func TestWebhookRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{7, 32 * time.Minute},
+		{20, webhookRetryMax},
+	}
+	for _, tt := range tests {
+		if got := webhookRetryDelay(tt.attempts); got != tt.want {
+			t.Errorf("webhookRetryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestWebhookInactiveSubscriptionIsNotSent(t *testing.T) {
+	service, clock, receiver, sub := newTestWebhookService(t)
+
+	service.Publish(models.WebhookEventTransferCompleted, map[string]int{"transferId": 7})
+	inactive := false
+	if _, err := service.UpdateSubscription(sub.ID, models.WebhookSubscriptionUpdateRequest{IsActive: &inactive}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := service.DeliverDue(clock.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	delivery := onlyDelivery(t, service, sub.ID)
+	if delivery.Status != models.WebhookDeliveryFailed || delivery.LastError == nil {
+		t.Fatalf("delivery to inactive subscription: status %s lastError %v", delivery.Status, delivery.LastError)
+	}
+	if got := receiver.count(); got != 0 {
+		t.Fatalf("receiver got %d requests from an inactive subscription", got)
+	}
+}
+
+func TestWebhookDispatcherWakesOnPublish(t *testing.T) {
+	service, _, receiver, sub := newTestWebhookService(t)
+
+	stop := make(chan struct{})
+	defer close(stop)
+	// รอบของ ticker ยาวมาก delivery จึงถูกส่งได้จากการปลุกตอน Publish เท่านั้น
+	service.StartDispatcher(time.Hour, stop)
+	service.Publish(models.WebhookEventTransferCompleted, map[string]int{"transferId": 7})
+
+	deadline := time.Now().Add(5 * time.Second)
+	for receiver.count() == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("dispatcher did not send the published event")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for onlyDelivery(t, service, sub.ID).Status != models.WebhookDeliverySucceeded {
+		if time.Now().After(deadline) {
+			t.Fatal("delivery was not recorded as succeeded")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,97 @@
+// Package testutil รวม helper ที่ test ของ services และ handlers ใช้ร่วมกัน
+// (เปิดฐานข้อมูลชั่วคราว สร้าง user และอ่านแต้ม) import ได้เฉพาะจาก test
+// ไม่ import services เพื่อให้ test ภายใน package services ใช้ได้โดยไม่เกิด import cycle
+package testutil
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"kbtg-backend/internal/database"
+	"kbtg-backend/internal/models"
+	"kbtg-backend/internal/repositories"
+)
+
+// NewDB เปิดฐานข้อมูลชั่วคราวที่ migrate แล้วและมีบัญชี house ด้วย DSN แบบเดียวกับ main
+func NewDB(t *testing.T) *database.DB {
+	t.Helper()
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL&_synchronous=NORMAL"
+	db, err := database.NewConnection(dsn)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrate: %v", err)
+	}
+	if err := db.EnsureHouseAccount(); err != nil {
+		t.Fatalf("house account: %v", err)
+	}
+	return db
+}
+
+// NewUser สร้าง user ระดับ Gold (ไม่มีค่าธรรมเนียม) ที่มีแต้มเริ่มต้นตาม points
+func NewUser(t *testing.T, db *database.DB, points models.Points) *models.User {
+	t.Helper()
+	return NewUserAtLevel(t, db, "Gold", points)
+}
+
+// NewUserAtLevel สร้าง user ระดับ level ที่มีแต้มเริ่มต้นตาม points
+func NewUserAtLevel(t *testing.T, db *database.DB, level string, points models.Points) *models.User {
+	t.Helper()
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM users").Scan(&n); err != nil {
+		t.Fatalf("count users: %v", err)
+	}
+	user, err := repositories.NewUserRepository(db.DB).Create(models.CreateUserRequest{
+		FirstName:       "Tst",
+		LastName:        "Usr",
+		Phone:           fmt.Sprintf("08%08d", n),
+		Email:           fmt.Sprintf("user%d@example.com", n),
+		MembershipLevel: level,
+		Points:          points,
+	})
+	if err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+// DisableRules ปิด rule ตามชื่อ เช่น repeat_recipient สำหรับ test ที่โอนให้คนเดิมซ้ำ
+// ต้องเรียกก่อนโหลด RuleService
+func DisableRules(t *testing.T, db *database.DB, names ...string) {
+	t.Helper()
+	ruleRepo := repositories.NewRuleRepository(db.DB)
+	for _, name := range names {
+		rule, err := ruleRepo.GetByName(name)
+		if err != nil || rule == nil {
+			t.Fatalf("get rule %s: %v", name, err)
+		}
+		rule.Enabled = false
+		if err := ruleRepo.Update(rule); err != nil {
+			t.Fatalf("disable rule %s: %v", name, err)
+		}
+	}
+}
+
+// UserPoints อ่านแต้มปัจจุบันของ user จากตาราง users
+func UserPoints(t *testing.T, db *database.DB, userID int) models.Points {
+	t.Helper()
+	var points models.Points
+	if err := db.QueryRow("SELECT points FROM users WHERE id = ?", userID).Scan(&points); err != nil {
+		t.Fatalf("read points of user %d: %v", userID, err)
+	}
+	return points
+}
+
+// TotalPoints คือแต้มรวมของทุก user รวมบัญชีระบบที่รับค่าธรรมเนียม
+func TotalPoints(t *testing.T, db *database.DB) models.Points {
+	t.Helper()
+	var total models.Points
+	if err := db.QueryRow("SELECT COALESCE(SUM(points), 0) FROM users").Scan(&total); err != nil {
+		t.Fatal(err)
+	}
+	return total
+}
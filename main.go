@@ -1,11 +1,15 @@
 package main
 
 import (
+	"encoding/json"
+	"flag"
 	"log"
+	"os"
 	"time"
 
 	"kbtg-backend/internal/database"
 	"kbtg-backend/internal/handlers"
+	"kbtg-backend/internal/models"
 	"kbtg-backend/internal/repositories"
 	"kbtg-backend/internal/services"
 
@@ -15,8 +19,16 @@ import (
 )
 
 func main() {
+	// -reconcile ตรวจ users.points เทียบกับ point_ledger หนึ่งรอบแล้วจบโดยไม่เปิด server (เพิ่ม -repair เพื่อเขียน adjust entry)
+	reconcile := flag.Bool("reconcile", false, "run ledger reconciliation once, print the report and exit")
+	repair := flag.Bool("repair", false, "with -reconcile, write adjust entries for balance discrepancies")
+	// -verify-ledger ไล่ตรวจ hash chain ของ point_ledger และ checkpoint แล้วจบโดยไม่เปิด server
+	verifyLedger := flag.Bool("verify-ledger", false, "verify the ledger hash chain and checkpoints, print the result and exit")
+	flag.Parse()
+
 	// Initialize database
-	db, err := database.NewConnection("./kbtg.db")
+	// busy_timeout + immediate transaction กัน "database is locked" ตอนมี request เขียนพร้อมกัน
+	db, err := database.NewConnection("./kbtg.db?_busy_timeout=5000&_txlock=immediate&_journal_mode=WAL&_synchronous=NORMAL")
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
@@ -30,17 +42,150 @@ func main() {
 	// Seed database with sample data
 	seedDatabase(db)
 
+	// บัญชี house รับค่าธรรมเนียมการโอน
+	if err := db.EnsureHouseAccount(); err != nil {
+		log.Fatal("Failed to create house account:", err)
+	}
+
+	// seed เขียน users หลัง migrate จึงต้องบันทึกแต้มตั้งต้นลง ledger อีกรอบ
+	if err := db.EnsureOpeningBalances(); err != nil {
+		log.Fatal("Failed to write opening balances:", err)
+	}
+
 	// Initialize repositories
 	userRepo := repositories.NewUserRepository(db.DB)
 	transferRepo := repositories.NewTransferRepository(db.DB)
+	mandateRepo := repositories.NewMandateRepository(db.DB)
+	pointRequestRepo := repositories.NewPointRequestRepository(db.DB)
+	limitRepo := repositories.NewLimitRepository(db.DB)
+	ruleRepo := repositories.NewRuleRepository(db.DB)
+	feeRepo := repositories.NewFeeRepository(db.DB)
+	webhookRepo := repositories.NewWebhookRepository(db.DB)
+	fraudRepo := repositories.NewFraudRepository(db.DB)
+	disputeRepo := repositories.NewDisputeRepository(db.DB)
+	holdRepo := repositories.NewHoldRepository(db.DB)
+	ledgerRepo := repositories.NewLedgerRepository(db.DB)
+	reconciliationRepo := repositories.NewReconciliationRepository(db.DB)
+	ledgerAuditRepo := repositories.NewLedgerAuditRepository(db.DB)
+	balanceSnapshotRepo := repositories.NewBalanceSnapshotRepository(db.DB)
 
 	// Initialize services
+	webhookService := services.NewWebhookService(webhookRepo)
 	userService := services.NewUserService(userRepo)
-	transferService := services.NewTransferService(transferRepo)
+	userService.SetWebhooks(webhookService)
+	limitService := services.NewLimitService(limitRepo)
+	feeService := services.NewFeeService(feeRepo)
+	ruleService := services.NewRuleService(ruleRepo, transferRepo)
+	if err := ruleService.Reload(); err != nil {
+		log.Fatal("Failed to load transfer rules:", err)
+	}
+	transferService := services.NewTransferService(transferRepo, userService, limitService, ruleService, feeService)
+	transferService.SetWebhooks(webhookService)
+	fraudService := services.NewFraudService(fraudRepo)
+	transferService.SetFraud(fraudService)
+	if policy := os.Getenv("REVERSAL_NEGATIVE_BALANCE"); policy != "" {
+		if err := transferService.SetReversalPolicy(services.ReversalPolicy(policy)); err != nil {
+			log.Fatal("Invalid REVERSAL_NEGATIVE_BALANCE:", err)
+		}
+	}
+
+	mandateService := services.NewMandateService(mandateRepo, transferService)
+	pointRequestService := services.NewPointRequestService(pointRequestRepo, transferService)
+	disputeService := services.NewDisputeService(disputeRepo, transferService)
+	disputeService.SetWebhooks(webhookService)
+	transferService.SetDisputes(disputeService)
+	holdService := services.NewHoldService(holdRepo)
+	holdService.SetWebhooks(webhookService)
+	ledgerService := services.NewLedgerService(ledgerRepo, userService)
+	balanceHistoryService := services.NewBalanceHistoryService(balanceSnapshotRepo, userService)
+	reconciliationService := services.NewReconciliationService(reconciliationRepo)
+	reconciliationService.SetWebhooks(webhookService)
+	ledgerAuditService := services.NewLedgerAuditService(ledgerAuditRepo)
+	ledgerAuditService.SetWebhooks(webhookService)
+	// LEDGER_SIGNING_KEY คือ Ed25519 seed 32 byte แบบ base64 ไม่ตั้งก็ได้แต่จะไม่มี checkpoint
+	if key := os.Getenv("LEDGER_SIGNING_KEY"); key != "" {
+		if err := ledgerAuditService.SetSigningKey(key); err != nil {
+			log.Fatal("Invalid LEDGER_SIGNING_KEY:", err)
+		}
+	}
+
+	if *reconcile {
+		code := runReconciliation(reconciliationService, *repair)
+		db.DB.Close()
+		os.Exit(code)
+	}
+	if *verifyLedger {
+		code := runLedgerVerification(ledgerAuditService)
+		db.DB.Close()
+		os.Exit(code)
+	}
+
+	// Background workers: ปล่อย hold ของ transfer แบบ two-phase ที่หมดเวลา, โอนรายการล่วงหน้าที่ถึงเวลา,
+	// ปิดคำขอแต้มและ hold ของร้านค้าที่หมดอายุ, reload transfer rule ที่แก้ใน database และส่ง webhook
+	stopWorkers := make(chan struct{})
+	defer close(stopWorkers)
+	transferService.StartExpirySweeper(time.Minute, stopWorkers)
+	transferService.StartScheduledExecutor(30*time.Second, stopWorkers)
+	mandateService.StartRunner(time.Minute, stopWorkers)
+	pointRequestService.StartExpirySweeper(time.Minute, stopWorkers)
+	holdService.StartExpirySweeper(time.Minute, stopWorkers)
+	ruleService.StartReloader(30*time.Second, stopWorkers)
+	webhookService.StartDispatcher(5*time.Second, stopWorkers)
+	// ตรวจยอดเทียบกับ ledger ทุก RECONCILIATION_INTERVAL (ค่าเริ่มต้น 1h, 0 คือปิด)
+	reconciliationInterval := time.Hour
+	if v := os.Getenv("RECONCILIATION_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatal("Invalid RECONCILIATION_INTERVAL:", err)
+		}
+		reconciliationInterval = interval
+	}
+	if reconciliationInterval > 0 {
+		reconciliationService.StartScheduler(reconciliationInterval, stopWorkers)
+	}
+	// sign checkpoint ของ ledger ทุก LEDGER_CHECKPOINT_INTERVAL (ค่าเริ่มต้น 1h, 0 คือปิด) เมื่อตั้ง LEDGER_SIGNING_KEY
+	checkpointInterval := time.Hour
+	if v := os.Getenv("LEDGER_CHECKPOINT_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatal("Invalid LEDGER_CHECKPOINT_INTERVAL:", err)
+		}
+		checkpointInterval = interval
+	}
+	if os.Getenv("LEDGER_SIGNING_KEY") == "" {
+		log.Println("LEDGER_SIGNING_KEY is not set, ledger checkpoints are disabled")
+	} else if checkpointInterval > 0 {
+		ledgerAuditService.StartCheckpointer(checkpointInterval, stopWorkers)
+	}
+	// บันทึกยอดปิดของวันที่จบแล้วทุก BALANCE_SNAPSHOT_INTERVAL (ค่าเริ่มต้น 1h, 0 คือปิด)
+	snapshotInterval := time.Hour
+	if v := os.Getenv("BALANCE_SNAPSHOT_INTERVAL"); v != "" {
+		interval, err := time.ParseDuration(v)
+		if err != nil {
+			log.Fatal("Invalid BALANCE_SNAPSHOT_INTERVAL:", err)
+		}
+		snapshotInterval = interval
+	}
+	if snapshotInterval > 0 {
+		balanceHistoryService.StartSnapshotter(snapshotInterval, stopWorkers)
+	}
 
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(userService)
 	transferHandler := handlers.NewTransferHandler(transferService)
+	mandateHandler := handlers.NewMandateHandler(mandateService)
+	pointRequestHandler := handlers.NewPointRequestHandler(pointRequestService)
+	limitHandler := handlers.NewLimitHandler(limitService)
+	ruleHandler := handlers.NewRuleHandler(ruleService)
+	feeHandler := handlers.NewFeeHandler(feeService)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	fraudHandler := handlers.NewFraudHandler(fraudService, transferService)
+	disputeHandler := handlers.NewDisputeHandler(disputeService)
+	holdHandler := handlers.NewHoldHandler(holdService)
+	ledgerHandler := handlers.NewLedgerHandler(ledgerService)
+	reconciliationHandler := handlers.NewReconciliationHandler(reconciliationService)
+	ledgerAuditHandler := handlers.NewLedgerAuditHandler(ledgerAuditService)
+	balanceHistoryHandler := handlers.NewBalanceHistoryHandler(balanceHistoryService)
 
 	// Create a new Fiber instance
 	app := fiber.New(fiber.Config{
@@ -55,14 +200,63 @@ func main() {
 	app.Static("/", "./public")
 	app.Static("/swagger.yml", "./swagger.yml")
 
+	// ADMIN_API_KEY ต้องตั้งเสมอ ไม่ตั้งแล้ว endpoint ของ admin ถูกปิด
+	// ยกเว้นตั้ง ADMIN_AUTH_DISABLED=true ไว้ชัดเจน (ใช้ตอน develop เท่านั้น)
+	adminKey := os.Getenv("ADMIN_API_KEY")
+	adminAuthDisabled := os.Getenv("ADMIN_AUTH_DISABLED") == "true"
+	if adminKey == "" {
+		if adminAuthDisabled {
+			log.Println("WARNING: ADMIN_AUTH_DISABLED is set, admin endpoints are open without X-Admin-Key")
+		} else {
+			log.Println("ADMIN_API_KEY is not set, admin endpoints are disabled")
+		}
+	}
+
 	// Routes
-	setupRoutes(app, userHandler, transferHandler)
+	setupRoutes(app, routeHandlers{
+		user:           userHandler,
+		transfer:       transferHandler,
+		mandate:        mandateHandler,
+		pointRequest:   pointRequestHandler,
+		limit:          limitHandler,
+		rule:           ruleHandler,
+		fee:            feeHandler,
+		webhook:        webhookHandler,
+		fraud:          fraudHandler,
+		dispute:        disputeHandler,
+		hold:           holdHandler,
+		ledger:         ledgerHandler,
+		reconciliation: reconciliationHandler,
+		ledgerAudit:    ledgerAuditHandler,
+		balanceHistory: balanceHistoryHandler,
+		adminAuth:      handlers.AdminAuth(adminKey, adminAuthDisabled),
+	})
 
 	// Start server on port 3000
 	log.Fatal(app.Listen(":3000"))
 }
 
-func setupRoutes(app *fiber.App, userHandler *handlers.UserHandler, transferHandler *handlers.TransferHandler) {
+type routeHandlers struct {
+	user           *handlers.UserHandler
+	transfer       *handlers.TransferHandler
+	mandate        *handlers.MandateHandler
+	pointRequest   *handlers.PointRequestHandler
+	limit          *handlers.LimitHandler
+	rule           *handlers.RuleHandler
+	fee            *handlers.FeeHandler
+	webhook        *handlers.WebhookHandler
+	fraud          *handlers.FraudHandler
+	dispute        *handlers.DisputeHandler
+	hold           *handlers.HoldHandler
+	ledger         *handlers.LedgerHandler
+	reconciliation *handlers.ReconciliationHandler
+	ledgerAudit    *handlers.LedgerAuditHandler
+	balanceHistory *handlers.BalanceHistoryHandler
+	adminAuth      fiber.Handler
+}
+
+func setupRoutes(app *fiber.App, h routeHandlers) {
+
 	// API v1 group
 	api := app.Group("/api/v1")
 
@@ -73,10 +267,13 @@ func setupRoutes(app *fiber.App, userHandler *handlers.UserHandler, transferHand
 			"status":  "success",
 			"version": "1.0.0",
 			"endpoints": fiber.Map{
-				"swagger":   "/",
-				"health":    "/api/v1/health",
-				"users":     "/api/v1/users",
-				"transfers": "/api/v1/transfers",
+				"swagger":       "/",
+				"health":        "/api/v1/health",
+				"users":         "/api/v1/users",
+				"transfers":     "/api/v1/transfers",
+				"pointRequests": "/api/v1/point-requests",
+				"disputes":      "/api/v1/disputes",
+				"webhooks":      "/api/v1/webhooks",
 			},
 		})
 	})
@@ -91,17 +288,152 @@ func setupRoutes(app *fiber.App, userHandler *handlers.UserHandler, transferHand
 
 	// User CRUD endpoints
 	users := api.Group("/users")
-	users.Get("/", userHandler.GetUsers)         // GET /api/v1/users
-	users.Get("/:id", userHandler.GetUser)       // GET /api/v1/users/:id
-	users.Post("/", userHandler.CreateUser)      // POST /api/v1/users
-	users.Put("/:id", userHandler.UpdateUser)    // PUT /api/v1/users/:id
-	users.Delete("/:id", userHandler.DeleteUser) // DELETE /api/v1/users/:id
+	users.Get("/", h.user.GetUsers)                                             // GET /api/v1/users
+	users.Get("/resolve", h.user.ResolveRecipient)                              // GET /api/v1/users/resolve?memberId=|phone=|email=
+	users.Get("/:id", h.user.GetUser)                                           // GET /api/v1/users/:id
+	users.Get("/:id/balance", h.balanceHistory.GetBalanceAt, h.user.GetBalance) // GET /api/v1/users/:id/balance?at=
+	users.Get("/:id/balance/history", h.balanceHistory.GetHistory)              // GET /api/v1/users/:id/balance/history?interval=day&from=&to=
+	users.Get("/:id/limits", h.limit.GetUserLimits)                             // GET /api/v1/users/:id/limits
+	users.Get("/:id/ledger", h.ledger.GetLedger)                                // GET /api/v1/users/:id/ledger?eventType=&createdFrom=&createdTo=&cursor=
+	users.Post("/", h.user.CreateUser)                                          // POST /api/v1/users
+	users.Put("/:id", h.user.UpdateUser)                                        // PUT /api/v1/users/:id
+	users.Delete("/:id", h.user.DeleteUser)                                     // DELETE /api/v1/users/:id
+
+	// Scheduled transfer endpoints
+	users.Get("/:id/scheduled-transfers", h.transfer.GetScheduledTransfers)                  // GET /api/v1/users/:id/scheduled-transfers
+	users.Delete("/:id/scheduled-transfers/:transferId", h.transfer.CancelScheduledTransfer) // DELETE /api/v1/users/:id/scheduled-transfers/:transferId
+
+	// Recurring transfer mandate endpoints
+	users.Get("/:id/mandates", h.mandate.GetMandates)                 // GET /api/v1/users/:id/mandates
+	users.Post("/:id/mandates", h.mandate.CreateMandate)              // POST /api/v1/users/:id/mandates
+	users.Get("/:id/mandates/:mandateId", h.mandate.GetMandate)       // GET /api/v1/users/:id/mandates/:mandateId
+	users.Put("/:id/mandates/:mandateId", h.mandate.UpdateMandate)    // PUT /api/v1/users/:id/mandates/:mandateId
+	users.Delete("/:id/mandates/:mandateId", h.mandate.CancelMandate) // DELETE /api/v1/users/:id/mandates/:mandateId
+
+	// Merchant hold endpoints: authorize (POST) แล้ว capture หรือ void ภายหลัง
+	users.Get("/:id/holds", h.hold.GetHolds)                     // GET /api/v1/users/:id/holds?status=
+	users.Post("/:id/holds", h.hold.CreateHold)                  // POST /api/v1/users/:id/holds
+	users.Get("/:id/holds/:holdId", h.hold.GetHold)              // GET /api/v1/users/:id/holds/:holdId
+	users.Post("/:id/holds/:holdId/capture", h.hold.CaptureHold) // POST /api/v1/users/:id/holds/:holdId/capture
+	users.Post("/:id/holds/:holdId/void", h.hold.VoidHold)       // POST /api/v1/users/:id/holds/:holdId/void
+
+	// Point request endpoints
+	users.Get("/:id/point-requests", h.pointRequest.GetRequests) // GET /api/v1/users/:id/point-requests?role=&status=
+
+	pointRequests := api.Group("/point-requests")
+	pointRequests.Post("/", h.pointRequest.CreateRequest)                    // POST /api/v1/point-requests
+	pointRequests.Post("/:requestId/approve", h.pointRequest.ApproveRequest) // POST /api/v1/point-requests/:requestId/approve
+	pointRequests.Post("/:requestId/decline", h.pointRequest.DeclineRequest) // POST /api/v1/point-requests/:requestId/decline
+
+	// Dispute endpoints: ผู้โอนเปิด dispute ผ่าน POST /transfers/:id/disputes แล้วคู่กรณีตอบที่นี่
+	users.Get("/:id/disputes", h.dispute.GetUserDisputes) // GET /api/v1/users/:id/disputes?role=&status=
+
+	disputes := api.Group("/disputes")
+	disputes.Get("/:disputeId", h.dispute.GetDispute)                // GET /api/v1/disputes/:disputeId
+	disputes.Post("/:disputeId/accept", h.dispute.AcceptDispute)     // POST /api/v1/disputes/:disputeId/accept
+	disputes.Post("/:disputeId/contest", h.dispute.ContestDispute)   // POST /api/v1/disputes/:disputeId/contest
+	disputes.Post("/:disputeId/withdraw", h.dispute.WithdrawDispute) // POST /api/v1/disputes/:disputeId/withdraw
+
+	// Admin endpoints (ต้องส่ง X-Admin-Key ตาม ADMIN_API_KEY)
+	admin := api.Group("/admin", h.adminAuth)
+	admin.Get("/limits", h.limit.GetLimits)          // GET /api/v1/admin/limits
+	admin.Put("/limits/:level", h.limit.UpdateLimit) // PUT /api/v1/admin/limits/:level
+	admin.Get("/rules", h.rule.GetRules)             // GET /api/v1/admin/rules
+	admin.Post("/rules", h.rule.CreateRule)          // POST /api/v1/admin/rules
+	admin.Post("/rules/reload", h.rule.ReloadRules)  // POST /api/v1/admin/rules/reload
+	admin.Put("/rules/:name", h.rule.UpdateRule)     // PUT /api/v1/admin/rules/:name
+	admin.Delete("/rules/:name", h.rule.DeleteRule)  // DELETE /api/v1/admin/rules/:name
+	admin.Get("/fees", h.fee.GetFees)                // GET /api/v1/admin/fees
+	admin.Put("/fees/:level", h.fee.UpdateFee)       // PUT /api/v1/admin/fees/:level
+
+	// คิวตรวจ fraud: transfer ที่คะแนนถึงเกณฑ์ review ถูกพักไว้ (pending + hold) จนกว่าจะ approve หรือ reject
+	admin.Get("/fraud/settings", h.fraud.GetSettings)                     // GET /api/v1/admin/fraud/settings
+	admin.Put("/fraud/settings", h.fraud.UpdateSettings)                  // PUT /api/v1/admin/fraud/settings
+	admin.Get("/fraud/reviews", h.fraud.GetReviews)                       // GET /api/v1/admin/fraud/reviews?status=
+	admin.Get("/fraud/reviews/:reviewId", h.fraud.GetReview)              // GET /api/v1/admin/fraud/reviews/:reviewId
+	admin.Post("/fraud/reviews/:reviewId/approve", h.fraud.ApproveReview) // POST /api/v1/admin/fraud/reviews/:reviewId/approve
+	admin.Post("/fraud/reviews/:reviewId/reject", h.fraud.RejectReview)   // POST /api/v1/admin/fraud/reviews/:reviewId/reject
+
+	// dispute ที่คู่กรณีโต้แย้ง (contested) รอ admin ตัดสิน
+	admin.Get("/disputes", h.dispute.ListDisputes)                       // GET /api/v1/admin/disputes?status=
+	admin.Post("/disputes/:disputeId/resolve", h.dispute.ResolveDispute) // POST /api/v1/admin/disputes/:disputeId/resolve
+
+	// reverse transfer ที่สำเร็จแล้ว (ทีม support) ย้ายแต้มกลับโดยไม่ต้องมีความยินยอมของผู้รับ จึงต้องเป็น admin
+	admin.Post("/transfers/:id/reverse", h.transfer.ReverseTransfer) // POST /api/v1/admin/transfers/:id/reverse
+
+	// ตรวจ users.points เทียบกับ point_ledger (รันเองตามรอบด้วย ดู RECONCILIATION_INTERVAL)
+	admin.Get("/reconciliation/runs", h.reconciliation.ListReports)      // GET /api/v1/admin/reconciliation/runs?limit=
+	admin.Post("/reconciliation/runs", h.reconciliation.Run)             // POST /api/v1/admin/reconciliation/runs
+	admin.Get("/reconciliation/runs/:runId", h.reconciliation.GetReport) // GET /api/v1/admin/reconciliation/runs/:runId
+
+	// hash chain ของ ledger และ checkpoint ที่ sign แล้วสำหรับ auditor
+	admin.Get("/ledger/verify", h.ledgerAudit.Verify)                 // GET /api/v1/admin/ledger/verify
+	admin.Get("/ledger/checkpoints", h.ledgerAudit.ListCheckpoints)   // GET /api/v1/admin/ledger/checkpoints?limit=
+	admin.Post("/ledger/checkpoints", h.ledgerAudit.CreateCheckpoint) // POST /api/v1/admin/ledger/checkpoints
+
+	// Webhook endpoints (ใช้ X-Admin-Key เดียวกับ admin)
+	webhooks := api.Group("/webhooks", h.adminAuth)
+	webhooks.Get("/", h.webhook.GetSubscriptions)                               // GET /api/v1/webhooks
+	webhooks.Post("/", h.webhook.CreateSubscription)                            // POST /api/v1/webhooks
+	webhooks.Get("/:id", h.webhook.GetSubscription)                             // GET /api/v1/webhooks/:id
+	webhooks.Put("/:id", h.webhook.UpdateSubscription)                          // PUT /api/v1/webhooks/:id
+	webhooks.Delete("/:id", h.webhook.DeleteSubscription)                       // DELETE /api/v1/webhooks/:id
+	webhooks.Post("/:id/ping", h.webhook.Ping)                                  // POST /api/v1/webhooks/:id/ping
+	webhooks.Get("/:id/deliveries", h.webhook.GetDeliveries)                    // GET /api/v1/webhooks/:id/deliveries?status=
+	webhooks.Post("/:id/deliveries/:deliveryId/redeliver", h.webhook.Redeliver) // POST /api/v1/webhooks/:id/deliveries/:deliveryId/redeliver
 
 	// Transfer endpoints
 	transfers := api.Group("/transfers")
-	transfers.Post("/", transferHandler.CreateTransfer) // POST /api/v1/transfers
-	transfers.Get("/", transferHandler.GetTransfers)    // GET /api/v1/transfers?userId=X
-	transfers.Get("/:id", transferHandler.GetTransfer)  // GET /api/v1/transfers/:id
+	transfers.Post("/", h.transfer.CreateTransfer)             // POST /api/v1/transfers
+	transfers.Get("/", h.transfer.GetTransfers)                // GET /api/v1/transfers?userId=X
+	transfers.Post("/batch", h.transfer.BatchTransfer)         // POST /api/v1/transfers/batch
+	transfers.Post("/quote", h.transfer.QuoteTransfer)         // POST /api/v1/transfers/quote
+	transfers.Get("/:id", h.transfer.GetTransfer)              // GET /api/v1/transfers/:id
+	transfers.Post("/:id/confirm", h.transfer.ConfirmTransfer) // POST /api/v1/transfers/:id/confirm
+	transfers.Post("/:id/cancel", h.transfer.CancelTransfer)   // POST /api/v1/transfers/:id/cancel
+	transfers.Post("/:id/disputes", h.dispute.OpenDispute)     // POST /api/v1/transfers/:id/disputes
+}
+
+// runReconciliation รัน reconciliation หนึ่งรอบจาก command line และพิมพ์ report เป็น JSON
+// คืน exit code 1 ถ้ายังมีรายการที่ไม่ตรงกันเหลืออยู่ และ 2 ถ้ารันไม่สำเร็จ
+func runReconciliation(service *services.ReconciliationService, repair bool) int {
+	report, err := service.Run(models.ReconciliationTriggerCLI, repair)
+	if err != nil {
+		log.Printf("Reconciliation failed: %v", err)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		log.Printf("Failed to print reconciliation report: %v", err)
+		return 2
+	}
+	if report.DiscrepancyCount > report.RepairedCount {
+		return 1
+	}
+	return 0
+}
+
+// runLedgerVerification ไล่ตรวจ hash chain จาก command line และพิมพ์ผลเป็น JSON
+// exit code 0 คือ chain ถูกต้อง, 1 คือ chain ขาด, 2 คือตรวจไม่สำเร็จ
+func runLedgerVerification(service *services.LedgerAuditService) int {
+	verification, err := service.Verify()
+	if err != nil {
+		log.Printf("Ledger verification failed: %v", err)
+		return 2
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(verification); err != nil {
+		log.Printf("Failed to print ledger verification: %v", err)
+		return 2
+	}
+	if !verification.Valid {
+		return 1
+	}
+	return 0
 }
 
 func seedDatabase(db *database.DB) {
@@ -135,7 +467,7 @@ func seedDatabase(db *database.DB) {
 		"somchai@example.com",
 		membershipDate,
 		"Gold",
-		15420,
+		15420*models.PointsScale,
 		now,
 		now,
 	)
@@ -153,7 +485,7 @@ func seedDatabase(db *database.DB) {
 			"phone":            "082-345-6789",
 			"email":            "somying@example.com",
 			"membership_level": "Silver",
-			"points":           8750,
+			"points":           8750 * models.PointsScale,
 		},
 		{
 			"member_id":        "LBK001236",
@@ -162,7 +494,7 @@ func seedDatabase(db *database.DB) {
 			"phone":            "083-456-7890",
 			"email":            "wichai@example.com",
 			"membership_level": "Bronze",
-			"points":           3200,
+			"points":           3200 * models.PointsScale,
 		},
 	}
 